@@ -0,0 +1,192 @@
+package godns
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// ChallengeStatus 挑战记录在某个服务器上的状态
+type ChallengeStatus string
+
+const (
+	ChallengePresent ChallengeStatus = "present" // 存在期望的值
+	ChallengeAbsent  ChallengeStatus = "absent"  // 不存在TXT记录
+	ChallengeStale   ChallengeStatus = "stale"   // 存在TXT记录但不包含期望的值
+	ChallengeError   ChallengeStatus = "error"   // 查询失败
+)
+
+// ChallengeObservation 单个服务器上观察到的挑战记录
+type ChallengeObservation struct {
+	Server        string
+	Authoritative bool // 是否为权威服务器
+	Status        ChallengeStatus
+	Values        []string // 观察到的TXT值
+	Error         string
+}
+
+// ChallengeReport ACME DNS-01 挑战记录的可见性报告
+type ChallengeReport struct {
+	Domain       string
+	Owner        string   // 挑战记录名称，即 _acme-challenge.<domain>
+	Target       string   // 跟随CNAME委派后实际存放TXT记录的名称
+	CNAMEChain   []string // CNAME 委派链
+	Expected     string
+	Observations []ChallengeObservation
+}
+
+// Ready 所有权威服务器上是否都已存在期望的值
+func (r *ChallengeReport) Ready() bool {
+	authoritative := 0
+	for _, o := range r.Observations {
+		if !o.Authoritative {
+			continue
+		}
+		authoritative++
+		if o.Status != ChallengePresent {
+			return false
+		}
+	}
+	return authoritative > 0
+}
+
+// VerifyACMEChallenge 检查 ACME DNS-01 挑战记录是否可见：计算挑战记录名称并跟随CNAME委派
+// （常见于 acme-dns），直接查询目标区域的权威服务器（传播情况以权威服务器为准），
+// 同时查询配置的递归服务器，报告每个服务器上期望值存在、缺失或过期
+// keyAuthDigest 为密钥授权的 base64url 编码 SHA-256 摘要
+func (c *Client) VerifyACMEChallenge(ctx context.Context, domain, keyAuthDigest string) (*ChallengeReport, error) {
+	// 挑战记录名称以下划线开头，CNAME 目标可以是任意名称
+	ctx = c.relaxNamePolicy(ctx, Permissive)
+	domain = strings.TrimPrefix(strings.TrimSuffix(domain, "."), "*.")
+	owner := "_acme-challenge." + domain
+	report := &ChallengeReport{
+		Domain:   domain,
+		Owner:    owner,
+		Target:   owner,
+		Expected: keyAuthDigest,
+	}
+
+	target, chain, err := c.followCNAME(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	report.Target = target
+	report.CNAMEChain = chain
+
+	zone, err := c.FindZone(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	auth, err := c.authoritativeServers(ctx, zone)
+	if err != nil {
+		return nil, err
+	}
+
+	observations := make([]ChallengeObservation, len(auth)+len(c.config.Servers))
+	var wg sync.WaitGroup
+	for i, server := range auth {
+		wg.Add(1)
+		go func(i int, server authServer) {
+			defer wg.Done()
+			res, err := c.queryAuthoritative(ctx, target, dns.TypeTXT, server)
+			observations[i] = observeChallenge(server.Address, true, target, keyAuthDigest, res, err)
+		}(i, server)
+	}
+	noCache := ContextWithNoCache(ctx)
+	for i, server := range c.config.Servers {
+		wg.Add(1)
+		go func(i int, server string) {
+			defer wg.Done()
+			res, err := c.queryServer(noCache, target, dns.TypeTXT, c.serverInfo(server))
+			observations[i] = observeChallenge(server, false, target, keyAuthDigest, res, err)
+		}(len(auth)+i, server)
+	}
+	wg.Wait()
+
+	report.Observations = observations
+	return report, ctx.Err()
+}
+
+// WaitForChallenge 按 interval 轮询挑战记录，直到所有权威服务器上都存在期望的值或 ctx 结束
+// 返回最后一次完成的检查的报告，截止时间在检查中途到达时不会丢弃之前的报告
+func (c *Client) WaitForChallenge(ctx context.Context, domain, keyAuthDigest string, interval time.Duration) (*ChallengeReport, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var last *ChallengeReport
+	for {
+		report, err := c.VerifyACMEChallenge(ctx, domain, keyAuthDigest)
+		if err == nil && report.Ready() {
+			return report, nil
+		}
+		// 截止时间可能在检查中途到达，此时保留上一次完整的报告
+		if report != nil && (err == nil || last == nil) {
+			last = report
+		}
+
+		select {
+		case <-ctx.Done():
+			if last == nil {
+				return nil, fmt.Errorf("challenge for %s not visible: %w", domain, ctx.Err())
+			}
+			return last, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// followCNAME 跟随 name 的CNAME链，返回最终名称及经过的名称
+func (c *Client) followCNAME(ctx context.Context, name string) (string, []string, error) {
+	const maxChain = 8
+	var chain []string
+	seen := map[string]bool{CanonicalName(name): true}
+
+	for len(chain) < maxChain {
+		res, err := c.Query(ctx, name, dns.TypeCNAME)
+		if err = ignoreNXDomain(err); err != nil {
+			return "", nil, err
+		}
+		aliases := ownedBy(res, name, dns.TypeCNAME)
+		if len(aliases) == 0 {
+			return name, chain, nil
+		}
+		next := strings.TrimSuffix(aliases[0].Value(), ".")
+		if seen[CanonicalName(next)] {
+			return "", nil, fmt.Errorf("CNAME loop at %s", next)
+		}
+		seen[CanonicalName(next)] = true
+		chain = append(chain, next)
+		name = next
+	}
+	return "", nil, fmt.Errorf("CNAME chain for %s exceeds %d links", chain[0], maxChain)
+}
+
+// observeChallenge 根据查询结果判断挑战记录的状态
+func observeChallenge(server string, authoritative bool, target, expected string, res *QueryResult, err error) ChallengeObservation {
+	o := ChallengeObservation{Server: server, Authoritative: authoritative}
+	if err = ignoreNXDomain(err); err != nil {
+		o.Status = ChallengeError
+		o.Error = err.Error()
+		return o
+	}
+
+	o.Status = ChallengeAbsent
+	for _, record := range ownedBy(res, target, dns.TypeTXT) {
+		txt, ok := record.RR().(*dns.TXT)
+		if !ok {
+			continue
+		}
+		value := strings.Join(txt.Txt, "")
+		o.Values = append(o.Values, value)
+		if value == expected {
+			o.Status = ChallengePresent
+		} else if o.Status == ChallengeAbsent {
+			o.Status = ChallengeStale
+		}
+	}
+	return o
+}
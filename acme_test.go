@@ -0,0 +1,134 @@
+package godns_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+	"github.com/zan8in/godns/internal/testserver"
+)
+
+const (
+	challengeOwner  = "_acme-challenge.example.test"
+	challengeDigest = "LoqXcYV8q5ONbJQxbmR7SCTNo3tiAXDfowyjxAjEuX0"
+)
+
+// challengeTXT 返回名称 owner 上值为 value 的TXT应答
+func challengeTXT(owner, value string) testserver.Reply {
+	return testserver.Reply{Answer: []dns.RR{testserver.RR(owner + `. 60 IN TXT "` + value + `"`)}}
+}
+
+// TestVerifyACMEChallenge 直接查询各权威服务器和配置的递归服务器，分别报告期望值存在、过期或缺失；
+// 通配符域名的挑战记录名称去掉 "*."
+func TestVerifyACMEChallenge(t *testing.T) {
+	recursive, ns1, ns2, c := hygieneZone(t, "example.test")
+	recursive.Handle(challengeOwner, dns.TypeCNAME, testserver.Reply{})
+	recursive.Handle(challengeOwner, dns.TypeSOA, testserver.Reply{Authority: []dns.RR{testserver.RR("example.test. 3600 IN SOA ns1.example.test. hostmaster.example.test. 1 7200 900 1209600 300")}})
+	recursive.Handle(challengeOwner, dns.TypeTXT, testserver.Reply{})
+	ns1.Handle(challengeOwner, dns.TypeTXT, challengeTXT(challengeOwner, challengeDigest))
+	ns2.Handle(challengeOwner, dns.TypeTXT, challengeTXT(challengeOwner, "previous-digest"))
+
+	report, err := c.VerifyACMEChallenge(context.Background(), "*.example.test.", challengeDigest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Domain != "example.test" || report.Owner != challengeOwner || report.Target != challengeOwner || len(report.CNAMEChain) != 0 {
+		t.Errorf("report = %+v", report)
+	}
+	want := map[string]struct {
+		authoritative bool
+		status        godns.ChallengeStatus
+	}{
+		"192.0.2.1:53": {true, godns.ChallengePresent},
+		"192.0.2.2:53": {true, godns.ChallengeStale},
+		recursiveAddr:  {false, godns.ChallengeAbsent},
+	}
+	if len(report.Observations) != len(want) {
+		t.Fatalf("observations = %+v", report.Observations)
+	}
+	for _, o := range report.Observations {
+		w := want[o.Server]
+		if o.Authoritative != w.authoritative || o.Status != w.status {
+			t.Errorf("%s: authoritative %v, status %s; want %v, %s", o.Server, o.Authoritative, o.Status, w.authoritative, w.status)
+		}
+	}
+	if report.Ready() {
+		t.Error("Ready with a stale authoritative server")
+	}
+
+	// 权威服务器收到的查询不设置RD位
+	for _, s := range []*testserver.Server{ns1, ns2} {
+		for _, q := range s.Queries() {
+			if q.Msg.RecursionDesired {
+				t.Error("authoritative query set RD")
+			}
+		}
+	}
+}
+
+// TestVerifyACMEChallengeCNAME 跟随CNAME委派，在委派目标上检查期望值
+func TestVerifyACMEChallengeCNAME(t *testing.T) {
+	const target = "abc.acme.example.test"
+	recursive, ns1, ns2, c := hygieneZone(t, "example.test")
+	recursive.Answer(challengeOwner, dns.TypeCNAME, challengeOwner+". 300 IN CNAME "+target+".")
+	recursive.Handle(target, dns.TypeCNAME, testserver.Reply{})
+	recursive.Handle(target, dns.TypeSOA, testserver.Reply{Authority: []dns.RR{testserver.RR("example.test. 3600 IN SOA ns1.example.test. hostmaster.example.test. 1 7200 900 1209600 300")}})
+	recursive.Handle(target, dns.TypeTXT, challengeTXT(target, challengeDigest))
+	for _, ns := range []*testserver.Server{ns1, ns2} {
+		ns.Handle(target, dns.TypeTXT, challengeTXT(target, challengeDigest))
+	}
+
+	report, err := c.VerifyACMEChallenge(context.Background(), "example.test", challengeDigest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Target != target || !slices.Equal(report.CNAMEChain, []string{target}) {
+		t.Errorf("Target %q, CNAMEChain %v", report.Target, report.CNAMEChain)
+	}
+	for _, o := range report.Observations {
+		if o.Status != godns.ChallengePresent || !slices.Equal(o.Values, []string{challengeDigest}) {
+			t.Errorf("%s: status %s, values %v", o.Server, o.Status, o.Values)
+		}
+	}
+	if !report.Ready() {
+		t.Error("not Ready with the value on every authoritative server")
+	}
+}
+
+// TestWaitForChallenge 轮询直到所有权威服务器上都存在期望的值；截止时间到达时返回最后一次完成的检查的报告和上下文错误
+func TestWaitForChallenge(t *testing.T) {
+	recursive, ns1, ns2, c := hygieneZone(t, "example.test")
+	recursive.Handle(challengeOwner, dns.TypeCNAME, testserver.Reply{})
+	recursive.Handle(challengeOwner, dns.TypeSOA, testserver.Reply{Authority: []dns.RR{testserver.RR("example.test. 3600 IN SOA ns1.example.test. hostmaster.example.test. 1 7200 900 1209600 300")}})
+	recursive.Handle(challengeOwner, dns.TypeTXT, testserver.Reply{})
+	ns1.Handle(challengeOwner, dns.TypeTXT, challengeTXT(challengeOwner, challengeDigest))
+	// ns2 前两次检查尚未更新
+	ns2.Handle(challengeOwner, dns.TypeTXT, challengeTXT(challengeOwner, "previous-digest"), challengeTXT(challengeOwner, "previous-digest"), challengeTXT(challengeOwner, challengeDigest))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	report, err := c.WaitForChallenge(ctx, "example.test", challengeDigest, 20*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Ready() {
+		t.Errorf("report not Ready: %+v", report.Observations)
+	}
+	if n := len(ns2.Queries()); n != 3 {
+		t.Errorf("ns2 saw %d queries, want 3 polls", n)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	report, err = c.WaitForChallenge(ctx, "example.test", "never-published", 20*time.Millisecond)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want the deadline", err)
+	}
+	if report == nil || report.Ready() {
+		t.Errorf("report = %+v, want the last unready report", report)
+	}
+}
@@ -0,0 +1,88 @@
+package godns_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+	"github.com/zan8in/godns/internal/testserver"
+)
+
+// TestADBitSurfaced 应答的AD位原样记录在结果中；未要求AD时查询不设置AD位，AD=0 的应答照常接受
+func TestADBitSurfaced(t *testing.T) {
+	s := startServer(t)
+	answer := []dns.RR{testserver.RR("signed.test. 60 IN A 192.0.2.1")}
+	s.Handle("signed.test", dns.TypeA, testserver.Reply{Answer: answer, AuthenticatedData: true})
+	s.Answer("plain.test", dns.TypeA, "plain.test. 60 IN A 192.0.2.2")
+	c := godns.New(godns.WithServers(s.UDPAddr), godns.WithRetries(0))
+	defer c.Close()
+
+	for name, want := range map[string]bool{"signed.test": true, "plain.test": false} {
+		res, err := c.QueryA(context.Background(), name)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if res.AD != want {
+			t.Errorf("%s: AD = %v, want %v", name, res.AD, want)
+		}
+	}
+	for _, q := range s.Queries() {
+		if q.Msg.AuthenticatedData {
+			t.Errorf("query for %s set the AD bit without WithRequireAD", q.Msg.Question[0].Name)
+		}
+	}
+}
+
+// TestRequireAD 要求AD时查询设置AD位（RFC 6840 §5.7），未经验证的应答被拒绝且不缓存，
+// 配置了多个服务器时转向能完成验证的服务器
+func TestRequireAD(t *testing.T) {
+	plain, validating := startServer(t), startServer(t)
+	plain.Answer("example.test", dns.TypeA, "example.test. 60 IN A 192.0.2.1")
+	validating.Handle("example.test", dns.TypeA, testserver.Reply{
+		Answer:            []dns.RR{testserver.RR("example.test. 60 IN A 192.0.2.1")},
+		AuthenticatedData: true,
+	})
+
+	t.Run("reject", func(t *testing.T) {
+		c := godns.New(godns.WithServers(plain.UDPAddr), godns.WithRequireAD(), godns.WithCache(time.Minute), godns.WithRetries(0))
+		defer c.Close()
+		for range 2 {
+			res, err := c.QueryA(context.Background(), "example.test")
+			if err == nil || !strings.Contains(err.Error(), "not DNSSEC validated (AD=0)") {
+				t.Fatalf("err = %v, want the AD=0 rejection", err)
+			}
+			if len(res.Records) != 0 || res.AD {
+				t.Errorf("result = %+v, want no records from an unvalidated answer", res)
+			}
+		}
+		// 被拒绝的应答不进入缓存，第二次查询同样发往网络
+		if n := len(plain.Queries()); n != 2 {
+			t.Errorf("server saw %d queries, want 2", n)
+		}
+		for _, q := range plain.Queries() {
+			if !q.Msg.AuthenticatedData {
+				t.Error("query did not set the AD bit")
+			}
+		}
+	})
+
+	t.Run("failover", func(t *testing.T) {
+		c := godns.New(
+			godns.WithServers(plain.UDPAddr, validating.UDPAddr),
+			godns.WithRequireAD(),
+			godns.WithMaxForwarders(2),
+			godns.WithRetries(0),
+		)
+		defer c.Close()
+		res, err := c.QueryA(context.Background(), "example.test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !res.AD || res.Server != validating.UDPAddr || len(res.Records) != 1 {
+			t.Errorf("AD %v from %s with %v, want the validated answer from %s", res.AD, res.Server, res.Records, validating.UDPAddr)
+		}
+	})
+}
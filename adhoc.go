@@ -0,0 +1,67 @@
+package godns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// adHocKey 标记临时指定服务器的查询
+type adHocKey struct{}
+
+// QueryAt 临时向指定服务器查询，不使用配置的服务器列表，
+// 协议、代理、重试和TLS设置保持不变
+// 临时查询不读写应答缓存，避免与配置服务器的应答互相混淆
+func (c *Client) QueryAt(ctx context.Context, domain string, qtype uint16, server string) (*QueryResult, error) {
+	if server == "" {
+		return nil, fmt.Errorf("%w: no server specified", ErrNoServers)
+	}
+	return c.queryServer(c.adHocContext(ctx), domain, qtype, c.serverInfo(normalizeServer(server, c.config.Protocol)))
+}
+
+// MultiQueryAt 临时并发查询指定的服务器列表，用于比较任意几个解析器的应答
+func (c *Client) MultiQueryAt(ctx context.Context, domain string, qtype uint16, servers []string) (*MultiQueryResult, error) {
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("%w: no servers specified", ErrNoServers)
+	}
+	return c.multiQuery(c.adHocContext(ctx), nil, domain, qtype, c.normalizeServers(servers)), nil
+}
+
+// adHocContext 标记查询使用临时指定的服务器
+func (c *Client) adHocContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, adHocKey{}, true)
+}
+
+// normalizeServers 按客户端协议规范化服务器列表
+func (c *Client) normalizeServers(servers []string) []string {
+	normalized := make([]string, len(servers))
+	for i, server := range servers {
+		normalized[i] = normalizeServer(server, c.config.Protocol)
+	}
+	return normalized
+}
+
+// isAdHoc 是否为临时指定服务器的查询
+func isAdHoc(ctx context.Context) bool {
+	adHoc, _ := ctx.Value(adHocKey{}).(bool)
+	return adHoc
+}
+
+// normalizeServer 按协议补全服务器地址的默认端口，DoH地址和带协议前缀的地址保持原样
+func normalizeServer(server string, protocol Protocol) string {
+	server = strings.TrimSpace(server)
+	if protocol == DoH || strings.Contains(server, "://") {
+		// DoH URL 及带协议前缀的地址由 ParseServer 处理
+		return server
+	}
+	if _, _, err := net.SplitHostPort(server); err == nil {
+		return server
+	}
+
+	port := "53"
+	if protocol == DoT || protocol == DoQ {
+		port = "853"
+	}
+	return net.JoinHostPort(strings.Trim(server, "[]"), port)
+}
@@ -0,0 +1,137 @@
+package godns_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+)
+
+// TestQueryAt 临时查询只发往指定的服务器，结果的 Server 为该服务器；
+// 临时查询不读写缓存，配置服务器的缓存应答不受影响
+func TestQueryAt(t *testing.T) {
+	configured, adHoc := startServer(t), startServer(t)
+	configured.Answer("example.test", dns.TypeA, "example.test. 300 IN A 192.0.2.1")
+	adHoc.Answer("example.test", dns.TypeA, "example.test. 300 IN A 198.51.100.1")
+	c := godns.New(godns.WithServers(configured.UDPAddr), godns.WithCache(time.Hour), godns.WithRetries(0))
+	defer c.Close()
+	ctx := context.Background()
+
+	if _, err := c.QueryA(ctx, "example.test"); err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		res, err := c.QueryAt(ctx, "example.test", dns.TypeA, adHoc.UDPAddr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Server != adHoc.UDPAddr || res.Path.Source != godns.SourceNetwork || res.Records[0].Value() != "198.51.100.1" {
+			t.Errorf("QueryAt = %s from %s via %s, want the ad hoc server's answer from the network",
+				res.Records[0].Value(), res.Server, res.Path.Source)
+		}
+	}
+	res, err := c.QueryA(ctx, "example.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Path.Source != godns.SourceCache || res.Records[0].Value() != "192.0.2.1" {
+		t.Errorf("configured query = %s via %s, want the cached answer of the configured server", res.Records[0].Value(), res.Path.Source)
+	}
+
+	if n := len(configured.Queries()); n != 1 {
+		t.Errorf("configured server saw %d queries, want 1", n)
+	}
+	if n := len(adHoc.Queries()); n != 2 {
+		t.Errorf("ad hoc server saw %d queries, want 2", n)
+	}
+	if servers := c.Servers(); !slices.Equal(servers, []string{configured.UDPAddr}) {
+		t.Errorf("Servers = %v, want the configured list unchanged", servers)
+	}
+}
+
+// TestQueryAtNormalize 临时指定的服务器与配置一样按协议补全默认端口
+func TestQueryAtNormalize(t *testing.T) {
+	tests := []struct {
+		protocol godns.Protocol
+		server   string
+		want     string
+	}{
+		{godns.UDP, "192.0.2.9", "192.0.2.9:53"},
+		{godns.UDP, " 192.0.2.9:5353 ", "192.0.2.9:5353"},
+		{godns.UDP, "[2001:db8::9]", "[2001:db8::9]:53"},
+		{godns.TCP, "2001:db8::9", "[2001:db8::9]:53"},
+		{godns.DoT, "192.0.2.9", "192.0.2.9:853"},
+	}
+	for _, tt := range tests {
+		var mu sync.Mutex
+		var seen []string
+		transport := transportFunc(func(ctx context.Context, msg *dns.Msg, server string) (*dns.Msg, error) {
+			mu.Lock()
+			seen = append(seen, server)
+			mu.Unlock()
+			reply := new(dns.Msg)
+			reply.SetReply(msg)
+			return reply, nil
+		})
+		c := godns.New(godns.WithServers("203.0.113.1:53"), godns.WithProtocol(tt.protocol), godns.WithTransport(transport), godns.WithRetries(0))
+
+		res, err := c.QueryAt(context.Background(), "example.test", dns.TypeA, tt.server)
+		if err != nil {
+			t.Errorf("%s %q: %v", tt.protocol, tt.server, err)
+		} else if res.Server != tt.want {
+			t.Errorf("%s %q: Server = %q, want %q", tt.protocol, tt.server, res.Server, tt.want)
+		}
+		if _, err := c.MultiQueryAt(context.Background(), "example.test", dns.TypeA, []string{tt.server}); err != nil {
+			t.Errorf("%s %q: MultiQueryAt: %v", tt.protocol, tt.server, err)
+		}
+		mu.Lock()
+		if !slices.Equal(seen, []string{tt.want, tt.want}) {
+			t.Errorf("%s %q: transport saw %v, want %s twice", tt.protocol, tt.server, seen, tt.want)
+		}
+		mu.Unlock()
+		c.Close()
+	}
+}
+
+// TestMultiQueryAt 临时并发查询指定的服务器列表，未指定服务器时返回 ErrNoServers
+func TestMultiQueryAt(t *testing.T) {
+	configured, a, b := startServer(t), startServer(t), startServer(t)
+	a.Answer("example.test", dns.TypeA, "example.test. 300 IN A 192.0.2.1")
+	b.Answer("example.test", dns.TypeA, "example.test. 300 IN A 192.0.2.2")
+	c := godns.New(godns.WithServers(configured.UDPAddr), godns.WithRetries(0))
+	defer c.Close()
+	ctx := context.Background()
+
+	res, err := c.MultiQueryAt(ctx, "example.test", dns.TypeA, []string{a.UDPAddr, b.UDPAddr})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var servers []string
+	for _, r := range res.Results {
+		if r.Error != nil {
+			t.Errorf("%s: %v", r.Server, r.Error)
+		}
+		servers = append(servers, r.Server)
+	}
+	slices.Sort(servers)
+	want := []string{a.UDPAddr, b.UDPAddr}
+	slices.Sort(want)
+	if !slices.Equal(servers, want) || len(res.AllIPs) != 2 {
+		t.Errorf("servers %v, AllIPs %v; want one answer from each of %v", servers, res.AllIPs, want)
+	}
+	if n := len(configured.Queries()); n != 0 {
+		t.Errorf("configured server saw %d queries, want none", n)
+	}
+
+	if _, err := c.QueryAt(ctx, "example.test", dns.TypeA, ""); !errors.Is(err, godns.ErrNoServers) {
+		t.Errorf("QueryAt without a server: err = %v, want ErrNoServers", err)
+	}
+	if _, err := c.MultiQueryAt(ctx, "example.test", dns.TypeA, nil); !errors.Is(err, godns.ErrNoServers) {
+		t.Errorf("MultiQueryAt without servers: err = %v, want ErrNoServers", err)
+	}
+}
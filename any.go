@@ -0,0 +1,122 @@
+package godns
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+// anyFallbackTypes ANY查询被拒绝时逐一查询的常见类型
+var anyFallbackTypes = []uint16{dns.TypeA, dns.TypeAAAA, dns.TypeMX, dns.TypeNS, dns.TypeTXT, dns.TypeSOA}
+
+// WithANYFallback 设置 QueryANY 在服务器拒绝ANY查询（RFC 8482）时是否改为并发查询常见类型（A、AAAA、MX、NS、TXT、SOA）
+func WithANYFallback(enabled bool) Option {
+	return func(c *Config) {
+		c.ANYFallback = enabled
+	}
+}
+
+// ANYResult ANY查询结果
+type ANYResult struct {
+	Domain   string
+	ByType   map[uint16][]Record // 按记录类型分组的记录
+	Minimal  bool                // 服务器按 RFC 8482 拒绝了ANY查询（HINFO "RFC8482"、NOTIMP 或 REFUSED）
+	Fallback bool                // 记录来自对常见类型的逐一查询
+	Results  []*QueryResult      // 原始结果：ANY查询的结果，回退时依次为各类型的结果
+}
+
+// Types 返回结果中出现的记录类型，按类型值升序排列
+func (r *ANYResult) Types() []uint16 {
+	types := make([]uint16, 0, len(r.ByType))
+	for qtype := range r.ByType {
+		types = append(types, qtype)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	return types
+}
+
+// QueryANY 发送ANY（类型255）查询，按记录类型分组返回服务器给出的全部记录
+// 许多服务器按 RFC 8482 只返回一条 HINFO 记录或直接拒绝，此时 Minimal 为 true；
+// 开启 WithANYFallback 后改为并发查询常见类型并合并结果
+func (c *Client) QueryANY(ctx context.Context, domain string) (*ANYResult, error) {
+	result := &ANYResult{Domain: domain, ByType: make(map[uint16][]Record)}
+	res, err := c.Query(ctx, domain, dns.TypeANY)
+	if res != nil {
+		result.Results = append(result.Results, res)
+	}
+	result.Minimal = minimalANY(res, err)
+	if !result.Minimal {
+		if err != nil {
+			return result, err
+		}
+		result.add(res)
+		return result, nil
+	}
+	if !c.config.ANYFallback {
+		if err == nil {
+			result.add(res)
+		}
+		return result, err
+	}
+
+	result.Fallback = true
+	results := make([]*QueryResult, len(anyFallbackTypes))
+	errs := make([]error, len(anyFallbackTypes))
+	var wg sync.WaitGroup
+	for i, qtype := range anyFallbackTypes {
+		wg.Add(1)
+		go func(i int, qtype uint16) {
+			defer wg.Done()
+			results[i], errs[i] = c.Query(ctx, domain, qtype)
+		}(i, qtype)
+	}
+	wg.Wait()
+
+	var firstErr error
+	succeeded := false
+	for i, res := range results {
+		if res != nil {
+			result.Results = append(result.Results, res)
+		}
+		if errs[i] != nil {
+			if firstErr == nil {
+				firstErr = errs[i]
+			}
+			continue
+		}
+		succeeded = true
+		result.add(res)
+	}
+	if !succeeded {
+		return result, firstErr
+	}
+	return result, nil
+}
+
+// add 按类型归入结果中的记录
+func (r *ANYResult) add(res *QueryResult) {
+	for _, record := range res.Records {
+		r.ByType[record.Type] = append(r.ByType[record.Type], record)
+	}
+}
+
+// minimalANY 服务器是否拒绝了ANY查询：RFC 8482 §4.2 的单条 HINFO "RFC8482" 应答、NOTIMP 或 REFUSED
+func minimalANY(res *QueryResult, err error) bool {
+	if errors.Is(err, ErrRefusedName) || errors.Is(err, ErrRefusedClient) {
+		return true
+	}
+	if err != nil || res == nil {
+		return false
+	}
+	if res.Rcode == dns.RcodeNotImplemented {
+		return true
+	}
+	if len(res.Records) != 1 {
+		return false
+	}
+	hinfo, ok := res.Records[0].RR().(*dns.HINFO)
+	return ok && hinfo.Cpu == "RFC8482"
+}
@@ -0,0 +1,97 @@
+package godns_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+	"github.com/zan8in/godns/internal/testserver"
+)
+
+// TestQueryANY 服务器给出完整的ANY应答时按记录类型分组，不发出其它查询
+func TestQueryANY(t *testing.T) {
+	s := startServer(t)
+	s.Answer("example.test", dns.TypeANY,
+		"example.test. 300 IN A 192.0.2.1",
+		"example.test. 300 IN A 192.0.2.2",
+		"example.test. 300 IN MX 10 mx.example.test.",
+		`example.test. 300 IN TXT "v=spf1 -all"`,
+	)
+	c := godns.New(godns.WithServers(s.UDPAddr), godns.WithRetries(0), godns.WithANYFallback(true))
+	defer c.Close()
+
+	res, err := c.QueryANY(context.Background(), "example.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Minimal || res.Fallback || len(res.Results) != 1 {
+		t.Errorf("Minimal %v, Fallback %v, %d results; want the ANY answer only", res.Minimal, res.Fallback, len(res.Results))
+	}
+	if types := res.Types(); !slices.Equal(types, []uint16{dns.TypeA, dns.TypeMX, dns.TypeTXT}) {
+		t.Errorf("Types = %v", types)
+	}
+	if len(res.ByType[dns.TypeA]) != 2 || res.ByType[dns.TypeMX][0].Value() != "10 mx.example.test." {
+		t.Errorf("ByType = %v", res.ByType)
+	}
+	if n := len(s.Queries()); n != 1 {
+		t.Errorf("server saw %d queries, want 1", n)
+	}
+}
+
+// TestQueryANYMinimal RFC 8482 的 HINFO 应答和 REFUSED 被识别为拒绝ANY查询；
+// 未开启回退时原样返回，开启后并发查询常见类型并合并结果（关闭 REFUSED 探测以便统计查询数）
+func TestQueryANYMinimal(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply testserver.Reply
+	}{
+		{"hinfo", testserver.Reply{Answer: []dns.RR{testserver.RR(`example.test. 3789 IN HINFO "RFC8482" ""`)}}},
+		{"refused", testserver.Reply{Rcode: dns.RcodeRefused}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := func() *testserver.Server {
+				s := startServer(t)
+				s.Handle("example.test", dns.TypeANY, tt.reply)
+				s.Answer("example.test", dns.TypeA, "example.test. 300 IN A 192.0.2.1")
+				s.Answer("example.test", dns.TypeMX, "example.test. 300 IN MX 10 mx.example.test.")
+				s.Answer("example.test", dns.TypeNS, "example.test. 300 IN NS ns1.example.test.")
+				return s
+			}
+
+			s := start()
+			plain := godns.New(godns.WithServers(s.UDPAddr), godns.WithRetries(0), godns.WithoutRefusalProbe())
+			defer plain.Close()
+			res, _ := plain.QueryANY(context.Background(), "example.test")
+			if !res.Minimal || res.Fallback || len(res.ByType[dns.TypeA]) != 0 {
+				t.Errorf("without fallback: Minimal %v, Fallback %v, ByType %v", res.Minimal, res.Fallback, res.ByType)
+			}
+			if n := len(s.Queries()); n != 1 {
+				t.Errorf("without fallback the server saw %d queries, want 1", n)
+			}
+
+			s = start()
+			c := godns.New(godns.WithServers(s.UDPAddr), godns.WithRetries(0), godns.WithoutRefusalProbe(), godns.WithANYFallback(true))
+			defer c.Close()
+			res, err := c.QueryANY(context.Background(), "example.test")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !res.Minimal || !res.Fallback {
+				t.Errorf("Minimal %v, Fallback %v", res.Minimal, res.Fallback)
+			}
+			if types := res.Types(); !slices.Equal(types, []uint16{dns.TypeA, dns.TypeNS, dns.TypeMX}) {
+				t.Errorf("Types = %v, want A, NS and MX from the fallback queries", types)
+			}
+			// ANY查询本身加上六种常见类型
+			if n := len(s.Queries()); n != 7 {
+				t.Errorf("server saw %d queries, want 7", n)
+			}
+			if len(res.Results) != 7 {
+				t.Errorf("got %d raw results, want 7", len(res.Results))
+			}
+		})
+	}
+}
@@ -0,0 +1,214 @@
+package godns
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// ErrInvalidCheckpoint 检查点令牌无法解析或与本次批量输入不匹配
+var ErrInvalidCheckpoint = errors.New("godns: invalid batch checkpoint")
+
+// BatchItem 批量解析中的一项
+type BatchItem struct {
+	Domain string
+	Type   uint16
+}
+
+// BatchResult 批量解析中一项的结果
+type BatchResult struct {
+	Seq    uint64 // 交付序号，单调递增，从检查点恢复时继续递增
+	Index  int    // 该项在输入中的位置
+	Item   BatchItem
+	Result *QueryResult
+	Err    error
+}
+
+// batchConfig 批量解析配置
+type batchConfig struct {
+	concurrency int
+	resume      string
+}
+
+// BatchOption 批量解析配置选项
+type BatchOption func(*batchConfig)
+
+// WithBatchConcurrency 设置批量解析的并发数
+func WithBatchConcurrency(n int) BatchOption {
+	return func(b *batchConfig) {
+		b.concurrency = n
+	}
+}
+
+// WithBatchResume 从 Checkpoint 返回的令牌恢复，跳过检查点之前已交付的项
+func WithBatchResume(token string) BatchOption {
+	return func(b *batchConfig) {
+		b.resume = token
+	}
+}
+
+// batchCursor 检查点内容：Low 之前的项均已交付，Done 为 Low 之后已交付的项
+type batchCursor struct {
+	Version int    `json:"v"`
+	Items   int    `json:"n"`
+	Low     int    `json:"low"`
+	Done    []int  `json:"done,omitempty"`
+	Seq     uint64 `json:"seq"`
+}
+
+// BatchRun 一次批量解析，通过 Next 逐项获取结果
+// 结果只有在被 Next 返回后才计入检查点，已完成但未交付的项在恢复时会重新解析，
+// 因此依次调用 Next 与 Checkpoint 可以保证跨进程重启的恰好一次交付
+type BatchRun struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	items   []BatchItem
+	results chan BatchResult
+	wg      sync.WaitGroup
+
+	mu   sync.Mutex
+	low  int
+	done map[int]bool
+	seq  uint64
+}
+
+// ResolveBatch 并发解析一批查询，可通过 WithBatchResume 从之前的检查点恢复
+func (c *Client) ResolveBatch(ctx context.Context, items []BatchItem, opts ...BatchOption) (*BatchRun, error) {
+	cfg := &batchConfig{concurrency: 8}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if cfg.concurrency <= 0 {
+		cfg.concurrency = 1
+	}
+
+	run := &BatchRun{
+		items:   items,
+		results: make(chan BatchResult, cfg.concurrency),
+		done:    make(map[int]bool),
+	}
+	if cfg.resume != "" {
+		cursor, err := decodeBatchCursor(cfg.resume)
+		if err != nil {
+			return nil, err
+		}
+		if cursor.Items != len(items) || cursor.Low > len(items) {
+			return nil, fmt.Errorf("%w: checkpoint covers %d items, batch has %d", ErrInvalidCheckpoint, cursor.Items, len(items))
+		}
+		run.low, run.seq = cursor.Low, cursor.Seq
+		for _, i := range cursor.Done {
+			if i < cursor.Low || i >= len(items) {
+				return nil, fmt.Errorf("%w: item %d out of range", ErrInvalidCheckpoint, i)
+			}
+			run.done[i] = true
+		}
+	}
+	run.ctx, run.cancel = context.WithCancel(ctx)
+
+	// 检查点之前已交付的项不再派发；done 随后由 Next 修改，派发时使用副本
+	start, skip := run.low, make(map[int]bool, len(run.done))
+	for i := range run.done {
+		skip[i] = true
+	}
+	jobs := make(chan int)
+	go func() {
+		defer close(jobs)
+		for i := start; i < len(items); i++ {
+			if skip[i] {
+				continue
+			}
+			select {
+			case jobs <- i:
+			case <-run.ctx.Done():
+				return
+			}
+		}
+	}()
+
+	for w := 0; w < cfg.concurrency; w++ {
+		run.wg.Add(1)
+		go func() {
+			defer run.wg.Done()
+			for i := range jobs {
+				item := items[i]
+				res, err := c.Query(run.ctx, item.Domain, item.Type)
+				if run.ctx.Err() != nil {
+					// 被取消的项不交付，恢复后重新解析
+					return
+				}
+				select {
+				case run.results <- BatchResult{Index: i, Item: item, Result: res, Err: err}:
+				case <-run.ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		run.wg.Wait()
+		close(run.results)
+	}()
+	return run, nil
+}
+
+// Next 返回下一个完成的结果，全部交付完毕或批量解析被取消时返回 false
+func (b *BatchRun) Next() (BatchResult, bool) {
+	select {
+	case res, ok := <-b.results:
+		if !ok || b.ctx.Err() != nil {
+			return BatchResult{}, false
+		}
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.seq++
+		res.Seq = b.seq
+		b.done[res.Index] = true
+		for b.done[b.low] {
+			delete(b.done, b.low)
+			b.low++
+		}
+		return res, true
+	case <-b.ctx.Done():
+		return BatchResult{}, false
+	}
+}
+
+// Checkpoint 返回可序列化的检查点令牌，包含所有已由 Next 交付的项
+func (b *BatchRun) Checkpoint() string {
+	b.mu.Lock()
+	cursor := batchCursor{Version: 1, Items: len(b.items), Low: b.low, Seq: b.seq}
+	for i := range b.done {
+		cursor.Done = append(cursor.Done, i)
+	}
+	b.mu.Unlock()
+	sort.Ints(cursor.Done)
+
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// Stop 取消批量解析并等待进行中的查询结束，未交付的项不计入检查点
+func (b *BatchRun) Stop() {
+	b.cancel()
+	b.wg.Wait()
+}
+
+// decodeBatchCursor 解析检查点令牌
+func decodeBatchCursor(token string) (*batchCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCheckpoint, err)
+	}
+	var cursor batchCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCheckpoint, err)
+	}
+	if cursor.Version != 1 {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidCheckpoint, cursor.Version)
+	}
+	return &cursor, nil
+}
@@ -0,0 +1,153 @@
+package godns_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+)
+
+// batchClient 返回按名称中的序号延迟应答的客户端，使结果乱序完成；queries 统计发出的查询数
+func batchClient(t *testing.T, queries *atomic.Int64) *godns.Client {
+	t.Helper()
+	c := godns.New(
+		godns.WithServers("192.0.2.53:53"),
+		godns.WithRetries(0),
+		godns.WithTransport(transportFunc(func(ctx context.Context, msg *dns.Msg, _ string) (*dns.Msg, error) {
+			queries.Add(1)
+			name := msg.Question[0].Name
+			i, _ := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(name, "item-"), ".test."))
+			select {
+			case <-time.After(time.Duration(i%7) * time.Millisecond):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			reply := new(dns.Msg)
+			reply.SetReply(msg)
+			reply.Answer = []dns.RR{mustRR(name + " 60 IN A 192.0.2.1")}
+			return reply, nil
+		})),
+	)
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func batchItems(n int) []godns.BatchItem {
+	items := make([]godns.BatchItem, n)
+	for i := range items {
+		items[i] = godns.BatchItem{Domain: fmt.Sprintf("item-%d.test", i), Type: dns.TypeA}
+	}
+	return items
+}
+
+// TestBatchCancelResumeExactlyOnce 中途取消后从检查点恢复，两次运行合起来每项恰好交付一次，序号连续递增
+func TestBatchCancelResumeExactlyOnce(t *testing.T) {
+	const n, stopAfter = 200, 70
+	var queries atomic.Int64
+	c := batchClient(t, &queries)
+	items := batchItems(n)
+
+	delivered := make(map[int]int)
+	var lastSeq uint64
+	deliver := func(res godns.BatchResult) {
+		if res.Err != nil {
+			t.Errorf("item %d: %v", res.Index, res.Err)
+		}
+		if res.Seq != lastSeq+1 {
+			t.Errorf("item %d delivered with seq %d after %d", res.Index, res.Seq, lastSeq)
+		}
+		lastSeq = res.Seq
+		if res.Item != items[res.Index] {
+			t.Errorf("result %d carries item %+v, want %+v", res.Index, res.Item, items[res.Index])
+		}
+		delivered[res.Index]++
+	}
+
+	// 第一次运行：交付 stopAfter 项后取消，模拟进程被中断
+	ctx, cancel := context.WithCancel(context.Background())
+	run, err := c.ResolveBatch(ctx, items, godns.WithBatchConcurrency(8))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var token string
+	for range stopAfter {
+		res, ok := run.Next()
+		if !ok {
+			t.Fatal("batch ended early")
+		}
+		deliver(res)
+		token = run.Checkpoint()
+	}
+	cancel()
+	if res, ok := run.Next(); ok {
+		t.Fatalf("Next after cancel returned item %d", res.Index)
+	}
+	run.Stop()
+	if len(delivered) != stopAfter {
+		t.Fatalf("first run delivered %d items, want %d", len(delivered), stopAfter)
+	}
+
+	// 令牌可跨进程保存：恢复时只依赖字符串本身
+	queries.Store(0)
+	run, err = c.ResolveBatch(context.Background(), items, godns.WithBatchConcurrency(8), godns.WithBatchResume(strings.Clone(token)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		res, ok := run.Next()
+		if !ok {
+			break
+		}
+		deliver(res)
+	}
+	run.Stop()
+
+	for i := range n {
+		if delivered[i] != 1 {
+			t.Errorf("item %d delivered %d times, want exactly once", i, delivered[i])
+		}
+	}
+	if lastSeq != n {
+		t.Errorf("last seq = %d, want %d", lastSeq, n)
+	}
+	// 已交付的项恢复后不再查询
+	if q := queries.Load(); q != n-stopAfter {
+		t.Errorf("resumed run sent %d queries, want %d", q, n-stopAfter)
+	}
+}
+
+func TestBatchResumeInvalidToken(t *testing.T) {
+	var queries atomic.Int64
+	c := batchClient(t, &queries)
+	items := batchItems(10)
+
+	run, err := c.ResolveBatch(context.Background(), items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	run.Next()
+	token := run.Checkpoint()
+	run.Stop()
+
+	for name, tt := range map[string]struct {
+		token string
+		items []godns.BatchItem
+	}{
+		"garbage":        {"not a token!", items},
+		"not-json":       {"bm90IGpzb24", items},
+		"different-size": {token, batchItems(11)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := c.ResolveBatch(context.Background(), tt.items, godns.WithBatchResume(tt.token)); !errors.Is(err, godns.ErrInvalidCheckpoint) {
+				t.Errorf("err = %v, want ErrInvalidCheckpoint", err)
+			}
+		})
+	}
+}
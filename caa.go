@@ -0,0 +1,68 @@
+package godns
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// CAARecord CAA记录的各字段
+type CAARecord struct {
+	Flag  uint8  // 标志位，128 表示关键（critical）
+	Tag   string // 属性标签，例如 "issue"、"issuewild"、"iodef"
+	Value string // 属性值，不含引号
+}
+
+// String 返回 `flag tag "value"` 形式，与CAA记录的 Record.Value 相同
+func (r *CAARecord) String() string {
+	return fmt.Sprintf("%d %s %q", r.Flag, r.Tag, r.Value)
+}
+
+// Critical 是否设置了关键标志位（RFC 8659 §4.1）
+func (r *CAARecord) Critical() bool {
+	return r.Flag&128 != 0
+}
+
+// CAA 返回CAA记录的各字段，记录不是CAA类型时返回 false
+// 反序列化得到的 Record 从 Value 中解析
+func (r Record) CAA() (*CAARecord, bool) {
+	if caa, ok := r.rr.(*dns.CAA); ok {
+		return &CAARecord{Flag: caa.Flag, Tag: caa.Tag, Value: caa.Value}, true
+	}
+	if r.Type != dns.TypeCAA {
+		return nil, false
+	}
+	fields := strings.SplitN(r.Value(), " ", 3)
+	if len(fields) != 3 {
+		return nil, false
+	}
+	flag, err := strconv.ParseUint(fields[0], 10, 8)
+	if err != nil {
+		return nil, false
+	}
+	value, err := strconv.Unquote(fields[2])
+	if err != nil {
+		return nil, false
+	}
+	return &CAARecord{Flag: uint8(flag), Tag: fields[1], Value: value}, true
+}
+
+// CAARecords 返回结果中的CAA记录，保持应答中的顺序
+func (r *QueryResult) CAARecords() []CAARecord {
+	var records []CAARecord
+	for _, record := range r.Records {
+		if caa, ok := record.CAA(); ok {
+			records = append(records, *caa)
+		}
+	}
+	return records
+}
+
+// QueryCAA 查询CAA记录，结构化的字段可通过 QueryResult.CAARecords 获取
+// 注意CAA策略按 RFC 8659 §3 需要沿域名树向上查找，本方法只查询 domain 本身
+func (c *Client) QueryCAA(ctx context.Context, domain string) (*QueryResult, error) {
+	return c.Query(ctx, domain, dns.TypeCAA)
+}
@@ -0,0 +1,94 @@
+package godns_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+)
+
+func TestQueryCAA(t *testing.T) {
+	s := startServer(t)
+	s.Answer("example.test", dns.TypeCAA,
+		`example.test. 300 IN CAA 0 issue "ca.example.net; account=230123"`,
+		`example.test. 300 IN CAA 0 issuewild ";"`,
+		`example.test. 300 IN CAA 128 iodef "mailto:security@example.test"`,
+		`example.test. 300 IN CAA 0 issue "quote\"d.example"`,
+	)
+	// 有A记录但没有CAA记录的名称得到空的NOERROR应答
+	s.Answer("nocaa.test", dns.TypeCAA)
+	c := godns.New(godns.WithServers(s.UDPAddr))
+	defer c.Close()
+
+	res, err := c.QueryCAA(context.Background(), "example.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q := s.Queries(); len(q) != 1 || q[0].Msg.Question[0].Qtype != dns.TypeCAA {
+		t.Fatalf("server saw %+v", q)
+	}
+	want := []godns.CAARecord{
+		{Flag: 0, Tag: "issue", Value: "ca.example.net; account=230123"},
+		{Flag: 0, Tag: "issuewild", Value: ";"},
+		{Flag: 128, Tag: "iodef", Value: "mailto:security@example.test"},
+		{Flag: 0, Tag: "issue", Value: `quote"d.example`},
+	}
+	if got := res.CAARecords(); !slices.Equal(got, want) {
+		t.Fatalf("CAARecords = %+v, want %+v", got, want)
+	}
+	wantValues := []string{
+		`0 issue "ca.example.net; account=230123"`,
+		`0 issuewild ";"`,
+		`128 iodef "mailto:security@example.test"`,
+		`0 issue "quote\"d.example"`,
+	}
+	for i, r := range res.Records {
+		if r.Value() != wantValues[i] {
+			t.Errorf("record %d value = %q, want %q", i, r.Value(), wantValues[i])
+		}
+		caa, _ := r.CAA()
+		if caa.String() != r.Value() || caa.Critical() != (i == 2) {
+			t.Errorf("record %d: String = %q, Critical = %v", i, caa.String(), caa.Critical())
+		}
+	}
+
+	// 序列化后从 Value 还原各字段
+	data, err := json.Marshal(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded godns.QueryResult
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if got := decoded.CAARecords(); !slices.Equal(got, want) {
+		t.Errorf("decoded CAARecords = %+v, want %+v", got, want)
+	}
+
+	// 没有CAA记录的名称不返回错误，不存在的名称返回 ErrNXDomain，两者都以应答码区分
+	for domain, rcode := range map[string]int{"nocaa.test": dns.RcodeSuccess, "missing.test": dns.RcodeNameError} {
+		res, err := c.QueryCAA(context.Background(), domain)
+		if rcode == dns.RcodeNameError && !errors.Is(err, godns.ErrNXDomain) || rcode == dns.RcodeSuccess && err != nil {
+			t.Fatalf("%s: err = %v", domain, err)
+		}
+		if res.Rcode != rcode || len(res.Records) != 0 || len(res.CAARecords()) != 0 {
+			t.Errorf("%s: rcode = %d, records = %v", domain, res.Rcode, res.Records)
+		}
+	}
+}
+
+func TestRecordCAANonCAA(t *testing.T) {
+	for _, r := range []godns.Record{
+		godns.NewRecord(mustRR("example.test. 60 IN A 192.0.2.1")),
+		godns.NewValueRecord("example.test", dns.TypeTXT, 60, `0 issue "ca.example.net"`),
+		godns.NewValueRecord("example.test", dns.TypeCAA, 60, "malformed"),
+	} {
+		if caa, ok := r.CAA(); ok {
+			t.Errorf("%s record parsed as CAA %+v", dns.TypeToString[r.Type], caa)
+		}
+	}
+}
@@ -0,0 +1,310 @@
+package godns
+
+import (
+	"container/list"
+	"net/netip"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// 应答来源
+const (
+	SourceCache = "cache" // 来自缓存
+)
+
+// defaultCacheEntries 缓存的默认最大条目数
+const defaultCacheEntries = 10000
+
+// WithCache 启用内存应答缓存，过期时间取应答记录的最小TTL，
+// maxTTL 大于0时作为缓存时间上限；最多保存 10000 条，超出时淘汰最近最少使用的条目
+func WithCache(maxTTL time.Duration) Option {
+	return func(c *Config) {
+		c.CacheEnabled = true
+		c.CacheMaxTTL = maxTTL
+	}
+}
+
+// Cache 应答缓存的存储后端，可用于在多个进程间共享缓存（例如基于 Redis 实现）
+// 键由 CacheKey 生成；客户端负责计算缓存时间，后端只需在 ttl 后使条目失效
+// Get 返回的消息按原样使用，记录TTL不随存放时间递减，结果的 OriginallyQueriedAt 为零值
+// 方法会被并发调用；Set 传入的消息归后端所有，Get 返回的消息不会被客户端修改
+type Cache interface {
+	Get(key string) (*dns.Msg, bool)
+	Set(key string, msg *dns.Msg, ttl time.Duration)
+}
+
+// WithCacheBackend 使用自定义的缓存后端并启用应答缓存，过期时间的计算与 WithCache 相同
+// 使用自定义后端时 WithCachePersistence 不生效
+func WithCacheBackend(cache Cache) Option {
+	return func(c *Config) {
+		c.CacheEnabled = true
+		c.CacheBackend = cache
+	}
+}
+
+// WithNegativeCache 同时缓存 NXDOMAIN 应答，ttl 为缓存时间；应答权威部分带SOA时，
+// 取其否定缓存时间（SOA 的TTL与MINIMUM中较小者，RFC 2308 §5）与 ttl 中较小者
+// ttl 应较短，以便新注册的名称尽快可见；启用后同时启用应答缓存
+func WithNegativeCache(ttl time.Duration) Option {
+	return func(c *Config) {
+		c.CacheEnabled = true
+		c.NegativeCacheTTL = ttl
+	}
+}
+
+// cacheEntry 缓存条目
+type cacheEntry struct {
+	key      string
+	msg      *dns.Msg
+	storedAt time.Time // 应答从网络获得的时间
+	expires  time.Time
+}
+
+// responseCache 按问题缓存的DNS应答
+// 默认后端容量为 defaultCacheEntries，超出时淘汰最近最少使用的条目；过期条目在读取时删除
+type responseCache struct {
+	mu         sync.Mutex
+	entries    map[string]*list.Element // 值为 *cacheEntry
+	lru        *list.List               // 最近使用的在前
+	maxEntries int
+	maxTTL     time.Duration
+	negTTL     time.Duration // NXDOMAIN 的缓存时间，0 表示不缓存
+	clock      Clock
+	backend    Cache // 自定义后端，为 nil 时使用 entries
+}
+
+func newResponseCache(clock Clock, maxTTL, negTTL time.Duration, backend Cache) *responseCache {
+	return &responseCache{
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+		maxEntries: defaultCacheEntries,
+		maxTTL:     maxTTL,
+		negTTL:     negTTL,
+		clock:      clock,
+		backend:    backend,
+	}
+}
+
+// cacheKey 生成缓存键
+func cacheKey(domain string, qtype uint16) string {
+	return CanonicalName(domain) + "|" + strconv.Itoa(int(qtype))
+}
+
+// CacheKey 返回经 protocol 发送的查询 msg 的缓存键，由协议、规范化名称、类型、类别和DO位组成，
+// 例如 "udp|example.com.|1|1"、"dot|example.com.|48|1|do"
+// DO位不同的应答内容不同（是否携带RRSIG等），非IN类别的应答与IN类别互不通用；
+// 不同协议的应答分别缓存，加密协议的查询不会使用经明文UDP/TCP获得的应答
+// 返回的键不含 EDNS Client Subnet：内置缓存读写带ECS的查询时才在此基础上附加 "|ecs|<子网>"，
+// 子网按应答的作用域前缀截断，因此自定义后端看到的键可能带有该后缀
+// msg 没有问题部分时返回空字符串，这样的消息不会被缓存
+func CacheKey(protocol Protocol, msg *dns.Msg) string {
+	if msg == nil || len(msg.Question) == 0 {
+		return ""
+	}
+	q := msg.Question[0]
+	key := string(protocol) + "|" + cacheKey(q.Name, q.Qtype) + "|" + strconv.Itoa(int(q.Qclass))
+	if opt := msg.IsEdns0(); opt != nil && opt.Do() {
+		key += "|do"
+	}
+	return key
+}
+
+// ecsOption 返回消息中的 EDNS Client Subnet 选项（RFC 7871），没有时返回 nil
+func ecsOption(msg *dns.Msg) *dns.EDNS0_SUBNET {
+	opt := msg.IsEdns0()
+	if opt == nil {
+		return nil
+	}
+	for _, o := range opt.Option {
+		if ecs, ok := o.(*dns.EDNS0_SUBNET); ok {
+			return ecs
+		}
+	}
+	return nil
+}
+
+// ecsAddr 返回ECS选项中的地址，地址族与地址不符时返回 false
+func ecsAddr(ecs *dns.EDNS0_SUBNET) (netip.Addr, bool) {
+	addr, ok := netip.AddrFromSlice(ecs.Address)
+	if !ok {
+		return netip.Addr{}, false
+	}
+	switch ecs.Family {
+	case 1:
+		addr = addr.Unmap()
+		return addr, addr.Is4()
+	case 2:
+		return addr, addr.Is6() && !addr.Is4In6()
+	}
+	return netip.Addr{}, false
+}
+
+// ecsKey 在 base 后附加按 bits 位截断的子网，带ECS的条目与不带ECS的条目互不通用
+// bits 为 0 表示应答适用于该地址族的所有子网
+func ecsKey(base string, addr netip.Addr, bits int) string {
+	prefix, err := addr.Prefix(bits)
+	if err != nil {
+		return ""
+	}
+	return base + "|ecs|" + prefix.String()
+}
+
+// get 返回未过期的缓存应答副本，记录TTL按剩余时间递减
+// 带ECS的查询按 RFC 7871 §7.3.2 查找覆盖查询地址的条目：从查询的源前缀长度向短逐级查找，使用最具体的一条，
+// 作用域前缀比查询源前缀更长的条目不会被使用
+func (rc *responseCache) get(protocol Protocol, query *dns.Msg) (*dns.Msg, time.Time, bool) {
+	base := CacheKey(protocol, query)
+	if base == "" {
+		return nil, time.Time{}, false
+	}
+	ecs := ecsOption(query)
+	if ecs == nil {
+		return rc.getKey(base)
+	}
+	addr, ok := ecsAddr(ecs)
+	if !ok {
+		return nil, time.Time{}, false
+	}
+	for bits := min(int(ecs.SourceNetmask), addr.BitLen()); bits >= 0; bits-- {
+		if msg, storedAt, ok := rc.getKey(ecsKey(base, addr, bits)); ok {
+			return msg, storedAt, true
+		}
+	}
+	return nil, time.Time{}, false
+}
+
+// getKey 按键返回未过期的缓存应答副本
+func (rc *responseCache) getKey(key string) (*dns.Msg, time.Time, bool) {
+	if rc.backend != nil {
+		msg, ok := rc.backend.Get(key)
+		if !ok || msg == nil {
+			return nil, time.Time{}, false
+		}
+		return msg.Copy(), time.Time{}, true
+	}
+
+	rc.mu.Lock()
+	elem, ok := rc.entries[key]
+	var entry *cacheEntry
+	if ok {
+		entry = elem.Value.(*cacheEntry)
+		if rc.clock.Now().Before(entry.expires) {
+			rc.lru.MoveToFront(elem)
+		} else {
+			rc.remove(elem)
+			ok = false
+		}
+	}
+	rc.mu.Unlock()
+	if !ok {
+		return nil, time.Time{}, false
+	}
+
+	msg := entry.msg.Copy()
+	remaining := uint32(entry.expires.Sub(rc.clock.Now()) / time.Second)
+	for _, section := range [][]dns.RR{msg.Answer, msg.Ns, msg.Extra} {
+		for _, rr := range section {
+			// OPT伪记录的TTL字段是扩展标志位
+			if rr.Header().Rrtype != dns.TypeOPT && rr.Header().Ttl > remaining {
+				rr.Header().Ttl = remaining
+			}
+		}
+	}
+	return msg, entry.storedAt, true
+}
+
+// set 缓存经 protocol 获得的 query 的应答，没有应答记录的消息不缓存，启用否定缓存时 NXDOMAIN 例外
+// 带ECS的查询按 RFC 7871 §7.3.1 以应答的作用域前缀（不超过查询的源前缀）截断查询地址作为键；
+// 应答不带ECS选项时视为作用域 0，应答回显的地址族、地址或源前缀与查询不符时不缓存
+func (rc *responseCache) set(protocol Protocol, query, msg *dns.Msg) {
+	d, ok := rc.ttl(msg)
+	if !ok {
+		return
+	}
+	key := CacheKey(protocol, query)
+	if key == "" {
+		return
+	}
+	if ecs := ecsOption(query); ecs != nil {
+		addr, ok := ecsAddr(ecs)
+		if !ok {
+			return
+		}
+		bits := min(int(ecs.SourceNetmask), addr.BitLen())
+		scope := 0
+		if echo := ecsOption(msg); echo != nil {
+			echoAddr, ok := ecsAddr(echo)
+			if !ok || echo.Family != ecs.Family || echo.SourceNetmask != ecs.SourceNetmask ||
+				ecsKey("", echoAddr, bits) != ecsKey("", addr, bits) {
+				return
+			}
+			scope = int(echo.SourceScope)
+		}
+		key = ecsKey(key, addr, min(scope, bits))
+	}
+
+	if rc.backend != nil {
+		rc.backend.Set(key, msg.Copy(), d)
+		return
+	}
+	now := rc.clock.Now()
+	rc.store(&cacheEntry{
+		key:      key,
+		msg:      msg.Copy(),
+		storedAt: now,
+		expires:  now.Add(d),
+	})
+}
+
+// ttl 返回应答的缓存时间：有应答记录时取最小TTL，NXDOMAIN 取否定缓存时间，均不超过 maxTTL
+// 其他没有应答记录的消息或缓存时间为 0 时返回 false
+func (rc *responseCache) ttl(msg *dns.Msg) (time.Duration, bool) {
+	var d time.Duration
+	switch {
+	case len(msg.Answer) > 0:
+		ttl := msg.Answer[0].Header().Ttl
+		for _, rr := range msg.Answer[1:] {
+			if rr.Header().Ttl < ttl {
+				ttl = rr.Header().Ttl
+			}
+		}
+		d = time.Duration(ttl) * time.Second
+	case msg.Rcode == dns.RcodeNameError && rc.negTTL > 0:
+		d = rc.negTTL
+		for _, rr := range msg.Ns {
+			if soa, ok := rr.(*dns.SOA); ok {
+				d = min(d, time.Duration(min(soa.Hdr.Ttl, soa.Minttl))*time.Second)
+				break
+			}
+		}
+	}
+	if rc.maxTTL > 0 && d > rc.maxTTL {
+		d = rc.maxTTL
+	}
+	return d, d > 0
+}
+
+// store 写入条目并标记为最近使用，超出容量时淘汰最近最少使用的条目
+func (rc *responseCache) store(entry *cacheEntry) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	if elem, ok := rc.entries[entry.key]; ok {
+		elem.Value = entry
+		rc.lru.MoveToFront(elem)
+		return
+	}
+	rc.entries[entry.key] = rc.lru.PushFront(entry)
+	for rc.lru.Len() > rc.maxEntries {
+		rc.remove(rc.lru.Back())
+	}
+}
+
+// remove 删除条目，调用方需持有 mu
+func (rc *responseCache) remove(elem *list.Element) {
+	rc.lru.Remove(elem)
+	delete(rc.entries, elem.Value.(*cacheEntry).key)
+}
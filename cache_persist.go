@@ -0,0 +1,197 @@
+package godns
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// 缓存快照文件格式：
+//
+//	magic "GODNSC3\n"
+//	重复的条目：uint16 键长度 | 键 | int64 获取时间(UnixNano) | int64 过期时间(UnixNano) | uint32 消息长度 | 打包后的 dns.Msg
+const snapshotMagic = "GODNSC3\n"
+
+const (
+	// defaultSnapshotMaxBytes 快照文件的默认大小上限
+	defaultSnapshotMaxBytes = 16 << 20
+	// snapshotInterval 周期性写入快照的间隔
+	snapshotInterval = time.Minute
+)
+
+// WithCachePersistence 启用缓存持久化：构建客户端时从 path 加载快照，
+// Close 时及运行期间周期性写回，加载时跳过已过期条目
+// 快照损坏时记录日志并以空缓存启动；快照大小超过上限的部分不会写入
+// 多个进程共享同一路径时，写入通过临时文件+重命名保证文件完整，但以最后写入者为准，
+// 不支持多进程间合并缓存内容
+func WithCachePersistence(path string) Option {
+	return func(c *Config) {
+		c.CacheEnabled = true
+		c.CachePersistPath = path
+		if c.CacheSnapshotMaxBytes == 0 {
+			c.CacheSnapshotMaxBytes = defaultSnapshotMaxBytes
+		}
+	}
+}
+
+// startCachePersistence 加载快照并启动周期性写入
+func (c *Client) startCachePersistence() {
+	if err := c.cache.load(c.config.CachePersistPath, c.config.CacheSnapshotMaxBytes); err != nil {
+		c.logf("ignoring cache snapshot %s: %v", c.config.CachePersistPath, err)
+	}
+	c.sched.every(snapshotInterval, func(context.Context) {
+		c.saveCacheSnapshot()
+	})
+}
+
+// stopCachePersistence 写入最终快照，周期性写入随后台任务调度器一同停止
+func (c *Client) stopCachePersistence() error {
+	if c.cache == nil || c.cache.backend != nil || c.config.CachePersistPath == "" {
+		return nil
+	}
+	return c.saveCacheSnapshot()
+}
+
+// saveCacheSnapshot 写入缓存快照
+func (c *Client) saveCacheSnapshot() error {
+	err := c.cache.save(c.config.CachePersistPath, c.config.CacheSnapshotMaxBytes)
+	if err != nil {
+		c.logf("failed to save cache snapshot %s: %v", c.config.CachePersistPath, err)
+	}
+	return err
+}
+
+// save 按最近使用的顺序将未过期条目写入快照文件，超出大小上限时舍弃最久未使用的条目
+func (rc *responseCache) save(path string, maxBytes int64) error {
+	var buf bytes.Buffer
+	buf.WriteString(snapshotMagic)
+
+	rc.mu.Lock()
+	now := rc.clock.Now()
+	for elem := rc.lru.Front(); elem != nil; elem = elem.Next() {
+		entry := elem.Value.(*cacheEntry)
+		key := entry.key
+		if !now.Before(entry.expires) {
+			continue
+		}
+		packed, err := entry.msg.Pack()
+		if err != nil {
+			continue
+		}
+		size := int64(2 + len(key) + 8 + 8 + 4 + len(packed))
+		if int64(buf.Len())+size > maxBytes {
+			break
+		}
+		var hdr [8]byte
+		binary.BigEndian.PutUint16(hdr[:2], uint16(len(key)))
+		buf.Write(hdr[:2])
+		buf.WriteString(key)
+		binary.BigEndian.PutUint64(hdr[:], uint64(entry.storedAt.UnixNano()))
+		buf.Write(hdr[:])
+		binary.BigEndian.PutUint64(hdr[:], uint64(entry.expires.UnixNano()))
+		buf.Write(hdr[:])
+		binary.BigEndian.PutUint32(hdr[:4], uint32(len(packed)))
+		buf.Write(hdr[:4])
+		buf.Write(packed)
+	}
+	rc.mu.Unlock()
+
+	// 写入临时文件后重命名，避免读者看到写了一半的快照
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// load 从快照文件加载未过期条目，任何格式错误都会放弃整个快照
+func (rc *responseCache) load(path string, maxBytes int64) error {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if info, err := f.Stat(); err == nil && info.Size() > maxBytes {
+		return fmt.Errorf("snapshot size %d exceeds limit %d", info.Size(), maxBytes)
+	}
+
+	r := bufio.NewReader(f)
+	magic := make([]byte, len(snapshotMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != snapshotMagic {
+		return fmt.Errorf("invalid snapshot header")
+	}
+
+	var entries []*cacheEntry
+	now := rc.clock.Now()
+	for {
+		var keyLen uint16
+		if err := binary.Read(r, binary.BigEndian, &keyLen); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return fmt.Errorf("corrupt snapshot: %v", err)
+		}
+		key := make([]byte, keyLen)
+		var storedAt, expires int64
+		var msgLen uint32
+		if _, err := io.ReadFull(r, key); err != nil {
+			return fmt.Errorf("corrupt snapshot: %v", err)
+		}
+		if err := binary.Read(r, binary.BigEndian, &storedAt); err != nil {
+			return fmt.Errorf("corrupt snapshot: %v", err)
+		}
+		if err := binary.Read(r, binary.BigEndian, &expires); err != nil {
+			return fmt.Errorf("corrupt snapshot: %v", err)
+		}
+		if err := binary.Read(r, binary.BigEndian, &msgLen); err != nil {
+			return fmt.Errorf("corrupt snapshot: %v", err)
+		}
+		if int64(msgLen) > maxBytes {
+			return fmt.Errorf("corrupt snapshot: message length %d", msgLen)
+		}
+		packed := make([]byte, msgLen)
+		if _, err := io.ReadFull(r, packed); err != nil {
+			return fmt.Errorf("corrupt snapshot: %v", err)
+		}
+
+		entry := &cacheEntry{
+			key:      string(key),
+			msg:      new(dns.Msg),
+			storedAt: time.Unix(0, storedAt),
+			expires:  time.Unix(0, expires),
+		}
+		if err := entry.msg.Unpack(packed); err != nil {
+			return fmt.Errorf("corrupt snapshot: %v", err)
+		}
+		if now.Before(entry.expires) {
+			entries = append(entries, entry)
+		}
+	}
+
+	// 快照中最近使用的条目在前，逆序写入以恢复使用顺序
+	for i := len(entries) - 1; i >= 0; i-- {
+		rc.store(entries[i])
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package godns_test
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+	"github.com/zan8in/godns/internal/testserver"
+)
+
+// fakeClock 手动推进的时钟
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// startServer 启动测试服务器并在测试结束时关闭
+func startServer(t *testing.T) *testserver.Server {
+	t.Helper()
+	s, err := testserver.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// captureLog 在测试期间捕获标准库 log 的输出
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	return &buf
+}
+
+func persistentClient(s *testserver.Server, path string, clock godns.Clock) *godns.Client {
+	return godns.New(
+		godns.WithServers(s.UDPAddr),
+		godns.WithCachePersistence(path),
+		godns.WithClock(clock),
+	)
+}
+
+// fillSnapshot 查询两个TTL不同的名称，关闭客户端写入快照
+func fillSnapshot(t *testing.T, s *testserver.Server, path string, clock godns.Clock) {
+	t.Helper()
+	s.Answer("short.test", dns.TypeA, "short.test. 60 IN A 192.0.2.1")
+	s.Answer("long.test", dns.TypeA, "long.test. 600 IN A 192.0.2.2")
+	c := persistentClient(s, path, clock)
+	for _, name := range []string{"short.test", "long.test"} {
+		if _, err := c.QueryA(context.Background(), name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	s.Reset()
+}
+
+func TestCachePersistenceRoundTrip(t *testing.T) {
+	s := startServer(t)
+	path := filepath.Join(t.TempDir(), "cache.snap")
+	clock := newFakeClock()
+	fillSnapshot(t, s, path, clock)
+
+	c := persistentClient(s, path, clock)
+	defer c.Close()
+	for name, want := range map[string]string{"short.test": "192.0.2.1", "long.test": "192.0.2.2"} {
+		res, err := c.QueryA(context.Background(), name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Path.Source != godns.SourceCache {
+			t.Errorf("%s: source = %q, want cache", name, res.Path.Source)
+		}
+		if len(res.Records) != 1 || res.Records[0].Value() != want {
+			t.Errorf("%s: records = %v", name, res.Records)
+		}
+	}
+	if q := s.Queries(); len(q) != 0 {
+		t.Errorf("reloaded cache sent %d queries", len(q))
+	}
+}
+
+func TestCachePersistenceExpiryOnReload(t *testing.T) {
+	s := startServer(t)
+	path := filepath.Join(t.TempDir(), "cache.snap")
+	clock := newFakeClock()
+	fillSnapshot(t, s, path, clock)
+
+	// 重新加载前推进时钟：short.test 已过期，long.test 剩余 480 秒
+	clock.Advance(120 * time.Second)
+	s.Answer("short.test", dns.TypeA, "short.test. 60 IN A 192.0.2.9")
+	c := persistentClient(s, path, clock)
+	defer c.Close()
+
+	res, err := c.QueryA(context.Background(), "short.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Path.Source != godns.SourceNetwork || res.Records[0].Value() != "192.0.2.9" {
+		t.Errorf("expired entry: source = %q, records = %v", res.Path.Source, res.Records)
+	}
+
+	res, err = c.QueryA(context.Background(), "long.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Path.Source != godns.SourceCache {
+		t.Fatalf("live entry: source = %q, want cache", res.Path.Source)
+	}
+	if ttl := res.Records[0].TTL; ttl != 480 {
+		t.Errorf("reloaded TTL = %d, want 480", ttl)
+	}
+}
+
+func TestCachePersistenceBadSnapshot(t *testing.T) {
+	tests := []struct {
+		name    string
+		corrupt func(data []byte) []byte
+	}{
+		{"garbage", func([]byte) []byte { return []byte("not a snapshot") }},
+		{"truncated", func(data []byte) []byte { return data[:len(data)-5] }},
+		{"bad-message", func(data []byte) []byte {
+			out := bytes.Clone(data)
+			// 覆盖最后一条消息的末尾，使其无法解包
+			for i := len(out) - 8; i < len(out); i++ {
+				out[i] = 0xff
+			}
+			return out
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := startServer(t)
+			path := filepath.Join(t.TempDir(), "cache.snap")
+			clock := newFakeClock()
+			fillSnapshot(t, s, path, clock)
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, tt.corrupt(data), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			logs := captureLog(t)
+			s.Answer("long.test", dns.TypeA, "long.test. 600 IN A 192.0.2.2")
+			c := persistentClient(s, path, clock)
+			defer c.Close()
+			if !strings.Contains(logs.String(), "ignoring cache snapshot") {
+				t.Errorf("log = %q, want an ignored-snapshot message", logs.String())
+			}
+
+			// 整个快照被放弃，缓存为空
+			res, err := c.QueryA(context.Background(), "long.test")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Path.Source != godns.SourceNetwork {
+				t.Errorf("source = %q, want network", res.Path.Source)
+			}
+		})
+	}
+}
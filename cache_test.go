@@ -0,0 +1,435 @@
+package godns
+
+import (
+	"context"
+	"net/netip"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns/internal/testserver"
+)
+
+func cacheQuery(name string) *dns.Msg {
+	msg := new(dns.Msg)
+	msg.SetQuestion(dns.Fqdn(name), dns.TypeA)
+	return msg
+}
+
+func cacheReply(name string) *dns.Msg {
+	query := cacheQuery(name)
+	reply := new(dns.Msg)
+	reply.SetReply(query)
+	rr, err := dns.NewRR(dns.Fqdn(name) + " 300 IN A 192.0.2.1")
+	if err != nil {
+		panic(err)
+	}
+	reply.Answer = []dns.RR{rr}
+	return reply
+}
+
+// TestResponseCacheLRU 默认后端超出容量时淘汰最近最少使用的条目
+func TestResponseCacheLRU(t *testing.T) {
+	rc := newResponseCache(systemClock{}, 0, 0, nil)
+	rc.maxEntries = 3
+	names := []string{"a.test", "b.test", "c.test"}
+	for _, name := range names {
+		rc.set(UDP, cacheQuery(name), cacheReply(name))
+	}
+	// 读取 a.test 使 b.test 成为最久未使用的条目
+	if _, _, ok := rc.get(UDP, cacheQuery("a.test")); !ok {
+		t.Fatal("a.test missing")
+	}
+	rc.set(UDP, cacheQuery("d.test"), cacheReply("d.test"))
+
+	for name, want := range map[string]bool{"a.test": true, "b.test": false, "c.test": true, "d.test": true} {
+		if _, _, ok := rc.get(UDP, cacheQuery(name)); ok != want {
+			t.Errorf("%s cached = %v, want %v", name, ok, want)
+		}
+	}
+	// 覆盖已有条目不淘汰其他条目
+	rc.set(UDP, cacheQuery("c.test"), cacheReply("c.test"))
+	if n := rc.lru.Len(); n != 3 || len(rc.entries) != 3 {
+		t.Errorf("entries = %d/%d, want 3", n, len(rc.entries))
+	}
+}
+
+// mapCache 记录调用次数的自定义缓存后端
+type mapCache struct {
+	mu         sync.Mutex
+	msgs       map[string]*dns.Msg
+	gets, sets int
+}
+
+func (m *mapCache) Get(key string) (*dns.Msg, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.gets++
+	msg, ok := m.msgs[key]
+	return msg, ok
+}
+
+func (m *mapCache) Set(key string, msg *dns.Msg, ttl time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sets++
+	m.msgs[key] = msg
+}
+
+func TestCacheKey(t *testing.T) {
+	do := cacheQuery("WWW.Example.COM")
+	do.SetEdns0(4096, true)
+	chaos := cacheQuery("version.bind")
+	chaos.Question[0].Qclass = dns.ClassCHAOS
+	ecs := cacheQuery("www.example.com")
+	setClientSubnet(ecs, &dns.EDNS0_SUBNET{Code: dns.EDNS0SUBNET, Family: 1, SourceNetmask: 24, Address: []byte{198, 51, 100, 0}})
+
+	tests := []struct {
+		name     string
+		protocol Protocol
+		msg      *dns.Msg
+		want     string
+	}{
+		{"udp", UDP, cacheQuery("www.example.com"), "udp|www.example.com.|1|1"},
+		{"do", DoT, do, "dot|www.example.com.|1|1|do"},
+		{"class", TCP, chaos, "tcp|version.bind.|1|3"},
+		// ECS后缀只由内置缓存的读写附加
+		{"ecs", UDP, ecs, "udp|www.example.com.|1|1"},
+		{"no-question", UDP, new(dns.Msg), ""},
+		{"nil", UDP, nil, ""},
+	}
+	for _, tt := range tests {
+		if got := CacheKey(tt.protocol, tt.msg); got != tt.want {
+			t.Errorf("%s: CacheKey = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	// 没有问题部分的消息不读写缓存
+	rc := newResponseCache(systemClock{}, 0, 0, nil)
+	reply := cacheReply("www.example.com")
+	rc.set(UDP, new(dns.Msg), reply)
+	if rc.lru.Len() != 0 {
+		t.Errorf("cache has %d entries after storing a message without a question", rc.lru.Len())
+	}
+	if _, _, ok := rc.get(UDP, new(dns.Msg)); ok {
+		t.Error("get hit for a message without a question")
+	}
+}
+
+// ecsQuery 构建带ECS选项的 A 查询，prefix 形如 "198.51.100.0/24"
+func ecsQuery(name, prefix string) *dns.Msg {
+	p := netip.MustParsePrefix(prefix)
+	family := uint16(1)
+	if p.Addr().Is6() {
+		family = 2
+	}
+	msg := cacheQuery(name)
+	setClientSubnet(msg, &dns.EDNS0_SUBNET{Code: dns.EDNS0SUBNET, Family: family, SourceNetmask: uint8(p.Bits()), Address: p.Addr().AsSlice()})
+	return msg
+}
+
+// ecsReply 构建对 query 的应答，回显查询的ECS选项并设置作用域前缀，答案为 ip
+func ecsReply(query *dns.Msg, scope uint8, ip string) *dns.Msg {
+	reply := new(dns.Msg)
+	reply.SetReply(query)
+	rr, err := dns.NewRR(query.Question[0].Name + " 300 IN A " + ip)
+	if err != nil {
+		panic(err)
+	}
+	reply.Answer = []dns.RR{rr}
+	if ecs := ecsOption(query); ecs != nil {
+		echo := *ecs
+		echo.SourceScope = scope
+		setClientSubnet(reply, &echo)
+	}
+	return reply
+}
+
+// cachedIP 返回缓存中 query 的应答地址，未命中时返回空字符串
+func cachedIP(rc *responseCache, query *dns.Msg) string {
+	msg, _, ok := rc.get(UDP, query)
+	if !ok {
+		return ""
+	}
+	return msg.Answer[0].(*dns.A).A.String()
+}
+
+// TestCacheECSScope 带ECS的应答按作用域前缀缓存（RFC 7871 §7.3.1），查找时使用覆盖查询地址的最具体条目（§7.3.2）
+func TestCacheECSScope(t *testing.T) {
+	type stored struct {
+		query string // 查询的客户端子网，空表示不带ECS
+		scope uint8
+		ip    string
+	}
+	tests := []struct {
+		name   string
+		stored []stored
+		lookup map[string]string // 查询的客户端子网 -> 期望命中的地址，空字符串表示未命中
+	}{
+		{
+			// RFC 7871 §7.3.1 的例子：源前缀 /24、作用域 /16 的应答适用于整个 /16
+			name:   "scope-shorter-than-source",
+			stored: []stored{{"198.51.100.0/24", 16, "192.0.2.1"}},
+			lookup: map[string]string{
+				"198.51.100.0/24": "192.0.2.1",
+				"198.51.7.0/24":   "192.0.2.1",
+				"198.52.100.0/24": "",
+				"":                "",
+			},
+		},
+		{
+			name:   "scope-zero",
+			stored: []stored{{"198.51.100.0/24", 0, "192.0.2.1"}},
+			lookup: map[string]string{
+				"198.51.100.0/24": "192.0.2.1",
+				"203.0.113.0/24":  "192.0.2.1",
+				"2001:db8::/56":   "",
+				"":                "",
+			},
+		},
+		{
+			// 作用域比源前缀更长时只按源前缀缓存
+			name:   "scope-longer-than-source",
+			stored: []stored{{"198.51.100.0/24", 28, "192.0.2.1"}},
+			lookup: map[string]string{
+				"198.51.100.0/24":  "192.0.2.1",
+				"198.51.100.64/26": "192.0.2.1",
+				"198.51.101.0/24":  "",
+			},
+		},
+		{
+			name:   "ipv6",
+			stored: []stored{{"2001:db8:0:100::/56", 48, "192.0.2.1"}},
+			lookup: map[string]string{
+				"2001:db8:0:ff00::/56": "192.0.2.1",
+				"2001:db8:1::/56":      "",
+				"198.51.100.0/24":      "",
+			},
+		},
+		{
+			name: "most-specific-wins",
+			stored: []stored{
+				{"198.51.100.0/24", 16, "192.0.2.16"},
+				{"198.51.100.0/24", 24, "192.0.2.24"},
+			},
+			lookup: map[string]string{
+				"198.51.100.0/24": "192.0.2.24",
+				"198.51.7.0/24":   "192.0.2.16",
+				// 源前缀比条目作用域短的查询不能使用更具体的条目
+				"198.51.0.0/16": "192.0.2.16",
+			},
+		},
+		{
+			// 不带ECS的条目与带ECS的条目互不通用
+			name:   "no-ecs-entry",
+			stored: []stored{{"", 0, "192.0.2.1"}},
+			lookup: map[string]string{
+				"":                "192.0.2.1",
+				"198.51.100.0/24": "",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rc := newResponseCache(systemClock{}, 0, 0, nil)
+			for _, st := range tt.stored {
+				query := cacheQuery("www.example.com")
+				if st.query != "" {
+					query = ecsQuery("www.example.com", st.query)
+				}
+				rc.set(UDP, query, ecsReply(query, st.scope, st.ip))
+			}
+			for prefix, want := range tt.lookup {
+				query := cacheQuery("www.example.com")
+				if prefix != "" {
+					query = ecsQuery("www.example.com", prefix)
+				}
+				if got := cachedIP(rc, query); got != want {
+					t.Errorf("lookup from %q = %q, want %q", prefix, got, want)
+				}
+			}
+		})
+	}
+}
+
+// TestCacheECSMismatchedEcho 应答回显的ECS选项与查询不符时不缓存
+func TestCacheECSMismatchedEcho(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(echo *dns.EDNS0_SUBNET)
+	}{
+		{"family", func(echo *dns.EDNS0_SUBNET) {
+			echo.Family = 2
+			echo.Address = netip.MustParseAddr("2001:db8::").AsSlice()
+		}},
+		{"address", func(echo *dns.EDNS0_SUBNET) { echo.Address = []byte{203, 0, 113, 0} }},
+		{"source-netmask", func(echo *dns.EDNS0_SUBNET) { echo.SourceNetmask = 16 }},
+		{"bad-address", func(echo *dns.EDNS0_SUBNET) { echo.Address = []byte{1, 2, 3} }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rc := newResponseCache(systemClock{}, 0, 0, nil)
+			query := ecsQuery("www.example.com", "198.51.100.0/24")
+			reply := ecsReply(query, 24, "192.0.2.1")
+			tt.mutate(ecsOption(reply))
+			rc.set(UDP, query, reply)
+			if n := rc.lru.Len(); n != 0 {
+				t.Errorf("cache has %d entries, want none", n)
+			}
+		})
+	}
+}
+
+// TestCacheDOIsolation DO位不同的查询互不使用对方的应答
+func TestCacheDOIsolation(t *testing.T) {
+	rc := newResponseCache(systemClock{}, 0, 0, nil)
+	do := cacheQuery("www.example.com")
+	do.SetEdns0(4096, true)
+	plain := cacheQuery("www.example.com")
+	plainEDNS := cacheQuery("www.example.com")
+	plainEDNS.SetEdns0(4096, false)
+
+	rc.set(UDP, do, ecsReply(do, 0, "192.0.2.1"))
+	if got := cachedIP(rc, do); got != "192.0.2.1" {
+		t.Errorf("DO lookup = %q, want a hit", got)
+	}
+	for name, query := range map[string]*dns.Msg{"plain": plain, "edns-without-do": plainEDNS} {
+		if got := cachedIP(rc, query); got != "" {
+			t.Errorf("%s lookup hit the DO entry: %q", name, got)
+		}
+	}
+
+	rc.set(UDP, plain, ecsReply(plain, 0, "192.0.2.2"))
+	if got := cachedIP(rc, plainEDNS); got != "192.0.2.2" {
+		t.Errorf("EDNS query without DO = %q, want the plain entry", got)
+	}
+	if got := cachedIP(rc, do); got != "192.0.2.1" {
+		t.Errorf("DO lookup after storing the plain entry = %q, want the DO entry", got)
+	}
+}
+
+// TestCacheBackend 自定义后端替代默认后端，键由 CacheKey 生成
+func TestCacheBackend(t *testing.T) {
+	s, err := testserver.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.Answer("www.example.com", dns.TypeA, "www.example.com. 300 IN A 192.0.2.1")
+
+	backend := &mapCache{msgs: make(map[string]*dns.Msg)}
+	c := New(WithServers(s.UDPAddr), WithCacheBackend(backend))
+	defer c.Close()
+
+	for i, want := range []string{SourceNetwork, SourceCache} {
+		res, err := c.QueryA(context.Background(), "www.example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Path.Source != want || len(res.Records) != 1 {
+			t.Errorf("query %d: source = %q, records = %v", i+1, res.Path.Source, res.Records)
+		}
+	}
+	if backend.gets != 2 || backend.sets != 1 {
+		t.Errorf("backend gets = %d, sets = %d, want 2 and 1", backend.gets, backend.sets)
+	}
+	if key := CacheKey(UDP, cacheQuery("www.example.com")); backend.msgs[key] == nil {
+		t.Errorf("backend has no entry for %q", key)
+	}
+	if c.cache.lru.Len() != 0 {
+		t.Error("default backend was used alongside the custom one")
+	}
+	if n := len(s.Queries()); n != 1 {
+		t.Errorf("server saw %d queries, want 1", n)
+	}
+}
+
+// countingTransport 统计交换次数，对 nx. 开头的名称返回 NXDOMAIN，其余返回一条A记录
+type countingTransport struct {
+	mu    sync.Mutex
+	calls int
+}
+
+func (t *countingTransport) Exchange(ctx context.Context, msg *dns.Msg, server string) (*dns.Msg, error) {
+	t.mu.Lock()
+	t.calls++
+	t.mu.Unlock()
+	name := msg.Question[0].Name
+	if strings.HasPrefix(name, "nx.") {
+		reply := new(dns.Msg)
+		reply.SetRcode(msg, dns.RcodeNameError)
+		return reply, nil
+	}
+	reply := cacheReply(name)
+	reply.Id = msg.Id
+	reply.Question = msg.Question
+	return reply, nil
+}
+
+func (t *countingTransport) count() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.calls
+}
+
+// TestCacheHitSkipsNetwork 相同的查询第二次命中缓存，不发出网络请求
+func TestCacheHitSkipsNetwork(t *testing.T) {
+	transport := &countingTransport{}
+	c := New(
+		WithTransport(transport),
+		WithServers("192.0.2.53:53", "198.51.100.53:53"),
+		WithCache(time.Minute),
+		WithNegativeCache(time.Minute),
+	)
+	defer c.Close()
+	ctx := context.Background()
+
+	// 缓存键不含服务器，MultiQuery 的各服务器共享缓存条目，第一次调用的网络请求数取决于并发时序
+	tests := []struct {
+		name  string
+		query func() error
+	}{
+		{"Query", func() error {
+			_, err := c.Query(ctx, "www.example.com", dns.TypeA)
+			return err
+		}},
+		{"NXDOMAIN", func() error {
+			_, err := c.Query(ctx, "nx.example.com", dns.TypeA)
+			return ignoreNXDomain(err)
+		}},
+		{"MultiQueryA", func() error {
+			_, err := c.MultiQueryA(ctx, "multi.example.com")
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := transport.count()
+			if err := tt.query(); err != nil {
+				t.Fatal(err)
+			}
+			first := transport.count()
+			if first == before {
+				t.Fatal("first query made no network calls")
+			}
+			if err := tt.query(); err != nil {
+				t.Fatal(err)
+			}
+			if n := transport.count() - first; n != 0 {
+				t.Errorf("second query made %d network calls, want 0", n)
+			}
+		})
+	}
+
+	// 类型不同的查询不命中缓存
+	before := transport.count()
+	res, err := c.Query(ctx, "www.example.com", dns.TypeAAAA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transport.count() != before+1 || res.Path.Source != SourceNetwork {
+		t.Errorf("AAAA query: calls = %d, source = %q", transport.count()-before, res.Path.Source)
+	}
+}
@@ -2,8 +2,15 @@ package godns
 
 import (
 	"context"
+	"crypto/rand"
 	"crypto/tls"
+	"encoding/hex"
+	"log"
 	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/miekg/dns"
@@ -26,6 +33,12 @@ var DoTServers = []string{
 	"1.1.1.1:853", // Cloudflare - 在国内可能不稳定
 }
 
+// DoQServers DoQ（RFC 9250）的默认服务器，端口 853
+var DoQServers = []string{
+	"dns.adguard-dns.com:853",        // AdGuard DoQ - 在国内可能较慢
+	"unfiltered.adguard-dns.com:853", // AdGuard 无过滤 DoQ
+}
+
 var UDPServers = []string{
 	"223.5.5.5:53", // 阿里DNS - 保留
 	// "223.6.6.6:53",       // 阿里DNS备用
@@ -42,17 +55,73 @@ var UDPServers = []string{
 // Client DNS客户端
 type Client struct {
 	config *Config
+	name   string
+
+	transports  transports
+	pipelines   *pipelinePool
+	serverTags  map[string]string // 服务器 -> 标签
+	router      atomic.Pointer[router]
+	cache       *responseCache
+	errCache    *errorCache
+	refusals    *refusalTracker     // 服务器的 REFUSED 分类
+	dane        *daneCache          // DANE校验使用的TLSA记录
+	zones       *zoneCache          // 区域切分缓存
+	outstanding *outstandingLimiter // 网络交互并发上限，未启用时为 nil
+	edns        *ednsMemory
+	rand        *lockedRand // 注入的随机数来源，为 nil 时使用加密安全的随机数
+	servers     map[string]ServerInfo
+	proxied     proxiedServers
+	intern      internPool // CompactResult 使用的字符串驻留池
+	sched       *scheduler // 后台任务调度器
+	debugOnce   sync.Once  // 调试日志中的配置快照只输出一次
+	guard       *loopGuard // 解析循环检测标识，子客户端与父客户端共享
+	drain       *drainer   // 进行中的查询，子客户端与父客户端共享
+
+	subnet    *dns.EDNS0_SUBNET // 查询中附加的ECS选项，未配置时为 nil
+	subnetErr error             // ClientSubnet 格式错误，延迟到查询时返回
+
+	releaseOnce sync.Once // Close 和 Shutdown 只释放一次资源
+	releaseErr  error
 }
 
 // Config 配置选项
 type Config struct {
+	// 客户端名称，用于区分同一进程内的多个客户端
+	Name string
+
 	// 基础配置
 	Timeout  time.Duration
 	Retries  int
 	Protocol Protocol
 
+	// 连接服务器时使用的IP地址族，为空时不限定
+	NetworkFamily NetworkFamily
+
+	// 协议回退配置
+	FallbackProtocols []Protocol
+	HedgedFallback    bool
+	HedgeDelay        time.Duration
+
 	// 服务器配置
-	Servers []string
+	Servers       []string
+	ServerInfos   []ServerInfo        // 结构化的服务器定义
+	TaggedServers map[string][]string // 标签 -> 服务器列表
+	MaxForwarders int                 // Query 单次最多咨询的服务器数量，0 表示不限制
+
+	// MultiQuery配置
+	PartialResults bool // 截止时间到达时返回已收到的结果
+	Quorum         int  // 达到该数量的一致应答即提前返回，0 表示关闭
+
+	// 交错扇出配置
+	FanoutWaveSize int           // 每波查询的服务器数量，0 表示一次查询全部
+	FanoutStagger  time.Duration // 相邻波次的间隔
+
+	// 混合协议竞速配置
+	MixedRace        []ServerSpec
+	RacePreferWindow time.Duration
+
+	// 置信度评分权重，为 nil 时使用 DefaultConfidenceWeights
+	ConfidenceWeights *ConfidenceWeights
 
 	// 代理配置
 	ProxyType ProxyType
@@ -60,10 +129,100 @@ type Config struct {
 	ProxyAuth *ProxyAuth
 
 	// TLS配置
-	TLSConfig *tls.Config
+	TLSConfig        *tls.Config
+	ServerTLSConfigs map[string]*tls.Config // 服务器 -> TLS覆盖配置
 
 	// HTTP配置（用于DoH）
 	HTTPClient *http.Client
+	DoHMethod  string // DoH请求方法："GET"（默认）或 "POST"
+
+	// 自定义传输层，设置后替代内置的协议实现
+	Transport Transport
+
+	// 管道化配置（用于TCP/DoT）
+	Pipelining bool
+
+	// DNSSEC配置
+	RequireAD bool
+
+	// 安全配置
+	SourcePortRandomization bool // UDP查询每次使用随机源端口
+
+	// 路由配置
+	Routes []RouteRule
+
+	// 查询名称的校验策略
+	NamePolicy NamePolicy
+
+	// TTL限制，0 表示不限制
+	TTLMin uint32
+	TTLMax uint32
+
+	// 缓存配置
+	CacheEnabled          bool
+	CacheMaxTTL           time.Duration // 缓存时间上限，0 表示不限制
+	NegativeCacheTTL      time.Duration // NXDOMAIN 应答的缓存时间，0 表示不缓存
+	CacheBackend          Cache         // 自定义缓存后端，为 nil 时使用内存缓存
+	CachePersistPath      string        // 缓存快照文件路径，为空时不持久化
+	CacheSnapshotMaxBytes int64         // 缓存快照大小上限
+	ErrorCacheTTL         time.Duration // 记住近期失败的时间窗口，0 表示不记住
+
+	// 查询中附加的 EDNS Client Subnet，CIDR 格式，为空时不附加
+	ClientSubnet string
+
+	// 服务器应答 REFUSED 时不发送探测查询区分拒绝范围
+	SkipRefusalProbe bool
+
+	// QueryANY 被拒绝时改为逐一查询常见类型
+	ANYFallback bool
+
+	// DoT 连接按服务器的TLSA记录校验证书
+	DANE bool
+
+	// 应答大小限制
+	MaxAnswers       int // 单次应答解析出的记录数上限
+	MaxResponseBytes int // TCP/DoT/DoH/DoQ应答的字节数上限
+
+	// SelfTest 用于验证解析的已知可用名称
+	SelfTestName string
+	SelfTestType uint16
+
+	// DialContext 相邻连接尝试的间隔
+	HappyEyeballsDelay time.Duration
+
+	// 重试时的服务器选择策略
+	RetryPlacement RetryPlacement
+
+	// UDP单次尝试内的重发间隔，为空时不重发
+	UDPRetransmit []time.Duration
+
+	// 并发网络交互上限，0 表示不限制
+	MaxOutstanding int
+	QueueTimeout   time.Duration                                 // 排队等待的时间上限，0 表示一直等待
+	OnSaturated    func(ctx context.Context, ev SaturationEvent) // 因达到上限而排队的回调
+
+	// 后台任务配置
+	BackgroundConcurrency int     // 后台任务并发数
+	BackgroundQPS         float64 // 后台任务每秒启动数量上限，0 使用默认值，小于 0 表示不限制
+
+	// 时钟，默认使用系统时间
+	Clock Clock
+
+	// 随机数来源，默认使用加密安全的随机数
+	Rand RandSource
+
+	// 调试日志，首次查询时输出一次配置快照
+	Debug bool
+
+	// 审计配置
+	RecordSentQuery bool                                                   // 在结果中记录实际发送的查询
+	OnRequest       func(ctx context.Context, server string, msg *dns.Msg) // 查询发送前的回调
+
+	// 应答拦截器，用于故障注入
+	ResponseInterceptor func(ctx context.Context, msg *dns.Msg) (*dns.Msg, error)
+
+	// serversExplicit 标记服务器列表是否由 WithServers 显式指定
+	serversExplicit bool
 }
 
 // Protocol 协议类型
@@ -74,6 +233,7 @@ const (
 	TCP Protocol = "tcp"
 	DoT Protocol = "dot" // DNS over TLS
 	DoH Protocol = "doh" // DNS over HTTPS
+	DoQ Protocol = "doq" // DNS over QUIC（RFC 9250）
 )
 
 // ProxyType 代理类型
@@ -97,32 +257,226 @@ type Option func(*Config)
 // NewDefault 创建默认客户端
 // 在 NewDefault 函数中设置合理的默认重试次数
 func NewDefault() *Client {
-	return &Client{
-		config: &Config{
-			Timeout:  5 * time.Second,
-			Retries:  2, // 默认重试2次，总共3次尝试
-			Protocol: UDP,
-			Servers:  UDPServers,
-		},
-	}
+	return newClient(&Config{
+		Timeout:       5 * time.Second,
+		Retries:       2, // 默认重试2次，总共3次尝试
+		Protocol:      UDP,
+		Servers:       UDPServers,
+		MaxForwarders: 1,
+	})
 }
 
 // New 创建自定义客户端
 func New(opts ...Option) *Client {
 
-	config := &Config{
-		Timeout:   5 * time.Second,
-		Retries:   3,
-		Protocol:  UDP,
-		Servers:   UDPServers,
-		ProxyType: NoProxy,
-	}
+	config := defaultConfig()
 
 	for _, opt := range opts {
 		opt(config)
 	}
 
-	return &Client{config: config}
+	return newClient(config)
+}
+
+// defaultConfig 返回 New 使用的默认配置
+func defaultConfig() *Config {
+	return &Config{
+		Timeout:       5 * time.Second,
+		Retries:       3,
+		Protocol:      UDP,
+		Servers:       UDPServers,
+		ProxyType:     NoProxy,
+		MaxForwarders: 1, // 默认只查询第一个服务器
+	}
+}
+
+// newClient 根据配置初始化客户端及其运行时状态
+// 客户端持有配置的深拷贝，构建后不再变化，可被多个 goroutine 无锁读取；
+// 运行时可变状态（连接池、缓存、路由表等）均由各自的同步结构维护
+func newClient(config *Config) *Client {
+	config = config.clone()
+	if config.Clock == nil {
+		config.Clock = systemClock{}
+	}
+	if config.MaxAnswers <= 0 {
+		config.MaxAnswers = defaultMaxAnswers
+	}
+	if config.MaxResponseBytes <= 0 {
+		config.MaxResponseBytes = defaultMaxResponseBytes
+	}
+	c := &Client{
+		config:     config,
+		name:       config.Name,
+		serverTags: make(map[string]string),
+		edns:       newEDNSMemory(config.Clock),
+		zones:      newZoneCache(config.Clock),
+		refusals:   newRefusalTracker(config.Clock),
+		dane:       newDANECache(config.Clock),
+		sched:      newScheduler(config.BackgroundConcurrency, config.BackgroundQPS),
+	}
+	if config.Rand != nil {
+		c.rand = &lockedRand{src: config.Rand}
+	}
+	if c.name == "" {
+		if c.rand != nil {
+			c.name = c.seededClientID()
+		} else {
+			c.name = newClientID()
+		}
+	}
+	c.guard = &loopGuard{name: c.name}
+	c.drain = newDrainer(c)
+	c.pipelines = newPipelinePool(config.MaxResponseBytes, c.randomID)
+	if config.ClientSubnet != "" {
+		c.subnet, c.subnetErr = parseClientSubnet(config.ClientSubnet)
+	}
+	c.buildServerInfos()
+	for _, tag := range sortedTags(config.TaggedServers) {
+		for _, server := range config.TaggedServers[tag] {
+			if _, ok := c.serverTags[server]; !ok {
+				c.serverTags[server] = tag
+			}
+		}
+	}
+	// 专用代理服务器的子客户端共用名额，需先于它们创建
+	if config.MaxOutstanding > 0 {
+		c.outstanding = newOutstandingLimiter(config.MaxOutstanding, config.QueueTimeout)
+	}
+	c.prepareTransports()
+	c.buildProxiedServers()
+	if config.CacheEnabled {
+		c.cache = newResponseCache(config.Clock, config.CacheMaxTTL, config.NegativeCacheTTL, config.CacheBackend)
+		if config.CachePersistPath != "" && config.CacheBackend == nil {
+			c.startCachePersistence()
+		}
+	}
+	if config.ErrorCacheTTL > 0 {
+		c.errCache = newErrorCache(config.Clock, config.ErrorCacheTTL)
+	}
+	if len(config.Routes) > 0 {
+		c.router.Store(c.buildRouter(config.Routes))
+	}
+	return c
+}
+
+// Name 返回客户端名称，未通过 WithName 设置时为自动生成的短ID
+func (c *Client) Name() string {
+	return c.name
+}
+
+// clone 深拷贝配置，避免调用方在构建后修改切片、映射或TLS配置影响客户端
+func (c *Config) clone() *Config {
+	cfg := *c
+	cfg.FallbackProtocols = append([]Protocol(nil), c.FallbackProtocols...)
+	cfg.Servers = append([]string(nil), c.Servers...)
+	cfg.MixedRace = append([]ServerSpec(nil), c.MixedRace...)
+	for i := range cfg.MixedRace {
+		cfg.MixedRace[i].TLSConfig = cfg.MixedRace[i].TLSConfig.Clone()
+	}
+	cfg.UDPRetransmit = append([]time.Duration(nil), c.UDPRetransmit...)
+	if c.ServerInfos != nil {
+		cfg.ServerInfos = make([]ServerInfo, len(c.ServerInfos))
+		for i, info := range c.ServerInfos {
+			info.Pins = append([]string(nil), info.Pins...)
+			info.TLSConfig = info.TLSConfig.Clone()
+			cfg.ServerInfos[i] = info
+		}
+	}
+	if c.TaggedServers != nil {
+		cfg.TaggedServers = make(map[string][]string, len(c.TaggedServers))
+		for tag, servers := range c.TaggedServers {
+			cfg.TaggedServers[tag] = append([]string(nil), servers...)
+		}
+	}
+	if c.Routes != nil {
+		cfg.Routes = make([]RouteRule, len(c.Routes))
+		for i, rule := range c.Routes {
+			rule.Servers = append([]string(nil), rule.Servers...)
+			if rule.ProxyAuth != nil {
+				auth := *rule.ProxyAuth
+				rule.ProxyAuth = &auth
+			}
+			cfg.Routes[i] = rule
+		}
+	}
+	if c.ConfidenceWeights != nil {
+		w := *c.ConfidenceWeights
+		cfg.ConfidenceWeights = &w
+	}
+	if c.ProxyAuth != nil {
+		auth := *c.ProxyAuth
+		cfg.ProxyAuth = &auth
+	}
+	if c.TLSConfig != nil {
+		cfg.TLSConfig = c.TLSConfig.Clone()
+	}
+	if c.ServerTLSConfigs != nil {
+		cfg.ServerTLSConfigs = make(map[string]*tls.Config, len(c.ServerTLSConfigs))
+		for server, tlsConfig := range c.ServerTLSConfigs {
+			cfg.ServerTLSConfigs[server] = tlsConfig.Clone()
+		}
+	}
+	return &cfg
+}
+
+// Servers 返回客户端配置的服务器列表副本
+func (c *Client) Servers() []string {
+	return append([]string(nil), c.config.Servers...)
+}
+
+// Timeout 返回单次尝试的超时时间
+func (c *Client) Timeout() time.Duration {
+	return c.config.Timeout
+}
+
+// Retries 返回重试次数
+func (c *Client) Retries() int {
+	return c.config.Retries
+}
+
+// Protocol 返回主协议
+func (c *Client) Protocol() Protocol {
+	return c.config.Protocol
+}
+
+// newClientID 生成客户端短ID
+func newClientID() string {
+	var b [4]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "godns"
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// logf 输出带客户端名称的日志
+func (c *Client) logf(format string, args ...interface{}) {
+	log.Printf("godns[%s]: "+format, append([]interface{}{c.name}, args...)...)
+}
+
+// logConfigOnce 启用调试日志时，在首次查询前输出配置快照
+func (c *Client) logConfigOnce() {
+	if !c.config.Debug {
+		return
+	}
+	c.debugOnce.Do(func() {
+		c.logf("config: %s", c.ConfigSnapshot())
+	})
+}
+
+// Close 立即关闭客户端：拒绝新查询并取消进行中的查询，释放持有的连接，启用缓存持久化时写入最终快照
+// 需要等待进行中的查询时使用 Shutdown
+func (c *Client) Close() error {
+	c.drain.close(c)
+	c.drain.abort(c)
+	c.sched.close()
+	return c.release()
+}
+
+// WithName 设置客户端名称，会附加到错误信息和日志中
+func WithName(name string) Option {
+	return func(c *Config) {
+		c.Name = name
+	}
 }
 
 // 配置选项函数
@@ -138,23 +492,105 @@ func WithRetries(retries int) Option {
 	}
 }
 
+// WithProtocol 设置DNS协议
+// 仅当未通过 WithServers 显式指定服务器时，才会安装该协议的默认服务器列表，
+// 因此 WithServers 与 WithProtocol 的先后顺序不影响结果
 func WithProtocol(protocol Protocol) Option {
 	return func(c *Config) {
 		c.Protocol = protocol
+		if c.serversExplicit {
+			return
+		}
 		switch protocol {
 		case DoH:
 			c.Servers = DoHServers
 		case DoT:
 			c.Servers = DoTServers
+		case DoQ:
+			c.Servers = DoQServers
 		default:
 			c.Servers = UDPServers
 		}
 	}
 }
 
+// WithServers 设置DNS服务器列表，优先级高于 WithProtocol 的默认服务器
 func WithServers(servers ...string) Option {
 	return func(c *Config) {
 		c.Servers = servers
+		c.serversExplicit = true
+	}
+}
+
+// WithTaggedServers 按标签分组设置DNS服务器，例如 internal/public/filtering
+// 带标签的服务器会追加到服务器列表中，查询结果会携带服务器所属标签
+func WithTaggedServers(tagged map[string][]string) Option {
+	return func(c *Config) {
+		if !c.serversExplicit {
+			c.Servers = nil
+		}
+		if c.TaggedServers == nil {
+			c.TaggedServers = make(map[string][]string)
+		}
+		seen := make(map[string]bool, len(c.Servers))
+		for _, server := range c.Servers {
+			seen[server] = true
+		}
+		for _, tag := range sortedTags(tagged) {
+			c.TaggedServers[tag] = append(c.TaggedServers[tag], tagged[tag]...)
+			for _, server := range tagged[tag] {
+				if !seen[server] {
+					seen[server] = true
+					c.Servers = append(c.Servers, server)
+				}
+			}
+		}
+		c.serversExplicit = true
+	}
+}
+
+// sortedTags 返回按字典序排列的标签，保证服务器顺序稳定
+func sortedTags(tagged map[string][]string) []string {
+	tags := make([]string, 0, len(tagged))
+	for tag := range tagged {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// WithMaxForwarders 设置 Query 单次最多咨询的服务器数量
+// 前一个服务器（含重试）失败后依次转向下一个服务器，n <= 0 表示使用全部服务器；
+// 默认只查询第一个服务器
+func WithMaxForwarders(n int) Option {
+	return func(c *Config) {
+		c.MaxForwarders = n
+	}
+}
+
+// WithPartialResults MultiQuery 在 context 截止时不再等待，直接返回已收到的结果，
+// 未应答的服务器以超时占位结果（Synthetic）表示，MultiQueryResult.Partial 置为 true
+func WithPartialResults() Option {
+	return func(c *Config) {
+		c.PartialResults = true
+	}
+}
+
+// WithQuorum MultiQuery 在任意 k 个服务器返回相同的规范化应答（忽略TTL与顺序）后立即返回，
+// 并取消其余查询；始终无法达成一致时退化为等待所有服务器
+// 该模式下各服务器的查询不读写缓存，否则缓存的应答会被计为多个服务器的一致
+func WithQuorum(k int) Option {
+	return func(c *Config) {
+		c.Quorum = k
+	}
+}
+
+// WithTTLClamp 将返回记录的TTL限制在 [min, max] 范围内，max 为0表示不设上限
+// 可避免服务器返回 TTL=0 导致频繁重查或返回过长的TTL，缓存也使用限制后的TTL
+func WithTTLClamp(min, max uint32) Option {
+	return func(c *Config) {
+		c.TTLMin = min
+		c.TTLMax = max
 	}
 }
 
@@ -174,6 +610,9 @@ func WithHTTPProxy(addr string, auth *ProxyAuth) Option {
 	}
 }
 
+// WithTLSConfig 设置DoT/DoH使用的TLS配置
+// 客户端构建时会 Clone 传入的配置（包括服务器级和混合竞速的覆盖配置），之后只使用副本，
+// 从不修改调用方的对象，因此同一个 *tls.Config 可以安全地同时用于多个客户端和调用方自己的连接
 func WithTLSConfig(tlsConfig *tls.Config) Option {
 	return func(c *Config) {
 		c.TLSConfig = tlsConfig
@@ -186,14 +625,144 @@ func WithHTTPClient(client *http.Client) Option {
 	}
 }
 
+// WithDoHMethod 设置DoH请求方法（RFC 8484 §4.1），不区分大小写：
+// "GET"（默认）将消息以 base64url 编码放在 dns 查询参数中，便于HTTP缓存；
+// "POST" 将消息原样作为请求体发送，不受URL长度限制，适合携带较多EDNS选项的大消息
+// 其他取值在查询时返回错误
+func WithDoHMethod(method string) Option {
+	return func(c *Config) {
+		c.DoHMethod = strings.ToUpper(method)
+	}
+}
+
+// WithPipelining 在单个TCP/DoT连接上管道化发送多个查询，按消息ID匹配响应
+// 仅对 TCP 和 DoT 协议且未使用代理时生效
+func WithPipelining() Option {
+	return func(c *Config) {
+		c.Pipelining = true
+	}
+}
+
+// WithRequireAD 要求响应的AD位为1，拒绝未经递归服务器DNSSEC验证的应答
+// godns 本身不做DNSSEC验证，仅信任所配置的验证型递归服务器
+func WithRequireAD() Option {
+	return func(c *Config) {
+		c.RequireAD = true
+	}
+}
+
+// WithSourcePortRandomization 每个UDP查询都从随机选取的新源端口发出，
+// 提升抗DNS欺骗能力；连接复用/管道化不会作用于UDP查询
+func WithSourcePortRandomization() Option {
+	return func(c *Config) {
+		c.SourcePortRandomization = true
+	}
+}
+
+// WithDebug 启用调试日志，首次查询时通过标准日志输出一次 ConfigSnapshot，之后每次查询输出解析路径的摘要
+func WithDebug() Option {
+	return func(c *Config) {
+		c.Debug = true
+	}
+}
+
+// WithSentQuery 在 QueryResult.SentQuery 中记录实际发送的查询消息，
+// 包含所有EDNS选项等对查询的修改，便于审计
+func WithSentQuery() Option {
+	return func(c *Config) {
+		c.RecordSentQuery = true
+	}
+}
+
+// WithOnRequest 设置查询发送前的回调，msg 为最终发往服务器的消息
+// 回调不应修改 msg
+func WithOnRequest(fn func(ctx context.Context, server string, msg *dns.Msg)) Option {
+	return func(c *Config) {
+		c.OnRequest = fn
+	}
+}
+
+// WithResponseInterceptor 设置应答拦截器，用于故障注入测试（延迟、改写应答码、丢弃或打乱记录等）
+// 拦截器在网络应答通过问题部分校验之后、响应码分类、转换为记录和写入缓存之前调用，对所有传输路径生效；
+// 改写的响应码（如 SERVFAIL、REFUSED）与服务器实际发送的一样产生对应的类型化错误；
+// 返回的消息替代原应答，返回错误则本次查询以该错误失败（可包装 ErrServFail 等哨兵值），
+// 返回 nil, nil 表示不做修改。缓存命中的应答不会再次经过拦截器
+func WithResponseInterceptor(fn func(ctx context.Context, msg *dns.Msg) (*dns.Msg, error)) Option {
+	return func(c *Config) {
+		c.ResponseInterceptor = fn
+	}
+}
+
 // 在 Config 结构体中，Retries 字段已存在，无需修改
 
+// attemptDeadline 计算单次尝试的截止时间：min(now+Timeout, ctx.Deadline())
+func (c *Client) attemptDeadline(ctx context.Context) time.Time {
+	var deadline time.Time
+	timeout := c.config.Timeout
+	if t, ok := timeoutFromContext(ctx); ok && t > 0 {
+		timeout = t
+	}
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	if ctxDeadline, ok := ctx.Deadline(); ok && (deadline.IsZero() || ctxDeadline.Before(deadline)) {
+		deadline = ctxDeadline
+	}
+	return deadline
+}
+
+// attemptContext 为单次尝试派生独立截止时间的 context，
+// 避免前一次尝试的慢服务器耗尽后续尝试的时间预算
+func (c *Client) attemptContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	deadline := c.attemptDeadline(ctx)
+	if deadline.IsZero() {
+		return context.WithCancel(ctx)
+	}
+	return context.WithDeadline(ctx, deadline)
+}
+
 // 添加重试包装器函数
-func (c *Client) withRetry(ctx context.Context, operation func() (*dns.Msg, error)) (*dns.Msg, error) {
+// 每次尝试都会获得独立计算的截止时间，并按重试策略从 servers 中选择服务器，记录到解析路径中
+func (c *Client) withRetry(ctx context.Context, protocol Protocol, servers []string, operation func(ctx context.Context, server string) (*dns.Msg, error)) (*dns.Msg, error) {
 	var lastErr error
+	rec := recorderFrom(ctx)
+	attempts, pick := c.attemptPlan(len(servers))
+
+	for attempt := 0; attempt < attempts; attempt++ {
+		server := servers[pick(attempt)]
+		// 排队发生在单次尝试的超时计时之前，不占用尝试的时间预算
+		release, queueWait, err := c.acquireSlot(ctx)
+		if err != nil {
+			return nil, err
+		}
+		attemptCtx, cancel := c.attemptContext(ctx)
+		attemptCtx, info := withAttemptInfo(attemptCtx)
+		start := time.Now()
+		result, err := operation(attemptCtx, server)
+		cancel()
+		release()
+
+		a := Attempt{
+			Server:        server,
+			Protocol:      protocol,
+			Proxy:         c.config.ProxyType,
+			Duration:      time.Since(start),
+			QueueWait:     queueWait,
+			RespondedBy:   info.peer(),
+			Transmissions: info.sent(),
+		}
+		a.RTT = info.measured()
+		if a.RTT == 0 {
+			a.RTT = a.Duration
+		}
+		if protocol.encrypted() && c.config.Transport == nil {
+			a.TLS = describeTLS(c.tlsConfigFor(server))
+		}
+		if err != nil {
+			a.Error = err.Error()
+		}
+		rec.record(a)
 
-	for attempt := 0; attempt <= c.config.Retries; attempt++ {
-		result, err := operation()
 		if err == nil {
 			return result, nil
 		}
@@ -201,7 +770,7 @@ func (c *Client) withRetry(ctx context.Context, operation func() (*dns.Msg, erro
 		lastErr = err
 
 		// 最后一次尝试失败，直接返回
-		if attempt == c.config.Retries {
+		if attempt == attempts-1 {
 			break
 		}
 
@@ -0,0 +1,168 @@
+package godns_test
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"runtime"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+	"github.com/zan8in/godns/internal/testserver"
+)
+
+func TestProtocolAndServerOptions(t *testing.T) {
+	custom := []string{"192.0.2.53:53", "198.51.100.53:53"}
+	tests := []struct {
+		name     string
+		client   func() *godns.Client
+		protocol godns.Protocol
+		servers  []string
+	}{
+		{"NewDefault", godns.NewDefault, godns.UDP, godns.UDPServers},
+		{"New without options", func() *godns.Client { return godns.New() }, godns.UDP, godns.UDPServers},
+		{"WithServers alone", func() *godns.Client {
+			return godns.New(godns.WithServers(custom...))
+		}, godns.UDP, custom},
+		{"WithProtocol DoH alone", func() *godns.Client {
+			return godns.New(godns.WithProtocol(godns.DoH))
+		}, godns.DoH, godns.DoHServers},
+		{"WithProtocol DoT alone", func() *godns.Client {
+			return godns.New(godns.WithProtocol(godns.DoT))
+		}, godns.DoT, godns.DoTServers},
+		{"WithProtocol DoQ alone", func() *godns.Client {
+			return godns.New(godns.WithProtocol(godns.DoQ))
+		}, godns.DoQ, godns.DoQServers},
+		{"WithProtocol TCP alone", func() *godns.Client {
+			return godns.New(godns.WithProtocol(godns.TCP))
+		}, godns.TCP, godns.UDPServers},
+		{"WithServers before WithProtocol", func() *godns.Client {
+			return godns.New(godns.WithServers(custom...), godns.WithProtocol(godns.DoT))
+		}, godns.DoT, custom},
+		{"WithServers after WithProtocol", func() *godns.Client {
+			return godns.New(godns.WithProtocol(godns.DoT), godns.WithServers(custom...))
+		}, godns.DoT, custom},
+		{"WithProtocol twice", func() *godns.Client {
+			return godns.New(godns.WithProtocol(godns.DoT), godns.WithProtocol(godns.DoH))
+		}, godns.DoH, godns.DoHServers},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := tt.client()
+			defer c.Close()
+			if got := c.ConfigSnapshot().Protocol; got != tt.protocol {
+				t.Errorf("protocol = %s, want %s", got, tt.protocol)
+			}
+			if got := c.Servers(); !slices.Equal(got, tt.servers) {
+				t.Errorf("servers = %v, want %v", got, tt.servers)
+			}
+		})
+	}
+}
+
+// TestConcurrentClientAccess 在 -race 下从多个 goroutine 并发查询和读取配置，同时替换路由并修改传给 New 的参数
+func TestConcurrentClientAccess(t *testing.T) {
+	s := startServer(t)
+	s.Answer("www.example.com", dns.TypeA, "www.example.com. 60 IN A 192.0.2.1")
+	s.Answer("host.corp.example", dns.TypeA, "host.corp.example. 60 IN A 10.0.0.1")
+
+	servers := []string{s.UDPAddr}
+	tlsConfig := &tls.Config{ServerName: "dns.example"}
+	c := godns.New(
+		godns.WithServers(servers...),
+		godns.WithTLSConfig(tlsConfig),
+		godns.WithCache(time.Minute),
+		godns.WithErrorCaching(time.Second),
+		godns.WithMaxOutstanding(8),
+	)
+	defer c.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var mutators sync.WaitGroup
+	mutators.Add(1)
+	go func() {
+		defer mutators.Done()
+		for i := 0; ctx.Err() == nil; i++ {
+			c.SetRoutes(godns.RouteRule{Name: fmt.Sprintf("corp-%d", i), Suffix: "corp.example", Servers: []string{s.UDPAddr}})
+			// 构建后修改传入的参数不影响客户端
+			servers[0] = "192.0.2.1:53"
+			tlsConfig.ServerName = fmt.Sprintf("changed-%d.example", i)
+			runtime.Gosched()
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for g := range 32 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 20 {
+				switch (g + i) % 5 {
+				case 0:
+					if _, err := c.QueryA(ctx, "www.example.com"); err != nil {
+						t.Error(err)
+					}
+				case 1:
+					res, err := c.QueryA(ctx, "host.corp.example")
+					if err != nil || len(res.Records) != 1 {
+						t.Errorf("routed query = %+v, %v", res, err)
+					}
+				case 2:
+					if _, err := c.MultiQueryA(ctx, "www.example.com"); err != nil {
+						t.Error(err)
+					}
+				case 3:
+					if got := c.Servers(); len(got) != 1 || got[0] != s.UDPAddr {
+						t.Errorf("servers = %v", got)
+					}
+				case 4:
+					snap := c.ConfigSnapshot()
+					_ = c.OutstandingStats()
+					_ = c.ErrorCacheStats()
+					_ = c.ServerHealth()
+					if snap.Timeout == "" {
+						t.Error("empty snapshot")
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	cancel()
+	mutators.Wait()
+}
+
+func BenchmarkParallelQuery(b *testing.B) {
+	s, err := testserver.Start()
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer s.Close()
+	s.Answer("www.example.com", dns.TypeA, "www.example.com. 60 IN A 192.0.2.1")
+
+	for _, bm := range []struct {
+		name string
+		opts []godns.Option
+	}{
+		{"network", nil},
+		{"cached", []godns.Option{godns.WithCache(time.Minute)}},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			c := godns.New(append([]godns.Option{godns.WithServers(s.UDPAddr)}, bm.opts...)...)
+			defer c.Close()
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if _, err := c.QueryA(context.Background(), "www.example.com"); err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+		})
+	}
+}
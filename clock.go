@@ -0,0 +1,34 @@
+package godns
+
+import "time"
+
+// Clock 时间源抽象，便于在测试中注入可控时钟
+// 时钟若同时实现 After(time.Duration) <-chan time.Time，交错扇出的波次间隔等定时器也由其驱动
+type Clock interface {
+	Now() time.Time
+}
+
+// afterClock 可驱动定时器的时钟
+type afterClock interface {
+	After(d time.Duration) <-chan time.Time
+}
+
+// systemClock 使用系统时间的默认时钟
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// after 返回 d 之后触发的通道，时钟实现了 After 时使用时钟的定时器
+func (c *Client) after(d time.Duration) <-chan time.Time {
+	if clock, ok := c.config.Clock.(afterClock); ok {
+		return clock.After(d)
+	}
+	return time.After(d)
+}
+
+// WithClock 设置客户端使用的时钟，影响结果时间戳及缓存过期判断
+func WithClock(clock Clock) Option {
+	return func(c *Config) {
+		c.Clock = clock
+	}
+}
@@ -0,0 +1,129 @@
+package godns_test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+)
+
+// TestClockTimestamps 结果时间戳全部来自注入的时钟：网络应答记录 QueriedAt 和按最小TTL计算的 ValidUntil，
+// 缓存应答另外记录最初从网络获得的时间，过期判断同样按注入的时钟进行
+func TestClockTimestamps(t *testing.T) {
+	s := startServer(t)
+	s.Answer("example.test", dns.TypeA,
+		"example.test. 300 IN A 192.0.2.1",
+		"example.test. 60 IN A 192.0.2.2",
+	)
+	clock := newFakeClock()
+	t0 := clock.Now()
+	c := godns.New(godns.WithServers(s.UDPAddr), godns.WithClock(clock), godns.WithCache(time.Hour))
+	defer c.Close()
+	ctx := context.Background()
+
+	res, err := c.QueryA(ctx, "example.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.QueriedAt.Equal(t0) || !res.OriginallyQueriedAt.IsZero() || !res.ValidUntil.Equal(t0.Add(60*time.Second)) {
+		t.Errorf("network answer: QueriedAt %v, OriginallyQueriedAt %v, ValidUntil %v", res.QueriedAt, res.OriginallyQueriedAt, res.ValidUntil)
+	}
+
+	clock.Advance(40 * time.Second)
+	res, err = c.QueryA(ctx, "example.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Path.Source != godns.SourceCache {
+		t.Fatalf("source = %s, want the cache", res.Path.Source)
+	}
+	if !res.QueriedAt.Equal(t0.Add(40*time.Second)) || !res.OriginallyQueriedAt.Equal(t0) || !res.ValidUntil.Equal(t0.Add(60*time.Second)) {
+		t.Errorf("cached answer: QueriedAt %v, OriginallyQueriedAt %v, ValidUntil %v; want %v, %v, %v",
+			res.QueriedAt, res.OriginallyQueriedAt, res.ValidUntil, t0.Add(40*time.Second), t0, t0.Add(60*time.Second))
+	}
+
+	// 越过最小TTL后缓存过期，无需真实等待
+	clock.Advance(21 * time.Second)
+	res, err = c.QueryA(ctx, "example.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Path.Source != godns.SourceNetwork || !res.QueriedAt.Equal(t0.Add(61*time.Second)) || !res.OriginallyQueriedAt.IsZero() {
+		t.Errorf("after expiry: source %s, QueriedAt %v, OriginallyQueriedAt %v", res.Path.Source, res.QueriedAt, res.OriginallyQueriedAt)
+	}
+	if n := len(s.Queries()); n != 2 {
+		t.Errorf("server saw %d queries, want 2", n)
+	}
+}
+
+// TestClockMultiQueryTimestamps 多服务器查询的开始、结束时间和各服务器的 QueriedAt 来自注入的时钟
+func TestClockMultiQueryTimestamps(t *testing.T) {
+	a, b := startServer(t), startServer(t)
+	a.Answer("example.test", dns.TypeA, "example.test. 300 IN A 192.0.2.1")
+	b.Answer("example.test", dns.TypeA, "example.test. 300 IN A 192.0.2.1")
+	clock := newFakeClock()
+	c := godns.New(godns.WithServers(a.UDPAddr, b.UDPAddr), godns.WithClock(clock))
+	defer c.Close()
+
+	res, err := c.MultiQuery(context.Background(), "example.test", dns.TypeA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t0 := clock.Now()
+	if !res.StartedAt.Equal(t0) || !res.FinishedAt.Equal(t0) {
+		t.Errorf("StartedAt %v, FinishedAt %v, want %v", res.StartedAt, res.FinishedAt, t0)
+	}
+	for _, r := range res.Results {
+		if !r.QueriedAt.Equal(t0) || !r.ValidUntil.Equal(t0.Add(300*time.Second)) {
+			t.Errorf("%s: QueriedAt %v, ValidUntil %v", r.Server, r.QueriedAt, r.ValidUntil)
+		}
+	}
+}
+
+// TestTimestampsJSON 时间戳在JSON中以带亚秒精度的 RFC 3339 格式输出，反序列化后不丢失精度
+func TestTimestampsJSON(t *testing.T) {
+	s := startServer(t)
+	s.Answer("example.test", dns.TypeA, "example.test. 300 IN A 192.0.2.1")
+	clock := newFakeClock()
+	clock.Advance(123456789 * time.Nanosecond)
+	c := godns.New(godns.WithServers(s.UDPAddr), godns.WithClock(clock))
+	defer c.Close()
+
+	res, err := c.QueryA(context.Background(), "example.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`"QueriedAt":"2024-01-01T00:00:00.123456789Z"`,
+		`"ValidUntil":"2024-01-01T00:05:00.123456789Z"`,
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("JSON %s does not contain %s", data, want)
+		}
+	}
+	var decoded godns.QueryResult
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.QueriedAt.Equal(res.QueriedAt) || !decoded.ValidUntil.Equal(res.ValidUntil) {
+		t.Errorf("after round trip QueriedAt %v, ValidUntil %v; want %v, %v", decoded.QueriedAt, decoded.ValidUntil, res.QueriedAt, res.ValidUntil)
+	}
+	var raw map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range []string{"QueriedAt", "ValidUntil"} {
+		text, _ := raw[field].(string)
+		if _, err := time.Parse(time.RFC3339Nano, text); err != nil {
+			t.Errorf("%s = %q is not RFC 3339: %v", field, text, err)
+		}
+	}
+}
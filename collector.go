@@ -0,0 +1,82 @@
+package godns
+
+import (
+	"context"
+	"net/netip"
+	"sync"
+	"time"
+)
+
+// ipSetPool 复用 AllIPs 去重使用的集合
+var ipSetPool = sync.Pool{
+	New: func() any { return make(map[netip.Addr]bool) },
+}
+
+// ResultCollector 可复用的 MultiQuery 结果收集器
+// 在循环中反复执行大规模 MultiQuery 时，复用同一个收集器可以避免每次调用重新分配结果切片和去重集合
+// 收集器不能被并发使用；MultiQueryInto 返回的结果归收集器所有，下一次使用或 Reset 后失效
+type ResultCollector struct {
+	result MultiQueryResult
+	ipSet  map[netip.Addr]bool
+	pooled bool // 去重集合取自 ipSetPool，用完需要归还
+}
+
+// NewResultCollector 创建结果收集器
+func NewResultCollector() *ResultCollector {
+	return &ResultCollector{ipSet: make(map[netip.Addr]bool)}
+}
+
+// Reset 清空收集器中的结果，保留已分配的空间；同时释放对上一次结果中记录的引用
+func (rc *ResultCollector) Reset() {
+	results := rc.result.Results
+	clear(results)
+	ips := rc.result.AllIPs
+	clear(ips)
+	rc.result = MultiQueryResult{Results: results[:0], AllIPs: ips[:0]}
+	clear(rc.ipSet)
+}
+
+// Result 返回收集器当前的结果
+func (rc *ResultCollector) Result() *MultiQueryResult {
+	return &rc.result
+}
+
+// MultiQueryInto 与 MultiQuery 相同，但将结果收集到 rc 中，调用前会先 Reset
+func (c *Client) MultiQueryInto(ctx context.Context, rc *ResultCollector, domain string, qtype uint16) (*MultiQueryResult, error) {
+	rc.Reset()
+	return c.multiQueryInto(ctx, rc, domain, qtype)
+}
+
+// newPooledCollector 创建一次性的收集器，结果交给调用方，去重集合来自 ipSetPool
+func newPooledCollector() *ResultCollector {
+	return &ResultCollector{ipSet: ipSetPool.Get().(map[netip.Addr]bool), pooled: true}
+}
+
+// release 归还一次性收集器的去重集合
+func (rc *ResultCollector) release() {
+	if rc.pooled {
+		clear(rc.ipSet)
+		ipSetPool.Put(rc.ipSet)
+		rc.ipSet = nil
+	}
+}
+
+// begin 开始一次收集
+func (rc *ResultCollector) begin(domain string, qtype uint16, servers int, now time.Time) *MultiQueryResult {
+	r := &rc.result
+	r.Domain = domain
+	r.Type = qtype
+	r.StartedAt = now
+	if r.Results == nil {
+		r.Results = make([]QueryResult, 0, servers)
+	}
+	if r.AllIPs == nil {
+		r.AllIPs = make([]string, 0)
+	}
+	return r
+}
+
+// add 追加单个服务器的结果
+func (rc *ResultCollector) add(res QueryResult) {
+	rc.result.addResult(res, rc.ipSet)
+}
@@ -0,0 +1,93 @@
+package godns_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+)
+
+// TestResultCollectorReset 复用收集器的连续扫描之间不会残留上一次的结果
+func TestResultCollectorReset(t *testing.T) {
+	s1, s2 := startServer(t), startServer(t)
+	s1.Answer("a.test", dns.TypeA, "a.test. 60 IN A 192.0.2.1")
+	s2.Answer("a.test", dns.TypeA, "a.test. 60 IN A 192.0.2.2", "a.test. 60 IN A 192.0.2.3")
+	c := godns.New(godns.WithServers(s1.UDPAddr, s2.UDPAddr), godns.WithRetries(0))
+	defer c.Close()
+	ctx := context.Background()
+	rc := godns.NewResultCollector()
+
+	first, err := c.MultiQueryInto(ctx, rc, "a.test", dns.TypeA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sorted(first.AllIPs); !slices.Equal(got, []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"}) {
+		t.Fatalf("first sweep AllIPs = %v", got)
+	}
+	firstResults := first.Results
+
+	// b.test 未编排，两台服务器都返回 NXDOMAIN
+	second, err := c.MultiQueryInto(ctx, rc, "b.test", dns.TypeA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.Domain != "b.test" || len(second.Results) != 2 || len(second.AllIPs) != 0 {
+		t.Fatalf("second sweep = domain %q, %d results, AllIPs %v", second.Domain, len(second.Results), second.AllIPs)
+	}
+	for _, r := range second.Results {
+		if r.Domain != "b.test" || len(r.Records) != 0 || !r.IsNXDOMAIN() {
+			t.Errorf("second sweep result = %+v", r)
+		}
+	}
+
+	// 再次扫描 a.test 时去重集合已清空，地址重新出现
+	third, err := c.MultiQueryInto(ctx, rc, "a.test", dns.TypeA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sorted(third.AllIPs); len(got) != 3 {
+		t.Errorf("third sweep AllIPs = %v", got)
+	}
+
+	rc.Reset()
+	if r := rc.Result(); r.Domain != "" || len(r.Results) != 0 || len(r.AllIPs) != 0 || r.Partial || r.QuorumReached {
+		t.Errorf("after Reset: %+v", r)
+	}
+	// Reset 释放对上一次结果中记录的引用
+	for i, r := range firstResults {
+		if r.Domain != "" || r.Records != nil {
+			t.Errorf("backing result %d still holds %q with %d records", i, r.Domain, len(r.Records))
+		}
+	}
+}
+
+// TestPooledCollectorIsolation 内部池化的去重集合在调用之间被清空
+func TestPooledCollectorIsolation(t *testing.T) {
+	s := startServer(t)
+	s.Answer("a.test", dns.TypeA, "a.test. 60 IN A 192.0.2.1")
+	c := godns.New(godns.WithServers(s.UDPAddr), godns.WithRetries(0))
+	defer c.Close()
+
+	for range 3 {
+		a, err := c.MultiQueryA(context.Background(), "a.test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(a.AllIPs, []string{"192.0.2.1"}) {
+			t.Errorf("a.test AllIPs = %v", a.AllIPs)
+		}
+		b, err := c.MultiQueryA(context.Background(), "b.test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(b.AllIPs) != 0 {
+			t.Errorf("b.test AllIPs = %v", b.AllIPs)
+		}
+		// 前一次结果不被后续调用修改
+		if !slices.Equal(a.AllIPs, []string{"192.0.2.1"}) || a.Domain != "a.test" {
+			t.Errorf("earlier result changed: %+v", a)
+		}
+	}
+}
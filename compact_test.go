@@ -0,0 +1,54 @@
+package godns_test
+
+import (
+	"fmt"
+	"runtime"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+)
+
+// syntheticResult 构造海量解析场景中的一条结果：名称和服务器集合有限，每条结果的字符串都是独立分配的
+func syntheticResult(i int) *godns.QueryResult {
+	domain := fmt.Sprintf("host%d.example.com", i%10000)
+	return &godns.QueryResult{
+		Domain: domain,
+		Type:   dns.TypeA,
+		Server: fmt.Sprintf("192.0.2.%d:53", i%8),
+		Records: []godns.Record{
+			godns.NewValueRecord(domain+".", dns.TypeA, 300, fmt.Sprintf("10.%d.%d.1", i%256, i/256%256)),
+			godns.NewValueRecord(domain+".", dns.TypeA, 300, fmt.Sprintf("10.%d.%d.2", i%256, i/256%256)),
+		},
+	}
+}
+
+// BenchmarkCompactResults 比较保存 100 万条结果时普通形式与紧凑形式的堆内存占用，以 B/result 报告
+func BenchmarkCompactResults(b *testing.B) {
+	const n = 1_000_000
+	for _, bm := range []struct {
+		name string
+		keep func(c *godns.Client, i int) any
+	}{
+		{"QueryResult", func(_ *godns.Client, i int) any { return syntheticResult(i) }},
+		{"CompactResult", func(c *godns.Client, i int) any { return c.Compact(syntheticResult(i)) }},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			for b.Loop() {
+				c := godns.New()
+				var before, after runtime.MemStats
+				runtime.GC()
+				runtime.ReadMemStats(&before)
+				kept := make([]any, n)
+				for i := range kept {
+					kept[i] = bm.keep(c, i)
+				}
+				runtime.GC()
+				runtime.ReadMemStats(&after)
+				b.ReportMetric(float64(int64(after.HeapAlloc)-int64(before.HeapAlloc))/n, "B/result")
+				runtime.KeepAlive(kept)
+				c.Close()
+			}
+		})
+	}
+}
@@ -0,0 +1,214 @@
+package godns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+// ConfidenceWeights 置信度评分权重
+type ConfidenceWeights struct {
+	Plaintext     float64 // UDP/TCP 应答的权重
+	Encrypted     float64 // DoT/DoH/DoQ 应答的权重
+	SameProvider  float64 // 同一提供方的后续一致应答的权重系数，取值 0~1
+	Corroboration float64 // 获得满置信度所需的最低得分，防止单一来源得到满分
+}
+
+// DefaultConfidenceWeights 默认的置信度评分权重
+var DefaultConfidenceWeights = ConfidenceWeights{
+	Plaintext:     1,
+	Encrypted:     2,
+	SameProvider:  0.25,
+	Corroboration: 3,
+}
+
+// WithConfidenceWeights 设置 ResolveWithConfidence 的评分权重
+func WithConfidenceWeights(w ConfidenceWeights) Option {
+	return func(c *Config) {
+		c.ConfidenceWeights = &w
+	}
+}
+
+// ConfidenceSource 参与评分的一个应答来源
+type ConfidenceSource struct {
+	Server   string
+	Protocol Protocol
+	Provider string // 提供方，取服务器的主机部分
+}
+
+// AnswerSet 一组一致的应答及其来源
+type AnswerSet struct {
+	Values  []string // 规范化后的记录值
+	Score   float64
+	Sources []ConfidenceSource
+}
+
+// ConfidenceResult 带置信度的解析结果
+type ConfidenceResult struct {
+	Domain     string
+	IPs        []string    // 胜出应答集合中的地址
+	Confidence float64     // 0~1，综合胜出集合的得分占比和来源的佐证程度
+	Winner     AnswerSet   // 胜出的应答集合
+	Dissent    []AnswerSet // 其他应答集合，按得分降序
+	Failed     []QueryResult
+}
+
+// ResolveWithConfidence 通过已配置的 UDP/TCP、DoT、DoH 来源查询域名的A记录，
+// 按一致来源的数量和多样性为每个不同的应答集合评分（加密传输权重更高，
+// 同一提供方的重复一致计分更少），返回得分最高的集合、置信度及不一致的集合
+// 各来源的查询不读写缓存
+func (c *Client) ResolveWithConfidence(ctx context.Context, domain string) (*ConfidenceResult, error) {
+	weights := DefaultConfidenceWeights
+	if c.config.ConfidenceWeights != nil {
+		weights = *c.config.ConfidenceWeights
+	}
+
+	specs := c.confidenceSources()
+	if len(specs) == 0 {
+		return nil, ErrNoServers
+	}
+
+	// 每个来源只使用自身的协议，应答才能归属到对应的传输方式；
+	// 缓存按协议而不是服务器区分，命中缓存会把一个来源的应答计为另一个来源的佐证，因此每个来源都查询网络
+	ctx = ContextWithNoCache(withoutFallback(ctx))
+	results := make([]QueryResult, len(specs))
+	var wg sync.WaitGroup
+	for i, spec := range specs {
+		wg.Add(1)
+		go func(i int, spec ServerSpec) {
+			defer wg.Done()
+			res, err := c.queryServer(ctx, domain, dns.TypeA, c.specInfo(spec))
+			if res == nil {
+				res = &QueryResult{Domain: domain, Type: dns.TypeA, Server: spec.Address, Error: c.newErrorInfo(err, spec.Address, 0)}
+			}
+			results[i] = *res
+		}(i, spec)
+	}
+	wg.Wait()
+
+	result := scoreAnswers(domain, specs, results, weights)
+	if len(result.Winner.Sources) == 0 {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		return result, fmt.Errorf("all sources failed to resolve %s", domain)
+	}
+	return result, nil
+}
+
+// confidenceSources 返回参与评分的来源：优先使用混合竞速配置，
+// 否则为每个服务器组合主协议和备用协议
+func (c *Client) confidenceSources() []ServerSpec {
+	if len(c.config.MixedRace) > 0 {
+		return c.config.MixedRace
+	}
+
+	protocols := append([]Protocol{c.config.Protocol}, c.config.FallbackProtocols...)
+
+	var specs []ServerSpec
+	for _, server := range c.config.Servers {
+		info := c.serverInfo(server)
+		specs = append(specs, ServerSpec{Address: info.Address, Protocol: info.Protocol})
+		seen := map[Protocol]bool{info.Protocol: true}
+		for _, p := range protocols {
+			if !seen[p] {
+				seen[p] = true
+				specs = append(specs, ServerSpec{
+					Address:  serverForProtocol(info.Address, info.Protocol, p),
+					Protocol: p,
+				})
+			}
+		}
+	}
+	return specs
+}
+
+// scoreAnswers 按应答集合分组并评分
+func scoreAnswers(domain string, specs []ServerSpec, results []QueryResult, w ConfidenceWeights) *ConfidenceResult {
+	result := &ConfidenceResult{Domain: domain}
+
+	type group struct {
+		set       AnswerSet
+		providers map[string]bool
+		res       QueryResult
+	}
+	groups := make(map[string]*group)
+	var order []string
+	var total float64
+
+	for i, res := range results {
+		if !res.answered() {
+			result.Failed = append(result.Failed, res)
+			continue
+		}
+		key := answerSetKey(res)
+		g, ok := groups[key]
+		if !ok {
+			g = &group{providers: make(map[string]bool), res: res}
+			if key != "" {
+				g.set.Values = strings.Split(key, "\n")
+			}
+			groups[key] = g
+			order = append(order, key)
+		}
+
+		source := ConfidenceSource{
+			Server:   specs[i].Address,
+			Protocol: specs[i].Protocol,
+			Provider: providerOf(specs[i].Address),
+		}
+		weight := w.Plaintext
+		if source.Protocol.encrypted() {
+			weight = w.Encrypted
+		}
+		if g.providers[source.Provider] {
+			weight *= w.SameProvider
+		}
+		g.providers[source.Provider] = true
+		g.set.Sources = append(g.set.Sources, source)
+		g.set.Score += weight
+		total += weight
+	}
+
+	if len(order) == 0 {
+		return result
+	}
+
+	// 得分相同时以首次出现的顺序为准，保证结果稳定
+	sort.SliceStable(order, func(i, j int) bool {
+		return groups[order[i]].set.Score > groups[order[j]].set.Score
+	})
+	winner := groups[order[0]]
+	result.Winner = winner.set
+	for _, key := range order[1:] {
+		result.Dissent = append(result.Dissent, groups[key].set)
+	}
+	result.IPs = AddrStrings(winner.res.IPAddrs())
+
+	if total > 0 {
+		result.Confidence = winner.set.Score / total
+	}
+	if w.Corroboration > 0 && winner.set.Score < w.Corroboration {
+		result.Confidence *= winner.set.Score / w.Corroboration
+	}
+	return result
+}
+
+// providerOf 返回服务器地址的主机部分，用于判断是否为同一提供方
+func providerOf(server string) string {
+	if strings.HasPrefix(server, "http") {
+		if u, err := url.Parse(server); err == nil {
+			return strings.ToLower(u.Hostname())
+		}
+	}
+	if host, _, err := net.SplitHostPort(server); err == nil {
+		return strings.ToLower(host)
+	}
+	return strings.ToLower(server)
+}
@@ -0,0 +1,143 @@
+package godns
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns/internal/testserver"
+)
+
+// aResult 构建包含给定地址的A记录查询结果
+func aResult(server string, addrs ...string) QueryResult {
+	res := QueryResult{Domain: "example.com", Type: dns.TypeA, Server: server}
+	for _, addr := range addrs {
+		rr, err := dns.NewRR("example.com. 60 IN A " + addr)
+		if err != nil {
+			panic(err)
+		}
+		res.Records = append(res.Records, NewRecord(rr))
+	}
+	return res
+}
+
+func failedResult(server string) QueryResult {
+	return QueryResult{Domain: "example.com", Type: dns.TypeA, Server: server, Error: &ErrorInfo{Kind: KindTimeout}}
+}
+
+func TestScoreAnswers(t *testing.T) {
+	tests := []struct {
+		name       string
+		specs      []ServerSpec
+		results    []QueryResult
+		winner     []string
+		score      float64
+		dissent    int
+		failed     int
+		confidence float64
+	}{
+		{
+			name:       "all agree across providers and transports",
+			specs:      []ServerSpec{{"192.0.2.1:53", UDP, false, nil}, {"https://dns.example/dns-query", DoH, false, nil}, {"198.51.100.1:853", DoT, false, nil}},
+			results:    []QueryResult{aResult("a", "203.0.113.1"), aResult("b", "203.0.113.1"), aResult("c", "203.0.113.1")},
+			winner:     []string{"203.0.113.1"},
+			score:      5,
+			confidence: 1,
+		},
+		{
+			// 三个来源各不相同：加密来源得分最高，但只占总分的一部分且没有达到佐证阈值
+			name:       "everything disagrees",
+			specs:      []ServerSpec{{"192.0.2.1:53", UDP, false, nil}, {"198.51.100.1:853", DoT, false, nil}, {"203.0.113.9:53", TCP, false, nil}},
+			results:    []QueryResult{aResult("a", "203.0.113.1"), aResult("b", "203.0.113.2"), aResult("c", "203.0.113.3")},
+			winner:     []string{"203.0.113.2"},
+			score:      2,
+			dissent:    2,
+			confidence: 2.0 / 4 * 2 / 3,
+		},
+		{
+			// 只有一种传输可用：单个明文来源占全部得分，但受佐证阈值限制
+			name:       "only one transport available",
+			specs:      []ServerSpec{{"192.0.2.1:53", UDP, false, nil}, {"192.0.2.1:853", DoT, false, nil}},
+			results:    []QueryResult{aResult("a", "203.0.113.1"), failedResult("b")},
+			winner:     []string{"203.0.113.1"},
+			score:      1,
+			failed:     1,
+			confidence: 1.0 / 3,
+		},
+		{
+			// 同一提供方的后续一致应答按 SameProvider 折算，不能压过两个独立提供方
+			name: "same provider discount",
+			specs: []ServerSpec{
+				{"192.0.2.1:53", UDP, false, nil}, {"192.0.2.1:853", DoT, false, nil}, {"https://192.0.2.1/dns-query", DoH, false, nil},
+				{"198.51.100.1:53", UDP, false, nil}, {"203.0.113.9:853", DoT, false, nil},
+			},
+			results: []QueryResult{
+				aResult("a", "203.0.113.1"), aResult("b", "203.0.113.1"), aResult("c", "203.0.113.1"),
+				aResult("d", "203.0.113.2"), aResult("e", "203.0.113.2"),
+			},
+			winner:     []string{"203.0.113.2"},
+			score:      3,
+			dissent:    1,
+			confidence: 3.0 / 5,
+		},
+		{
+			name:    "all failed",
+			specs:   []ServerSpec{{"192.0.2.1:53", UDP, false, nil}},
+			results: []QueryResult{failedResult("a")},
+			failed:  1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := scoreAnswers("example.com", tt.specs, tt.results, DefaultConfidenceWeights)
+			if got := res.IPs; len(got) != len(tt.winner) || (len(got) > 0 && got[0] != tt.winner[0]) {
+				t.Errorf("IPs = %v, want %v", got, tt.winner)
+			}
+			if res.Winner.Score != tt.score {
+				t.Errorf("winner score = %v, want %v", res.Winner.Score, tt.score)
+			}
+			if len(res.Dissent) != tt.dissent || len(res.Failed) != tt.failed {
+				t.Errorf("dissent = %d, failed = %d, want %d, %d", len(res.Dissent), len(res.Failed), tt.dissent, tt.failed)
+			}
+			if math.Abs(res.Confidence-tt.confidence) > 1e-9 {
+				t.Errorf("confidence = %v, want %v", res.Confidence, tt.confidence)
+			}
+		})
+	}
+}
+
+// TestResolveWithConfidenceBypassesCache 缓存按协议区分，两个UDP来源若共用缓存会把一个来源的应答计为另一个来源的佐证
+func TestResolveWithConfidenceBypassesCache(t *testing.T) {
+	var specs []ServerSpec
+	for _, addr := range []string{"203.0.113.1", "203.0.113.2"} {
+		s, err := testserver.Start()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer s.Close()
+		s.Answer("example.com", dns.TypeA, "example.com. 300 IN A "+addr)
+		specs = append(specs, ServerSpec{Address: s.UDPAddr, Protocol: UDP})
+	}
+	c := New(WithMixedRace(specs), WithCache(time.Minute), WithRetries(0))
+	defer c.Close()
+
+	for i := 0; i < 2; i++ {
+		res, err := c.ResolveWithConfidence(context.Background(), "example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res.Winner.Sources) != 1 || len(res.Dissent) != 1 {
+			t.Fatalf("round %d: winner sources = %v, dissent = %v", i+1, res.Winner.Sources, res.Dissent)
+		}
+	}
+	// 各来源查询后也不写入缓存
+	res, err := c.QueryA(ContextWithServers(context.Background(), specs[0].Address), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Path.Source != SourceNetwork {
+		t.Errorf("source = %q, want network", res.Path.Source)
+	}
+}
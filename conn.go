@@ -0,0 +1,123 @@
+package godns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// QueryOverConn 在调用方提供的已连接套接字上完成一次查询，适用于跳板机管道、
+// 由其他库建立的连接等隧道场景
+// net.PacketConn 使用数据报格式，其他连接使用TCP长度前缀格式
+// 仅应用客户端的超时、校验和记录解析，不拨号、不复用、不重试也不故障转移，
+// 连接由调用方持有，godns 不会关闭它
+func (c *Client) QueryOverConn(ctx context.Context, conn net.Conn, domain string, qtype uint16) (*QueryResult, error) {
+	ctx, rec := withPathRecorder(ctx)
+	msg := c.newQueryMsg(ctx, domain, qtype)
+
+	server := ""
+	if addr := conn.RemoteAddr(); addr != nil {
+		server = addr.String()
+	}
+	result := &QueryResult{
+		Domain: domain,
+		Type:   qtype,
+		Server: server,
+	}
+
+	if err := c.namePolicy(ctx).Check(domain); err != nil {
+		result.Error = c.newErrorInfo(err, server, 0)
+		return result, err
+	}
+
+	if c.config.OnRequest != nil {
+		c.config.OnRequest(ctx, server, msg)
+	}
+	if c.config.RecordSentQuery {
+		result.SentQuery = msg.String()
+	}
+
+	start := time.Now()
+	response, err := c.exchangeOverConn(ctx, conn, msg)
+	if err == nil {
+		response, result.Warnings, err = c.checkResponse(ctx, msg, response, server)
+	}
+
+	a := Attempt{
+		Number:      1,
+		Server:      server,
+		Protocol:    TCP,
+		Duration:    time.Since(start),
+		RespondedBy: server,
+	}
+	if _, ok := conn.(net.PacketConn); ok {
+		a.Protocol = UDP
+	}
+	if err != nil {
+		a.Error = err.Error()
+		a.RespondedBy = ""
+	}
+	rec.record(a)
+
+	result.Path = rec.snapshot()
+	result.QueriedAt = c.config.Clock.Now()
+	if err != nil {
+		result.Rcode = failedRcode(response, err)
+		result.Error = c.newErrorInfo(err, server, 1)
+		return result, err
+	}
+
+	c.fillRecords(ctx, result, response)
+	return result, nil
+}
+
+// exchangeOverConn 在连接上发送查询并读取ID匹配的应答，返回前恢复连接的截止时间
+func (c *Client) exchangeOverConn(ctx context.Context, conn net.Conn, msg *dns.Msg) (*dns.Msg, error) {
+	dnsConn := &dns.Conn{Conn: conn, UDPSize: dns.MaxMsgSize}
+	if _, ok := conn.(net.PacketConn); !ok {
+		dnsConn.Conn = newLimitConn(conn, c.config.MaxResponseBytes)
+	}
+
+	// context 取消时让阻塞的读写立即返回；结束后清除截止时间，把连接原样交还调用方
+	var mu sync.Mutex
+	finished := false
+	conn.SetDeadline(c.attemptDeadline(ctx))
+	stop := context.AfterFunc(ctx, func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if !finished {
+			conn.SetDeadline(time.Unix(1, 0))
+		}
+	})
+	defer func() {
+		stop()
+		mu.Lock()
+		defer mu.Unlock()
+		finished = true
+		conn.SetDeadline(time.Time{})
+	}()
+
+	if err := dnsConn.WriteMsg(msg); err != nil {
+		return nil, connError(ctx, fmt.Errorf("failed to write DNS message: %w", err))
+	}
+	response, err := dnsConn.ReadMsg()
+	if err != nil {
+		return nil, connError(ctx, fmt.Errorf("failed to read DNS response: %w", err))
+	}
+	if err := checkResponseID(response.Id, msg.Id); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// connError context 已结束时优先返回 context 的错误
+func connError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
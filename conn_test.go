@@ -0,0 +1,172 @@
+package godns_test
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+)
+
+// pipeResponder 在 net.Pipe 的一端按TCP长度前缀格式读取查询，交给 respond 处理；
+// respond 返回 nil 时不应答
+func pipeResponder(t *testing.T, respond func(q *dns.Msg) *dns.Msg) net.Conn {
+	t.Helper()
+	client, server := net.Pipe()
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+	go func() {
+		conn := &dns.Conn{Conn: server}
+		for {
+			q, err := conn.ReadMsg()
+			if err != nil {
+				return
+			}
+			if reply := respond(q); reply != nil {
+				if err := conn.WriteMsg(reply); err != nil {
+					return
+				}
+			}
+		}
+	}()
+	return client
+}
+
+func pipeAnswer(q *dns.Msg) *dns.Msg {
+	reply := new(dns.Msg)
+	reply.SetReply(q)
+	reply.Answer = []dns.RR{mustRR(q.Question[0].Name + " 60 IN A 192.0.2.1")}
+	return reply
+}
+
+func TestQueryOverConnPipe(t *testing.T) {
+	conn := pipeResponder(t, pipeAnswer)
+	c := godns.New()
+	defer c.Close()
+
+	// 同一连接上连续查询：godns 不关闭调用方的连接
+	for _, name := range []string{"one.test", "two.test"} {
+		res, err := c.QueryOverConn(context.Background(), conn, name, dns.TypeA)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res.Records) != 1 || res.Records[0].Value() != "192.0.2.1" {
+			t.Errorf("%s: records = %v", name, res.Records)
+		}
+		if n := len(res.Path.Attempts); n != 1 || res.Path.Attempts[0].Protocol != godns.TCP {
+			t.Errorf("%s: attempts = %+v", name, res.Path.Attempts)
+		}
+	}
+}
+
+func TestQueryOverConnErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []godns.Option
+		respond func(q *dns.Msg) *dns.Msg
+		raw     []byte // 直接写回的原始数据，用于构造超大长度前缀
+		check   func(err error, info *godns.ErrorInfo) bool
+	}{
+		{"wrong-id", nil, func(q *dns.Msg) *dns.Msg {
+			reply := pipeAnswer(q)
+			reply.Id = q.Id + 1
+			return reply
+		}, nil, func(err error, _ *godns.ErrorInfo) bool { return err != nil }},
+		{"wrong-question", nil, func(q *dns.Msg) *dns.Msg {
+			reply := pipeAnswer(q)
+			reply.Question[0].Name = "other.test."
+			return reply
+		}, nil, func(err error, _ *godns.ErrorInfo) bool { return err != nil }},
+		{"timeout", []godns.Option{godns.WithTimeout(100 * time.Millisecond)}, func(*dns.Msg) *dns.Msg { return nil }, nil,
+			func(_ error, info *godns.ErrorInfo) bool { return info.Kind == godns.KindTimeout }},
+		{"oversized-frame", []godns.Option{godns.WithMaxResponseBytes(512)}, nil, binary.BigEndian.AppendUint16(nil, 4000),
+			func(err error, _ *godns.ErrorInfo) bool {
+				var tooLarge *godns.ResponseTooLargeError
+				return errors.As(err, &tooLarge) && tooLarge.Size == 4000
+			}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var conn net.Conn
+			if tt.raw != nil {
+				client, server := net.Pipe()
+				defer client.Close()
+				defer server.Close()
+				go func() {
+					(&dns.Conn{Conn: server}).ReadMsg()
+					server.Write(tt.raw)
+				}()
+				conn = client
+			} else {
+				conn = pipeResponder(t, tt.respond)
+			}
+			c := godns.New(tt.opts...)
+			defer c.Close()
+
+			start := time.Now()
+			res, err := c.QueryOverConn(context.Background(), conn, "www.test", dns.TypeA)
+			if res.Error == nil {
+				t.Fatalf("result does not carry the error %v", err)
+			}
+			if !tt.check(err, res.Error) {
+				t.Fatalf("err = %v, kind = %s", err, res.Error.Kind)
+			}
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Errorf("query took %v", elapsed)
+			}
+		})
+	}
+}
+
+func TestQueryOverConnCancel(t *testing.T) {
+	conn := pipeResponder(t, func(*dns.Msg) *dns.Msg { return nil })
+	c := godns.New(godns.WithTimeout(10 * time.Second))
+	defer c.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	_, err := c.QueryOverConn(ctx, conn, "www.test", dns.TypeA)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("cancelled query took %v", elapsed)
+	}
+	// 截止时间已清除，连接交还调用方后仍可使用
+	if err := conn.SetReadDeadline(time.Now().Add(10 * time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Write([]byte{0, 0}); err != nil {
+		t.Errorf("connection unusable after cancel: %v", err)
+	}
+}
+
+func TestQueryOverConnPacket(t *testing.T) {
+	s := startServer(t)
+	s.Answer("www.test", dns.TypeA, "www.test. 60 IN A 192.0.2.7")
+	conn, err := net.Dial("udp", s.UDPAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	c := godns.New()
+	defer c.Close()
+
+	res, err := c.QueryOverConn(context.Background(), conn, "www.test", dns.TypeA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Records) != 1 || res.Path.Attempts[0].Protocol != godns.UDP || res.Server != s.UDPAddr {
+		t.Errorf("records = %v, attempt = %+v, server = %s", res.Records, res.Path.Attempts[0], res.Server)
+	}
+	if q := s.Queries(); len(q) != 1 || q[0].Protocol != "udp" {
+		t.Errorf("server saw %+v", q)
+	}
+}
@@ -0,0 +1,69 @@
+package godns
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// answerSetKey 规范化应答集合：忽略TTL和记录顺序，名称与值不区分大小写
+// 仅适用于成功的结果
+func answerSetKey(res QueryResult) string {
+	items := make([]string, 0, len(res.Records))
+	for _, record := range res.Records {
+		items = append(items, strconv.Itoa(int(record.Type))+" "+strings.ToLower(record.Value()))
+	}
+	sort.Strings(items)
+
+	// 去重，避免同一记录重复出现导致集合不一致
+	unique := items[:0]
+	for i, item := range items {
+		if i == 0 || item != items[i-1] {
+			unique = append(unique, item)
+		}
+	}
+	return strings.Join(unique, "\n")
+}
+
+// quorumTracker 按规范化应答集合统计服务器一致性
+type quorumTracker struct {
+	k      int
+	groups map[string][]string // 应答集合 -> 服务器
+	order  []string            // 应答集合首次出现顺序
+	winner string
+}
+
+func newQuorumTracker(k int) *quorumTracker {
+	return &quorumTracker{k: k, groups: make(map[string][]string)}
+}
+
+// add 记录一个结果，返回是否已达成法定数量的一致
+func (q *quorumTracker) add(res QueryResult) bool {
+	if !res.answered() || res.Synthetic {
+		return false
+	}
+	key := answerSetKey(res)
+	if _, ok := q.groups[key]; !ok {
+		q.order = append(q.order, key)
+	}
+	q.groups[key] = append(q.groups[key], res.Server)
+	if len(q.groups[key]) >= q.k && q.winner == "" {
+		q.winner = key
+	}
+	return q.winner != ""
+}
+
+// result 返回一致的服务器与持不同应答的服务器
+func (q *quorumTracker) result() (agreeing, dissenters []string) {
+	if q.winner == "" {
+		return nil, nil
+	}
+	for _, key := range q.order {
+		if key == q.winner {
+			agreeing = append(agreeing, q.groups[key]...)
+		} else {
+			dissenters = append(dissenters, q.groups[key]...)
+		}
+	}
+	return agreeing, dissenters
+}
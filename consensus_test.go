@@ -0,0 +1,141 @@
+package godns_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+	"github.com/zan8in/godns/internal/testserver"
+)
+
+// startAnswering 启动多个测试服务器，第 i 个以 replies[i] 应答 example.com 的A查询
+func startAnswering(t *testing.T, replies ...testserver.Reply) ([]*testserver.Server, []string) {
+	t.Helper()
+	var servers []*testserver.Server
+	var addrs []string
+	for _, reply := range replies {
+		s := startServer(t)
+		s.Handle("example.com", dns.TypeA, reply)
+		servers = append(servers, s)
+		addrs = append(addrs, s.UDPAddr)
+	}
+	return servers, addrs
+}
+
+func aReply(addr string, delay time.Duration) testserver.Reply {
+	return testserver.Reply{Answer: []dns.RR{testserver.RR("example.com. 300 IN A " + addr)}, Delay: delay}
+}
+
+func TestQuorum(t *testing.T) {
+	tests := []struct {
+		name       string
+		k          int
+		replies    []testserver.Reply
+		reached    bool
+		agreeing   []int // 期望一致的服务器下标
+		dissenters []int
+		fast       bool // 应在慢服务器应答前返回
+	}{
+		{
+			name:     "reached before the slow server",
+			k:        2,
+			replies:  []testserver.Reply{aReply("192.0.2.1", 0), aReply("192.0.2.1", 0), aReply("192.0.2.2", 3*time.Second)},
+			reached:  true,
+			agreeing: []int{0, 1},
+			fast:     true,
+		},
+		{
+			name:       "reached with a dissenter",
+			k:          2,
+			replies:    []testserver.Reply{aReply("192.0.2.1", 0), aReply("192.0.2.2", 0), aReply("192.0.2.1", 50*time.Millisecond)},
+			reached:    true,
+			agreeing:   []int{0, 2},
+			dissenters: []int{1},
+		},
+		{
+			name:    "everything disagrees",
+			k:       2,
+			replies: []testserver.Reply{aReply("192.0.2.1", 0), aReply("192.0.2.2", 0), aReply("192.0.2.3", 0)},
+		},
+		{
+			name:    "failures do not count",
+			k:       2,
+			replies: []testserver.Reply{aReply("192.0.2.1", 0), {Rcode: dns.RcodeServerFailure}, {Rcode: dns.RcodeServerFailure}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, addrs := startAnswering(t, tt.replies...)
+			c := godns.New(godns.WithServers(addrs...), godns.WithQuorum(tt.k), godns.WithRetries(0), godns.WithTimeout(5*time.Second))
+			defer c.Close()
+
+			start := time.Now()
+			res, err := c.MultiQuery(context.Background(), "example.com", dns.TypeA)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.fast && time.Since(start) > time.Second {
+				t.Errorf("quorum returned after %v, want before the slow server", time.Since(start))
+			}
+			if res.QuorumReached != tt.reached {
+				t.Fatalf("QuorumReached = %v, want %v", res.QuorumReached, tt.reached)
+			}
+			if !tt.reached {
+				if len(res.Results) != len(addrs) {
+					t.Errorf("results = %d, want all %d servers", len(res.Results), len(addrs))
+				}
+				return
+			}
+			if got, want := sorted(res.Agreeing), pick(addrs, tt.agreeing); !slices.Equal(got, want) {
+				t.Errorf("Agreeing = %v, want %v", got, want)
+			}
+			if got, want := sorted(res.Dissenters), pick(addrs, tt.dissenters); !slices.Equal(got, want) {
+				t.Errorf("Dissenters = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+// TestQuorumBypassesCache 缓存按协议区分，若读写缓存，第二轮所有服务器都会"一致"地返回第一轮缓存的应答
+func TestQuorumBypassesCache(t *testing.T) {
+	servers, addrs := startAnswering(t, aReply("192.0.2.1", 0), aReply("192.0.2.2", 0), aReply("192.0.2.3", 0))
+	c := godns.New(godns.WithServers(addrs...), godns.WithQuorum(2), godns.WithCache(time.Minute), godns.WithRetries(0))
+	defer c.Close()
+
+	for round := 1; round <= 2; round++ {
+		res, err := c.MultiQuery(context.Background(), "example.com", dns.TypeA)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.QuorumReached {
+			t.Fatalf("round %d: quorum reached on disagreeing servers (agreeing %v)", round, res.Agreeing)
+		}
+		for _, r := range res.Results {
+			if r.Path.Source != godns.SourceNetwork {
+				t.Errorf("round %d: %s answered from %q", round, r.Server, r.Path.Source)
+			}
+		}
+	}
+	for i, s := range servers {
+		if n := len(s.Queries()); n != 2 {
+			t.Errorf("server %d saw %d queries, want 2", i, n)
+		}
+	}
+}
+
+func pick(addrs []string, indexes []int) []string {
+	var out []string
+	for _, i := range indexes {
+		out = append(out, addrs[i])
+	}
+	return sorted(out)
+}
+
+func sorted(s []string) []string {
+	s = slices.Clone(s)
+	slices.Sort(s)
+	return s
+}
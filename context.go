@@ -0,0 +1,134 @@
+package godns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// doKey 单次查询的EDNS DO位设置
+type doKey struct{}
+
+// maxAnswersKey 单次查询的记录数上限
+type maxAnswersKey struct{}
+
+// 请求级覆盖配置的 context 键
+type (
+	serversKey struct{}
+	timeoutKey struct{}
+	noCacheKey struct{}
+)
+
+// ErrInvalidOverride context 中的覆盖配置无效或相互冲突
+var ErrInvalidOverride = errors.New("godns: invalid context override")
+
+// serversOverride context 中的服务器列表覆盖，conflict 记录同一 context 链上以不同列表重复覆盖的情况
+type serversOverride struct {
+	servers  []string
+	conflict error
+}
+
+// timeoutOverride context 中的单次尝试超时覆盖，conflict 含义同 serversOverride
+type timeoutOverride struct {
+	timeout  time.Duration
+	conflict error
+}
+
+// WithDO 返回携带EDNS DO位设置的 context，queryServer 会据此为该次查询设置DO位，
+// 便于在同一客户端上混合发起需要DNSSEC记录与不需要的查询
+func WithDO(ctx context.Context, do bool) context.Context {
+	return context.WithValue(ctx, doKey{}, do)
+}
+
+// doFromContext 读取 context 中的DO位设置
+func doFromContext(ctx context.Context) (do bool, ok bool) {
+	do, ok = ctx.Value(doKey{}).(bool)
+	return do, ok
+}
+
+// WithQueryMaxAnswers 返回携带记录数上限的 context，覆盖客户端的 WithMaxAnswers 设置
+func WithQueryMaxAnswers(ctx context.Context, n int) context.Context {
+	return context.WithValue(ctx, maxAnswersKey{}, n)
+}
+
+// maxAnswersFromContext 读取 context 中的记录数上限
+func maxAnswersFromContext(ctx context.Context) (n int, ok bool) {
+	n, ok = ctx.Value(maxAnswersKey{}).(int)
+	return n, ok
+}
+
+// 请求级覆盖：无法修改调用签名的框架可以通过装饰 context 调整单次请求的行为
+// 优先级：调用级选项（如 QueryAt、QueryWithTag 显式指定的服务器）> context 覆盖 > 客户端配置
+// 同一 context 链上以不同的值重复设置同一项覆盖（例如两层中间件各自指定服务器）视为冲突，查询返回 ErrInvalidOverride；
+// 以相同的值重复设置不算冲突
+
+// ContextWithServers 返回覆盖服务器列表的 context，Query/MultiQuery 将使用这些服务器
+// 代替客户端配置的服务器和路由规则，且不读写应答缓存
+func ContextWithServers(ctx context.Context, servers ...string) context.Context {
+	o := serversOverride{servers: append([]string(nil), servers...)}
+	if prev, ok := ctx.Value(serversKey{}).(serversOverride); ok {
+		o.conflict = prev.conflict
+		if o.conflict == nil && !slices.Equal(prev.servers, o.servers) {
+			o.conflict = fmt.Errorf("%w: ContextWithServers set to %v over %v", ErrInvalidOverride, o.servers, prev.servers)
+		}
+	}
+	return context.WithValue(ctx, serversKey{}, o)
+}
+
+// ContextWithTimeout 返回覆盖单次尝试超时时间的 context
+func ContextWithTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	o := timeoutOverride{timeout: timeout}
+	if prev, ok := ctx.Value(timeoutKey{}).(timeoutOverride); ok {
+		o.conflict = prev.conflict
+		if o.conflict == nil && prev.timeout != timeout {
+			o.conflict = fmt.Errorf("%w: ContextWithTimeout set to %v over %v", ErrInvalidOverride, timeout, prev.timeout)
+		}
+	}
+	return context.WithValue(ctx, timeoutKey{}, o)
+}
+
+// ContextWithNoCache 返回跳过应答缓存的 context，应答既不从缓存读取也不写入缓存
+func ContextWithNoCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noCacheKey{}, true)
+}
+
+// serversFromContext 读取 context 中的服务器列表
+func serversFromContext(ctx context.Context) ([]string, bool) {
+	o, ok := ctx.Value(serversKey{}).(serversOverride)
+	return o.servers, ok
+}
+
+// timeoutFromContext 读取 context 中的单次尝试超时时间
+func timeoutFromContext(ctx context.Context) (time.Duration, bool) {
+	o, ok := ctx.Value(timeoutKey{}).(timeoutOverride)
+	return o.timeout, ok
+}
+
+// noCacheFromContext context 是否要求跳过缓存
+func noCacheFromContext(ctx context.Context) bool {
+	noCache, _ := ctx.Value(noCacheKey{}).(bool)
+	return noCache
+}
+
+// checkOverrides 校验 context 中的覆盖配置
+func checkOverrides(ctx context.Context) error {
+	if o, ok := ctx.Value(serversKey{}).(serversOverride); ok {
+		if o.conflict != nil {
+			return o.conflict
+		}
+		if len(o.servers) == 0 {
+			return fmt.Errorf("%w: ContextWithServers requires at least one server", ErrInvalidOverride)
+		}
+	}
+	if o, ok := ctx.Value(timeoutKey{}).(timeoutOverride); ok {
+		if o.conflict != nil {
+			return o.conflict
+		}
+		if o.timeout <= 0 {
+			return fmt.Errorf("%w: ContextWithTimeout requires a positive timeout, got %v", ErrInvalidOverride, o.timeout)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package godns_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+	"github.com/zan8in/godns/internal/testserver"
+)
+
+// TestContextServersPrecedence context 覆盖优先于客户端配置的服务器和路由，调用级指定的服务器优先于 context 覆盖
+func TestContextServersPrecedence(t *testing.T) {
+	configured, routed, tagged, override, adHoc := startServer(t), startServer(t), startServer(t), startServer(t), startServer(t)
+	all := []*testserver.Server{configured, routed, tagged, override, adHoc}
+	for _, s := range all {
+		s.Answer("www.example.com", dns.TypeA, "www.example.com. 60 IN A 192.0.2.1")
+	}
+
+	c := godns.New(
+		godns.WithServers(configured.UDPAddr),
+		godns.WithTaggedServers(map[string][]string{"internal": {tagged.UDPAddr}}),
+		godns.WithRouting(godns.RouteRule{Name: "example", Suffix: "example.com", Servers: []string{routed.UDPAddr}}),
+		godns.WithRetries(0),
+		godns.WithTimeout(2*time.Second),
+	)
+	defer c.Close()
+	ctx := godns.ContextWithServers(context.Background(), override.UDPAddr)
+
+	tests := []struct {
+		name  string
+		query func() (*godns.QueryResult, error)
+		want  *testserver.Server
+	}{
+		{"no-override-uses-route", func() (*godns.QueryResult, error) {
+			return c.Query(context.Background(), "www.example.com", dns.TypeA)
+		}, routed},
+		{"override-beats-route", func() (*godns.QueryResult, error) {
+			return c.Query(ctx, "www.example.com", dns.TypeA)
+		}, override},
+		{"query-at-beats-override", func() (*godns.QueryResult, error) {
+			return c.QueryAt(ctx, "www.example.com", dns.TypeA, adHoc.UDPAddr)
+		}, adHoc},
+		{"tag-beats-override", func() (*godns.QueryResult, error) {
+			return c.QueryWithTag(ctx, "www.example.com", dns.TypeA, "internal")
+		}, tagged},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, s := range all {
+				s.Reset()
+				s.Answer("www.example.com", dns.TypeA, "www.example.com. 60 IN A 192.0.2.1")
+			}
+			res, err := tt.query()
+			if err != nil {
+				t.Fatalf("query: %v", err)
+			}
+			if res.Server != tt.want.UDPAddr {
+				t.Errorf("Server = %s, want %s", res.Server, tt.want.UDPAddr)
+			}
+			for _, s := range all {
+				want := 0
+				if s == tt.want {
+					want = 1
+				}
+				if n := len(s.Queries()); n != want {
+					t.Errorf("server %s got %d queries, want %d", s.UDPAddr, n, want)
+				}
+			}
+		})
+	}
+}
+
+// TestContextTimeoutOverridesClientTimeout context 中的超时替代客户端配置的单次尝试超时
+func TestContextTimeoutOverridesClientTimeout(t *testing.T) {
+	s := startServer(t)
+	s.Handle("example.com", dns.TypeA, testserver.Reply{Drop: true})
+
+	c := godns.New(godns.WithServers(s.UDPAddr), godns.WithRetries(0), godns.WithTimeout(10*time.Second))
+	defer c.Close()
+
+	start := time.Now()
+	_, err := c.Query(godns.ContextWithTimeout(context.Background(), 100*time.Millisecond), "example.com", dns.TypeA)
+	if err == nil {
+		t.Fatal("Query against a silent server succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Query took %v, want the 100ms context timeout rather than the 10s client timeout", elapsed)
+	}
+}
+
+// TestContextNoCacheBypassesClientCache ContextWithNoCache 跳过客户端启用的应答缓存
+func TestContextNoCacheBypassesClientCache(t *testing.T) {
+	s := startServer(t)
+	s.Answer("example.com", dns.TypeA, "example.com. 300 IN A 192.0.2.1")
+
+	c := godns.New(godns.WithServers(s.UDPAddr), godns.WithCache(time.Hour), godns.WithRetries(0))
+	defer c.Close()
+
+	ctx := context.Background()
+	for range 2 {
+		if _, err := c.Query(ctx, "example.com", dns.TypeA); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := len(s.Queries()); n != 1 {
+		t.Fatalf("server got %d queries with the cache enabled, want 1", n)
+	}
+	if _, err := c.Query(godns.ContextWithNoCache(ctx), "example.com", dns.TypeA); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(s.Queries()); n != 2 {
+		t.Errorf("server got %d queries, want 2 (ContextWithNoCache must skip the cache)", n)
+	}
+}
+
+func TestContextOverrideValidation(t *testing.T) {
+	s := startServer(t)
+	s.Answer("example.com", dns.TypeA, "example.com. 60 IN A 192.0.2.1")
+	other := startServer(t)
+
+	c := godns.New(godns.WithServers(s.UDPAddr), godns.WithRetries(0), godns.WithTimeout(2*time.Second))
+	defer c.Close()
+
+	bg := context.Background()
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		wantErr bool
+	}{
+		{"no-override", bg, false},
+		{"empty-servers", godns.ContextWithServers(bg), true},
+		{"zero-timeout", godns.ContextWithTimeout(bg, 0), true},
+		{"negative-timeout", godns.ContextWithTimeout(bg, -time.Second), true},
+		{"same-servers-twice", godns.ContextWithServers(godns.ContextWithServers(bg, s.UDPAddr), s.UDPAddr), false},
+		{"same-timeout-twice", godns.ContextWithTimeout(godns.ContextWithTimeout(bg, time.Second), time.Second), false},
+		{"conflicting-servers", godns.ContextWithServers(godns.ContextWithServers(bg, s.UDPAddr), other.UDPAddr), true},
+		{"conflicting-timeouts", godns.ContextWithTimeout(godns.ContextWithTimeout(bg, time.Second), 2*time.Second), true},
+		{"conflict-survives-reapply", godns.ContextWithServers(godns.ContextWithServers(godns.ContextWithServers(bg, s.UDPAddr), other.UDPAddr), other.UDPAddr), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := c.Query(tt.ctx, "example.com", dns.TypeA)
+			checkOverrideErr(t, "Query", err, tt.wantErr)
+
+			_, err = c.MultiQuery(tt.ctx, "example.com", dns.TypeA)
+			checkOverrideErr(t, "MultiQuery", err, tt.wantErr)
+
+			msg := new(dns.Msg)
+			msg.SetQuestion("example.com.", dns.TypeA)
+			_, err = c.Exchange(tt.ctx, msg)
+			checkOverrideErr(t, "Exchange", err, tt.wantErr)
+		})
+	}
+	if n := len(other.Queries()); n != 0 {
+		t.Errorf("conflicting override reached the second server %d times", n)
+	}
+}
+
+func checkOverrideErr(t *testing.T, call string, err error, wantErr bool) {
+	t.Helper()
+	if wantErr {
+		if !errors.Is(err, godns.ErrInvalidOverride) {
+			t.Errorf("%s err = %v, want ErrInvalidOverride", call, err)
+		}
+		return
+	}
+	if err != nil {
+		t.Errorf("%s: %v", call, err)
+	}
+}
@@ -0,0 +1,288 @@
+package godns
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// maxDANECacheTTL TLSA记录在DANE缓存中的最长保留时间
+const maxDANECacheTTL = time.Hour
+
+// WithDANEVerification 开启DANE校验（RFC 6698、RFC 7671）：DoT 查询前先查询服务器主机名的TLSA记录
+// （_853._tcp.<主机名>，端口取自服务器地址），握手时用它校验服务器出示的证书链，代替或补充常规的CA校验
+// 主机名取自TLS配置的 ServerName，未设置时取服务器地址的主机部分，以IP地址配置的服务器需通过
+// WithServerInfos 的 ServerName 指定主机名；TLSA应答必须经过DNSSEC验证（AD位），否则校验失败
+// TLSA记录本身通过客户端配置的服务器查询，这次查询的DoT连接只做常规的CA校验，
+// 因此证书无法通过CA校验的服务器（例如只发布 DANE-EE 记录的自签名证书）需要与其他服务器一同配置
+// 校验失败时返回 *DANEError，可用 errors.As 与普通TLS错误区分
+func WithDANEVerification() Option {
+	return func(c *Config) {
+		c.DANE = true
+	}
+}
+
+// DANEError DANE校验失败：无法取得可信的TLSA记录，或服务器证书与TLSA记录不符
+type DANEError struct {
+	Server string // 查询的服务器
+	Name   string // TLSA记录名称，例如 _853._tcp.dns.example
+	Reason string // 失败原因
+}
+
+func (e *DANEError) Error() string {
+	return fmt.Sprintf("DANE verification failed for %s (%s): %s", e.Server, e.Name, e.Reason)
+}
+
+// TLSARecord TLSA记录的各字段（RFC 6698 §2.1）
+type TLSARecord struct {
+	Usage        uint8  // 0 PKIX-TA、1 PKIX-EE、2 DANE-TA、3 DANE-EE
+	Selector     uint8  // 0 完整证书、1 SubjectPublicKeyInfo
+	MatchingType uint8  // 0 原样比较、1 SHA-256、2 SHA-512
+	Certificate  string // 十六进制编码的关联数据（小写）
+}
+
+// String 返回区域文件格式的 RDATA，与TLSA记录的 Record.Value 相同
+func (t *TLSARecord) String() string {
+	return fmt.Sprintf("%d %d %d %s", t.Usage, t.Selector, t.MatchingType, t.Certificate)
+}
+
+// Matches 证书是否与记录的关联数据一致，只比较选择器和匹配方式，不考虑 Usage
+func (t *TLSARecord) Matches(cert *x509.Certificate) bool {
+	data, err := dns.CertificateToDANE(t.Selector, t.MatchingType, cert)
+	return err == nil && strings.EqualFold(data, t.Certificate)
+}
+
+// newTLSARecord 从资源记录构建 TLSARecord
+func newTLSARecord(rr *dns.TLSA) *TLSARecord {
+	return &TLSARecord{
+		Usage:        rr.Usage,
+		Selector:     rr.Selector,
+		MatchingType: rr.MatchingType,
+		Certificate:  strings.ToLower(rr.Certificate),
+	}
+}
+
+// TLSA 返回TLSA记录的各字段，记录不是TLSA类型时返回 false
+// 反序列化得到的 Record 从 Value 中解析
+func (r Record) TLSA() (*TLSARecord, bool) {
+	if tlsa, ok := r.rr.(*dns.TLSA); ok {
+		return newTLSARecord(tlsa), true
+	}
+	if r.Type != dns.TypeTLSA {
+		return nil, false
+	}
+	nums, data, ok := parseUints(r.Value(), 8, 8, 8)
+	if !ok || data == "" {
+		return nil, false
+	}
+	return newTLSARecord(&dns.TLSA{
+		Usage:        uint8(nums[0]),
+		Selector:     uint8(nums[1]),
+		MatchingType: uint8(nums[2]),
+		Certificate:  data,
+	}), true
+}
+
+// TLSARecords 返回结果中的TLSA记录，保持应答中的顺序
+func (r *QueryResult) TLSARecords() []TLSARecord {
+	var records []TLSARecord
+	for _, record := range r.Records {
+		if tlsa, ok := record.TLSA(); ok {
+			records = append(records, *tlsa)
+		}
+	}
+	return records
+}
+
+// QueryTLSA 查询TLSA记录，name 按原样查询，应为 _853._tcp.dns.example 形式，
+// 结构化的字段可通过 QueryResult.TLSARecords 获取
+func (c *Client) QueryTLSA(ctx context.Context, name string) (*QueryResult, error) {
+	return c.Query(c.relaxNamePolicy(ctx, ServiceLabels), name, dns.TypeTLSA)
+}
+
+// daneBypassKey 标记查询TLSA记录本身的 context，其DoT连接不再做DANE校验，避免无限递归
+type daneBypassKey struct{}
+
+// daneEntry 缓存的TLSA记录
+type daneEntry struct {
+	records []TLSARecord
+	expires time.Time
+}
+
+// daneCache 按TLSA名称缓存经过验证的记录
+type daneCache struct {
+	mu      sync.Mutex
+	entries map[string]daneEntry
+	clock   Clock
+}
+
+func newDANECache(clock Clock) *daneCache {
+	return &daneCache{entries: make(map[string]daneEntry), clock: clock}
+}
+
+// get 返回未过期的记录
+func (dc *daneCache) get(name string) ([]TLSARecord, bool) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	entry, ok := dc.entries[name]
+	if !ok || !dc.clock.Now().Before(entry.expires) {
+		delete(dc.entries, name)
+		return nil, false
+	}
+	return entry.records, true
+}
+
+// set 按记录的最小TTL缓存，不超过 maxDANECacheTTL
+func (dc *daneCache) set(name string, records []TLSARecord, ttl time.Duration) {
+	ttl = min(ttl, maxDANECacheTTL)
+	if ttl <= 0 {
+		return
+	}
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	dc.entries[name] = daneEntry{records: records, expires: dc.clock.Now().Add(ttl)}
+}
+
+// daneTLSConfig 开启DANE校验时，返回在握手中按TLSA记录校验证书的TLS配置，未开启时原样返回 base
+func (c *Client) daneTLSConfig(ctx context.Context, server string, base *tls.Config) (*tls.Config, error) {
+	if !c.config.DANE || ctx.Value(daneBypassKey{}) != nil {
+		return base, nil
+	}
+	host, port, err := net.SplitHostPort(server)
+	if err != nil {
+		host, port = server, "853"
+	}
+	if base.ServerName != "" {
+		host = base.ServerName
+	}
+	name := "_" + port + "._tcp." + dns.Fqdn(host)
+	if net.ParseIP(host) != nil {
+		return nil, &DANEError{Server: server, Name: name, Reason: "server has no hostname, set ServerName"}
+	}
+	records, err := c.tlsaRecords(ctx, server, name)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := base.Clone()
+	cfg.ServerName = host
+	// 证书由 VerifyConnection 按TLSA记录校验，PKIX-TA/PKIX-EE 记录在其中完成常规的CA校验
+	cfg.InsecureSkipVerify = true
+	roots := base.RootCAs
+	previous := base.VerifyConnection
+	cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+		if previous != nil {
+			if err := previous(cs); err != nil {
+				return err
+			}
+		}
+		if reason := verifyDANE(cs.PeerCertificates, records, host, roots); reason != "" {
+			return &DANEError{Server: server, Name: name, Reason: reason}
+		}
+		return nil
+	}
+	return cfg, nil
+}
+
+// tlsaRecords 返回 name 的TLSA记录，优先使用缓存；记录缺失或未经DNSSEC验证时返回 *DANEError
+func (c *Client) tlsaRecords(ctx context.Context, server, name string) ([]TLSARecord, error) {
+	if records, ok := c.dane.get(name); ok {
+		return records, nil
+	}
+	// TLSA查询发生在传输层中，它自己的DoT连接跳过DANE校验，因此不会递归，可以解除循环检测；
+	// 使用独立的解析路径，不混入本次查询的尝试记录；本次尝试已持有并发名额，TLSA查询不再排队
+	lookupCtx := context.WithValue(withSlotHeld(ctx), daneBypassKey{}, true)
+	lookupCtx = context.WithValue(lookupCtx, infraKey{}, (*loopGuard)(nil))
+	lookupCtx = context.WithValue(lookupCtx, pathRecorderKey{}, (*pathRecorder)(nil))
+	res, err := c.QueryTLSA(WithDO(lookupCtx, true), name)
+	if err = ignoreNXDomain(err); err != nil {
+		return nil, &DANEError{Server: server, Name: name, Reason: "TLSA lookup failed: " + err.Error()}
+	}
+	if !res.AD {
+		return nil, &DANEError{Server: server, Name: name, Reason: "TLSA records are not DNSSEC validated (AD=0)"}
+	}
+	records := res.TLSARecords()
+	if len(records) == 0 {
+		return nil, &DANEError{Server: server, Name: name, Reason: "no TLSA records"}
+	}
+	var ttl uint32
+	for i, record := range res.Records {
+		if i == 0 || record.TTL < ttl {
+			ttl = record.TTL
+		}
+	}
+	c.dane.set(name, records, time.Duration(ttl)*time.Second)
+	return records, nil
+}
+
+// verifyDANE 按 RFC 7671 校验证书链，任意一条可用的记录匹配即通过，失败时返回原因
+//   - DANE-EE(3)：叶证书匹配即可，不校验有效期和名称
+//   - DANE-TA(2)：链中某个证书匹配，且叶证书能以它为信任锚完成校验
+//   - PKIX-EE(1)/PKIX-TA(0)：先完成常规的CA校验，再要求叶证书或校验链中的某个CA证书匹配
+func verifyDANE(certs []*x509.Certificate, records []TLSARecord, host string, roots *x509.CertPool) string {
+	if len(certs) == 0 {
+		return "server presented no certificate"
+	}
+	leaf := certs[0]
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	var pkixChains [][]*x509.Certificate
+	var pkixErr error
+	pkixDone := false
+	pkix := func() ([][]*x509.Certificate, error) {
+		if !pkixDone {
+			pkixDone = true
+			pkixChains, pkixErr = leaf.Verify(x509.VerifyOptions{DNSName: host, Roots: roots, Intermediates: intermediates})
+		}
+		return pkixChains, pkixErr
+	}
+
+	for _, record := range records {
+		switch record.Usage {
+		case 3:
+			if record.Matches(leaf) {
+				return ""
+			}
+		case 2:
+			for _, cert := range certs {
+				if !record.Matches(cert) {
+					continue
+				}
+				anchor := x509.NewCertPool()
+				anchor.AddCert(cert)
+				if _, err := leaf.Verify(x509.VerifyOptions{DNSName: host, Roots: anchor, Intermediates: intermediates}); err == nil {
+					return ""
+				}
+			}
+		case 1:
+			if _, err := pkix(); err == nil && record.Matches(leaf) {
+				return ""
+			}
+		case 0:
+			chains, err := pkix()
+			if err != nil {
+				continue
+			}
+			for _, chain := range chains {
+				for _, cert := range chain[1:] {
+					if record.Matches(cert) {
+						return ""
+					}
+				}
+			}
+		}
+	}
+	if _, err := pkix(); err != nil {
+		return "no TLSA record matches the certificate chain (PKIX: " + err.Error() + ")"
+	}
+	return "no TLSA record matches the certificate chain"
+}
@@ -0,0 +1,189 @@
+package godns_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+	"github.com/zan8in/godns/internal/testserver"
+)
+
+// tlsaName 返回测试服务器DoT端点的TLSA记录名称
+func tlsaName(t *testing.T, s *testserver.Server) string {
+	t.Helper()
+	_, port, err := net.SplitHostPort(s.DoTAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fmt.Sprintf("_%s._tcp.%s.", port, s.ServerName)
+}
+
+// publishTLSA 为测试服务器发布TLSA记录，ad 控制应答是否带AD位
+func publishTLSA(t *testing.T, s *testserver.Server, ad bool, rdata ...string) {
+	t.Helper()
+	name := tlsaName(t, s)
+	var rrs []dns.RR
+	for _, r := range rdata {
+		rrs = append(rrs, testserver.RR(name+" 300 IN TLSA "+r))
+	}
+	s.Handle(name, dns.TypeTLSA, testserver.Reply{Answer: rrs, AuthenticatedData: ad})
+}
+
+// caTLSA 以测试服务器的CA为信任锚的 DANE-TA 记录
+func caTLSA(s *testserver.Server) string {
+	sum := sha256.Sum256(s.CA().Raw)
+	return "2 0 1 " + hex.EncodeToString(sum[:])
+}
+
+func daneClient(s *testserver.Server, opts ...godns.Option) *godns.Client {
+	return godns.New(append([]godns.Option{
+		godns.WithProtocol(godns.DoT),
+		godns.WithServers(s.DoTAddr),
+		godns.WithTLSConfig(s.ClientTLSConfig()),
+		godns.WithDANEVerification(),
+		godns.WithRetries(0),
+	}, opts...)...)
+}
+
+func TestDANEVerification(t *testing.T) {
+	tests := []struct {
+		name   string
+		ad     bool
+		rdata  []string
+		reason string // 为空表示校验通过
+	}{
+		{"dane-ta", true, nil, ""},
+		{"mismatch", true, []string{"3 1 1 " + hex.EncodeToString(make([]byte, 32))}, "match"},
+		{"not-validated", false, nil, "AD=0"},
+		{"no-records", true, []string{}, "no TLSA records"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := startServer(t)
+			s.Answer("www.test", dns.TypeA, "www.test. 60 IN A 192.0.2.1")
+			rdata := tt.rdata
+			if rdata == nil {
+				rdata = []string{caTLSA(s)}
+			}
+			publishTLSA(t, s, tt.ad, rdata...)
+			c := daneClient(s)
+			defer c.Close()
+
+			res, err := c.QueryA(context.Background(), "www.test")
+			if tt.reason == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(res.Records) != 1 {
+					t.Errorf("records = %v", res.Records)
+				}
+				// TLSA查询不混入本次查询的尝试记录
+				if n := len(res.Path.Attempts); n != 1 {
+					t.Errorf("attempts = %d, want 1", n)
+				}
+				return
+			}
+			var daneErr *godns.DANEError
+			if !errors.As(err, &daneErr) || daneErr.Name != tlsaName(t, s) {
+				t.Fatalf("err = %v, want a DANEError for %s", err, tlsaName(t, s))
+			}
+			if !strings.Contains(daneErr.Reason, tt.reason) {
+				t.Errorf("reason = %q, want it to mention %q", daneErr.Reason, tt.reason)
+			}
+		})
+	}
+}
+
+// TestDANEWithMaxOutstanding 并发上限为1时，DoT尝试内部的TLSA查询不等待尝试自身持有的名额
+func TestDANEWithMaxOutstanding(t *testing.T) {
+	s := startServer(t)
+	s.Answer("www.test", dns.TypeA, "www.test. 60 IN A 192.0.2.1")
+	publishTLSA(t, s, true, caTLSA(s))
+	c := daneClient(s, godns.WithMaxOutstanding(1), godns.WithTimeout(5*time.Second))
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	if _, err := c.QueryA(ctx, "www.test"); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("query took %v", elapsed)
+	}
+	if st := c.OutstandingStats(); st.InFlight != 0 || st.Saturated != 0 {
+		t.Errorf("outstanding stats = %+v", st)
+	}
+}
+
+func TestQueryTLSA(t *testing.T) {
+	s := startServer(t)
+	publishTLSA(t, s, true, "3 1 1 ABCDEF0123", "2 0 2 00ff")
+	c := godns.New(godns.WithServers(s.UDPAddr))
+	defer c.Close()
+
+	res, err := c.QueryTLSA(context.Background(), tlsaName(t, s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []godns.TLSARecord{
+		{Usage: 3, Selector: 1, MatchingType: 1, Certificate: "abcdef0123"},
+		{Usage: 2, Selector: 0, MatchingType: 2, Certificate: "00ff"},
+	}
+	got := res.TLSARecords()
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("TLSARecords = %+v, want %+v", got, want)
+	}
+	if !res.AD {
+		t.Error("AD bit not surfaced")
+	}
+}
+
+// TestTLSARecordFromValue 反序列化得到的记录从 Value 中解析出相同的字段，Matches 按选择器和匹配方式比较证书
+func TestTLSARecordFromValue(t *testing.T) {
+	s := startServer(t)
+	publishTLSA(t, s, true, caTLSA(s), "3 1 1 ABCDEF0123")
+	c := godns.New(godns.WithServers(s.UDPAddr), godns.WithRetries(0))
+	defer c.Close()
+
+	res, err := c.QueryTLSA(context.Background(), tlsaName(t, s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded godns.QueryResult
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	live, parsed := res.TLSARecords(), decoded.TLSARecords()
+	if len(parsed) != 2 || fmt.Sprint(parsed) != fmt.Sprint(live) {
+		t.Errorf("after JSON round trip TLSARecords = %+v, want %+v", parsed, live)
+	}
+	if live[0].String() != res.Records[0].Value() {
+		t.Errorf("String = %q, Value = %q", live[0].String(), res.Records[0].Value())
+	}
+	if !parsed[0].Matches(s.CA()) || parsed[1].Matches(s.CA()) {
+		t.Errorf("Matches = %v, %v; want only the DANE-TA record to match the CA", parsed[0].Matches(s.CA()), parsed[1].Matches(s.CA()))
+	}
+
+	s.Answer("www.test", dns.TypeA, "www.test. 60 IN A 192.0.2.1")
+	a, err := c.QueryA(context.Background(), "www.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := a.Records[0].TLSA(); ok {
+		t.Error("A record parsed as TLSA")
+	}
+}
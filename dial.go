@@ -0,0 +1,199 @@
+package godns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// defaultHappyEyeballsDelay 相邻两次连接尝试之间的默认间隔（RFC 8305 §5 Connection Attempt Delay）
+const defaultHappyEyeballsDelay = 250 * time.Millisecond
+
+// WithHappyEyeballsDelay 设置 DialContext 相邻两次连接尝试之间的间隔
+func WithHappyEyeballsDelay(delay time.Duration) Option {
+	return func(c *Config) {
+		c.HappyEyeballsDelay = delay
+	}
+}
+
+// familyAnswer 一个地址族的解析结果
+type familyAnswer struct {
+	ipv6  bool
+	addrs []string
+	err   error
+}
+
+// dialResult 单次连接尝试的结果
+type dialResult struct {
+	conn net.Conn
+	addr string
+	err  error
+}
+
+// DialContext 使用 godns 解析主机名并按 RFC 8305（Happy Eyeballs）建立连接，
+// 可直接用作 http.Transport.DialContext 等拨号钩子
+// 同时发起A和AAAA查询，任一地址族的应答到达后立即开始拨号；两个地址族交替尝试，
+// 相邻尝试间隔 HappyEyeballsDelay，某次尝试失败时立即开始下一次；
+// 首个连接成功后取消其余尝试，全部失败时返回每个地址的错误
+func (c *Client) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+
+	var dialer net.Dialer
+	if _, err := netip.ParseAddr(host); err == nil {
+		return dialer.DialContext(ctx, network, address)
+	}
+
+	delay := c.config.HappyEyeballsDelay
+	if delay <= 0 {
+		delay = defaultHappyEyeballsDelay
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// 按网络类型发起A/AAAA查询
+	answers := make(chan familyAnswer, 2)
+	pendingQueries := 0
+	lookup := func(ipv6 bool) {
+		pendingQueries++
+		qtype := dns.TypeA
+		if ipv6 {
+			qtype = dns.TypeAAAA
+		}
+		go func() {
+			res, err := c.Query(ctx, host, qtype)
+			ans := familyAnswer{ipv6: ipv6, err: err}
+			if res != nil {
+				for _, record := range res.Records {
+					if record.Type == qtype {
+						ans.addrs = append(ans.addrs, net.JoinHostPort(record.Value(), port))
+					}
+				}
+			}
+			answers <- ans
+		}()
+	}
+	switch network {
+	case "tcp4", "udp4":
+		lookup(false)
+	case "tcp6", "udp6":
+		lookup(true)
+	default:
+		lookup(true)
+		lookup(false)
+	}
+
+	results := make(chan dialResult)
+	inflight := 0
+	var v6, v4 []string
+	nextIPv6 := true // 交替选择地址族，先到的地址族先开始
+	var errs []error
+
+	// next 取出下一个要尝试的地址，两个地址族交替
+	next := func() (string, bool) {
+		if len(v6) == 0 && len(v4) == 0 {
+			return "", false
+		}
+		var addr string
+		if (nextIPv6 && len(v6) > 0) || len(v4) == 0 {
+			addr, v6 = v6[0], v6[1:]
+			nextIPv6 = false
+		} else {
+			addr, v4 = v4[0], v4[1:]
+			nextIPv6 = true
+		}
+		return addr, true
+	}
+
+	timer := time.NewTimer(delay)
+	timer.Stop()
+	timerActive := false
+	started := false
+
+	// start 开始一次连接尝试，并安排下一次尝试
+	start := func() {
+		addr, ok := next()
+		if !ok {
+			return
+		}
+		started = true
+		inflight++
+		go func() {
+			conn, err := dialer.DialContext(ctx, network, addr)
+			results <- dialResult{conn: conn, addr: addr, err: err}
+		}()
+		timer.Reset(delay)
+		timerActive = true
+	}
+
+	// 成功后关闭仍在进行的其他尝试建立的连接
+	drain := func(n int) {
+		go func() {
+			for i := 0; i < n; i++ {
+				if r := <-results; r.conn != nil {
+					r.conn.Close()
+				}
+			}
+		}()
+	}
+
+	for pendingQueries > 0 || inflight > 0 || len(v6)+len(v4) > 0 {
+		select {
+		case ans := <-answers:
+			pendingQueries--
+			if ans.err != nil {
+				errs = append(errs, ans.err)
+			}
+			if ans.ipv6 {
+				v6 = append(v6, ans.addrs...)
+			} else {
+				v4 = append(v4, ans.addrs...)
+			}
+			if !started && len(ans.addrs) > 0 {
+				nextIPv6 = ans.ipv6
+			}
+			if !timerActive && inflight == 0 {
+				start()
+			}
+		case <-timer.C:
+			timerActive = false
+			start()
+		case r := <-results:
+			inflight--
+			if r.err == nil {
+				cancel()
+				drain(inflight)
+				return r.conn, nil
+			}
+			errs = append(errs, fmt.Errorf("dial %s: %w", r.addr, r.err))
+			// 尝试失败时立即开始下一次
+			timer.Stop()
+			timerActive = false
+			start()
+		case <-ctx.Done():
+			drain(inflight)
+			return nil, ctx.Err()
+		}
+	}
+
+	// 最后一次尝试因 context 结束而失败时，与其他情况一样返回 context 的错误；
+	// net.Dialer 按 context 的截止时间设置连接超时，可能先于 context 本身报告结束
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok && !time.Now().Before(deadline) {
+		return nil, context.DeadlineExceeded
+	}
+	if len(errs) == 0 {
+		return nil, fmt.Errorf("%w: no addresses found for %s", ErrNoRecords, host)
+	}
+	return nil, errors.Join(errs...)
+}
@@ -0,0 +1,148 @@
+package godns_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+	"github.com/zan8in/godns/internal/testserver"
+)
+
+// slowListener 在 ip 上监听并占满 backlog，之后的连接请求挂起，模拟迟迟不接受连接的服务器
+func slowListener(t *testing.T, ip [4]byte) (port int) {
+	t.Helper()
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_STREAM, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Bind(fd, &syscall.SockaddrInet4{Addr: ip}); err != nil {
+		syscall.Close(fd)
+		t.Fatal(err)
+	}
+	if err := syscall.Listen(fd, 0); err != nil {
+		syscall.Close(fd)
+		t.Fatal(err)
+	}
+	f := os.NewFile(uintptr(fd), "slow-listener")
+	l, err := net.FileListener(f)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	filler, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { filler.Close() })
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+// acceptingListener 在 addr 上监听并接受所有连接
+func acceptingListener(t *testing.T, addr string) {
+	t.Helper()
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { conn.Close() })
+		}
+	}()
+}
+
+func TestDialContextHappyEyeballs(t *testing.T) {
+	s := startServer(t)
+	port := slowListener(t, [4]byte{127, 0, 0, 2})
+	acceptingListener(t, net.JoinHostPort("127.0.0.3", strconv.Itoa(port)))
+	// 127.0.0.4 和 127.0.0.5 上没有监听，连接立即被拒绝
+	s.Answer("slow-first.test", dns.TypeA, "slow-first.test. 60 IN A 127.0.0.2", "slow-first.test. 60 IN A 127.0.0.3")
+	s.Answer("refused-first.test", dns.TypeA, "refused-first.test. 60 IN A 127.0.0.4", "refused-first.test. 60 IN A 127.0.0.3")
+	s.Answer("all-refused.test", dns.TypeA, "all-refused.test. 60 IN A 127.0.0.4", "all-refused.test. 60 IN A 127.0.0.5")
+	s.Handle("slow-aaaa.test", dns.TypeAAAA, testserver.Reply{
+		Answer: []dns.RR{testserver.RR("slow-aaaa.test. 60 IN AAAA ::1")},
+		Delay:  time.Second,
+	})
+	s.Answer("slow-aaaa.test", dns.TypeA, "slow-aaaa.test. 60 IN A 127.0.0.3")
+	s.Answer("hanging.test", dns.TypeA, "hanging.test. 60 IN A 127.0.0.2")
+
+	tests := []struct {
+		host   string
+		delay  time.Duration
+		min    time.Duration // 连接建立前至少经过的时间
+		max    time.Duration
+		remote string // 为空表示全部失败
+	}{
+		// 首个地址挂起，间隔到期后开始第二个地址的尝试并胜出
+		{"slow-first.test", 200 * time.Millisecond, 200 * time.Millisecond, 900 * time.Millisecond, "127.0.0.3"},
+		// 首个地址被拒绝时不等待间隔，立即尝试下一个
+		{"refused-first.test", 5 * time.Second, 0, time.Second, "127.0.0.3"},
+		// 先到的A应答立即开始拨号，不等待延迟的AAAA应答
+		{"slow-aaaa.test", 5 * time.Second, 0, 500 * time.Millisecond, "127.0.0.3"},
+		{"all-refused.test", 5 * time.Second, 0, time.Second, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			c := godns.New(godns.WithServers(s.UDPAddr), godns.WithHappyEyeballsDelay(tt.delay))
+			defer c.Close()
+			before := runtime.NumGoroutine()
+
+			start := time.Now()
+			conn, err := c.DialContext(context.Background(), "tcp", net.JoinHostPort(tt.host, strconv.Itoa(port)))
+			elapsed := time.Since(start)
+			if elapsed < tt.min || elapsed > tt.max {
+				t.Errorf("dial took %v, want between %v and %v", elapsed, tt.min, tt.max)
+			}
+			if tt.remote == "" {
+				if conn != nil || !errors.Is(err, syscall.ECONNREFUSED) {
+					t.Fatalf("conn = %v, err = %v, want connection refused", conn, err)
+				}
+				// 每个地址的错误都保留在合并的错误中
+				for _, ip := range []string{"127.0.0.4", "127.0.0.5"} {
+					if !strings.Contains(err.Error(), ip) {
+						t.Errorf("error %q does not mention %s", err, ip)
+					}
+				}
+			} else {
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer conn.Close()
+				if got := conn.RemoteAddr().(*net.TCPAddr).IP.String(); got != tt.remote {
+					t.Errorf("connected to %s, want %s", got, tt.remote)
+				}
+			}
+			// 落败的拨号和查询都被取消，不遗留 goroutine
+			waitFor(t, "dial goroutines to exit", func() bool { return runtime.NumGoroutine() <= before })
+		})
+	}
+
+	t.Run("cancel", func(t *testing.T) {
+		c := godns.New(godns.WithServers(s.UDPAddr))
+		defer c.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		if _, err := c.DialContext(ctx, "tcp4", net.JoinHostPort("hanging.test", strconv.Itoa(port))); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("err = %v, want DeadlineExceeded", err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("cancelled dial took %v", elapsed)
+		}
+	})
+}
@@ -0,0 +1,199 @@
+package godns
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// largeAnswerKey 标记应答通常超过512字节的查询（DNSKEY、DS）：通告较大的EDNS缓冲区，UDP应答被截断时改用TCP重新查询
+type largeAnswerKey struct{}
+
+// withLargeAnswer 返回标记为大应答查询的 context
+func withLargeAnswer(ctx context.Context) context.Context {
+	return context.WithValue(ctx, largeAnswerKey{}, true)
+}
+
+// largeAnswer context 中的查询是否为大应答查询
+func largeAnswer(ctx context.Context) bool {
+	return ctx.Value(largeAnswerKey{}) != nil
+}
+
+// DNSKEYRecord DNSKEY记录的各字段（RFC 4034 §2）
+type DNSKEYRecord struct {
+	Flags     uint16 // 257 为密钥签名密钥（KSK），256 为区域签名密钥（ZSK）
+	Protocol  uint8  // 固定为 3
+	Algorithm uint8  // 签名算法，例如 8（RSASHA256）、13（ECDSAP256SHA256）
+	KeyTag    uint16 // 按 RFC 4034 附录B计算的密钥标签，DS 和 RRSIG 记录通过它引用该密钥
+	PublicKey string // base64 编码的公钥
+}
+
+// ZoneKey 是否设置了区域密钥标志位（位7），未设置的密钥不能用于验证区域数据
+func (k *DNSKEYRecord) ZoneKey() bool {
+	return k.Flags&dns.ZONE != 0
+}
+
+// SecureEntryPoint 是否设置了安全入口点标志位（位15），通常表示KSK
+func (k *DNSKEYRecord) SecureEntryPoint() bool {
+	return k.Flags&dns.SEP != 0
+}
+
+// Revoked 是否设置了撤销标志位（RFC 5011）
+func (k *DNSKEYRecord) Revoked() bool {
+	return k.Flags&dns.REVOKE != 0
+}
+
+// String 返回区域文件格式的 RDATA，与DNSKEY记录的 Record.Value 相同
+func (k *DNSKEYRecord) String() string {
+	return fmt.Sprintf("%d %d %d %s", k.Flags, k.Protocol, k.Algorithm, k.PublicKey)
+}
+
+// rr 转换为 miekg/dns 的资源记录，owner 为区域名称
+func (k *DNSKEYRecord) rr(owner string) *dns.DNSKEY {
+	return &dns.DNSKEY{
+		Hdr:       dns.RR_Header{Name: dns.Fqdn(owner), Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET},
+		Flags:     k.Flags,
+		Protocol:  k.Protocol,
+		Algorithm: k.Algorithm,
+		PublicKey: k.PublicKey,
+	}
+}
+
+// newDNSKEYRecord 从资源记录构建 DNSKEYRecord
+func newDNSKEYRecord(rr *dns.DNSKEY) *DNSKEYRecord {
+	return &DNSKEYRecord{
+		Flags:     rr.Flags,
+		Protocol:  rr.Protocol,
+		Algorithm: rr.Algorithm,
+		KeyTag:    rr.KeyTag(),
+		PublicKey: rr.PublicKey,
+	}
+}
+
+// DSRecord DS记录的各字段（RFC 4034 §5）
+type DSRecord struct {
+	KeyTag     uint16 // 所指向的子区域DNSKEY的密钥标签
+	Algorithm  uint8  // 所指向密钥的签名算法
+	DigestType uint8  // 摘要算法，例如 2（SHA-256）、4（SHA-384）
+	Digest     string // 十六进制编码的摘要（大写）
+}
+
+// String 返回区域文件格式的 RDATA，与DS记录的 Record.Value 相同
+func (d *DSRecord) String() string {
+	return fmt.Sprintf("%d %d %d %s", d.KeyTag, d.Algorithm, d.DigestType, d.Digest)
+}
+
+// Matches 判断该DS记录是否指向区域 zone 的密钥 key：密钥标签、算法和摘要均一致
+func (d *DSRecord) Matches(zone string, key *DNSKEYRecord) bool {
+	if d.KeyTag != key.KeyTag || d.Algorithm != key.Algorithm {
+		return false
+	}
+	ds := key.rr(zone).ToDS(d.DigestType)
+	return ds != nil && strings.EqualFold(ds.Digest, d.Digest)
+}
+
+// newDSRecord 从资源记录构建 DSRecord
+func newDSRecord(rr *dns.DS) *DSRecord {
+	return &DSRecord{
+		KeyTag:     rr.KeyTag,
+		Algorithm:  rr.Algorithm,
+		DigestType: rr.DigestType,
+		Digest:     strings.ToUpper(rr.Digest),
+	}
+}
+
+// parseUints 解析以空格分隔的前 len(bits) 个无符号整数，返回剩余部分
+func parseUints(value string, bits ...int) ([]uint64, string, bool) {
+	fields := strings.SplitN(value, " ", len(bits)+1)
+	if len(fields) != len(bits)+1 {
+		return nil, "", false
+	}
+	nums := make([]uint64, len(bits))
+	for i, size := range bits {
+		n, err := strconv.ParseUint(fields[i], 10, size)
+		if err != nil {
+			return nil, "", false
+		}
+		nums[i] = n
+	}
+	return nums, fields[len(bits)], true
+}
+
+// DNSKEY 返回DNSKEY记录的各字段，记录不是DNSKEY类型时返回 false
+// 反序列化得到的 Record 从 Value 中解析
+func (r Record) DNSKEY() (*DNSKEYRecord, bool) {
+	if key, ok := r.rr.(*dns.DNSKEY); ok {
+		return newDNSKEYRecord(key), true
+	}
+	if r.Type != dns.TypeDNSKEY {
+		return nil, false
+	}
+	nums, key, ok := parseUints(r.Value(), 16, 8, 8)
+	if !ok || key == "" {
+		return nil, false
+	}
+	return newDNSKEYRecord(&dns.DNSKEY{
+		Flags:     uint16(nums[0]),
+		Protocol:  uint8(nums[1]),
+		Algorithm: uint8(nums[2]),
+		PublicKey: key,
+	}), true
+}
+
+// DS 返回DS记录的各字段，记录不是DS类型时返回 false
+// 反序列化得到的 Record 从 Value 中解析
+func (r Record) DS() (*DSRecord, bool) {
+	if ds, ok := r.rr.(*dns.DS); ok {
+		return newDSRecord(ds), true
+	}
+	if r.Type != dns.TypeDS {
+		return nil, false
+	}
+	nums, digest, ok := parseUints(r.Value(), 16, 8, 8)
+	if !ok || digest == "" {
+		return nil, false
+	}
+	return newDSRecord(&dns.DS{
+		KeyTag:     uint16(nums[0]),
+		Algorithm:  uint8(nums[1]),
+		DigestType: uint8(nums[2]),
+		Digest:     digest,
+	}), true
+}
+
+// DNSKEYRecords 返回结果中的DNSKEY记录，保持应答中的顺序
+func (r *QueryResult) DNSKEYRecords() []DNSKEYRecord {
+	var records []DNSKEYRecord
+	for _, record := range r.Records {
+		if key, ok := record.DNSKEY(); ok {
+			records = append(records, *key)
+		}
+	}
+	return records
+}
+
+// DSRecords 返回结果中的DS记录，保持应答中的顺序
+func (r *QueryResult) DSRecords() []DSRecord {
+	var records []DSRecord
+	for _, record := range r.Records {
+		if ds, ok := record.DS(); ok {
+			records = append(records, *ds)
+		}
+	}
+	return records
+}
+
+// QueryDNSKEY 查询区域的DNSKEY记录，结构化的字段可通过 QueryResult.DNSKEYRecords 获取
+// DNSKEY应答经常超过512字节：查询通告 1232 字节的EDNS缓冲区，UDP应答仍被截断时改用TCP重新查询
+func (c *Client) QueryDNSKEY(ctx context.Context, zone string) (*QueryResult, error) {
+	return c.Query(withLargeAnswer(ctx), zone, dns.TypeDNSKEY)
+}
+
+// QueryDS 查询区域的DS记录（由父区域提供），结构化的字段可通过 QueryResult.DSRecords 获取
+// 与 QueryDNSKEY 一样使用较大的EDNS缓冲区，截断时改用TCP
+func (c *Client) QueryDS(ctx context.Context, zone string) (*QueryResult, error) {
+	return c.Query(withLargeAnswer(ctx), zone, dns.TypeDS)
+}
@@ -0,0 +1,138 @@
+package godns_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+	"github.com/zan8in/godns/internal/testserver"
+)
+
+// zoneKeys 返回区域 example.test 的一对 KSK/ZSK 以及指向 KSK 的 SHA-256 DS记录
+func zoneKeys() (ksk, zsk *dns.DNSKEY, ds *dns.DS) {
+	ksk = mustRR("example.test. 300 IN DNSKEY 257 3 13 mdsswUyr3DPW132mOi8V9xESWE8jTo0dxCjjnopKl+GqJxpVXckHAeF+KkxLbxILfDLUT0rAK9iUzy1L53eKGQ==").(*dns.DNSKEY)
+	zsk = mustRR("example.test. 300 IN DNSKEY 256 3 13 " + base64.StdEncoding.EncodeToString([]byte(strings.Repeat("zone-signing-key", 4)))).(*dns.DNSKEY)
+	return ksk, zsk, ksk.ToDS(dns.SHA256)
+}
+
+// TestQueryDNSKEY DNSKEY记录的各字段和计算得到的密钥标签，标志位辅助方法，以及反序列化后从 Value 解析
+func TestQueryDNSKEY(t *testing.T) {
+	ksk, zsk, _ := zoneKeys()
+	s := startServer(t)
+	s.Handle("example.test", dns.TypeDNSKEY, testserver.Reply{Answer: []dns.RR{ksk, zsk}})
+	c := godns.New(godns.WithServers(s.UDPAddr), godns.WithRetries(0))
+	defer c.Close()
+
+	res, err := c.QueryDNSKEY(context.Background(), "example.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := res.DNSKEYRecords()
+	if len(keys) != 2 {
+		t.Fatalf("DNSKEYRecords = %v", keys)
+	}
+	for i, want := range []*dns.DNSKEY{ksk, zsk} {
+		got := keys[i]
+		if got.Flags != want.Flags || got.Protocol != 3 || got.Algorithm != dns.ECDSAP256SHA256 ||
+			got.KeyTag != want.KeyTag() || got.PublicKey != want.PublicKey {
+			t.Errorf("key %d = %+v, want tag %d", i, got, want.KeyTag())
+		}
+		if !got.ZoneKey() || got.Revoked() {
+			t.Errorf("key %d: ZoneKey %v, Revoked %v", i, got.ZoneKey(), got.Revoked())
+		}
+	}
+	if !keys[0].SecureEntryPoint() || keys[1].SecureEntryPoint() {
+		t.Errorf("SecureEntryPoint = %v, %v; want only the KSK", keys[0].SecureEntryPoint(), keys[1].SecureEntryPoint())
+	}
+	if keys[0].String() != res.Records[0].Value() {
+		t.Errorf("String = %q, Value = %q", keys[0].String(), res.Records[0].Value())
+	}
+
+	data, err := json.Marshal(res.Records[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded godns.Record
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := decoded.DNSKEY(); !ok || *got != keys[0] {
+		t.Errorf("after JSON round trip DNSKEY = %+v, %v; want %+v", got, ok, keys[0])
+	}
+	if _, ok := decoded.DS(); ok {
+		t.Error("DNSKEY record parsed as DS")
+	}
+}
+
+// TestQueryDS DS记录的各字段，摘要统一为大写十六进制，Matches 核对DS与子区域密钥
+func TestQueryDS(t *testing.T) {
+	ksk, zsk, ds := zoneKeys()
+	ds.Digest = strings.ToLower(ds.Digest)
+	s := startServer(t)
+	s.Handle("example.test", dns.TypeDS, testserver.Reply{Answer: []dns.RR{ds}})
+	c := godns.New(godns.WithServers(s.UDPAddr), godns.WithRetries(0))
+	defer c.Close()
+
+	res, err := c.QueryDS(context.Background(), "example.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	records := res.DSRecords()
+	if len(records) != 1 {
+		t.Fatalf("DSRecords = %v", records)
+	}
+	got := records[0]
+	want := godns.DSRecord{KeyTag: ksk.KeyTag(), Algorithm: dns.ECDSAP256SHA256, DigestType: dns.SHA256, Digest: strings.ToUpper(ds.Digest)}
+	if got != want {
+		t.Errorf("DS = %+v, want %+v", got, want)
+	}
+
+	data, err := json.Marshal(res.Records[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded godns.Record
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if parsed, ok := decoded.DS(); !ok || *parsed != want {
+		t.Errorf("after JSON round trip DS = %+v, %v", parsed, ok)
+	}
+
+	kskRecord := godns.DNSKEYRecord{Flags: ksk.Flags, Protocol: ksk.Protocol, Algorithm: ksk.Algorithm, KeyTag: ksk.KeyTag(), PublicKey: ksk.PublicKey}
+	zskRecord := godns.DNSKEYRecord{Flags: zsk.Flags, Protocol: zsk.Protocol, Algorithm: zsk.Algorithm, KeyTag: zsk.KeyTag(), PublicKey: zsk.PublicKey}
+	if !got.Matches("example.test", &kskRecord) {
+		t.Error("DS does not match the KSK it was computed from")
+	}
+	if got.Matches("example.test", &zskRecord) || got.Matches("other.test", &kskRecord) {
+		t.Error("DS matches a different key or zone")
+	}
+}
+
+// TestDNSKEYLargeAnswer DNSKEY/DS查询通告 1232 字节的EDNS缓冲区，UDP应答被截断时改用TCP重新查询
+func TestDNSKEYLargeAnswer(t *testing.T) {
+	ksk, zsk, _ := zoneKeys()
+	s := startServer(t)
+	s.Handle("example.test", dns.TypeDNSKEY, testserver.Reply{Answer: []dns.RR{ksk, zsk}, Truncate: true})
+	c := godns.New(godns.WithServers(s.UDPAddr), godns.WithRetries(0))
+	defer c.Close()
+
+	res, err := c.QueryDNSKEY(context.Background(), "example.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.DNSKEYRecords()) != 2 {
+		t.Errorf("DNSKEYRecords = %v, want both keys from the TCP retry", res.DNSKEYRecords())
+	}
+	queries := s.Queries()
+	if len(queries) != 2 || queries[0].Protocol != testserver.UDP || queries[1].Protocol != testserver.TCP {
+		t.Fatalf("queries = %v, want UDP then TCP", queries)
+	}
+	if opt := queries[0].Msg.IsEdns0(); opt == nil || opt.UDPSize() != 1232 {
+		t.Errorf("UDP query OPT = %v, want a 1232 byte buffer", opt)
+	}
+}
@@ -0,0 +1,138 @@
+package godns
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/quic-go/quic-go"
+)
+
+// DoQ 应用层错误码（RFC 9250 §4.3）
+const (
+	doqNoError          quic.ApplicationErrorCode = 0x0
+	doqRequestCancelled quic.StreamErrorCode      = 0x3
+)
+
+// doqALPN DoQ 的 ALPN 标识（RFC 9250 §4.1.1）
+const doqALPN = "doq"
+
+// exchangeDoQ DoQ查询（RFC 9250）：每次查询新建QUIC连接，在一个双向流上发送带2字节长度前缀的消息，
+// 发送后关闭流的写方向，读取同样带长度前缀的应答。按 RFC 要求发送的消息ID为 0，应答的ID恢复为原查询的ID
+// QUIC 基于UDP，不支持经 SOCKS5/HTTP 代理转发
+func (c *Client) exchangeDoQ(ctx context.Context, msg *dns.Msg, info ServerInfo) (*dns.Msg, error) {
+	server := info.Address
+	if c.transports.tlsErr != nil {
+		return nil, c.transports.tlsErr
+	}
+	if c.config.ProxyType != NoProxy {
+		return nil, fmt.Errorf("DoQ cannot be used through a %s proxy", c.config.ProxyType)
+	}
+
+	tlsConfig := c.tlsConfigFor(server).Clone()
+	tlsConfig.NextProtos = []string{doqALPN}
+	if tlsConfig.ServerName == "" {
+		if host, _, err := net.SplitHostPort(server); err == nil {
+			tlsConfig.ServerName = host
+		}
+	}
+
+	network := c.network(DoQ)
+	addr, err := net.ResolveUDPAddr(network, server)
+	if err != nil {
+		return nil, err
+	}
+	udpConn, err := net.ListenUDP(network, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer udpConn.Close()
+
+	deadline := c.attemptDeadline(ctx)
+	quicConfig := &quic.Config{}
+	if !deadline.IsZero() {
+		quicConfig.HandshakeIdleTimeout = time.Until(deadline)
+	}
+	conn, err := quic.Dial(ctx, udpConn, addr, tlsConfig, quicConfig)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	defer conn.CloseWithError(doqNoError, "")
+
+	stream, err := conn.OpenStreamSync(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stream.SetDeadline(deadline)
+	// context 取消时以 DOQ_REQUEST_CANCELLED 重置流，让阻塞的读写立即返回
+	stop := context.AfterFunc(ctx, func() {
+		stream.CancelRead(doqRequestCancelled)
+		stream.CancelWrite(doqRequestCancelled)
+	})
+	defer stop()
+
+	query := msg.Copy()
+	query.Id = 0
+	wire, err := query.Pack()
+	if err != nil {
+		return nil, fmt.Errorf("failed to pack DNS message: %v", err)
+	}
+	start := time.Now()
+	buf := make([]byte, 2+len(wire))
+	binary.BigEndian.PutUint16(buf, uint16(len(wire)))
+	copy(buf[2:], wire)
+	if _, err := stream.Write(buf); err != nil {
+		return nil, doqError(ctx, "failed to write DNS message", err)
+	}
+	// 客户端发送查询后必须关闭流的写方向（RFC 9250 §4.2）
+	stream.Close()
+
+	response, err := readDoQResponse(stream, c.config.MaxResponseBytes)
+	if err != nil {
+		return nil, doqError(ctx, "failed to read DNS response", err)
+	}
+	// 查询以ID 0发送，应答的ID也必须为 0（RFC 9250 §4.2.1）
+	if err := checkResponseID(response.Id, 0); err != nil {
+		return nil, err
+	}
+	setRTT(ctx, time.Since(start))
+	setRespondedBy(ctx, conn.RemoteAddr())
+	response.Id = msg.Id
+	return response, nil
+}
+
+// readDoQResponse 读取带2字节长度前缀的应答，超过 limit 字节时返回 *ResponseTooLargeError
+func readDoQResponse(r io.Reader, limit int) (*dns.Msg, error) {
+	var length [2]byte
+	if _, err := io.ReadFull(r, length[:]); err != nil {
+		return nil, err
+	}
+	size := int(binary.BigEndian.Uint16(length[:]))
+	if limit > 0 && size > limit {
+		return nil, &ResponseTooLargeError{Size: size, Limit: limit}
+	}
+	wire := make([]byte, size)
+	if _, err := io.ReadFull(r, wire); err != nil {
+		return nil, err
+	}
+	response := new(dns.Msg)
+	if err := response.Unpack(wire); err != nil {
+		return nil, fmt.Errorf("failed to unpack DNS response: %v", err)
+	}
+	return response, nil
+}
+
+// doqError context 已结束时返回它的错误，否则包装流上的读写错误
+func doqError(ctx context.Context, op string, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return fmt.Errorf("%s: %w", op, err)
+}
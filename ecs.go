@@ -0,0 +1,50 @@
+package godns
+
+import (
+	"fmt"
+	"net/netip"
+
+	"github.com/miekg/dns"
+)
+
+// WithClientSubnet 在每个查询中附加 EDNS Client Subnet 选项（RFC 7871），cidr 例如 "203.0.113.0/24"、"2001:db8::/56"，
+// 用于测试CDN等按客户端网络返回不同应答的服务；前缀之外的地址位按 RFC 7871 §6 清零
+// 与DNSSEC等需要EDNS的查询共用同一个OPT记录；应答按子网分别缓存
+// cidr 格式错误时查询返回错误，而不是不带该选项发送
+func WithClientSubnet(cidr string) Option {
+	return func(c *Config) {
+		c.ClientSubnet = cidr
+	}
+}
+
+// parseClientSubnet 将 CIDR 解析为ECS选项，源前缀长度取 CIDR 的前缀长度，作用域前缀为 0
+func parseClientSubnet(cidr string) (*dns.EDNS0_SUBNET, error) {
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid client subnet %q: %w", cidr, err)
+	}
+	prefix = prefix.Masked()
+	ecs := &dns.EDNS0_SUBNET{
+		Code:          dns.EDNS0SUBNET,
+		SourceNetmask: uint8(prefix.Bits()),
+		Address:       prefix.Addr().AsSlice(),
+	}
+	if prefix.Addr().Is4() {
+		ecs.Family = 1
+	} else {
+		ecs.Family = 2
+	}
+	return ecs, nil
+}
+
+// setClientSubnet 在查询消息中附加ECS选项，消息还没有OPT记录时先添加
+func setClientSubnet(msg *dns.Msg, ecs *dns.EDNS0_SUBNET) {
+	opt := msg.IsEdns0()
+	if opt == nil {
+		msg.SetEdns0(dnssecUDPSize, false)
+		opt = msg.IsEdns0()
+	}
+	subnet := *ecs
+	subnet.Address = append([]byte(nil), ecs.Address...)
+	opt.Option = append(opt.Option, &subnet)
+}
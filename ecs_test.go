@@ -0,0 +1,98 @@
+package godns_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+)
+
+// TestClientSubnetWireFormat ECS选项按 RFC 7871 §6 序列化：地址族、源前缀、作用域前缀为 0，
+// 地址截断到源前缀所需的字节数且前缀之外的位清零
+func TestClientSubnetWireFormat(t *testing.T) {
+	tests := []struct {
+		cidr   string
+		family uint16
+		source uint8
+		want   []byte // 选项码、长度之后的全部内容
+	}{
+		{"203.0.113.0/24", 1, 24, []byte{0, 1, 24, 0, 203, 0, 113}},
+		{"203.0.113.77/20", 1, 20, []byte{0, 1, 20, 0, 203, 0, 112}},
+		{"198.51.100.7/32", 1, 32, []byte{0, 1, 32, 0, 198, 51, 100, 7}},
+		{"0.0.0.0/0", 1, 0, []byte{0, 1, 0, 0}},
+		{"2001:db8:ffff::/56", 2, 56, []byte{0, 2, 56, 0, 0x20, 0x01, 0x0d, 0xb8, 0xff, 0xff, 0}},
+		{"2001:db8::1/61", 2, 61, []byte{0, 2, 61, 0, 0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.cidr, func(t *testing.T) {
+			s := startServer(t)
+			s.Answer("example.com", dns.TypeA, "example.com. 60 IN A 192.0.2.1")
+			var wire []byte
+			c := godns.New(
+				godns.WithServers(s.UDPAddr),
+				godns.WithClientSubnet(tt.cidr),
+				godns.WithRetries(0),
+				godns.WithOnRequest(func(_ context.Context, _ string, msg *dns.Msg) {
+					wire, _ = msg.Pack()
+				}),
+			)
+			defer c.Close()
+			// 与 DO 位共用同一个OPT记录
+			ctx := godns.WithDO(context.Background(), true)
+			if _, err := c.QueryA(ctx, "example.com"); err != nil {
+				t.Fatal(err)
+			}
+
+			option := append([]byte{0, dns.EDNS0SUBNET, 0, byte(len(tt.want))}, tt.want...)
+			if !bytes.Contains(wire, option) {
+				t.Errorf("query wire %x does not contain the ECS option %x", wire, option)
+			}
+
+			queries := s.Queries()
+			if len(queries) != 1 {
+				t.Fatalf("server saw %d queries, want 1", len(queries))
+			}
+			msg := queries[0].Msg
+			opts := 0
+			for _, rr := range msg.Extra {
+				if rr.Header().Rrtype == dns.TypeOPT {
+					opts++
+				}
+			}
+			opt := msg.IsEdns0()
+			if opts != 1 || opt == nil || !opt.Do() {
+				t.Fatalf("server saw %d OPT records (DO = %v), want one with the DO bit", opts, opt != nil && opt.Do())
+			}
+			var ecs *dns.EDNS0_SUBNET
+			for _, o := range opt.Option {
+				if e, ok := o.(*dns.EDNS0_SUBNET); ok {
+					ecs = e
+				}
+			}
+			if ecs == nil {
+				t.Fatal("server saw no ECS option")
+			}
+			if ecs.Family != tt.family || ecs.SourceNetmask != tt.source || ecs.SourceScope != 0 {
+				t.Errorf("ECS family/source/scope = %d/%d/%d, want %d/%d/0", ecs.Family, ecs.SourceNetmask, ecs.SourceScope, tt.family, tt.source)
+			}
+		})
+	}
+}
+
+// TestClientSubnetInvalid 格式错误的 CIDR 在查询时报错，不会不带该选项发送
+func TestClientSubnetInvalid(t *testing.T) {
+	s := startServer(t)
+	c := godns.New(godns.WithServers(s.UDPAddr), godns.WithClientSubnet("203.0.113.0/33"), godns.WithRetries(0))
+	defer c.Close()
+
+	_, err := c.QueryA(context.Background(), "example.com")
+	if err == nil || !strings.Contains(err.Error(), `invalid client subnet "203.0.113.0/33"`) {
+		t.Errorf("Query err = %v, want an invalid client subnet error", err)
+	}
+	if n := len(s.Queries()); n != 0 {
+		t.Errorf("server saw %d queries, want none", n)
+	}
+}
@@ -0,0 +1,97 @@
+package godns
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// defaultNoEDNSExpiry 服务器"不支持EDNS"记忆的默认有效期，过期后重新尝试EDNS
+const defaultNoEDNSExpiry = 30 * time.Minute
+
+// ednsMemory 记录不支持EDNS的服务器
+type ednsMemory struct {
+	mu     sync.Mutex
+	until  map[string]time.Time
+	expiry time.Duration
+	clock  Clock
+}
+
+func newEDNSMemory(clock Clock) *ednsMemory {
+	return &ednsMemory{
+		until:  make(map[string]time.Time),
+		expiry: defaultNoEDNSExpiry,
+		clock:  clock,
+	}
+}
+
+// disabled 服务器当前是否被记为不支持EDNS
+func (m *ednsMemory) disabled(server string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	until, ok := m.until[server]
+	if !ok {
+		return false
+	}
+	if !m.clock.Now().Before(until) {
+		delete(m.until, server)
+		return false
+	}
+	return true
+}
+
+// markNoEDNS 记录服务器不支持EDNS
+func (m *ednsMemory) markNoEDNS(server string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.until[server] = m.clock.Now().Add(m.expiry)
+}
+
+// stripEDNS 移除消息中的OPT记录，返回是否有移除
+func stripEDNS(msg *dns.Msg) bool {
+	extra := msg.Extra[:0]
+	stripped := false
+	for _, rr := range msg.Extra {
+		if rr.Header().Rrtype == dns.TypeOPT {
+			stripped = true
+			continue
+		}
+		extra = append(extra, rr)
+	}
+	msg.Extra = extra
+	return stripped
+}
+
+// exchangeEDNSAware 发送查询，服务器对EDNS查询返回FORMERR/NOTIMP时去掉EDNS重试一次，
+// 并记住该服务器不支持EDNS，后续查询直接跳过EDNS
+// 返回实际得到应答的查询消息：降级后是去掉EDNS的副本，应答须按它缓存（不带DO位的应答不能冒充DO查询的应答）
+func (c *Client) exchangeEDNSAware(ctx context.Context, protocol Protocol, msg *dns.Msg, server string) (*dns.Msg, *dns.Msg, error) {
+	response, err := c.exchangeWithFallback(ctx, protocol, msg, server)
+	if err != nil || msg.IsEdns0() == nil {
+		response, err = c.retryTruncated(ctx, protocol, msg, server, response, err)
+		return msg, response, err
+	}
+	if response.Rcode != dns.RcodeFormatError && response.Rcode != dns.RcodeNotImplemented {
+		response, err = c.retryTruncated(ctx, protocol, msg, server, response, nil)
+		return msg, response, err
+	}
+
+	plain := msg.Copy()
+	stripEDNS(plain)
+	c.edns.markNoEDNS(server)
+	recorderFrom(ctx).markEDNSDowngraded()
+
+	response, err = c.exchangeWithFallback(ctx, protocol, plain, server)
+	response, err = c.retryTruncated(ctx, protocol, plain, server, response, err)
+	return plain, response, err
+}
+
+// retryTruncated 大应答查询（见 largeAnswerKey）的UDP应答被截断时，改用TCP向同一服务器重新查询
+func (c *Client) retryTruncated(ctx context.Context, protocol Protocol, msg *dns.Msg, server string, response *dns.Msg, err error) (*dns.Msg, error) {
+	if err != nil || !response.Truncated || protocol != UDP || !largeAnswer(ctx) {
+		return response, err
+	}
+	return c.exchange(ctx, TCP, msg, server)
+}
@@ -0,0 +1,127 @@
+package godns_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+	"github.com/zan8in/godns/internal/testserver"
+)
+
+// ednsSent 返回服务器收到的各查询是否带OPT记录
+func ednsSent(s *testserver.Server) []bool {
+	var sent []bool
+	for _, q := range s.Queries() {
+		sent = append(sent, q.Msg.IsEdns0() != nil)
+	}
+	return sent
+}
+
+// TestEDNSFormErrFallback 服务器对EDNS查询返回 FORMERR/NOTIMP 时去掉EDNS重试，记住降级直到过期
+func TestEDNSFormErrFallback(t *testing.T) {
+	for _, rcode := range []int{dns.RcodeFormatError, dns.RcodeNotImplemented} {
+		t.Run(dns.RcodeToString[rcode], func(t *testing.T) {
+			s := startServer(t)
+			answer := testserver.Reply{Answer: []dns.RR{testserver.RR("old.test. 60 IN A 192.0.2.1")}}
+			s.Handle("old.test", dns.TypeA, testserver.Reply{Rcode: rcode}, answer)
+			clock := newFakeClock()
+			c := godns.New(godns.WithServers(s.UDPAddr), godns.WithClock(clock), godns.WithRetries(0))
+			defer c.Close()
+			ctx := godns.WithDO(context.Background(), true)
+
+			// 首次查询：带EDNS的查询被拒绝，去掉EDNS后重试成功
+			res, err := c.QueryA(ctx, "old.test")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(res.Records) != 1 || !res.Path.EDNSDowngraded {
+				t.Errorf("records = %v, downgraded = %v", res.Records, res.Path.EDNSDowngraded)
+			}
+			if got := ednsSent(s); !slices.Equal(got, []bool{true, false}) {
+				t.Fatalf("EDNS per query = %v, want [true false]", got)
+			}
+
+			// 记住降级：直接发送不带EDNS的查询
+			res, err = c.QueryA(ctx, "old.test")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !res.Path.EDNSDowngraded || len(res.Path.Attempts) != 1 {
+				t.Errorf("downgraded = %v, attempts = %d", res.Path.EDNSDowngraded, len(res.Path.Attempts))
+			}
+			if got := ednsSent(s); !slices.Equal(got, []bool{true, false, false}) {
+				t.Fatalf("EDNS per query = %v, want [true false false]", got)
+			}
+
+			// 记忆过期后重新尝试EDNS
+			clock.Advance(31 * time.Minute)
+			res, err = c.QueryA(ctx, "old.test")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Path.EDNSDowngraded {
+				t.Error("EDNS still downgraded after the memory expired")
+			}
+			if got := ednsSent(s); !slices.Equal(got, []bool{true, false, false, true}) {
+				t.Errorf("EDNS per query = %v, want [true false false true]", got)
+			}
+		})
+	}
+}
+
+// TestEDNSFormErrWithoutEDNS 不带EDNS的查询收到 FORMERR 时不重试也不记住降级
+func TestEDNSFormErrWithoutEDNS(t *testing.T) {
+	s := startServer(t)
+	s.Handle("bad.test", dns.TypeA, testserver.Reply{Rcode: dns.RcodeFormatError})
+	c := godns.New(godns.WithServers(s.UDPAddr), godns.WithRetries(0))
+	defer c.Close()
+
+	res, _ := c.QueryA(context.Background(), "bad.test")
+	if res.Path.EDNSDowngraded {
+		t.Error("plain query was marked as EDNS downgraded")
+	}
+	if n := len(s.Queries()); n != 1 {
+		t.Errorf("server saw %d queries, want 1", n)
+	}
+}
+
+// TestEDNSDowngradeCachesUnderSentQuery 降级后得到的应答按实际发出的（不带EDNS的）查询缓存，不冒充DO查询的应答
+func TestEDNSDowngradeCachesUnderSentQuery(t *testing.T) {
+	s := startServer(t)
+	answer := testserver.Reply{Answer: []dns.RR{testserver.RR("old.test. 60 IN A 192.0.2.1")}}
+	s.Handle("old.test", dns.TypeA, testserver.Reply{Rcode: dns.RcodeFormatError}, answer)
+	c := godns.New(godns.WithServers(s.UDPAddr), godns.WithCache(time.Hour), godns.WithRetries(0))
+	defer c.Close()
+	doCtx := godns.WithDO(context.Background(), true)
+
+	if _, err := c.QueryA(doCtx, "old.test"); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(s.Queries()); n != 2 {
+		t.Fatalf("server saw %d queries, want 2 (EDNS then plain)", n)
+	}
+
+	// 不带DO位的查询命中降级应答的缓存
+	res, err := c.QueryA(context.Background(), "old.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Path.Source != godns.SourceCache {
+		t.Errorf("plain query source = %v, want the cache", res.Path.Source)
+	}
+
+	// DO查询不能使用不带DO位得到的应答
+	res, err = c.QueryA(doCtx, "old.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Path.Source != godns.SourceNetwork {
+		t.Errorf("DO query source = %v, want the network", res.Path.Source)
+	}
+	if n := len(s.Queries()); n != 3 {
+		t.Errorf("server saw %d queries, want 3", n)
+	}
+}
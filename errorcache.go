@@ -0,0 +1,137 @@
+package godns
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 应答来源
+const (
+	SourceErrorCache = "error-cache" // 近期失败被抑制，未发送查询
+)
+
+// WithErrorCaching 在 ttl 时间窗口内记住对同一服务器同一问题的超时、SERVFAIL 和网络错误，
+// 窗口内的重复查询直接返回记住的错误而不访问上游，用于抑制应用层的重试风暴
+// 窗口应尽量短（通常数百毫秒到数秒）；任意一次成功的查询立即清除对应条目，
+// ContextWithNoErrorCache 可强制单次查询真正发出请求
+// NXDOMAIN 是确定的应答，由否定缓存处理，不会被记住
+func WithErrorCaching(ttl time.Duration) Option {
+	return func(c *Config) {
+		c.ErrorCacheTTL = ttl
+	}
+}
+
+// noErrorCacheKey 标记单次查询忽略已记住的错误
+type noErrorCacheKey struct{}
+
+// ContextWithNoErrorCache 返回忽略 WithErrorCaching 已记住错误的 context，查询总是发往上游
+func ContextWithNoErrorCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noErrorCacheKey{}, true)
+}
+
+// ErrorCacheStats 错误缓存的统计信息
+type ErrorCacheStats struct {
+	Entries    int    // 当前记住的失败数
+	Suppressed uint64 // 被抑制、未发往上游的查询数
+}
+
+// ErrorCacheStats 返回错误缓存的统计信息，未启用 WithErrorCaching 时为零值
+func (c *Client) ErrorCacheStats() ErrorCacheStats {
+	if c.errCache == nil {
+		return ErrorCacheStats{}
+	}
+	return c.errCache.stats()
+}
+
+// errorEntry 记住的失败
+type errorEntry struct {
+	err     error
+	expires time.Time
+}
+
+// errorCache 按服务器和问题记住的近期失败
+type errorCache struct {
+	mu         sync.Mutex
+	entries    map[string]errorEntry
+	ttl        time.Duration
+	clock      Clock
+	suppressed atomic.Uint64
+}
+
+func newErrorCache(clock Clock, ttl time.Duration) *errorCache {
+	return &errorCache{
+		entries: make(map[string]errorEntry),
+		ttl:     ttl,
+		clock:   clock,
+	}
+}
+
+// errorCacheKey 生成错误缓存键，失败与具体服务器相关，因此键中包含服务器
+func errorCacheKey(server, domain string, qtype uint16) string {
+	return server + "|" + cacheKey(domain, qtype)
+}
+
+// cacheableError 是否应记住该错误：只记住上游的硬性失败，调用方自身取消或超时的查询不记住
+func cacheableError(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	switch classifyError(err) {
+	case KindTimeout, KindServFail, KindNetwork:
+		return true
+	}
+	return false
+}
+
+// get 返回窗口内记住的错误，命中时计入抑制次数
+func (ec *errorCache) get(key string) (error, bool) {
+	ec.mu.Lock()
+	defer ec.mu.Unlock()
+	entry, ok := ec.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if !ec.clock.Now().Before(entry.expires) {
+		delete(ec.entries, key)
+		return nil, false
+	}
+	ec.suppressed.Add(1)
+	return fmt.Errorf("recent failure suppressed: %w", entry.err), true
+}
+
+// set 记住失败，超出容量时先清理过期条目，仍不足则不再记录
+func (ec *errorCache) set(key string, err error) {
+	ec.mu.Lock()
+	defer ec.mu.Unlock()
+	now := ec.clock.Now()
+	if _, ok := ec.entries[key]; !ok && len(ec.entries) >= defaultCacheEntries {
+		for k, e := range ec.entries {
+			if !now.Before(e.expires) {
+				delete(ec.entries, k)
+			}
+		}
+		if len(ec.entries) >= defaultCacheEntries {
+			return
+		}
+	}
+	ec.entries[key] = errorEntry{err: err, expires: now.Add(ec.ttl)}
+}
+
+// clear 成功应答后清除记住的失败
+func (ec *errorCache) clear(key string) {
+	ec.mu.Lock()
+	delete(ec.entries, key)
+	ec.mu.Unlock()
+}
+
+func (ec *errorCache) stats() ErrorCacheStats {
+	ec.mu.Lock()
+	defer ec.mu.Unlock()
+	return ErrorCacheStats{
+		Entries:    len(ec.entries),
+		Suppressed: ec.suppressed.Load(),
+	}
+}
@@ -0,0 +1,134 @@
+package godns_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+	"github.com/zan8in/godns/internal/testserver"
+)
+
+// TestErrorCachingRetryStorm 应用层对失效服务器的重试风暴在窗口内最多到达上游一次，窗口过期后才再次发出
+func TestErrorCachingRetryStorm(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply testserver.Reply
+		kind  godns.ErrorKind
+	}{
+		{"servfail", testserver.Reply{Rcode: dns.RcodeServerFailure}, godns.KindServFail},
+		{"timeout", testserver.Reply{Drop: true}, godns.KindTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := startServer(t)
+			s.Handle("dead.test", dns.TypeA, tt.reply)
+			clock := newFakeClock()
+			c := godns.New(
+				godns.WithServers(s.UDPAddr),
+				godns.WithErrorCaching(time.Second),
+				godns.WithClock(clock),
+				godns.WithRetries(0),
+				godns.WithTimeout(50*time.Millisecond),
+			)
+			defer c.Close()
+			ctx := context.Background()
+
+			// 首次失败被记住，之后的顺序重试全部被抑制
+			const sequential = 200
+			for i := range sequential {
+				_, err := c.QueryA(ctx, "dead.test")
+				var info *godns.ErrorInfo
+				if !errors.As(err, &info) || info.Kind != tt.kind {
+					t.Fatalf("attempt %d: err = %v, want %s", i, err, tt.kind)
+				}
+			}
+			if n := len(s.Queries()); n != 1 {
+				t.Fatalf("upstream saw %d queries during a %d-call storm, want 1", n, sequential)
+			}
+
+			// 并发风暴同样被抑制
+			const workers, perWorker = 20, 20
+			var wg sync.WaitGroup
+			for range workers {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for range perWorker {
+						c.QueryA(ctx, "dead.test")
+					}
+				}()
+			}
+			wg.Wait()
+			if n := len(s.Queries()); n != 1 {
+				t.Errorf("upstream saw %d queries after the concurrent storm, want 1", n)
+			}
+			st := c.ErrorCacheStats()
+			if want := uint64(sequential - 1 + workers*perWorker); st.Suppressed != want || st.Entries != 1 {
+				t.Errorf("stats = %+v, want %d suppressed and 1 entry", st, want)
+			}
+
+			// 窗口过期后再次发往上游，并重新记住
+			clock.Advance(time.Second)
+			for range 50 {
+				c.QueryA(ctx, "dead.test")
+			}
+			if n := len(s.Queries()); n != 2 {
+				t.Errorf("upstream saw %d queries after the window expired, want 2", n)
+			}
+		})
+	}
+}
+
+// TestErrorCachingOverrideAndSuccess ContextWithNoErrorCache 强制发出查询，成功应答立即清除记住的失败
+func TestErrorCachingOverrideAndSuccess(t *testing.T) {
+	s := startServer(t)
+	s.Handle("flaky.test", dns.TypeA, testserver.Reply{Rcode: dns.RcodeServerFailure})
+	c := godns.New(godns.WithServers(s.UDPAddr), godns.WithErrorCaching(time.Minute), godns.WithRetries(0))
+	defer c.Close()
+	ctx := context.Background()
+
+	c.QueryA(ctx, "flaky.test")
+	res, _ := c.QueryA(ctx, "flaky.test")
+	if res.Path.Source != godns.SourceErrorCache || len(s.Queries()) != 1 {
+		t.Fatalf("source = %s after %d upstream queries, want the error cache after 1", res.Path.Source, len(s.Queries()))
+	}
+
+	// 服务器恢复：强制查询发往上游，成功后清除记住的失败
+	s.Answer("flaky.test", dns.TypeA, "flaky.test. 60 IN A 192.0.2.1")
+	if _, err := c.QueryA(godns.ContextWithNoErrorCache(ctx), "flaky.test"); err != nil {
+		t.Fatal(err)
+	}
+	if st := c.ErrorCacheStats(); st.Entries != 0 {
+		t.Errorf("entries after a success = %d, want 0", st.Entries)
+	}
+	res, err := c.QueryA(ctx, "flaky.test")
+	if err != nil || res.Path.Source != godns.SourceNetwork {
+		t.Errorf("query after recovery: source = %v, err = %v, want a network answer", res.Path.Source, err)
+	}
+	if n := len(s.Queries()); n != 3 {
+		t.Errorf("upstream saw %d queries, want 3", n)
+	}
+}
+
+// TestErrorCachingIgnoresNXDomain NXDOMAIN 是确定的应答，不被错误缓存记住
+func TestErrorCachingIgnoresNXDomain(t *testing.T) {
+	s := startServer(t)
+	c := godns.New(godns.WithServers(s.UDPAddr), godns.WithErrorCaching(time.Minute), godns.WithRetries(0))
+	defer c.Close()
+
+	for range 5 {
+		if _, err := c.QueryA(context.Background(), "missing.test"); !errors.Is(err, godns.ErrNXDomain) {
+			t.Fatalf("err = %v, want NXDOMAIN", err)
+		}
+	}
+	if n := len(s.Queries()); n != 5 {
+		t.Errorf("upstream saw %d queries, want 5", n)
+	}
+	if st := c.ErrorCacheStats(); st.Entries != 0 || st.Suppressed != 0 {
+		t.Errorf("stats = %+v, want empty", st)
+	}
+}
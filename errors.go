@@ -0,0 +1,186 @@
+package godns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/miekg/dns"
+)
+
+// ErrorKind 错误类别
+type ErrorKind string
+
+const (
+	KindTimeout       ErrorKind = "timeout"
+	KindNXDomain      ErrorKind = "nxdomain"
+	KindServFail      ErrorKind = "servfail"
+	KindRefusedName   ErrorKind = "refused-name"
+	KindRefusedClient ErrorKind = "refused-client"
+	KindRefused       ErrorKind = "refused" // 未区分名称或客户端的 REFUSED
+	KindNetwork       ErrorKind = "network"
+	KindNoServers     ErrorKind = "no-servers"
+	KindNoRecords     ErrorKind = "no-records"
+	KindOther         ErrorKind = "other"
+)
+
+// 可与 errors.Is 配合使用的错误类别哨兵值
+// 查询返回的错误以及 QueryResult.Error（包括反序列化得到的）都可以用 errors.Is 判断
+var (
+	ErrTimeout  = errors.New("dns query timeout")
+	ErrNXDomain = errors.New("dns: NXDOMAIN") // 名称不存在；Query 等查询方法返回包装它的 *RcodeError，同时照常返回填充好的结果（见 IsNXDOMAIN）
+	ErrServFail = errors.New("dns: SERVFAIL")
+	ErrRefused  = errors.New("dns: REFUSED") // 服务器拒绝应答，ErrRefusedName 和 ErrRefusedClient 均包装该错误
+	ErrNetwork  = errors.New("dns: network error")
+
+	ErrNoServers = errors.New("godns: no DNS servers configured") // 没有可查询的服务器
+	ErrNoRecords = errors.New("godns: no records")                // 名称存在但没有所需的记录
+)
+
+// RcodeError 服务器以失败的响应码应答，包含应答的服务器和响应码
+// Unwrap 返回 Err，Err 为 nil 时返回响应码对应的哨兵值（ErrNXDomain、ErrServFail、ErrRefused）
+type RcodeError struct {
+	Rcode  int    // 应答的响应码，例如 dns.RcodeServerFailure
+	Server string // 应答的服务器
+	Err    error  // 更具体的原因，例如 REFUSED 的分类；可为 nil
+}
+
+// Error 实现 error 接口
+func (e *RcodeError) Error() string {
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	name, ok := dns.RcodeToString[e.Rcode]
+	if !ok {
+		name = "RCODE" + strconv.Itoa(e.Rcode)
+	}
+	return fmt.Sprintf("dns: %s from %s", name, e.Server)
+}
+
+// Unwrap 返回具体原因或响应码对应的哨兵值
+func (e *RcodeError) Unwrap() error {
+	if e.Err != nil {
+		return e.Err
+	}
+	switch e.Rcode {
+	case dns.RcodeNameError:
+		return ErrNXDomain
+	case dns.RcodeServerFailure:
+		return ErrServFail
+	case dns.RcodeRefused:
+		return ErrRefused
+	}
+	return nil
+}
+
+// kindSentinels 错误类别对应的哨兵值，classifyError 按顺序匹配，
+// 一个错误同时匹配多个哨兵值时（例如同时包装超时和网络错误）结果是确定的
+var kindSentinels = []struct {
+	kind     ErrorKind
+	sentinel error
+}{
+	{KindTimeout, ErrTimeout},
+	{KindNXDomain, ErrNXDomain},
+	{KindServFail, ErrServFail},
+	{KindRefusedName, ErrRefusedName},
+	{KindRefusedClient, ErrRefusedClient},
+	{KindRefused, ErrRefused}, // 位于更具体的两种 REFUSED 分类之后
+	{KindNetwork, ErrNetwork},
+	{KindNoServers, ErrNoServers},
+	{KindNoRecords, ErrNoRecords},
+}
+
+// kindSentinel 返回错误类别对应的哨兵值
+func kindSentinel(kind ErrorKind) (error, bool) {
+	for _, ks := range kindSentinels {
+		if ks.kind == kind {
+			return ks.sentinel, true
+		}
+	}
+	return nil, false
+}
+
+// ErrorInfo 可序列化的查询错误信息，可安全地通过 JSON/gob 跨进程传递
+type ErrorInfo struct {
+	Message string    // 错误描述
+	Kind    ErrorKind // 错误类别
+	Server  string    // 出错的服务器
+	Attempt int       // 失败前的尝试次数
+	Client  string    // 产生该错误的客户端名称
+
+	cause error // 原始错误，仅在进程内可用，不参与序列化
+}
+
+// NewErrorInfo 根据原始错误构建可序列化的错误信息，错误类别自动归类
+// 可用于在自定义 Resolver 实现或测试中构建失败的结果
+func NewErrorInfo(err error, server string) *ErrorInfo {
+	if err == nil {
+		return nil
+	}
+	return &ErrorInfo{
+		Message: err.Error(),
+		Kind:    classifyError(err),
+		Server:  server,
+		cause:   err,
+	}
+}
+
+// newErrorInfo 根据原始错误构建错误信息
+func (c *Client) newErrorInfo(err error, server string, attempt int) *ErrorInfo {
+	info := NewErrorInfo(err, server)
+	if info != nil {
+		info.Attempt = attempt
+		info.Client = c.name
+	}
+	return info
+}
+
+// Error 实现 error 接口
+func (e *ErrorInfo) Error() string {
+	return e.Message
+}
+
+// Unwrap 返回错误类别哨兵值（反序列化后依然可用）以及进程内的原始错误
+func (e *ErrorInfo) Unwrap() []error {
+	errs := make([]error, 0, 2)
+	if sentinel, ok := kindSentinel(e.Kind); ok {
+		errs = append(errs, sentinel)
+	}
+	if e.cause != nil {
+		errs = append(errs, e.cause)
+	}
+	return errs
+}
+
+// ignoreNXDomain 把 NXDOMAIN 视为成功的否定应答，供按应答内容判断名称是否存在的内部调用方使用
+func ignoreNXDomain(err error) error {
+	if errors.Is(err, ErrNXDomain) {
+		return nil
+	}
+	return err
+}
+
+// classifyError 将原始错误归类
+func classifyError(err error) ErrorKind {
+	for _, ks := range kindSentinels {
+		if errors.Is(err, ks.sentinel) {
+			return ks.kind
+		}
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return KindTimeout
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return KindTimeout
+		}
+		return KindNetwork
+	}
+
+	return KindOther
+}
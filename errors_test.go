@@ -0,0 +1,200 @@
+package godns
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns/internal/testserver"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		err  error
+		kind ErrorKind
+	}{
+		{ErrTimeout, KindTimeout},
+		{context.DeadlineExceeded, KindTimeout},
+		{&RcodeError{Rcode: dns.RcodeNameError, Server: "a"}, KindNXDomain},
+		{&RcodeError{Rcode: dns.RcodeServerFailure, Server: "a"}, KindServFail},
+		{refusedError("a", ErrRefusedName), KindRefusedName},
+		{refusedError("a", ErrRefusedClient), KindRefusedClient},
+		{&RcodeError{Rcode: dns.RcodeRefused, Server: "a"}, KindRefused},
+		{fmt.Errorf("dial: %w", ErrNetwork), KindNetwork},
+		{ErrNoServers, KindNoServers},
+		{fmt.Errorf("%w: no addresses found for example.com", ErrNoRecords), KindNoRecords},
+		{errors.New("something else"), KindOther},
+		// 同时匹配多个哨兵值时按 kindSentinels 的顺序取第一个
+		{errors.Join(ErrNetwork, ErrTimeout), KindTimeout},
+		{errors.Join(ErrNetwork, ErrServFail), KindServFail},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 50; i++ {
+			if got := classifyError(tt.err); got != tt.kind {
+				t.Fatalf("classifyError(%v) = %s, want %s", tt.err, got, tt.kind)
+			}
+		}
+	}
+}
+
+// roundTrip 以 JSON 和 gob 分别编码解码 v，返回两个解码结果
+func roundTrip[T any](t *testing.T, v T) (fromJSON, fromGob T) {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &fromJSON); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		t.Fatal(err)
+	}
+	if err := gob.NewDecoder(&buf).Decode(&fromGob); err != nil {
+		t.Fatal(err)
+	}
+	return fromJSON, fromGob
+}
+
+func TestErrorInfoRoundTrip(t *testing.T) {
+	for _, sentinel := range []error{ErrTimeout, ErrNXDomain, ErrServFail, ErrRefusedName, ErrRefusedClient, ErrRefused, ErrNetwork, ErrNoServers, ErrNoRecords} {
+		info := NewErrorInfo(fmt.Errorf("query failed: %w", sentinel), "192.0.2.53:53")
+		info.Attempt = 3
+		info.Client = "test"
+		fromJSON, fromGob := roundTrip(t, info)
+		for codec, got := range map[string]*ErrorInfo{"json": fromJSON, "gob": fromGob} {
+			if got.Message != info.Message || got.Kind != info.Kind || got.Server != info.Server || got.Attempt != 3 || got.Client != "test" {
+				t.Errorf("%s: %+v, want %+v", codec, got, info)
+			}
+			if !errors.Is(got, sentinel) {
+				t.Errorf("%s: decoded %s error does not match %v", codec, got.Kind, sentinel)
+			}
+		}
+	}
+}
+
+func TestQueryResultRoundTrip(t *testing.T) {
+	rr, _ := dns.NewRR(`example.com. 300 IN TXT "a" "b"`)
+	ns, _ := dns.NewRR("example.com. 3600 IN NS ns1.example.com.")
+	ok := &QueryResult{
+		Domain:        "example.com",
+		Type:          dns.TypeTXT,
+		Server:        "192.0.2.53:53",
+		Records:       []Record{NewRecord(rr)},
+		Authority:     []Record{NewRecord(ns)},
+		Rcode:         dns.RcodeSuccess,
+		QueriedAt:     time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Duration:      5 * time.Millisecond,
+		Authoritative: true,
+	}
+	failed := &QueryResult{
+		Domain: "example.com",
+		Type:   dns.TypeTXT,
+		Server: "198.51.100.53:53",
+		Rcode:  dns.RcodeServerFailure,
+		Error:  NewErrorInfo(&RcodeError{Rcode: dns.RcodeServerFailure, Server: "198.51.100.53:53"}, "198.51.100.53:53"),
+	}
+
+	fromJSON, fromGob := roundTrip(t, ok)
+	for codec, got := range map[string]*QueryResult{"json": fromJSON, "gob": fromGob} {
+		if got.Domain != ok.Domain || got.Server != ok.Server || !got.QueriedAt.Equal(ok.QueriedAt) || got.Duration != ok.Duration || !got.Authoritative {
+			t.Errorf("%s: %+v", codec, got)
+		}
+		if len(got.Records) != 1 || got.Records[0].Value() != "ab" || len(got.Records[0].Values) != 2 {
+			t.Errorf("%s: records = %+v", codec, got.Records)
+		}
+		if len(got.Authority) != 1 || got.Authority[0].Value() != "ns1.example.com." {
+			t.Errorf("%s: authority = %+v", codec, got.Authority)
+		}
+		if got.Error != nil {
+			t.Errorf("%s: error = %v", codec, got.Error)
+		}
+	}
+
+	fromJSON, fromGob = roundTrip(t, failed)
+	for codec, got := range map[string]*QueryResult{"json": fromJSON, "gob": fromGob} {
+		if got.Error == nil || got.Rcode != dns.RcodeServerFailure {
+			t.Fatalf("%s: error = %v, rcode = %d", codec, got.Error, got.Rcode)
+		}
+		if got.Error.Kind != KindServFail || !errors.Is(got.Error, ErrServFail) || got.Error.Message != failed.Error.Message {
+			t.Errorf("%s: error = %+v", codec, got.Error)
+		}
+	}
+}
+
+// TestQuerySentinels 查询方法返回的错误可用 errors.Is 匹配哨兵值，因响应码失败时可用 errors.As 取得服务器和响应码
+func TestQuerySentinels(t *testing.T) {
+	s, err := testserver.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.Handle("servfail.test", dns.TypeA, testserver.Reply{Rcode: dns.RcodeServerFailure})
+	s.Handle("refused.test", dns.TypeA, testserver.Reply{Rcode: dns.RcodeRefused})
+	s.Handle("drop.test", dns.TypeA, testserver.Reply{Drop: true})
+	s.Handle("nodata.test", dns.TypeA, testserver.Reply{})
+	s.Handle("nodata.test", dns.TypeAAAA, testserver.Reply{})
+
+	c := New(WithServers(s.UDPAddr), WithRetries(0), WithTimeout(200*time.Millisecond), WithoutRefusalProbe())
+	defer c.Close()
+	ctx := context.Background()
+
+	tests := []struct {
+		domain string
+		want   []error
+		rcode  int
+	}{
+		{"servfail.test", []error{ErrServFail}, dns.RcodeServerFailure},
+		{"refused.test", []error{ErrRefused, ErrRefusedName}, dns.RcodeRefused},
+		{"drop.test", []error{ErrTimeout}, -1},
+	}
+	for _, tt := range tests {
+		res, err := c.QueryA(ctx, tt.domain)
+		for _, want := range tt.want {
+			if !errors.Is(err, want) {
+				t.Errorf("%s: err = %v, want %v", tt.domain, err, want)
+			}
+			// 结果经 JSON 往返后依然匹配
+			decoded, _ := roundTrip(t, res)
+			if !errors.Is(decoded.Err(), want) {
+				t.Errorf("%s: decoded error %v (kind %s) does not match %v", tt.domain, decoded.Err(), decoded.Error.Kind, want)
+			}
+		}
+		var rcodeErr *RcodeError
+		if tt.rcode >= 0 && (!errors.As(err, &rcodeErr) || rcodeErr.Rcode != tt.rcode || rcodeErr.Server != s.UDPAddr) {
+			t.Errorf("%s: RcodeError = %+v, want rcode %d from %s", tt.domain, rcodeErr, tt.rcode, s.UDPAddr)
+		}
+	}
+
+	// NXDOMAIN 以 *RcodeError 返回，结果照常填充应答码
+	res, err := c.QueryA(ctx, "missing.test")
+	var nxErr *RcodeError
+	if !errors.Is(err, ErrNXDomain) || !errors.As(err, &nxErr) || nxErr.Server != s.UDPAddr || !res.IsNXDOMAIN() {
+		t.Errorf("NXDOMAIN query: err = %v, rcode = %s", err, res.RcodeString())
+	}
+	if !errors.Is(res.Err(), ErrNXDomain) || res.Error.Kind != KindNXDomain {
+		t.Errorf("NXDOMAIN result error = %+v", res.Error)
+	}
+	if _, err := c.LookupNetIP(ctx, "ip", "missing.test"); !errors.Is(err, ErrNXDomain) {
+		t.Errorf("LookupNetIP NXDOMAIN: err = %v", err)
+	}
+	if _, err := c.LookupNetIP(ctx, "ip", "nodata.test"); !errors.Is(err, ErrNoRecords) {
+		t.Errorf("LookupNetIP NODATA: err = %v", err)
+	}
+
+	empty := New(WithServers())
+	defer empty.Close()
+	if _, err := empty.QueryA(ctx, "example.com"); !errors.Is(err, ErrNoServers) {
+		t.Errorf("no servers: err = %v", err)
+	}
+	if _, err := c.QueryWithTag(ctx, "example.com", dns.TypeA, "missing"); !errors.Is(err, ErrNoServers) {
+		t.Errorf("unknown tag: err = %v", err)
+	}
+}
@@ -35,6 +35,12 @@ func main() {
 		log.Printf("SOCKS5 代理查询失败: %v", err)
 	} else {
 		fmt.Println(result)
+		for _, res := range result.Results {
+			fmt.Println(res.Path)
+			if res.Error != nil {
+				fmt.Printf("  %s 查询失败 [%s]: %s\n", res.Server, res.Error.Kind, res.Error.Message)
+			}
+		}
 		if len(result.AllIPs) == 0 {
 			fmt.Printf("  DoT 可能不支持 SOCKS5 代理\n")
 		} else {
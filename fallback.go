@@ -0,0 +1,171 @@
+package godns
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// WithProtocolFallback 主协议查询失败后，依次使用备用协议查询同一服务器主机
+// 备用协议使用该协议的默认端口（UDP/TCP 53、DoT/DoQ 853、DoH /dns-query）
+func WithProtocolFallback(protocols ...Protocol) Option {
+	return func(c *Config) {
+		c.FallbackProtocols = protocols
+	}
+}
+
+// WithHedgedFallback 对冲式协议回退：主协议在 delay 内未应答时即并发启动下一协议，
+// 返回最先成功的应答并取消其余查询，用少量额外流量换取更好的尾延迟
+// 需配合 WithProtocolFallback 使用
+func WithHedgedFallback(delay time.Duration) Option {
+	return func(c *Config) {
+		c.HedgedFallback = true
+		c.HedgeDelay = delay
+	}
+}
+
+// usesProtocol 主协议或备用协议中是否包含指定协议
+func (c *Client) usesProtocol(protocol Protocol) bool {
+	if c.config.Protocol == protocol {
+		return true
+	}
+	for _, p := range c.config.FallbackProtocols {
+		if p == protocol {
+			return true
+		}
+	}
+	for _, spec := range c.config.MixedRace {
+		if spec.Protocol == protocol {
+			return true
+		}
+	}
+	for _, info := range c.servers {
+		if info.Protocol == protocol {
+			return true
+		}
+	}
+	return false
+}
+
+// noFallbackKey 标记查询必须使用指定协议，不进行协议回退
+type noFallbackKey struct{}
+
+// withoutFallback 返回禁用协议回退的 context，用于需要区分应答来自哪种协议的场景
+func withoutFallback(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noFallbackKey{}, true)
+}
+
+// exchangeWithFallback 使用指定协议查询，失败时按配置进行协议回退
+func (c *Client) exchangeWithFallback(ctx context.Context, primary Protocol, msg *dns.Msg, server string) (*dns.Msg, error) {
+	if len(c.config.FallbackProtocols) == 0 || ctx.Value(noFallbackKey{}) != nil {
+		return c.exchange(ctx, primary, msg, server)
+	}
+
+	protocols := []Protocol{primary}
+	for _, p := range c.config.FallbackProtocols {
+		if p != primary {
+			protocols = append(protocols, p)
+		}
+	}
+	if c.config.HedgedFallback {
+		return c.exchangeHedged(ctx, protocols, msg, server)
+	}
+
+	var lastErr error
+	for _, protocol := range protocols {
+		response, err := c.exchange(ctx, protocol, msg, serverForProtocol(server, primary, protocol))
+		if err == nil {
+			return response, nil
+		}
+		lastErr = err
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, lastErr
+}
+
+// exchangeHedged 依次错开启动各协议的查询，返回最先成功的应答
+func (c *Client) exchangeHedged(ctx context.Context, protocols []Protocol, msg *dns.Msg, server string) (*dns.Msg, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		response *dns.Msg
+		err      error
+	}
+	results := make(chan result, len(protocols))
+
+	launch := func(protocol Protocol) {
+		go func() {
+			response, err := c.exchange(ctx, protocol, msg.Copy(), serverForProtocol(server, protocols[0], protocol))
+			results <- result{response, err}
+		}()
+	}
+
+	launch(protocols[0])
+	next, pending := 1, 1
+	timer := time.NewTimer(c.config.HedgeDelay)
+	defer timer.Stop()
+
+	var lastErr error
+	for pending > 0 {
+		select {
+		case res := <-results:
+			pending--
+			if res.err == nil {
+				return res.response, nil
+			}
+			lastErr = res.err
+			// 当前协议已失败，立即启动下一个协议
+			if next < len(protocols) {
+				launch(protocols[next])
+				next++
+				pending++
+				timer.Reset(c.config.HedgeDelay)
+			}
+		case <-timer.C:
+			if next < len(protocols) {
+				launch(protocols[next])
+				next++
+				pending++
+				timer.Reset(c.config.HedgeDelay)
+			}
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return nil, lastErr
+}
+
+// serverForProtocol 将为 from 协议配置的服务器地址转换为 to 协议的默认地址
+func serverForProtocol(server string, from, to Protocol) string {
+	if from == to {
+		return server
+	}
+
+	host := server
+	if strings.HasPrefix(server, "http") {
+		if u, err := url.Parse(server); err == nil {
+			host = u.Hostname()
+		}
+	} else if h, _, err := net.SplitHostPort(server); err == nil {
+		host = h
+	}
+
+	switch to {
+	case DoT, DoQ:
+		return net.JoinHostPort(host, "853")
+	case DoH:
+		if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		return "https://" + host + "/dns-query"
+	default:
+		return net.JoinHostPort(host, "53")
+	}
+}
@@ -0,0 +1,143 @@
+package godns_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+)
+
+const (
+	hedgeUDP = "192.0.2.53:53"
+	hedgeDoT = "192.0.2.53:853"
+	hedgeDoH = "https://192.0.2.53/dns-query"
+)
+
+// hedgeTransport 按各协议的服务器地址分别应答：behavior 为 "ok" 立即应答，"fail" 立即失败，
+// "hang" 阻塞到查询被取消；记录各地址被查询的顺序和被取消的地址
+type hedgeTransport struct {
+	behavior map[string]string
+
+	mu        sync.Mutex
+	calls     []string
+	cancelled []string
+}
+
+func (h *hedgeTransport) Exchange(ctx context.Context, msg *dns.Msg, server string) (*dns.Msg, error) {
+	h.mu.Lock()
+	h.calls = append(h.calls, server)
+	h.mu.Unlock()
+
+	switch h.behavior[server] {
+	case "ok":
+		reply := new(dns.Msg)
+		reply.SetReply(msg)
+		reply.Answer = []dns.RR{mustRR(msg.Question[0].Name + " 60 IN A 192.0.2.1")}
+		return reply, nil
+	case "hang":
+		<-ctx.Done()
+		h.mu.Lock()
+		h.cancelled = append(h.cancelled, server)
+		h.mu.Unlock()
+		return nil, ctx.Err()
+	default:
+		return nil, errors.New("connection refused")
+	}
+}
+
+func (h *hedgeTransport) snapshot() (calls, cancelled []string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]string(nil), h.calls...), append([]string(nil), h.cancelled...)
+}
+
+// TestHedgedFallback 主协议在延迟内未应答时并发启动下一协议，返回最先成功的应答并取消其余查询；
+// 主协议失败时立即启动下一协议而不等待延迟；主协议及时应答时不发出额外查询
+func TestHedgedFallback(t *testing.T) {
+	tests := []struct {
+		name      string
+		delay     time.Duration
+		behavior  map[string]string
+		calls     []string
+		cancelled []string
+	}{
+		{
+			name:      "hedge after delay",
+			delay:     50 * time.Millisecond,
+			behavior:  map[string]string{hedgeUDP: "hang", hedgeDoT: "ok"},
+			calls:     []string{hedgeUDP, hedgeDoT},
+			cancelled: []string{hedgeUDP},
+		},
+		{
+			name:     "failure skips the delay",
+			delay:    time.Hour,
+			behavior: map[string]string{hedgeUDP: "fail", hedgeDoT: "fail", hedgeDoH: "ok"},
+			calls:    []string{hedgeUDP, hedgeDoT, hedgeDoH},
+		},
+		{
+			name:     "primary answers",
+			delay:    time.Hour,
+			behavior: map[string]string{hedgeUDP: "ok"},
+			calls:    []string{hedgeUDP},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := &hedgeTransport{behavior: tt.behavior}
+			c := godns.New(
+				godns.WithServers(hedgeUDP),
+				godns.WithTransport(transport),
+				godns.WithProtocolFallback(godns.DoT, godns.DoH),
+				godns.WithHedgedFallback(tt.delay),
+				godns.WithRetries(0),
+				godns.WithTimeout(5*time.Second),
+			)
+			defer c.Close()
+
+			start := time.Now()
+			res, err := c.QueryA(context.Background(), "example.test")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Errorf("query took %v", elapsed)
+			}
+			if len(res.Records) != 1 {
+				t.Errorf("records = %v", res.Records)
+			}
+			waitFor(t, "the losing queries to be cancelled", func() bool {
+				_, cancelled := transport.snapshot()
+				return len(cancelled) == len(tt.cancelled)
+			})
+			calls, cancelled := transport.snapshot()
+			if !slices.Equal(calls, tt.calls) || !slices.Equal(cancelled, tt.cancelled) {
+				t.Errorf("calls %v, cancelled %v; want %v, %v", calls, cancelled, tt.calls, tt.cancelled)
+			}
+		})
+	}
+}
+
+// TestHedgedFallbackAllFail 所有协议都失败时查询失败，每个协议只查询一次
+func TestHedgedFallbackAllFail(t *testing.T) {
+	transport := &hedgeTransport{behavior: map[string]string{}}
+	c := godns.New(
+		godns.WithServers(hedgeUDP),
+		godns.WithTransport(transport),
+		godns.WithProtocolFallback(godns.DoT),
+		godns.WithHedgedFallback(time.Hour),
+		godns.WithRetries(0),
+	)
+	defer c.Close()
+
+	if _, err := c.QueryA(context.Background(), "example.test"); err == nil {
+		t.Fatal("query succeeded with every protocol failing")
+	}
+	if calls, _ := transport.snapshot(); !slices.Equal(calls, []string{hedgeUDP, hedgeDoT}) {
+		t.Errorf("calls = %v, want each protocol once", calls)
+	}
+}
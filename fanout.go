@@ -0,0 +1,26 @@
+package godns
+
+import (
+	"sort"
+	"time"
+)
+
+// WithStaggeredFanout MultiQuery 按波次查询服务器：先查询排名最前的 waveSize 个服务器，
+// 若 stagger 内没有成功应答再启动下一波，已启动的服务器全部失败时立即启动下一波
+// 首个成功应答会取消进行中的查询并停止后续波次；开启 WithQuorum 时改为达成一致后停止
+// 服务器按 ServerInfo.Weight 降序排名，权重相同时保持配置顺序
+func WithStaggeredFanout(waveSize int, stagger time.Duration) Option {
+	return func(c *Config) {
+		c.FanoutWaveSize = waveSize
+		c.FanoutStagger = stagger
+	}
+}
+
+// rankServers 按权重降序返回服务器列表的副本
+func (c *Client) rankServers(servers []string) []string {
+	ranked := append([]string(nil), servers...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return c.serverInfo(ranked[i]).Weight > c.serverInfo(ranked[j]).Weight
+	})
+	return ranked
+}
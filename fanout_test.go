@@ -0,0 +1,213 @@
+package godns_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+)
+
+// timerClock 可手动推进的时钟，After 返回的定时器在 Advance 越过到期时间时触发
+type timerClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []clockTimer
+}
+
+type clockTimer struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func newTimerClock() *timerClock {
+	return &timerClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *timerClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *timerClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	c.timers = append(c.timers, clockTimer{c.now.Add(d), ch})
+	return ch
+}
+
+func (c *timerClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	pending := c.timers[:0]
+	for _, timer := range c.timers {
+		if timer.at.After(c.now) {
+			pending = append(pending, timer)
+			continue
+		}
+		timer.ch <- c.now
+	}
+	c.timers = pending
+}
+
+// Pending 返回尚未触发的定时器数
+func (c *timerClock) Pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+// fanoutServers 按脚本应答的服务器集合：answer 立即成功，fail 立即失败，其余挂起直到查询被取消
+type fanoutServers struct {
+	mu        sync.Mutex
+	script    map[string]string
+	queried   []string
+	cancelled []string
+}
+
+func (f *fanoutServers) Exchange(ctx context.Context, msg *dns.Msg, server string) (*dns.Msg, error) {
+	f.mu.Lock()
+	f.queried = append(f.queried, server)
+	action := f.script[server]
+	f.mu.Unlock()
+
+	switch action {
+	case "answer":
+		reply := new(dns.Msg)
+		reply.SetReply(msg)
+		reply.Answer = []dns.RR{mustRR("example.com. 60 IN A 192.0.2.1")}
+		return reply, nil
+	case "fail":
+		return nil, errors.New("connection refused")
+	}
+	<-ctx.Done()
+	f.mu.Lock()
+	f.cancelled = append(f.cancelled, server)
+	f.mu.Unlock()
+	return nil, ctx.Err()
+}
+
+func (f *fanoutServers) Queried() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Sorted(slices.Values(f.queried))
+}
+
+func (f *fanoutServers) Cancelled() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Sorted(slices.Values(f.cancelled))
+}
+
+// TestStaggeredFanoutTiming 由可控时钟驱动波次：间隔到期前不启动下一波，成功应答取消进行中的查询并停止后续波次
+func TestStaggeredFanoutTiming(t *testing.T) {
+	servers := make([]string, 6)
+	for i := range servers {
+		servers[i] = fmt.Sprintf("192.0.2.%d:53", i+1)
+	}
+	const stagger = 300 * time.Millisecond
+
+	tests := []struct {
+		name   string
+		script map[string]string
+		// 每一步等待该数量的服务器被查询、下一波的定时器就绪后，将时钟推进一个间隔
+		steps         []int
+		wantQueried   []string
+		wantCancelled []string
+	}{
+		{
+			// 第一波挂起，间隔到期后启动第二波，第二波的应答取消第一波
+			name:          "second-wave-answers",
+			script:        map[string]string{servers[2]: "answer", servers[3]: "fail"},
+			steps:         []int{2},
+			wantQueried:   servers[:4],
+			wantCancelled: servers[:2],
+		},
+		{
+			// 第一波立即应答，不等待间隔也不启动后续波次
+			name:        "first-wave-answers",
+			script:      map[string]string{servers[0]: "answer"},
+			wantQueried: servers[:2],
+			// servers[1] 挂起，被首个应答取消
+			wantCancelled: servers[1:2],
+		},
+		{
+			// 第一波全部失败时立即启动第二波，无需推进时钟
+			name:        "first-wave-fails",
+			script:      map[string]string{servers[0]: "fail", servers[1]: "fail", servers[2]: "answer", servers[3]: "answer"},
+			wantQueried: servers[:4],
+		},
+		{
+			// 连续两个间隔都没有应答，第三波应答
+			name:          "third-wave-answers",
+			script:        map[string]string{servers[4]: "answer"},
+			steps:         []int{2, 4},
+			wantQueried:   servers,
+			wantCancelled: []string{servers[0], servers[1], servers[2], servers[3], servers[5]},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newTimerClock()
+			transport := &fanoutServers{script: tt.script}
+			c := godns.New(
+				godns.WithServers(servers...),
+				godns.WithTransport(transport),
+				godns.WithStaggeredFanout(2, stagger),
+				godns.WithClock(clock),
+				godns.WithRetries(0),
+			)
+			defer c.Close()
+
+			type outcome struct {
+				res *godns.MultiQueryResult
+				err error
+			}
+			done := make(chan outcome, 1)
+			go func() {
+				res, err := c.MultiQuery(context.Background(), "example.com", dns.TypeA)
+				done <- outcome{res, err}
+			}()
+
+			for _, queried := range tt.steps {
+				waitFor(t, fmt.Sprintf("%d servers and a pending wave timer", queried), func() bool {
+					return len(transport.Queried()) == queried && clock.Pending() == 1
+				})
+				// 间隔未到：稍等后仍不应启动下一波
+				clock.Advance(stagger - time.Millisecond)
+				time.Sleep(20 * time.Millisecond)
+				if n := len(transport.Queried()); n != queried {
+					t.Fatalf("%d servers queried before the stagger elapsed, want %d", n, queried)
+				}
+				clock.Advance(time.Millisecond)
+			}
+
+			var out outcome
+			select {
+			case out = <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("MultiQuery did not return")
+			}
+			if out.err != nil {
+				t.Fatal(out.err)
+			}
+			if len(out.res.AllIPs) != 1 {
+				t.Errorf("AllIPs = %v, want the answer", out.res.AllIPs)
+			}
+			if got := transport.Queried(); !slices.Equal(got, tt.wantQueried) {
+				t.Errorf("queried %v, want %v", got, tt.wantQueried)
+			}
+			if got := transport.Cancelled(); !slices.Equal(got, tt.wantCancelled) {
+				t.Errorf("cancelled %v, want %v", got, tt.wantCancelled)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package godns_test
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+	"github.com/zan8in/godns/internal/testserver"
+)
+
+// zoneQueries 按 "名称 类型" 列出服务器收到的查询
+func zoneQueries(s *testserver.Server) []string {
+	var queries []string
+	for _, q := range s.Queries() {
+		queries = append(queries, q.Msg.Question[0].Name+" "+dns.TypeToString[q.Msg.Question[0].Qtype])
+	}
+	return queries
+}
+
+// delegatedZone 递归服务器知道区域 example.test 及其委派的子区域 child.example.test
+func delegatedZone(t *testing.T) *testserver.Server {
+	t.Helper()
+	s := startServer(t)
+	soa := testserver.RR("example.test. 3600 IN SOA ns1.example.test. hostmaster.example.test. 2024010101 7200 900 1209600 300")
+	child := testserver.RR("child.example.test. 600 IN SOA ns.child.example.test. hostmaster.example.test. 7 7200 900 1209600 300")
+	s.Handle("example.test", dns.TypeSOA, testserver.Reply{Answer: []dns.RR{soa}})
+	s.Handle("www.example.test", dns.TypeSOA, testserver.Reply{Authority: []dns.RR{soa}})
+	s.Handle("mail.example.test", dns.TypeSOA, testserver.Reply{Authority: []dns.RR{soa}})
+	s.Handle("missing.example.test", dns.TypeSOA, testserver.Reply{Rcode: dns.RcodeNameError, Authority: []dns.RR{soa}})
+	s.Handle("a.b.child.example.test", dns.TypeSOA, testserver.Reply{Authority: []dns.RR{child}})
+	// 不带SOA的空应答，需要逐级向上查找
+	s.Handle("bare.example.test", dns.TypeSOA, testserver.Reply{})
+	// 别名的SOA属于目标所在的区域，不予采用
+	s.Handle("alias.example.test", dns.TypeSOA, testserver.Reply{
+		Answer:    []dns.RR{testserver.RR("alias.example.test. 300 IN CNAME www.other.test.")},
+		Authority: []dns.RR{testserver.RR("other.test. 3600 IN SOA ns.other.test. hostmaster.other.test. 1 7200 900 1209600 300")},
+	})
+	s.Answer("example.test", dns.TypeNS, "example.test. 3600 IN NS ns1.example.test.", "example.test. 3600 IN NS ns2.example.net.")
+	s.Answer("child.example.test", dns.TypeNS, "child.example.test. 600 IN NS ns.child.example.test.")
+	return s
+}
+
+// TestFindZone 区域顶点自身、NXDOMAIN名称、深层子区域中的名称、没有SOA的名称和别名都能确定所在区域
+func TestFindZone(t *testing.T) {
+	tests := []struct {
+		name, apex  string
+		serial      uint32
+		nameservers []string
+	}{
+		{"example.test", "example.test", 2024010101, []string{"ns1.example.test", "ns2.example.net"}},
+		{"www.example.test.", "example.test", 2024010101, []string{"ns1.example.test", "ns2.example.net"}},
+		{"missing.example.test", "example.test", 2024010101, []string{"ns1.example.test", "ns2.example.net"}},
+		{"a.b.child.example.test", "child.example.test", 7, []string{"ns.child.example.test"}},
+		{"bare.example.test", "example.test", 2024010101, []string{"ns1.example.test", "ns2.example.net"}},
+		{"alias.example.test", "example.test", 2024010101, []string{"ns1.example.test", "ns2.example.net"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := delegatedZone(t)
+			c := godns.New(godns.WithServers(s.UDPAddr), godns.WithRetries(0))
+			defer c.Close()
+
+			zone, err := c.FindZone(context.Background(), tt.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if zone.Apex != tt.apex || zone.SOA == nil || zone.SOA.Serial != tt.serial || !slices.Equal(zone.Nameservers, tt.nameservers) {
+				t.Errorf("zone = %s (SOA %v) with %v, want %s serial %d with %v", zone.Apex, zone.SOA, zone.Nameservers, tt.apex, tt.serial, tt.nameservers)
+			}
+		})
+	}
+}
+
+// TestFindZoneNoSOA 逐级向上直到顶级域仍未取得SOA时返回错误
+func TestFindZoneNoSOA(t *testing.T) {
+	s := startServer(t)
+	c := godns.New(godns.WithServers(s.UDPAddr), godns.WithRetries(0))
+	defer c.Close()
+
+	_, err := c.FindZone(context.Background(), "www.nowhere.test")
+	if err == nil || !strings.Contains(err.Error(), "no SOA found for www.nowhere.test") {
+		t.Fatalf("err = %v, want no SOA found", err)
+	}
+	if got, want := zoneQueries(s), []string{"www.nowhere.test. SOA", "nowhere.test. SOA", "test. SOA"}; !slices.Equal(got, want) {
+		t.Errorf("queries = %v, want %v", got, want)
+	}
+}
+
+// TestFindZoneCache 区域切分按SOA/NS的最小TTL缓存：同一名称再次查找不发出查询，
+// 同一区域内的其他名称只需一次SOA查询；过期后重新查找，ContextWithNoCache 跳过缓存
+func TestFindZoneCache(t *testing.T) {
+	s := delegatedZone(t)
+	clock := newFakeClock()
+	c := godns.New(godns.WithServers(s.UDPAddr), godns.WithClock(clock), godns.WithRetries(0))
+	defer c.Close()
+	ctx := context.Background()
+
+	// find 查找 name 所在的区域，返回本次查找发出的查询
+	find := func(ctx context.Context, name string) []string {
+		t.Helper()
+		before := len(s.Queries())
+		zone, err := c.FindZone(ctx, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if zone.Apex != "example.test" {
+			t.Errorf("%s: apex %s, want example.test", name, zone.Apex)
+		}
+		return zoneQueries(s)[before:]
+	}
+
+	if got, want := find(ctx, "www.example.test"), []string{"www.example.test. SOA", "example.test. NS"}; !slices.Equal(got, want) {
+		t.Errorf("first lookup queried %v, want %v", got, want)
+	}
+	if got := find(ctx, "www.example.test"); len(got) != 0 {
+		t.Errorf("repeated lookup queried %v, want nothing", got)
+	}
+	if got := find(ctx, "example.test"); len(got) != 0 {
+		t.Errorf("apex lookup queried %v, want nothing", got)
+	}
+	if got, want := find(ctx, "mail.example.test"), []string{"mail.example.test. SOA"}; !slices.Equal(got, want) {
+		t.Errorf("lookup in the cached zone queried %v, want %v", got, want)
+	}
+	if got, want := find(godns.ContextWithNoCache(ctx), "www.example.test"), []string{"www.example.test. SOA", "example.test. NS"}; !slices.Equal(got, want) {
+		t.Errorf("uncached lookup queried %v, want %v", got, want)
+	}
+
+	// SOA TTL 3600 与 NS TTL 3600 中的最小值过后缓存过期
+	clock.Advance(3601 * time.Second)
+	if got, want := find(ctx, "www.example.test"), []string{"www.example.test. SOA", "example.test. NS"}; !slices.Equal(got, want) {
+		t.Errorf("lookup after expiry queried %v, want %v", got, want)
+	}
+}
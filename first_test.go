@@ -0,0 +1,73 @@
+package godns_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+	"github.com/zan8in/godns/internal/testserver"
+)
+
+// TestMultiQueryFirst 首个成功且非空的应答胜出并位于 Results[0]，之前返回的失败和空应答随后列出，
+// 较慢的查询被取消而不等待其超时
+func TestMultiQueryFirst(t *testing.T) {
+	answer := []dns.RR{testserver.RR("example.test. 300 IN A 192.0.2.1")}
+	empty, failing, winner, slow := startServer(t), startServer(t), startServer(t), startServer(t)
+	empty.Handle("example.test", dns.TypeA, testserver.Reply{})
+	failing.Handle("example.test", dns.TypeA, testserver.Reply{Rcode: dns.RcodeServerFailure})
+	winner.Handle("example.test", dns.TypeA, testserver.Reply{Answer: answer, Delay: 100 * time.Millisecond})
+	slow.Handle("example.test", dns.TypeA, testserver.Reply{Answer: answer, Delay: 5 * time.Second})
+	c := godns.New(
+		godns.WithServers(empty.UDPAddr, failing.UDPAddr, winner.UDPAddr, slow.UDPAddr),
+		godns.WithRetries(0),
+		godns.WithTimeout(10*time.Second),
+	)
+	defer c.Close()
+
+	start := time.Now()
+	res, err := c.MultiQueryFirst(context.Background(), "example.test", dns.TypeA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("MultiQueryFirst took %v, want it to return with the first answer", elapsed)
+	}
+	if len(res.Results) != 3 {
+		t.Fatalf("got %d results, want the winner and the two earlier replies", len(res.Results))
+	}
+	if r := res.Results[0]; r.Server != winner.UDPAddr || r.Error != nil || len(r.Records) != 1 {
+		t.Errorf("Results[0] = %s with %v, err %v; want the answer from %s", r.Server, r.Records, r.Error, winner.UDPAddr)
+	}
+	for _, r := range res.Results[1:] {
+		if r.Server != empty.UDPAddr && r.Server != failing.UDPAddr {
+			t.Errorf("unexpected result from %s", r.Server)
+		}
+	}
+	if len(res.AllIPs) != 1 || res.AllIPs[0] != "192.0.2.1" {
+		t.Errorf("AllIPs = %v", res.AllIPs)
+	}
+}
+
+// TestMultiQueryFirstNoAnswer 没有服务器给出非空应答时与 MultiQuery 相同，等待并返回全部结果
+func TestMultiQueryFirstNoAnswer(t *testing.T) {
+	empty, failing := startServer(t), startServer(t)
+	empty.Handle("example.test", dns.TypeA, testserver.Reply{})
+	failing.Handle("example.test", dns.TypeA, testserver.Reply{Rcode: dns.RcodeServerFailure, Delay: 50 * time.Millisecond})
+	c := godns.New(godns.WithServers(empty.UDPAddr, failing.UDPAddr), godns.WithRetries(0))
+	defer c.Close()
+
+	res, err := c.MultiQueryFirst(context.Background(), "example.test", dns.TypeA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Results) != 2 {
+		t.Errorf("got %d results, want one per server", len(res.Results))
+	}
+	for _, r := range res.Results {
+		if r.Error == nil && len(r.Records) > 0 {
+			t.Errorf("%s: unexpected answer %v", r.Server, r.Records)
+		}
+	}
+}
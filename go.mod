@@ -4,11 +4,15 @@ go 1.24.1
 
 require (
 	github.com/miekg/dns v1.1.57
-	golang.org/x/net v0.19.0
+	github.com/quic-go/quic-go v0.54.1
+	golang.org/x/net v0.28.0
 )
 
 require (
-	golang.org/x/mod v0.12.0 // indirect
-	golang.org/x/sys v0.15.0 // indirect
-	golang.org/x/tools v0.13.0 // indirect
+	go.uber.org/mock v0.5.0 // indirect
+	golang.org/x/crypto v0.26.0 // indirect
+	golang.org/x/mod v0.18.0 // indirect
+	golang.org/x/sync v0.8.0 // indirect
+	golang.org/x/sys v0.23.0 // indirect
+	golang.org/x/tools v0.22.0 // indirect
 )
@@ -0,0 +1,277 @@
+package godnstest
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+)
+
+// fakeServer FakeResolver 结果中的服务器名称
+const fakeServer = "godnstest.fake"
+
+// Call FakeResolver 收到的一次调用
+type Call struct {
+	Method string // 调用的方法名，例如 "QueryA"、"LookupNetIP"
+	Domain string
+	Type   uint16 // LookupNetIP 为 0
+}
+
+// fakeAnswer 预置的结果
+type fakeAnswer struct {
+	records []godns.Record
+	rcode   int
+	err     error
+}
+
+// FakeResolver 实现 godns.Resolver 的测试替身，按名称和类型预置结果，记录所有调用，可设置错误和延迟
+// 未预置的查询交给 Fallback（若设置），否则返回 ErrNotRecorded
+type FakeResolver struct {
+	mu       sync.Mutex
+	answers  map[string]fakeAnswer
+	latency  time.Duration
+	fallback godns.Resolver
+	calls    []Call
+}
+
+var _ godns.Resolver = (*FakeResolver)(nil)
+
+// NewFakeResolver 创建空的 FakeResolver
+func NewFakeResolver() *FakeResolver {
+	return &FakeResolver{answers: make(map[string]fakeAnswer)}
+}
+
+// AddRecords 以区域文件格式的记录预置查询结果，同一名称和类型后添加的覆盖先添加的
+func (f *FakeResolver) AddRecords(name string, qtype uint16, records ...string) error {
+	answer := fakeAnswer{records: make([]godns.Record, 0, len(records))}
+	for _, record := range records {
+		rr, err := dns.NewRR(record)
+		if err != nil {
+			return fmt.Errorf("godnstest: invalid record %q: %v", record, err)
+		}
+		answer.records = append(answer.records, godns.NewRecord(rr))
+	}
+	f.set(name, qtype, answer)
+	return nil
+}
+
+// AddError 预置查询错误，qtype 为 0 时对该名称的所有类型生效（具体类型的预置优先）
+func (f *FakeResolver) AddError(name string, qtype uint16, err error) {
+	f.set(name, qtype, fakeAnswer{err: err})
+}
+
+// AddNXDOMAIN 预置名称不存在的应答，qtype 为 0 时对该名称的所有类型生效（具体类型的预置优先）
+// 与真实客户端一致，结果的 Rcode 为 dns.RcodeNameError，并返回包装 godns.ErrNXDomain 的 *godns.RcodeError
+func (f *FakeResolver) AddNXDOMAIN(name string, qtype uint16) {
+	f.set(name, qtype, fakeAnswer{rcode: dns.RcodeNameError, err: &godns.RcodeError{Rcode: dns.RcodeNameError, Server: fakeServer}})
+}
+
+// SetLatency 设置每次调用的延迟，延迟期间 context 取消会立即返回
+func (f *FakeResolver) SetLatency(latency time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.latency = latency
+}
+
+// SetFallback 设置未预置的查询使用的解析器，例如真实的 *godns.Client 或另一个 FakeResolver
+func (f *FakeResolver) SetFallback(r godns.Resolver) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.fallback = r
+}
+
+// Calls 返回已收到的调用
+func (f *FakeResolver) Calls() []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Call(nil), f.calls...)
+}
+
+// set 保存预置结果
+func (f *FakeResolver) set(name string, qtype uint16, answer fakeAnswer) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.answers[fakeKey(name, qtype)] = answer
+}
+
+// begin 记录调用并等待配置的延迟，返回调用时的回退解析器
+func (f *FakeResolver) begin(ctx context.Context, call Call) (godns.Resolver, error) {
+	f.mu.Lock()
+	f.calls = append(f.calls, call)
+	latency, fallback := f.latency, f.fallback
+	f.mu.Unlock()
+
+	if latency > 0 {
+		timer := time.NewTimer(latency)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return fallback, ctx.Err()
+		}
+	}
+	return fallback, ctx.Err()
+}
+
+// lookup 查找预置结果
+func (f *FakeResolver) lookup(name string, qtype uint16) (fakeAnswer, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if answer, ok := f.answers[fakeKey(name, qtype)]; ok {
+		return answer, true
+	}
+	answer, ok := f.answers[fakeKey(name, 0)]
+	return answer, ok
+}
+
+// result 按预置结果构建查询结果，未预置时返回 false
+func (f *FakeResolver) result(domain string, qtype uint16) (*godns.QueryResult, bool, error) {
+	answer, ok := f.lookup(domain, qtype)
+	if !ok {
+		return nil, false, nil
+	}
+	res := &godns.QueryResult{
+		Domain:    domain,
+		Type:      qtype,
+		Server:    fakeServer,
+		Rcode:     answer.rcode,
+		QueriedAt: time.Now(),
+	}
+	if answer.err != nil {
+		res.Error = godns.NewErrorInfo(answer.err, fakeServer)
+		return res, true, res.Error
+	}
+	res.Records = append([]godns.Record(nil), answer.records...)
+	return res, true, nil
+}
+
+// query 单次查询的公共实现
+func (f *FakeResolver) query(ctx context.Context, method, domain string, qtype uint16) (*godns.QueryResult, error) {
+	fallback, err := f.begin(ctx, Call{Method: method, Domain: domain, Type: qtype})
+	if err != nil {
+		return nil, err
+	}
+	if res, ok, err := f.result(domain, qtype); ok {
+		return res, err
+	}
+	if fallback != nil {
+		return fallback.Query(ctx, domain, qtype)
+	}
+	return nil, fmt.Errorf("%w: %s %s", ErrNotRecorded, domain, dns.TypeToString[qtype])
+}
+
+// Query 实现 godns.Resolver
+func (f *FakeResolver) Query(ctx context.Context, domain string, qtype uint16) (*godns.QueryResult, error) {
+	return f.query(ctx, "Query", domain, qtype)
+}
+
+// QueryA 实现 godns.Resolver
+func (f *FakeResolver) QueryA(ctx context.Context, domain string) (*godns.QueryResult, error) {
+	return f.query(ctx, "QueryA", domain, dns.TypeA)
+}
+
+// QueryAAAA 实现 godns.Resolver
+func (f *FakeResolver) QueryAAAA(ctx context.Context, domain string) (*godns.QueryResult, error) {
+	return f.query(ctx, "QueryAAAA", domain, dns.TypeAAAA)
+}
+
+// QueryCNAME 实现 godns.Resolver
+func (f *FakeResolver) QueryCNAME(ctx context.Context, domain string) (*godns.QueryResult, error) {
+	return f.query(ctx, "QueryCNAME", domain, dns.TypeCNAME)
+}
+
+// QueryMX 实现 godns.Resolver
+func (f *FakeResolver) QueryMX(ctx context.Context, domain string) (*godns.QueryResult, error) {
+	return f.query(ctx, "QueryMX", domain, dns.TypeMX)
+}
+
+// QueryTXT 实现 godns.Resolver
+func (f *FakeResolver) QueryTXT(ctx context.Context, domain string) (*godns.QueryResult, error) {
+	return f.query(ctx, "QueryTXT", domain, dns.TypeTXT)
+}
+
+// MultiQuery 实现 godns.Resolver，预置结果作为唯一服务器的结果返回
+func (f *FakeResolver) MultiQuery(ctx context.Context, domain string, qtype uint16) (*godns.MultiQueryResult, error) {
+	start := time.Now()
+	fallback, err := f.begin(ctx, Call{Method: "MultiQuery", Domain: domain, Type: qtype})
+	if err != nil {
+		return nil, err
+	}
+	res, ok, _ := f.result(domain, qtype)
+	if !ok {
+		if fallback != nil {
+			return fallback.MultiQuery(ctx, domain, qtype)
+		}
+		return nil, fmt.Errorf("%w: %s %s", ErrNotRecorded, domain, dns.TypeToString[qtype])
+	}
+	result := &godns.MultiQueryResult{
+		Domain:     domain,
+		Type:       qtype,
+		Results:    []godns.QueryResult{*res},
+		AllIPs:     make([]string, 0),
+		StartedAt:  start,
+		FinishedAt: time.Now(),
+	}
+	if res.Error == nil {
+		result.AllIPs = godns.AddrStrings(res.IPAddrs())
+	}
+	result.Elapsed = result.FinishedAt.Sub(start)
+	return result, nil
+}
+
+// LookupNetIP 实现 godns.Resolver，使用预置的A/AAAA结果
+func (f *FakeResolver) LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error) {
+	fallback, err := f.begin(ctx, Call{Method: "LookupNetIP", Domain: host})
+	if err != nil {
+		return nil, err
+	}
+	var qtypes []uint16
+	switch network {
+	case "ip":
+		qtypes = []uint16{dns.TypeA, dns.TypeAAAA}
+	case "ip4":
+		qtypes = []uint16{dns.TypeA}
+	case "ip6":
+		qtypes = []uint16{dns.TypeAAAA}
+	default:
+		return nil, fmt.Errorf("unsupported network %q", network)
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return []netip.Addr{addr}, nil
+	}
+
+	var addrs []netip.Addr
+	var firstErr error
+	programmed := false
+	for _, qtype := range qtypes {
+		res, ok, err := f.result(host, qtype)
+		if !ok {
+			continue
+		}
+		programmed = true
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		addrs = append(addrs, res.IPAddrs()...)
+	}
+	switch {
+	case !programmed && fallback != nil:
+		return fallback.LookupNetIP(ctx, network, host)
+	case !programmed:
+		return nil, fmt.Errorf("%w: %s", ErrNotRecorded, host)
+	case len(addrs) == 0 && firstErr != nil:
+		return nil, firstErr
+	case len(addrs) == 0:
+		return nil, fmt.Errorf("%w: no addresses found for %s", godns.ErrNoRecords, host)
+	}
+	return addrs, nil
+}
+
+// fakeKey 预置结果的索引键，名称按 DNS 规则规范化
+func fakeKey(name string, qtype uint16) string {
+	return fmt.Sprintf("%s|%d", godns.CanonicalName(name), qtype)
+}
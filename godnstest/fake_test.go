@@ -0,0 +1,186 @@
+package godnstest_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+	"github.com/zan8in/godns/godnstest"
+)
+
+func TestFakeResolverProgramming(t *testing.T) {
+	f := godnstest.NewFakeResolver()
+	must(t, f.AddRecords("www.example.com", dns.TypeA, "www.example.com. 60 IN A 192.0.2.1"))
+	must(t, f.AddRecords("www.example.com", dns.TypeAAAA, "www.example.com. 60 IN AAAA 2001:db8::1"))
+	must(t, f.AddRecords("other.example.com", dns.TypeA, "other.example.com. 60 IN A 192.0.2.2"))
+	// 同一名称和类型后添加的覆盖先添加的
+	must(t, f.AddRecords("other.example.com", dns.TypeA, "other.example.com. 60 IN A 192.0.2.3"))
+	f.AddNXDOMAIN("gone.example.com", 0)
+	f.AddError("gone.example.com", dns.TypeMX, godns.ErrServFail)
+
+	tests := []struct {
+		domain string
+		qtype  uint16
+		values []string
+		rcode  int
+		err    error
+	}{
+		{"www.example.com", dns.TypeA, []string{"192.0.2.1"}, dns.RcodeSuccess, nil},
+		{"WWW.Example.COM.", dns.TypeAAAA, []string{"2001:db8::1"}, dns.RcodeSuccess, nil},
+		{"other.example.com", dns.TypeA, []string{"192.0.2.3"}, dns.RcodeSuccess, nil},
+		{"gone.example.com", dns.TypeA, nil, dns.RcodeNameError, godns.ErrNXDomain},
+		{"gone.example.com", dns.TypeTXT, nil, dns.RcodeNameError, godns.ErrNXDomain},
+		{"gone.example.com", dns.TypeMX, nil, dns.RcodeSuccess, godns.ErrServFail},
+		{"www.example.com", dns.TypeTXT, nil, 0, godnstest.ErrNotRecorded},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s/%s", tt.domain, dns.TypeToString[tt.qtype]), func(t *testing.T) {
+			res, err := f.Query(context.Background(), tt.domain, tt.qtype)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("err = %v, want %v", err, tt.err)
+				}
+				if res != nil && res.Rcode != tt.rcode {
+					t.Errorf("rcode = %d, want %d", res.Rcode, tt.rcode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Rcode != tt.rcode {
+				t.Errorf("rcode = %d, want %d", res.Rcode, tt.rcode)
+			}
+			var values []string
+			for _, r := range res.Records {
+				values = append(values, r.Value())
+			}
+			if !slices.Equal(values, tt.values) {
+				t.Errorf("values = %v, want %v", values, tt.values)
+			}
+		})
+	}
+}
+
+func TestFakeResolverFallback(t *testing.T) {
+	inner := godnstest.NewFakeResolver()
+	must(t, inner.AddRecords("fallback.example.com", dns.TypeA, "fallback.example.com. 60 IN A 192.0.2.9"))
+	f := godnstest.NewFakeResolver()
+	must(t, f.AddRecords("own.example.com", dns.TypeA, "own.example.com. 60 IN A 192.0.2.1"))
+
+	// 未设置回退解析器时未预置的查询返回 ErrNotRecorded
+	if _, err := f.QueryA(context.Background(), "fallback.example.com"); !errors.Is(err, godnstest.ErrNotRecorded) {
+		t.Fatalf("err = %v, want ErrNotRecorded", err)
+	}
+	if _, err := f.LookupNetIP(context.Background(), "ip", "fallback.example.com"); !errors.Is(err, godnstest.ErrNotRecorded) {
+		t.Fatalf("LookupNetIP err = %v, want ErrNotRecorded", err)
+	}
+
+	f.SetFallback(inner)
+	res, err := f.QueryA(context.Background(), "fallback.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Records[0].Value() != "192.0.2.9" {
+		t.Errorf("fallback records = %v", res.Records)
+	}
+	addrs, err := f.LookupNetIP(context.Background(), "ip4", "fallback.example.com")
+	if err != nil || len(addrs) != 1 || addrs[0] != netip.MustParseAddr("192.0.2.9") {
+		t.Errorf("fallback LookupNetIP = %v, %v", addrs, err)
+	}
+	// 预置结果优先于回退解析器
+	if _, err := f.QueryA(context.Background(), "own.example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(inner.Calls()); n != 2 {
+		t.Errorf("fallback saw %d calls, want 2", n)
+	}
+}
+
+func TestFakeResolverCalls(t *testing.T) {
+	f := godnstest.NewFakeResolver()
+	must(t, f.AddRecords("www.example.com", dns.TypeA, "www.example.com. 60 IN A 192.0.2.1"))
+	ctx := context.Background()
+	f.QueryA(ctx, "www.example.com")
+	f.QueryMX(ctx, "example.com")
+	f.MultiQuery(ctx, "www.example.com", dns.TypeA)
+	f.LookupNetIP(ctx, "ip", "www.example.com")
+
+	want := []godnstest.Call{
+		{Method: "QueryA", Domain: "www.example.com", Type: dns.TypeA},
+		{Method: "QueryMX", Domain: "example.com", Type: dns.TypeMX},
+		{Method: "MultiQuery", Domain: "www.example.com", Type: dns.TypeA},
+		{Method: "LookupNetIP", Domain: "www.example.com"},
+	}
+	if got := f.Calls(); !slices.Equal(got, want) {
+		t.Errorf("calls = %v, want %v", got, want)
+	}
+}
+
+func TestFakeResolverErrors(t *testing.T) {
+	injected := errors.New("injected failure")
+	f := godnstest.NewFakeResolver()
+	f.AddError("broken.example.com", 0, injected)
+	f.AddNXDOMAIN("gone.example.com", 0)
+	must(t, f.AddRecords("empty.example.com", dns.TypeA))
+
+	res, err := f.QueryA(context.Background(), "broken.example.com")
+	if !errors.Is(err, injected) {
+		t.Fatalf("err = %v, want injected error", err)
+	}
+	if res == nil || res.Error == nil {
+		t.Fatal("result does not carry the error")
+	}
+	mq, err := f.MultiQuery(context.Background(), "broken.example.com", dns.TypeA)
+	if err != nil || len(mq.Results) != 1 || !errors.Is(mq.Results[0].Error, injected) {
+		t.Errorf("MultiQuery = %+v, %v", mq, err)
+	}
+	if _, err := f.LookupNetIP(context.Background(), "ip", "broken.example.com"); !errors.Is(err, injected) {
+		t.Errorf("LookupNetIP err = %v, want injected error", err)
+	}
+	if _, err := f.LookupNetIP(context.Background(), "ip", "gone.example.com"); !errors.Is(err, godns.ErrNXDomain) {
+		t.Errorf("LookupNetIP err = %v, want ErrNXDomain", err)
+	}
+	if _, err := f.LookupNetIP(context.Background(), "ip4", "empty.example.com"); !errors.Is(err, godns.ErrNoRecords) {
+		t.Errorf("LookupNetIP err = %v, want ErrNoRecords", err)
+	}
+}
+
+func TestFakeResolverLatency(t *testing.T) {
+	f := godnstest.NewFakeResolver()
+	must(t, f.AddRecords("www.example.com", dns.TypeA, "www.example.com. 60 IN A 192.0.2.1"))
+	f.SetLatency(50 * time.Millisecond)
+
+	start := time.Now()
+	if _, err := f.QueryA(context.Background(), "www.example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("query returned after %v, want at least 50ms", elapsed)
+	}
+
+	// 延迟期间 context 结束立即返回
+	f.SetLatency(time.Hour)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	if _, err := f.QueryA(ctx, "www.example.com"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("cancelled query took %v", elapsed)
+	}
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
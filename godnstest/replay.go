@@ -0,0 +1,100 @@
+// Package godnstest 提供用于测试基于 godns 的应用的辅助工具，无需真实网络
+package godnstest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+)
+
+// ErrNotRecorded 回放传输层中没有与查询问题匹配的应答
+var ErrNotRecorded = errors.New("godnstest: no recorded response for question")
+
+// ReplayTransport 按查询问题（名称、类型、类别）回放预置应答的传输层，
+// 通过 godns.WithTransport 注入客户端即可在不使用套接字的情况下测试上层逻辑
+type ReplayTransport struct {
+	mu        sync.Mutex
+	responses map[string]*dns.Msg
+	requests  []*dns.Msg
+}
+
+var _ godns.Transport = (*ReplayTransport)(nil)
+
+// NewReplayTransport 创建空的回放传输层
+func NewReplayTransport() *ReplayTransport {
+	return &ReplayTransport{responses: make(map[string]*dns.Msg)}
+}
+
+// Add 添加预置应答，按应答中的第一个问题建立索引，同一问题后添加的覆盖先添加的
+func (t *ReplayTransport) Add(response *dns.Msg) error {
+	if len(response.Question) == 0 {
+		return fmt.Errorf("godnstest: response has no question")
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.responses[questionKey(response.Question[0])] = response.Copy()
+	return nil
+}
+
+// AddRecords 以区域文件格式的记录构建 NOERROR 应答并添加
+func (t *ReplayTransport) AddRecords(name string, qtype uint16, records ...string) error {
+	response := new(dns.Msg)
+	response.SetQuestion(dns.Fqdn(name), qtype)
+	response.Response = true
+	response.RecursionAvailable = true
+	for _, record := range records {
+		rr, err := dns.NewRR(record)
+		if err != nil {
+			return fmt.Errorf("godnstest: invalid record %q: %v", record, err)
+		}
+		response.Answer = append(response.Answer, rr)
+	}
+	return t.Add(response)
+}
+
+// AddRcode 添加只包含响应码的应答，例如 dns.RcodeNameError
+func (t *ReplayTransport) AddRcode(name string, qtype uint16, rcode int) error {
+	response := new(dns.Msg)
+	response.SetQuestion(dns.Fqdn(name), qtype)
+	response.Response = true
+	response.Rcode = rcode
+	return t.Add(response)
+}
+
+// Exchange 实现 godns.Transport 接口，返回与查询问题匹配的预置应答副本
+func (t *ReplayTransport) Exchange(ctx context.Context, msg *dns.Msg, server string) (*dns.Msg, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if len(msg.Question) == 0 {
+		return nil, fmt.Errorf("godnstest: query has no question")
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.requests = append(t.requests, msg.Copy())
+
+	response, ok := t.responses[questionKey(msg.Question[0])]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotRecorded, msg.Question[0].String())
+	}
+	reply := response.Copy()
+	reply.Id = msg.Id
+	return reply, nil
+}
+
+// Requests 返回已收到的查询
+func (t *ReplayTransport) Requests() []*dns.Msg {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]*dns.Msg(nil), t.requests...)
+}
+
+// questionKey 查询问题的索引键，名称不区分大小写
+func questionKey(q dns.Question) string {
+	return fmt.Sprintf("%s|%d|%d", godns.CanonicalName(q.Name), q.Qtype, q.Qclass)
+}
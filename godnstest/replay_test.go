@@ -0,0 +1,175 @@
+package godnstest_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+	"github.com/zan8in/godns/godnstest"
+)
+
+// deadServers 对指定服务器返回错误，其余服务器交给回放传输层
+type deadServers struct {
+	*godnstest.ReplayTransport
+	dead map[string]bool
+
+	mu      sync.Mutex
+	servers []string
+}
+
+func (t *deadServers) Exchange(ctx context.Context, msg *dns.Msg, server string) (*dns.Msg, error) {
+	t.mu.Lock()
+	t.servers = append(t.servers, server)
+	t.mu.Unlock()
+	if t.dead[server] {
+		return nil, fmt.Errorf("connection refused by %s", server)
+	}
+	return t.ReplayTransport.Exchange(ctx, msg, server)
+}
+
+func newReplay(t *testing.T) *godnstest.ReplayTransport {
+	t.Helper()
+	replay := godnstest.NewReplayTransport()
+	if err := replay.AddRecords("www.example.com", dns.TypeA, "www.example.com. 300 IN A 192.0.2.1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := replay.AddRcode("missing.example.com", dns.TypeA, dns.RcodeNameError); err != nil {
+		t.Fatal(err)
+	}
+	return replay
+}
+
+func TestReplayRetries(t *testing.T) {
+	replay := newReplay(t)
+	c := godns.New(godns.WithTransport(replay), godns.WithServers("a"), godns.WithRetries(2))
+	defer c.Close()
+
+	res, err := c.QueryA(context.Background(), "unrecorded.example.com")
+	if !errors.Is(err, godnstest.ErrNotRecorded) {
+		t.Fatalf("err = %v, want ErrNotRecorded", err)
+	}
+	if n := len(res.Path.Attempts); n != 3 {
+		t.Errorf("attempts = %d, want 3", n)
+	}
+	if n := len(replay.Requests()); n != 3 {
+		t.Errorf("transport saw %d requests, want 3", n)
+	}
+}
+
+func TestReplayFailover(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []godns.Option
+		servers []string
+	}{
+		{"max-forwarders", []godns.Option{godns.WithRetries(0), godns.WithMaxForwarders(2)}, []string{"dead", "live"}},
+		{"next-server", []godns.Option{godns.WithRetries(1), godns.WithRetryPlacement(godns.NextServer)}, []string{"dead", "live"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := &deadServers{ReplayTransport: newReplay(t), dead: map[string]bool{"dead": true}}
+			opts := append([]godns.Option{godns.WithTransport(transport), godns.WithServers("dead", "live")}, tt.opts...)
+			c := godns.New(opts...)
+			defer c.Close()
+
+			res, err := c.QueryA(context.Background(), "www.example.com")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Server != "live" || len(res.Records) != 1 || res.Records[0].Value() != "192.0.2.1" {
+				t.Errorf("server = %s, records = %v", res.Server, res.Records)
+			}
+			if fmt.Sprint(transport.servers) != fmt.Sprint(tt.servers) {
+				t.Errorf("servers tried = %v, want %v", transport.servers, tt.servers)
+			}
+		})
+	}
+}
+
+func TestReplayMultiQuery(t *testing.T) {
+	transport := &deadServers{ReplayTransport: newReplay(t), dead: map[string]bool{"c": true}}
+	c := godns.New(godns.WithTransport(transport), godns.WithServers("a", "b", "c"), godns.WithRetries(0))
+	defer c.Close()
+
+	res, err := c.MultiQuery(context.Background(), "www.example.com", dns.TypeA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Results) != 3 {
+		t.Fatalf("results = %d, want 3", len(res.Results))
+	}
+	failed := 0
+	for _, r := range res.Results {
+		if r.Error != nil {
+			failed++
+			if r.Server != "c" {
+				t.Errorf("unexpected failure from %s: %v", r.Server, r.Error)
+			}
+		}
+	}
+	if failed != 1 {
+		t.Errorf("failed results = %d, want 1", failed)
+	}
+	if len(res.AllIPs) != 1 || res.AllIPs[0] != "192.0.2.1" {
+		t.Errorf("AllIPs = %v", res.AllIPs)
+	}
+}
+
+func TestReplayCache(t *testing.T) {
+	replay := newReplay(t)
+	c := godns.New(godns.WithTransport(replay), godns.WithServers("a"), godns.WithCache(time.Minute))
+	defer c.Close()
+
+	for i, want := range []string{godns.SourceNetwork, godns.SourceCache} {
+		res, err := c.QueryA(context.Background(), "www.example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Path.Source != want {
+			t.Errorf("query %d: source = %q, want %q", i+1, res.Path.Source, want)
+		}
+	}
+	if n := len(replay.Requests()); n != 1 {
+		t.Errorf("transport saw %d requests, want 1", n)
+	}
+}
+
+func TestReplayHooks(t *testing.T) {
+	replay := newReplay(t)
+	var requested []string
+	c := godns.New(
+		godns.WithTransport(replay),
+		godns.WithServers("a"),
+		godns.WithOnRequest(func(_ context.Context, server string, msg *dns.Msg) {
+			requested = append(requested, server+" "+msg.Question[0].Name)
+		}),
+		godns.WithResponseInterceptor(func(_ context.Context, msg *dns.Msg) (*dns.Msg, error) {
+			if msg.Question[0].Name == "missing.example.com." {
+				return nil, fmt.Errorf("injected: %w", godns.ErrServFail)
+			}
+			msg.Answer[0].(*dns.A).A = []byte{198, 51, 100, 1}
+			return msg, nil
+		}),
+	)
+	defer c.Close()
+
+	res, err := c.QueryA(context.Background(), "www.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := res.Records[0].Value(); got != "198.51.100.1" {
+		t.Errorf("intercepted value = %s, want 198.51.100.1", got)
+	}
+	if _, err := c.QueryA(context.Background(), "missing.example.com"); !errors.Is(err, godns.ErrServFail) {
+		t.Errorf("err = %v, want ErrServFail", err)
+	}
+	want := []string{"a www.example.com.", "a missing.example.com."}
+	if fmt.Sprint(requested) != fmt.Sprint(want) {
+		t.Errorf("OnRequest calls = %v, want %v", requested, want)
+	}
+}
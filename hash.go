@@ -0,0 +1,107 @@
+package godns
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+	"strconv"
+
+	"github.com/miekg/dns"
+)
+
+// AnswerHash 计算结果中应答记录集合的稳定哈希，可用于判断两次应答是否相同或作为变更检测的键
+// 基于 RFC 4034 §6 的规范形式：所有者名称及 RDATA 中的域名小写、不压缩，不包含TTL，
+// 按线路格式排序并去重，因此记录顺序和TTL的变化不影响哈希值，而任何 RDATA 的差异都会改变哈希值
+// 反序列化得到的记录没有原始资源记录，退化为对规范化名称、类型和 Value 的哈希，两种形式的哈希值不可互相比较
+func AnswerHash(res *QueryResult) string {
+	if res == nil {
+		return ""
+	}
+	blobs := make([][]byte, 0, len(res.Records))
+	buf := make([]byte, dns.MaxMsgSize)
+	for _, record := range res.Records {
+		if record.rr != nil {
+			if n, err := dns.PackRR(canonicalRR(record.rr), buf, 0, nil, false); err == nil {
+				blobs = append(blobs, append([]byte{'w'}, buf[:n]...))
+				continue
+			}
+		}
+		blob := []byte("t" + CanonicalName(record.Name) + "\x00" + strconv.Itoa(int(record.Type)) + "\x00" + record.Value())
+		blobs = append(blobs, blob)
+	}
+	slices.SortFunc(blobs, bytes.Compare)
+	blobs = slices.CompactFunc(blobs, bytes.Equal)
+
+	h := sha256.New()
+	for _, blob := range blobs {
+		h.Write([]byte{byte(len(blob) >> 8), byte(len(blob))})
+		h.Write(blob)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// AnswerHash 返回 AnswerHash(r)，首次调用时计算并缓存在结果中
+// 与修改 Records 的代码一样，首次调用不应与其他 goroutine 并发进行
+func (r *QueryResult) AnswerHash() string {
+	if r.answerHash == "" {
+		r.answerHash = AnswerHash(r)
+	}
+	return r.answerHash
+}
+
+// canonicalRR 返回记录的规范形式副本：TTL置零，所有者名称及 RFC 4034 §6.2（经 RFC 6840 §5.1 修订）
+// 所列类型 RDATA 中的域名转换为小写
+func canonicalRR(rr dns.RR) dns.RR {
+	rr = dns.Copy(rr)
+	hdr := rr.Header()
+	hdr.Name = CanonicalName(hdr.Name)
+	hdr.Ttl = 0
+	switch v := rr.(type) {
+	case *dns.NS:
+		v.Ns = CanonicalName(v.Ns)
+	case *dns.CNAME:
+		v.Target = CanonicalName(v.Target)
+	case *dns.PTR:
+		v.Ptr = CanonicalName(v.Ptr)
+	case *dns.DNAME:
+		v.Target = CanonicalName(v.Target)
+	case *dns.MX:
+		v.Mx = CanonicalName(v.Mx)
+	case *dns.SOA:
+		v.Ns = CanonicalName(v.Ns)
+		v.Mbox = CanonicalName(v.Mbox)
+	case *dns.SRV:
+		v.Target = CanonicalName(v.Target)
+	case *dns.NAPTR:
+		v.Replacement = CanonicalName(v.Replacement)
+	case *dns.KX:
+		v.Exchanger = CanonicalName(v.Exchanger)
+	case *dns.RT:
+		v.Host = CanonicalName(v.Host)
+	case *dns.AFSDB:
+		v.Hostname = CanonicalName(v.Hostname)
+	case *dns.PX:
+		v.Map822 = CanonicalName(v.Map822)
+		v.Mapx400 = CanonicalName(v.Mapx400)
+	case *dns.MINFO:
+		v.Rmail = CanonicalName(v.Rmail)
+		v.Email = CanonicalName(v.Email)
+	case *dns.RP:
+		v.Mbox = CanonicalName(v.Mbox)
+		v.Txt = CanonicalName(v.Txt)
+	case *dns.MB:
+		v.Mb = CanonicalName(v.Mb)
+	case *dns.MG:
+		v.Mg = CanonicalName(v.Mg)
+	case *dns.MR:
+		v.Mr = CanonicalName(v.Mr)
+	case *dns.MD:
+		v.Md = CanonicalName(v.Md)
+	case *dns.MF:
+		v.Mf = CanonicalName(v.Mf)
+	case *dns.RRSIG:
+		v.SignerName = CanonicalName(v.SignerName)
+	}
+	return rr
+}
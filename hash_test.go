@@ -0,0 +1,113 @@
+package godns_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+)
+
+func hashOf(t *testing.T, records ...string) string {
+	t.Helper()
+	res := &godns.QueryResult{Domain: "www.example.com", Type: dns.TypeA}
+	for _, s := range records {
+		res.Records = append(res.Records, godns.NewRecord(mustRR(s)))
+	}
+	return godns.AnswerHash(res)
+}
+
+func TestAnswerHash(t *testing.T) {
+	base := hashOf(t,
+		"www.example.com. 300 IN A 192.0.2.1",
+		"www.example.com. 300 IN A 192.0.2.2",
+		"www.example.com. 300 IN A 192.0.2.3",
+	)
+	tests := []struct {
+		name    string
+		records []string
+		same    bool
+	}{
+		{"permuted", []string{
+			"www.example.com. 300 IN A 192.0.2.3",
+			"www.example.com. 300 IN A 192.0.2.1",
+			"www.example.com. 300 IN A 192.0.2.2",
+		}, true},
+		{"different-ttls", []string{
+			"www.example.com. 60 IN A 192.0.2.1",
+			"www.example.com. 3600 IN A 192.0.2.2",
+			"www.example.com. 1 IN A 192.0.2.3",
+		}, true},
+		{"owner-case", []string{
+			"WWW.Example.COM. 300 IN A 192.0.2.2",
+			"www.example.com. 300 IN A 192.0.2.1",
+			"www.EXAMPLE.com. 300 IN A 192.0.2.3",
+		}, true},
+		{"duplicate", []string{
+			"www.example.com. 300 IN A 192.0.2.1",
+			"www.example.com. 300 IN A 192.0.2.2",
+			"www.example.com. 300 IN A 192.0.2.3",
+			"www.example.com. 120 IN A 192.0.2.2",
+		}, true},
+		{"one-address-changed", []string{
+			"www.example.com. 300 IN A 192.0.2.1",
+			"www.example.com. 300 IN A 192.0.2.2",
+			"www.example.com. 300 IN A 192.0.2.4",
+		}, false},
+		{"one-address-missing", []string{
+			"www.example.com. 300 IN A 192.0.2.1",
+			"www.example.com. 300 IN A 192.0.2.2",
+		}, false},
+		{"different-owner", []string{
+			"www.example.com. 300 IN A 192.0.2.1",
+			"www.example.com. 300 IN A 192.0.2.2",
+			"web.example.com. 300 IN A 192.0.2.3",
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hashOf(t, tt.records...); (got == base) != tt.same {
+				t.Errorf("hash equal = %v, want %v", got == base, tt.same)
+			}
+		})
+	}
+}
+
+func TestAnswerHashRDATANames(t *testing.T) {
+	// RDATA 中的域名按规范形式小写
+	a := hashOf(t, "example.com. 300 IN MX 10 Mail.Example.com.")
+	b := hashOf(t, "example.com. 300 IN MX 10 mail.example.com.")
+	if a != b {
+		t.Error("MX exchange case changed the hash")
+	}
+	if c := hashOf(t, "example.com. 300 IN MX 20 mail.example.com."); c == b {
+		t.Error("MX preference change did not change the hash")
+	}
+}
+
+func TestAnswerHashDecoded(t *testing.T) {
+	res := &godns.QueryResult{Domain: "www.example.com", Type: dns.TypeA, Records: []godns.Record{
+		godns.NewRecord(mustRR("www.example.com. 300 IN A 192.0.2.1")),
+		godns.NewRecord(mustRR("www.example.com. 300 IN A 192.0.2.2")),
+	}}
+	data, err := json.Marshal(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded, permuted godns.QueryResult
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &permuted); err != nil {
+		t.Fatal(err)
+	}
+	permuted.Records[0], permuted.Records[1] = permuted.Records[1], permuted.Records[0]
+	permuted.Records[0].TTL = 1
+	if decoded.AnswerHash() == "" || decoded.AnswerHash() != permuted.AnswerHash() {
+		t.Error("decoded records: permuted order and TTL changed the hash")
+	}
+	decoded.Records[0] = godns.NewValueRecord("www.example.com.", dns.TypeA, 300, "192.0.2.9")
+	if got := godns.AnswerHash(&decoded); got == permuted.AnswerHash() {
+		t.Error("decoded records: changed address did not change the hash")
+	}
+}
@@ -0,0 +1,95 @@
+package godns_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+	"github.com/zan8in/godns/internal/testserver"
+)
+
+// TestHeaderFlags 应答头部的 AA、TC、RA、AD 位和应答码原样记录在结果中
+func TestHeaderFlags(t *testing.T) {
+	s := startServer(t)
+	answer := []dns.RR{testserver.RR("www.test. 60 IN A 192.0.2.1")}
+	s.Handle("default.test", dns.TypeA, testserver.Reply{Answer: answer})
+	s.Handle("flipped.test", dns.TypeA, testserver.Reply{Answer: answer, NotAuthoritative: true, NoRecursion: true, AuthenticatedData: true})
+	s.Handle("truncated.test", dns.TypeA, testserver.Reply{Answer: answer, Truncate: true})
+	s.Handle("servfail.test", dns.TypeA, testserver.Reply{Rcode: dns.RcodeServerFailure})
+	s.Handle("refused.test", dns.TypeA, testserver.Reply{Rcode: dns.RcodeRefused})
+	c := godns.New(godns.WithServers(s.UDPAddr), godns.WithRetries(0), godns.WithoutRefusalProbe())
+	defer c.Close()
+
+	tests := []struct {
+		domain         string
+		rcode          string
+		err            error
+		aa, tc, ra, ad bool
+	}{
+		{"default.test", "NOERROR", nil, true, false, true, false},
+		{"flipped.test", "NOERROR", nil, false, false, false, true},
+		{"truncated.test", "NOERROR", nil, true, true, true, false},
+		// 未编排的名称得到 NXDOMAIN，以 ErrNXDomain 返回，结果照常带有头部标志
+		{"nonexistent.test", "NXDOMAIN", godns.ErrNXDomain, true, false, true, false},
+		{"servfail.test", "SERVFAIL", godns.ErrServFail, true, false, true, false},
+		{"refused.test", "REFUSED", godns.ErrRefused, true, false, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.domain, func(t *testing.T) {
+			res, err := c.QueryA(context.Background(), tt.domain)
+			if tt.err == nil && err != nil || tt.err != nil && !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if res.RcodeString() != tt.rcode || res.Rcode != dns.StringToRcode[tt.rcode] {
+				t.Errorf("rcode = %d (%s), want %s", res.Rcode, res.RcodeString(), tt.rcode)
+			}
+			if tt.err != nil && tt.err != godns.ErrNXDomain {
+				// 失败的结果只保证应答码
+				return
+			}
+			if res.Authoritative != tt.aa || res.Truncated != tt.tc || res.RecursionAvailable != tt.ra || res.AD != tt.ad {
+				t.Errorf("AA=%v TC=%v RA=%v AD=%v, want AA=%v TC=%v RA=%v AD=%v",
+					res.Authoritative, res.Truncated, res.RecursionAvailable, res.AD, tt.aa, tt.tc, tt.ra, tt.ad)
+			}
+		})
+	}
+}
+
+// TestMultiQueryAllIPsSkipsFailedRcodes AllIPs 只收集 NOERROR 应答中的地址，
+// NXDOMAIN、SERVFAIL、REFUSED 应答中携带的地址不计入
+func TestMultiQueryAllIPsSkipsFailedRcodes(t *testing.T) {
+	var servers []string
+	for _, reply := range []testserver.Reply{
+		{Answer: []dns.RR{testserver.RR("www.test. 60 IN A 192.0.2.1")}},
+		{Rcode: dns.RcodeNameError, Answer: []dns.RR{testserver.RR("www.test. 60 IN A 198.51.100.1")}},
+		{Rcode: dns.RcodeServerFailure, Answer: []dns.RR{testserver.RR("www.test. 60 IN A 198.51.100.2")}},
+		{Rcode: dns.RcodeRefused, Answer: []dns.RR{testserver.RR("www.test. 60 IN A 198.51.100.3")}},
+	} {
+		s := startServer(t)
+		s.Handle("www.test", dns.TypeA, reply)
+		servers = append(servers, s.UDPAddr)
+	}
+	c := godns.New(godns.WithServers(servers...), godns.WithRetries(0), godns.WithoutRefusalProbe())
+	defer c.Close()
+
+	res, err := c.MultiQueryA(context.Background(), "www.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(res.AllIPs, []string{"192.0.2.1"}) {
+		t.Errorf("AllIPs = %v, want only the NOERROR address", res.AllIPs)
+	}
+	rcodes := map[string]string{}
+	for _, r := range res.Results {
+		rcodes[r.Server] = r.RcodeString()
+	}
+	want := map[string]string{servers[0]: "NOERROR", servers[1]: "NXDOMAIN", servers[2]: "SERVFAIL", servers[3]: "REFUSED"}
+	for server, rcode := range want {
+		if rcodes[server] != rcode {
+			t.Errorf("%s: rcode = %s, want %s", server, rcodes[server], rcode)
+		}
+	}
+}
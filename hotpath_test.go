@@ -0,0 +1,133 @@
+package godns
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns/internal/testserver"
+)
+
+// TestTransportObjectsBuiltOnce 由配置决定的传输层对象只在构建客户端时创建一次，
+// 各协议的并发查询共享同一组对象（在 -race 下运行以确认共享是安全的）
+func TestTransportObjectsBuiltOnce(t *testing.T) {
+	s, err := testserver.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.Answer("example.com", dns.TypeA, "example.com. 60 IN A 192.0.2.1")
+	proxy, err := testserver.StartSOCKS5("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer proxy.Close()
+
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{"direct", []Option{WithServers(s.UDPAddr, "tcp://"+s.TCPAddr, "tls://"+s.DoTAddr, s.DoHURL)}},
+		{"socks5", []Option{WithServers("tcp://"+s.TCPAddr, "tls://"+s.DoTAddr), WithSOCKS5Proxy(proxy.Addr, nil)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(append(tt.opts, WithTLSConfig(s.ClientTLSConfig()), WithRetries(0))...)
+			defer c.Close()
+			before := c.transports
+			dohURLs := make(map[string]*url.URL, len(before.dohURLs))
+			for server, u := range before.dohURLs {
+				dohURLs[server] = u
+			}
+
+			var wg sync.WaitGroup
+			for range 8 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for range 10 {
+						res, err := c.MultiQuery(context.Background(), "example.com", dns.TypeA)
+						if err != nil {
+							t.Error(err)
+							return
+						}
+						for _, r := range res.Results {
+							if r.Error != nil {
+								t.Errorf("%s: %v", r.Server, r.Error)
+							}
+						}
+					}
+				}()
+			}
+			wg.Wait()
+
+			after := c.transports
+			if after.udpClient != before.udpClient || after.tcpClient != before.tcpClient || after.dotClient != before.dotClient ||
+				after.httpClient != before.httpClient || after.proxyDialer != before.proxyDialer || after.proxyURL != before.proxyURL {
+				t.Error("transport objects were rebuilt during queries")
+			}
+			for server, u := range dohURLs {
+				if after.dohURLs[server] != u {
+					t.Errorf("DoH URL for %s was re-parsed", server)
+				}
+			}
+		})
+	}
+}
+
+// TestTransportLookupsDoNotAllocate 查询路径上取用预先构建的对象不产生分配
+func TestTransportLookupsDoNotAllocate(t *testing.T) {
+	const doh = "https://dns.example.test/dns-query"
+	c := New(WithServers(doh, "192.0.2.1:53"), WithSOCKS5Proxy("127.0.0.1:1080", &ProxyAuth{Username: "u", Password: "p"}))
+	defer c.Close()
+	if c.transports.proxyDialer == nil || c.transports.proxyURL == nil || c.transports.dohURLs[doh] == nil {
+		t.Fatalf("transports not prepared: %+v", c.transports)
+	}
+
+	lookups := map[string]func(){
+		"proxy dialer": func() {
+			if _, err := c.proxyDialer(); err != nil {
+				t.Fatal(err)
+			}
+		},
+		"DoH URL": func() {
+			if c.transports.dohURLs[doh] == nil {
+				t.Fatal("missing DoH URL")
+			}
+		},
+		"server TLS": func() {
+			_ = c.tlsConfigFor("192.0.2.1:53")
+		},
+	}
+	for name, lookup := range lookups {
+		if n := testing.AllocsPerRun(100, lookup); n != 0 {
+			t.Errorf("%s: %v allocations per lookup, want 0", name, n)
+		}
+	}
+
+}
+
+// BenchmarkTransportRebuild 每次查询重新构建传输层对象的开销，作为预先构建的对照
+func BenchmarkTransportRebuild(b *testing.B) {
+	c := New(WithServers("https://dns.example.test/dns-query"), WithSOCKS5Proxy("127.0.0.1:1080", nil))
+	defer c.Close()
+	benchmarks := []struct {
+		name string
+		fn   func()
+	}{
+		{"prebuilt", func() { c.proxyDialer() }},
+		{"createDialer", func() { c.createDialer() }},
+		{"getProxyURL", func() { c.getProxyURL() }},
+		{"parseDoHURL", func() { parseDoHURL("https://dns.example.test/dns-query") }},
+	}
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				bm.fn()
+			}
+		})
+	}
+}
@@ -0,0 +1,339 @@
+package godns
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// defaultHygieneTimeout context 未设置截止时间时区域检查的整体时间预算
+const defaultHygieneTimeout = 30 * time.Second
+
+// Severity 检查发现的严重程度
+type Severity string
+
+const (
+	SeverityInfo    Severity = "info"
+	SeverityWarning Severity = "warning"
+	SeverityError   Severity = "error"
+)
+
+// Finding 一项区域配置问题
+type Finding struct {
+	Check       string   // 检查项名称，例如 "cname-apex"
+	Severity    Severity // 严重程度
+	Explanation string   // 简要说明
+	Evidence    []Record // 相关的记录
+}
+
+// HygieneReport 区域配置检查报告
+type HygieneReport struct {
+	Domain   string
+	Zone     string    // domain 所在区域的顶点
+	Servers  []string  // 参与检查的权威服务器
+	Findings []Finding // 发现的问题，按严重程度排序
+	Errors   []string  // 检查过程中无法完成的查询
+}
+
+// hygieneCheck 单项检查
+type hygieneCheck func(ctx context.Context, h *hygieneRun)
+
+// hygieneRun 一次区域检查的共享状态
+type hygieneRun struct {
+	c      *Client
+	domain string
+	zone   string
+	auth   []authServer
+
+	mu     sync.Mutex
+	report *HygieneReport
+}
+
+func (h *hygieneRun) add(f Finding) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.report.Findings = append(h.report.Findings, f)
+}
+
+func (h *hygieneRun) fail(format string, args ...interface{}) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.report.Errors = append(h.report.Errors, fmt.Sprintf(format, args...))
+}
+
+// query 查询 name，查询失败时记录错误并返回 nil
+func (h *hygieneRun) query(ctx context.Context, name string, qtype uint16) *QueryResult {
+	res, err := h.c.Query(ctx, name, qtype)
+	if err = ignoreNXDomain(err); err != nil {
+		h.fail("%s %s: %v", name, dns.TypeToString[qtype], err)
+		return nil
+	}
+	return res
+}
+
+// CheckZoneHygiene 并发运行一组针对性查询，报告常见的区域配置问题：
+// CNAME 与其他类型共存、区域顶点的 CNAME、MX/NS 目标为 CNAME 或无法解析、
+// 以 SPF 类型发布的 SPF、各权威服务器间 RRset TTL 不一致以及邮件服务器缺少反向解析
+// 所有检查共享 ctx 的截止时间，未设置时使用默认的时间预算
+func (c *Client) CheckZoneHygiene(ctx context.Context, domain string) (*HygieneReport, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultHygieneTimeout)
+		defer cancel()
+	}
+
+	domain = strings.TrimSuffix(domain, ".")
+	zone, err := c.FindZone(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+	auth, err := c.authoritativeServers(ctx, zone)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &hygieneRun{
+		c:      c,
+		domain: domain,
+		zone:   zone.Apex,
+		auth:   auth,
+		report: &HygieneReport{Domain: domain, Zone: zone.Apex},
+	}
+	for _, server := range auth {
+		h.report.Servers = append(h.report.Servers, server.Address)
+	}
+
+	checks := []hygieneCheck{
+		checkCNAMECoexistence,
+		checkCNAMEAtApex,
+		checkMXTargets,
+		checkNSTargets,
+		checkSPFType,
+		checkTTLConsistency,
+		checkMailReverse,
+	}
+	var wg sync.WaitGroup
+	for _, check := range checks {
+		wg.Add(1)
+		go func(check hygieneCheck) {
+			defer wg.Done()
+			check(ctx, h)
+		}(check)
+	}
+	wg.Wait()
+
+	rank := map[Severity]int{SeverityError: 0, SeverityWarning: 1, SeverityInfo: 2}
+	sort.SliceStable(h.report.Findings, func(i, j int) bool {
+		return rank[h.report.Findings[i].Severity] < rank[h.report.Findings[j].Severity]
+	})
+	return h.report, ctx.Err()
+}
+
+// ownedBy 返回属于 owner 的指定类型记录，qtype 为 0 时返回全部
+func ownedBy(res *QueryResult, owner string, qtype uint16) []Record {
+	if res == nil {
+		return nil
+	}
+	var records []Record
+	for _, record := range res.Records {
+		if equalNames(record.Name, owner) && (qtype == 0 || record.Type == qtype) {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+// checkCNAMECoexistence 检查 CNAME 是否与其他类型的记录共存于同一名称
+func checkCNAMECoexistence(ctx context.Context, h *hygieneRun) {
+	server := h.auth[0]
+	res, err := h.c.queryAuthoritative(ctx, h.domain, dns.TypeCNAME, server)
+	if err = ignoreNXDomain(err); err != nil {
+		h.fail("%s CNAME at %s: %v", h.domain, server.Address, err)
+		return
+	}
+	cnames := ownedBy(res, h.domain, dns.TypeCNAME)
+	if len(cnames) == 0 {
+		return
+	}
+
+	evidence := cnames
+	for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA, dns.TypeMX, dns.TypeTXT} {
+		res, err := h.c.queryAuthoritative(ctx, h.domain, qtype, server)
+		if err != nil {
+			continue
+		}
+		evidence = append(evidence, ownedBy(res, h.domain, qtype)...)
+	}
+	if len(evidence) > len(cnames) {
+		h.add(Finding{
+			Check:       "cname-coexistence",
+			Severity:    SeverityError,
+			Explanation: "CNAME must not coexist with other record types at the same name (RFC 1034 §3.6.2)",
+			Evidence:    evidence,
+		})
+	}
+}
+
+// checkCNAMEAtApex 检查区域顶点是否存在 CNAME
+func checkCNAMEAtApex(ctx context.Context, h *hygieneRun) {
+	res, err := h.c.queryAuthoritative(ctx, h.zone, dns.TypeCNAME, h.auth[0])
+	if err = ignoreNXDomain(err); err != nil {
+		h.fail("%s CNAME at %s: %v", h.zone, h.auth[0].Address, err)
+		return
+	}
+	if cnames := ownedBy(res, h.zone, dns.TypeCNAME); len(cnames) > 0 {
+		h.add(Finding{
+			Check:       "cname-apex",
+			Severity:    SeverityError,
+			Explanation: "zone apex must not be a CNAME because it always owns SOA and NS records",
+			Evidence:    cnames,
+		})
+	}
+}
+
+// checkTargets 检查 MX/NS 的目标是否为 CNAME 以及能否解析
+func checkTargets(ctx context.Context, h *hygieneRun, check string, records []Record, target func(dns.RR) string) {
+	for _, record := range records {
+		name := target(record.RR())
+		if name == "" || name == "." {
+			continue
+		}
+
+		cname := h.query(ctx, name, dns.TypeCNAME)
+		if aliases := ownedBy(cname, name, dns.TypeCNAME); len(aliases) > 0 {
+			h.add(Finding{
+				Check:       check + "-cname",
+				Severity:    SeverityError,
+				Explanation: fmt.Sprintf("%s target %s is a CNAME (RFC 2181 §10.3)", dns.TypeToString[record.Type], name),
+				Evidence:    append([]Record{record}, aliases...),
+			})
+			continue
+		}
+
+		a := h.query(ctx, name, dns.TypeA)
+		aaaa := h.query(ctx, name, dns.TypeAAAA)
+		if a != nil && aaaa != nil && !hasRecordType(a, dns.TypeA) && !hasRecordType(aaaa, dns.TypeAAAA) {
+			h.add(Finding{
+				Check:       check + "-unresolvable",
+				Severity:    SeverityError,
+				Explanation: fmt.Sprintf("%s target %s has no A or AAAA records", dns.TypeToString[record.Type], name),
+				Evidence:    []Record{record},
+			})
+		}
+	}
+}
+
+// checkMXTargets 检查 MX 目标
+func checkMXTargets(ctx context.Context, h *hygieneRun) {
+	res := h.query(ctx, h.domain, dns.TypeMX)
+	checkTargets(ctx, h, "mx", ownedBy(res, h.domain, dns.TypeMX), func(rr dns.RR) string {
+		if mx, ok := rr.(*dns.MX); ok {
+			return mx.Mx
+		}
+		return ""
+	})
+}
+
+// checkNSTargets 检查 NS 目标
+func checkNSTargets(ctx context.Context, h *hygieneRun) {
+	res := h.query(ctx, h.zone, dns.TypeNS)
+	checkTargets(ctx, h, "ns", ownedBy(res, h.zone, dns.TypeNS), func(rr dns.RR) string {
+		if ns, ok := rr.(*dns.NS); ok {
+			return ns.Ns
+		}
+		return ""
+	})
+}
+
+// checkSPFType 检查是否以已废弃的 SPF 类型发布 SPF
+func checkSPFType(ctx context.Context, h *hygieneRun) {
+	res := h.query(ctx, h.domain, dns.TypeSPF)
+	spf := ownedBy(res, h.domain, dns.TypeSPF)
+	if len(spf) == 0 {
+		return
+	}
+
+	finding := Finding{
+		Check:       "spf-type",
+		Severity:    SeverityWarning,
+		Explanation: "SPF record type is obsolete (RFC 7208 §3.1); publish SPF as TXT",
+		Evidence:    spf,
+	}
+	txt := h.query(ctx, h.domain, dns.TypeTXT)
+	for _, record := range ownedBy(txt, h.domain, dns.TypeTXT) {
+		if strings.HasPrefix(strings.ToLower(record.Value()), "v=spf1") {
+			// 同时发布了TXT，仅作提示
+			finding.Severity = SeverityInfo
+			finding.Evidence = append(finding.Evidence, record)
+			break
+		}
+	}
+	h.add(finding)
+}
+
+// checkTTLConsistency 比较各权威服务器返回的同一 RRset 的 TTL
+func checkTTLConsistency(ctx context.Context, h *hygieneRun) {
+	for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA, dns.TypeMX, dns.TypeTXT, dns.TypeNS} {
+		var evidence []Record
+		ttls := make(map[uint32]bool)
+		for _, server := range h.auth {
+			res, err := h.c.queryAuthoritative(ctx, h.domain, qtype, server)
+			if err = ignoreNXDomain(err); err != nil {
+				h.fail("%s %s at %s: %v", h.domain, dns.TypeToString[qtype], server.Address, err)
+				continue
+			}
+			for _, record := range ownedBy(res, h.domain, qtype) {
+				ttls[record.TTL] = true
+				evidence = append(evidence, record)
+			}
+		}
+		if len(ttls) > 1 {
+			h.add(Finding{
+				Check:       "ttl-inconsistent",
+				Severity:    SeverityWarning,
+				Explanation: fmt.Sprintf("%s RRset has differing TTLs within the set or across authoritative servers", dns.TypeToString[qtype]),
+				Evidence:    evidence,
+			})
+		}
+	}
+}
+
+// checkMailReverse 检查邮件服务器地址是否有反向解析
+func checkMailReverse(ctx context.Context, h *hygieneRun) {
+	res := h.query(ctx, h.domain, dns.TypeMX)
+	for _, record := range ownedBy(res, h.domain, dns.TypeMX) {
+		mx, ok := record.RR().(*dns.MX)
+		if !ok || mx.Mx == "." {
+			continue
+		}
+		for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+			addrs := h.query(ctx, mx.Mx, qtype)
+			if addrs == nil {
+				continue
+			}
+			for _, addr := range addrs.Records {
+				if addr.Type != qtype {
+					continue
+				}
+				arpa, err := dns.ReverseAddr(addr.Value())
+				if err != nil {
+					continue
+				}
+				ptr := h.query(ctx, arpa, dns.TypePTR)
+				if ptr != nil && !hasRecordType(ptr, dns.TypePTR) {
+					h.add(Finding{
+						Check:       "mx-reverse",
+						Severity:    SeverityWarning,
+						Explanation: fmt.Sprintf("mail server %s address %s has no PTR record", mx.Mx, addr.Value()),
+						Evidence:    []Record{record, addr},
+					})
+				}
+			}
+		}
+	}
+}
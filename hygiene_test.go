@@ -0,0 +1,132 @@
+package godns_test
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+	"github.com/zan8in/godns/internal/testserver"
+)
+
+// hygieneZone 搭建区域 apex：递归服务器知道SOA、两个NS及其地址，两个权威服务器分别位于 192.0.2.1 和 192.0.2.2
+func hygieneZone(t *testing.T, apex string) (recursive, ns1, ns2 *testserver.Server, c *godns.Client) {
+	t.Helper()
+	recursive, ns1, ns2 = startServer(t), startServer(t), startServer(t)
+	recursive.Answer(apex, dns.TypeSOA, apex+". 3600 IN SOA ns1."+apex+". hostmaster."+apex+". 1 7200 900 1209600 300")
+	recursive.Answer(apex, dns.TypeNS, apex+". 3600 IN NS ns1."+apex+".", apex+". 3600 IN NS ns2."+apex+".")
+	recursive.Answer("ns1."+apex, dns.TypeA, "ns1."+apex+". 3600 IN A 192.0.2.1")
+	recursive.Answer("ns2."+apex, dns.TypeA, "ns2."+apex+". 3600 IN A 192.0.2.2")
+	c = godns.New(
+		godns.WithServers(recursiveAddr),
+		godns.WithTransport(authFake{recursiveAddr: recursive, "192.0.2.1:53": ns1, "192.0.2.2:53": ns2}),
+		godns.WithRetries(0),
+	)
+	t.Cleanup(func() { c.Close() })
+	return recursive, ns1, ns2, c
+}
+
+// TestCheckZoneHygiene 每类配置问题各给出一项发现，附带严重程度、说明和相关记录，发现按严重程度排序
+func TestCheckZoneHygiene(t *testing.T) {
+	recursive, ns1, ns2, c := hygieneZone(t, "broken.test")
+	// 区域顶点的 CNAME 与 A 记录共存，两个权威服务器给出的 A 记录TTL不同
+	for _, ns := range []*testserver.Server{ns1, ns2} {
+		ns.Answer("broken.test", dns.TypeCNAME, "broken.test. 300 IN CNAME www.other.test.")
+	}
+	ns1.Answer("broken.test", dns.TypeA, "broken.test. 300 IN A 192.0.2.80")
+	ns2.Answer("broken.test", dns.TypeA, "broken.test. 600 IN A 192.0.2.80")
+	// MX 目标分别为：地址缺少反向解析、CNAME、无法解析
+	recursive.Answer("broken.test", dns.TypeMX,
+		"broken.test. 300 IN MX 10 mail.broken.test.",
+		"broken.test. 300 IN MX 20 alias-mx.broken.test.",
+		"broken.test. 300 IN MX 30 gone.broken.test.",
+	)
+	recursive.Handle("mail.broken.test", dns.TypeCNAME, testserver.Reply{})
+	recursive.Answer("mail.broken.test", dns.TypeA, "mail.broken.test. 300 IN A 192.0.2.25")
+	recursive.Handle("mail.broken.test", dns.TypeAAAA, testserver.Reply{})
+	recursive.Handle("25.2.0.192.in-addr.arpa", dns.TypePTR, testserver.Reply{})
+	recursive.Answer("alias-mx.broken.test", dns.TypeCNAME, "alias-mx.broken.test. 300 IN CNAME mail.broken.test.")
+	// 同时以 SPF 和 TXT 类型发布
+	recursive.Answer("broken.test", dns.TypeSPF, `broken.test. 300 IN SPF "v=spf1 -all"`)
+	recursive.Answer("broken.test", dns.TypeTXT, `broken.test. 300 IN TXT "v=spf1 -all"`)
+
+	report, err := c.CheckZoneHygiene(context.Background(), "broken.test.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Domain != "broken.test" || report.Zone != "broken.test" {
+		t.Errorf("Domain %q, Zone %q", report.Domain, report.Zone)
+	}
+	servers := append([]string(nil), report.Servers...)
+	sort.Strings(servers)
+	if len(servers) != 2 || servers[0] != "192.0.2.1:53" || servers[1] != "192.0.2.2:53" {
+		t.Errorf("Servers = %v", report.Servers)
+	}
+
+	want := map[string]struct {
+		severity godns.Severity
+		evidence int
+	}{
+		"cname-coexistence": {godns.SeverityError, 2},
+		"cname-apex":        {godns.SeverityError, 1},
+		"mx-cname":          {godns.SeverityError, 2},
+		"mx-unresolvable":   {godns.SeverityError, 1},
+		"ttl-inconsistent":  {godns.SeverityWarning, 2},
+		"mx-reverse":        {godns.SeverityWarning, 2},
+		"spf-type":          {godns.SeverityInfo, 2},
+	}
+	seen := make(map[string]bool)
+	for _, f := range report.Findings {
+		w, ok := want[f.Check]
+		if !ok || seen[f.Check] {
+			t.Errorf("unexpected finding %+v", f)
+			continue
+		}
+		seen[f.Check] = true
+		if f.Severity != w.severity || len(f.Evidence) != w.evidence || f.Explanation == "" {
+			t.Errorf("%s: severity %s with %d evidence records (%q); want %s with %d",
+				f.Check, f.Severity, len(f.Evidence), f.Explanation, w.severity, w.evidence)
+		}
+	}
+	for check := range want {
+		if !seen[check] {
+			t.Errorf("missing finding %s", check)
+		}
+	}
+
+	rank := map[godns.Severity]int{godns.SeverityError: 0, godns.SeverityWarning: 1, godns.SeverityInfo: 2}
+	if !sort.SliceIsSorted(report.Findings, func(i, j int) bool {
+		return rank[report.Findings[i].Severity] < rank[report.Findings[j].Severity]
+	}) {
+		t.Error("findings are not ordered by severity")
+	}
+}
+
+// TestCheckZoneHygieneClean 配置正确的区域没有发现；SPF 仅以 SPF 类型发布时为警告
+func TestCheckZoneHygieneClean(t *testing.T) {
+	recursive, ns1, ns2, c := hygieneZone(t, "clean.test")
+	for _, ns := range []*testserver.Server{ns1, ns2} {
+		ns.Answer("clean.test", dns.TypeA, "clean.test. 300 IN A 192.0.2.80")
+	}
+	recursive.Answer("clean.test", dns.TypeMX, "clean.test. 300 IN MX 10 mail.clean.test.")
+	recursive.Answer("mail.clean.test", dns.TypeA, "mail.clean.test. 300 IN A 192.0.2.25")
+	recursive.Answer("25.2.0.192.in-addr.arpa", dns.TypePTR, "25.2.0.192.in-addr.arpa. 300 IN PTR mail.clean.test.")
+
+	report, err := c.CheckZoneHygiene(context.Background(), "clean.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Findings) != 0 {
+		t.Errorf("findings = %+v, want none", report.Findings)
+	}
+
+	recursive.Answer("clean.test", dns.TypeSPF, `clean.test. 300 IN SPF "v=spf1 -all"`)
+	report, err = c.CheckZoneHygiene(context.Background(), "clean.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Findings) != 1 || report.Findings[0].Check != "spf-type" || report.Findings[0].Severity != godns.SeverityWarning {
+		t.Errorf("findings = %+v, want a single spf-type warning", report.Findings)
+	}
+}
@@ -0,0 +1,129 @@
+package godns_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+)
+
+// TestInterceptorInjectedServFail 拦截器注入的 SERVFAIL（改写响应码或返回包装 ErrServFail 的错误）
+// 在每种传输协议上都与服务器实际返回的一样成为类型化错误
+func TestInterceptorInjectedServFail(t *testing.T) {
+	s := startServer(t)
+	s.Answer("example.com", dns.TypeA, "example.com. 60 IN A 192.0.2.1")
+
+	injections := map[string]func(context.Context, *dns.Msg) (*dns.Msg, error){
+		"rcode": func(_ context.Context, msg *dns.Msg) (*dns.Msg, error) {
+			msg.Rcode = dns.RcodeServerFailure
+			msg.Answer = nil
+			return msg, nil
+		},
+		"error": func(context.Context, *dns.Msg) (*dns.Msg, error) {
+			return nil, fmt.Errorf("chaos: %w", godns.ErrServFail)
+		},
+	}
+	transports := map[string]godns.Option{
+		"udp": godns.WithServers(s.UDPAddr),
+		"tcp": godns.WithServers("tcp://" + s.TCPAddr),
+		"dot": godns.WithServers("tls://" + s.DoTAddr),
+		"doh": godns.WithServers(s.DoHURL),
+		"doq": godns.WithServers("quic://" + s.DoQAddr),
+	}
+	for injName, inject := range injections {
+		for transportName, servers := range transports {
+			t.Run(injName+"/"+transportName, func(t *testing.T) {
+				c := godns.New(
+					servers,
+					godns.WithTLSConfig(s.ClientTLSConfig()),
+					godns.WithResponseInterceptor(inject),
+					godns.WithRetries(0),
+					godns.WithTimeout(2*time.Second),
+				)
+				defer c.Close()
+
+				res, err := c.QueryA(context.Background(), "example.com")
+				if !errors.Is(err, godns.ErrServFail) {
+					t.Fatalf("err = %v, want ErrServFail", err)
+				}
+				var info *godns.ErrorInfo
+				if !errors.As(err, &info) || info.Kind != godns.KindServFail {
+					t.Errorf("error kind = %v, want %s", info, godns.KindServFail)
+				}
+				if res == nil || res.Rcode != dns.RcodeServerFailure || len(res.Records) != 0 {
+					t.Errorf("result = %+v, want rcode SERVFAIL and no records", res)
+				}
+			})
+		}
+	}
+}
+
+// TestInterceptorServFailFailsOver 注入的 SERVFAIL 与真实的一样触发故障转移并被错误缓存记住
+func TestInterceptorServFailFailsOver(t *testing.T) {
+	first, second := startServer(t), startServer(t)
+	first.Answer("example.com", dns.TypeA, "example.com. 60 IN A 192.0.2.1")
+	second.Answer("example.com", dns.TypeA, "example.com. 60 IN A 192.0.2.2")
+
+	var calls atomic.Int32
+	c := godns.New(
+		godns.WithServers(first.UDPAddr, second.UDPAddr),
+		godns.WithMaxForwarders(2),
+		godns.WithErrorCaching(time.Minute),
+		godns.WithRetries(0),
+		godns.WithResponseInterceptor(func(_ context.Context, msg *dns.Msg) (*dns.Msg, error) {
+			// 只让第一个应答失败
+			if calls.Add(1) == 1 {
+				msg.Rcode = dns.RcodeServerFailure
+			}
+			return msg, nil
+		}),
+	)
+	defer c.Close()
+
+	res, err := c.QueryA(context.Background(), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Server != second.UDPAddr || res.Records[0].Value() != "192.0.2.2" {
+		t.Errorf("answered by %s with %v, want failover to %s", res.Server, res.Records, second.UDPAddr)
+	}
+	if st := c.ErrorCacheStats(); st.Entries != 1 {
+		t.Errorf("error cache entries = %d, want the injected SERVFAIL remembered", st.Entries)
+	}
+}
+
+// TestInterceptorRewriteIsCached 拦截器改写后的应答被缓存，缓存命中时不再经过拦截器
+func TestInterceptorRewriteIsCached(t *testing.T) {
+	s := startServer(t)
+	s.Answer("example.com", dns.TypeA, "example.com. 300 IN A 192.0.2.1")
+	var calls atomic.Int32
+	c := godns.New(
+		godns.WithServers(s.UDPAddr),
+		godns.WithCache(time.Hour),
+		godns.WithResponseInterceptor(func(_ context.Context, msg *dns.Msg) (*dns.Msg, error) {
+			calls.Add(1)
+			replaced := msg.Copy()
+			replaced.Answer = []dns.RR{mustRR("example.com. 300 IN A 203.0.113.7")}
+			return replaced, nil
+		}),
+	)
+	defer c.Close()
+
+	for i, wantSource := range []string{godns.SourceNetwork, godns.SourceCache} {
+		res, err := c.QueryA(context.Background(), "example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Path.Source != wantSource || len(res.Records) != 1 || res.Records[0].Value() != "203.0.113.7" {
+			t.Errorf("query %d: source %s, records %v; want %s with the rewritten answer", i, res.Path.Source, res.Records, wantSource)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("interceptor called %d times, want 1", n)
+	}
+}
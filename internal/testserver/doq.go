@@ -0,0 +1,75 @@
+package testserver
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+
+	"github.com/miekg/dns"
+	"github.com/quic-go/quic-go"
+)
+
+// listenDoQ 启动 DoQ（RFC 9250）监听
+func (s *Server) listenDoQ(cert tls.Certificate) error {
+	ln, err := quic.ListenAddr("127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		NextProtos:   []string{"doq"},
+	}, nil)
+	if err != nil {
+		return err
+	}
+	s.doq = ln
+	s.DoQAddr = ln.Addr().String()
+	go func() {
+		for {
+			conn, err := ln.Accept(context.Background())
+			if err != nil {
+				return
+			}
+			go s.serveDoQConn(conn)
+		}
+	}()
+	return nil
+}
+
+// serveDoQConn 处理一个QUIC连接上的全部流，每个流承载一次查询
+func (s *Server) serveDoQConn(conn *quic.Conn) {
+	for {
+		stream, err := conn.AcceptStream(context.Background())
+		if err != nil {
+			return
+		}
+		go s.serveDoQStream(conn, stream)
+	}
+}
+
+// serveDoQStream 读取带2字节长度前缀的查询并写回应答，丢弃时挂起直到连接关闭
+func (s *Server) serveDoQStream(conn *quic.Conn, stream *quic.Stream) {
+	defer stream.Close()
+	var length [2]byte
+	if _, err := io.ReadFull(stream, length[:]); err != nil {
+		return
+	}
+	wire := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(stream, wire); err != nil {
+		return
+	}
+	req := new(dns.Msg)
+	if err := req.Unpack(wire); err != nil {
+		return
+	}
+	m := s.reply(DoQ, "", conn.RemoteAddr().String(), req)
+	if m == nil {
+		waitDone(conn.Context(), s.closed)
+		return
+	}
+	out, err := m.Pack()
+	if err != nil {
+		return
+	}
+	buf := make([]byte, 2+len(out))
+	binary.BigEndian.PutUint16(buf, uint16(len(out)))
+	copy(buf[2:], out)
+	stream.Write(buf)
+}
@@ -0,0 +1,293 @@
+package testserver
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// Proxy 最小化的转发代理，只支持 TCP CONNECT，记录每次连接的目标地址
+type Proxy struct {
+	Addr string // 监听地址（IP:端口）
+
+	username string
+	password string
+	ln       net.Listener
+	serve    func(net.Conn)
+	wg       sync.WaitGroup
+
+	mu      sync.Mutex
+	conns   map[net.Conn]struct{}
+	targets []string
+	closed  bool
+}
+
+// StartSOCKS5 启动 SOCKS5 代理（RFC 1928），username 非空时要求用户名/密码认证（RFC 1929）
+func StartSOCKS5(username, password string) (*Proxy, error) {
+	p := &Proxy{username: username, password: password}
+	p.serve = p.serveSOCKS5
+	return p, p.start()
+}
+
+// StartHTTPConnect 启动只支持 CONNECT 方法的HTTP代理，username 非空时要求 Basic 认证
+func StartHTTPConnect(username, password string) (*Proxy, error) {
+	p := &Proxy{username: username, password: password}
+	p.serve = p.serveHTTP
+	return p, p.start()
+}
+
+// start 在 127.0.0.1 的随机端口上开始接受连接
+func (p *Proxy) start() error {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	p.ln = ln
+	p.Addr = ln.Addr().String()
+	p.conns = make(map[net.Conn]struct{})
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			if !p.track(conn) {
+				conn.Close()
+				return
+			}
+			p.wg.Add(1)
+			go func() {
+				defer p.wg.Done()
+				defer p.untrack(conn)
+				p.serve(conn)
+			}()
+		}
+	}()
+	return nil
+}
+
+// Targets 返回成功建立的连接的目标地址，按建立顺序排列
+func (p *Proxy) Targets() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.targets...)
+}
+
+// Close 停止接受连接并关闭所有转发中的连接
+func (p *Proxy) Close() error {
+	p.mu.Lock()
+	p.closed = true
+	for conn := range p.conns {
+		conn.Close()
+	}
+	p.mu.Unlock()
+	err := p.ln.Close()
+	p.wg.Wait()
+	return err
+}
+
+// track 登记连接，代理已关闭时返回 false
+func (p *Proxy) track(conn net.Conn) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return false
+	}
+	p.conns[conn] = struct{}{}
+	return true
+}
+
+// untrack 关闭并注销连接
+func (p *Proxy) untrack(conn net.Conn) {
+	conn.Close()
+	p.mu.Lock()
+	delete(p.conns, conn)
+	p.mu.Unlock()
+}
+
+// connect 连接目标并记录目标地址
+func (p *Proxy) connect(target string) (net.Conn, error) {
+	upstream, err := net.Dial("tcp", target)
+	if err != nil {
+		return nil, err
+	}
+	if !p.track(upstream) {
+		upstream.Close()
+		return nil, net.ErrClosed
+	}
+	p.mu.Lock()
+	p.targets = append(p.targets, target)
+	p.mu.Unlock()
+	return upstream, nil
+}
+
+// relay 双向转发，任一方向结束时关闭两端
+func (p *Proxy) relay(client io.ReadWriter, upstream net.Conn) {
+	defer p.untrack(upstream)
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(upstream, client)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(client, upstream)
+		done <- struct{}{}
+	}()
+	<-done
+}
+
+// serveSOCKS5 处理一个 SOCKS5 连接
+func (p *Proxy) serveSOCKS5(conn net.Conn) {
+	r := bufio.NewReader(conn)
+	// 协商认证方式
+	var head [2]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil || head[0] != 5 {
+		return
+	}
+	methods := make([]byte, head[1])
+	if _, err := io.ReadFull(r, methods); err != nil {
+		return
+	}
+	want := byte(0x00)
+	if p.username != "" {
+		want = 0x02
+	}
+	if !containsByte(methods, want) {
+		conn.Write([]byte{5, 0xff})
+		return
+	}
+	conn.Write([]byte{5, want})
+	if want == 0x02 && !p.socksAuth(r, conn) {
+		return
+	}
+
+	// 请求：VER CMD RSV ATYP DST.ADDR DST.PORT
+	var req [4]byte
+	if _, err := io.ReadFull(r, req[:]); err != nil || req[0] != 5 {
+		return
+	}
+	host, err := readSOCKSAddr(r, req[3])
+	if err != nil {
+		return
+	}
+	var port [2]byte
+	if _, err := io.ReadFull(r, port[:]); err != nil {
+		return
+	}
+	if req[1] != 1 {
+		conn.Write([]byte{5, 7, 0, 1, 0, 0, 0, 0, 0, 0}) // 不支持的命令
+		return
+	}
+	target := net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port[:]))))
+	upstream, err := p.connect(target)
+	if err != nil {
+		conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0}) // 连接被拒绝
+		return
+	}
+	conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+	p.relay(struct {
+		io.Reader
+		io.Writer
+	}{r, conn}, upstream)
+}
+
+// socksAuth 用户名/密码认证（RFC 1929）
+func (p *Proxy) socksAuth(r *bufio.Reader, conn net.Conn) bool {
+	ver, err := r.ReadByte()
+	if err != nil || ver != 1 {
+		return false
+	}
+	user, err := readSOCKSString(r)
+	if err != nil {
+		return false
+	}
+	pass, err := readSOCKSString(r)
+	if err != nil {
+		return false
+	}
+	if user != p.username || pass != p.password {
+		conn.Write([]byte{1, 1})
+		return false
+	}
+	conn.Write([]byte{1, 0})
+	return true
+}
+
+// readSOCKSAddr 按地址类型读取目标地址
+func readSOCKSAddr(r *bufio.Reader, atyp byte) (string, error) {
+	switch atyp {
+	case 1:
+		ip := make([]byte, net.IPv4len)
+		_, err := io.ReadFull(r, ip)
+		return net.IP(ip).String(), err
+	case 4:
+		ip := make([]byte, net.IPv6len)
+		_, err := io.ReadFull(r, ip)
+		return net.IP(ip).String(), err
+	case 3:
+		return readSOCKSString(r)
+	default:
+		return "", errors.New("unsupported address type")
+	}
+}
+
+// readSOCKSString 读取以一个长度字节开头的字符串
+func readSOCKSString(r *bufio.Reader) (string, error) {
+	n, err := r.ReadByte()
+	if err != nil {
+		return "", err
+	}
+	buf := make([]byte, n)
+	_, err = io.ReadFull(r, buf)
+	return string(buf), err
+}
+
+// containsByte b 是否在 list 中
+func containsByte(list []byte, b byte) bool {
+	for _, v := range list {
+		if v == b {
+			return true
+		}
+	}
+	return false
+}
+
+// serveHTTP 处理一个HTTP CONNECT连接，其他方法应答 405
+func (p *Proxy) serveHTTP(conn net.Conn) {
+	r := bufio.NewReader(conn)
+	req, err := http.ReadRequest(r)
+	if err != nil {
+		return
+	}
+	if req.Method != http.MethodConnect {
+		io.WriteString(conn, "HTTP/1.1 405 Method Not Allowed\r\nContent-Length: 0\r\n\r\n")
+		return
+	}
+	if p.username != "" && req.Header.Get("Proxy-Authorization") != basicAuth(p.username, p.password) {
+		io.WriteString(conn, "HTTP/1.1 407 Proxy Authentication Required\r\nProxy-Authenticate: Basic realm=\"testserver\"\r\nContent-Length: 0\r\n\r\n")
+		return
+	}
+	upstream, err := p.connect(req.Host)
+	if err != nil {
+		io.WriteString(conn, "HTTP/1.1 502 Bad Gateway\r\nContent-Length: 0\r\n\r\n")
+		return
+	}
+	io.WriteString(conn, "HTTP/1.1 200 Connection Established\r\n\r\n")
+	p.relay(struct {
+		io.Reader
+		io.Writer
+	}{r, conn}, upstream)
+}
+
+// basicAuth 返回 Basic 认证头的值
+func basicAuth(username, password string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+}
@@ -0,0 +1,381 @@
+// Package testserver 在同一进程内提供可编排的多协议DNS服务器和代理，供集成测试使用
+//
+// 一个 Server 同时监听 UDP、TCP、DoT、DoH 和 DoQ，证书由启动时生成的自签名CA签发；
+// 应答按问题（名称和类型）编排，可以指定记录、响应码、延迟、丢弃、截断和错误的消息ID，
+// 同一问题的多次应答依次取用，便于测试重试和故障转移。SOCKS5 和 HTTP CONNECT 代理见 StartSOCKS5、StartHTTPConnect
+package testserver
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/quic-go/quic-go"
+)
+
+// 协议名称，用于 Query.Protocol
+const (
+	UDP = "udp"
+	TCP = "tcp"
+	DoT = "dot"
+	DoH = "doh"
+	DoQ = "doq"
+)
+
+// DoHPath DoH 服务的路径
+const DoHPath = "/dns-query"
+
+// Reply 对一个问题的一次应答
+type Reply struct {
+	Answer     []dns.RR      // 应答记录
+	Authority  []dns.RR      // 授权部分的记录，例如委派的NS或否定应答的SOA
+	Additional []dns.RR      // 附加部分的记录，例如粘连记录
+	Rcode      int           // 响应码，零值为 NOERROR
+	Delay      time.Duration // 应答前的延迟
+	Drop       bool          // 不应答：UDP/TCP/DoT 不写回任何数据，DoH/DoQ 挂起请求直到客户端放弃
+	Truncate   bool          // 只对 UDP 生效：设置TC位并清空全部记录，流式协议照常应答
+	WrongID    bool          // 应答使用与查询不同的消息ID
+
+	// 头部标志位：默认设置AA和RA，不设置AD
+	NotAuthoritative  bool // 清除AA位
+	NoRecursion       bool // 清除RA位
+	AuthenticatedData bool // 设置AD位
+}
+
+// Query 服务器收到的一次查询
+type Query struct {
+	Protocol string   // UDP、TCP、DoT、DoH 或 DoQ
+	Method   string   // DoH 请求的HTTP方法，其他协议为空
+	Msg      *dns.Msg // 收到的查询消息
+	Remote   string   // 客户端地址（host:port）
+}
+
+// question 编排应答的键
+type question struct {
+	name  string
+	qtype uint16
+}
+
+// script 一个问题的应答序列
+type script struct {
+	replies []Reply
+	next    int
+}
+
+// Server 多协议DNS测试服务器，所有方法可并发调用
+type Server struct {
+	UDPAddr    string // UDP 监听地址（IP:端口）
+	TCPAddr    string // TCP 监听地址，与 UDPAddr 相同
+	DoTAddr    string // DoT 监听地址
+	DoHURL     string // DoH 服务地址，例如 https://127.0.0.1:12345/dns-query
+	DoQAddr    string // DoQ 监听地址（UDP）
+	ServerName string // DoT/DoH/DoQ 证书中的主机名，证书同时包含 127.0.0.1 和 ::1
+
+	ca      *x509.Certificate
+	servers []*dns.Server
+	http    *http.Server
+	doq     *quic.Listener
+	closed  chan struct{}
+
+	mu       sync.Mutex
+	scripts  map[question]*script
+	fallback Reply
+	queries  []Query
+}
+
+// Start 在 127.0.0.1 的随机端口上启动全部协议
+// 未编排的问题应答 NXDOMAIN，可用 SetDefault 修改
+func Start() (*Server, error) {
+	ca, cert, err := newCertificates()
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		ServerName: certHost,
+		ca:         ca,
+		closed:     make(chan struct{}),
+		scripts:    make(map[question]*script),
+		fallback:   Reply{Rcode: dns.RcodeNameError},
+	}
+	if err := s.listen(cert); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// listen 启动各协议的监听
+func (s *Server) listen(cert tls.Certificate) error {
+	tcp, pc, err := listenPair()
+	if err != nil {
+		return err
+	}
+	s.UDPAddr = pc.LocalAddr().String()
+	s.TCPAddr = tcp.Addr().String()
+	s.serveDNS(&dns.Server{PacketConn: pc, Handler: s.handler(UDP)})
+	s.serveDNS(&dns.Server{Listener: tcp, Handler: s.handler(TCP)})
+
+	tlsConfig := &tls.Config{Certificates: []tls.Certificate{cert}}
+	dot, err := tls.Listen("tcp", "127.0.0.1:0", tlsConfig)
+	if err != nil {
+		return err
+	}
+	s.DoTAddr = dot.Addr().String()
+	s.serveDNS(&dns.Server{Listener: dot, Net: "tcp-tls", Handler: s.handler(DoT)})
+
+	doh, err := tls.Listen("tcp", "127.0.0.1:0", tlsConfig)
+	if err != nil {
+		return err
+	}
+	s.DoHURL = "https://" + doh.Addr().String() + DoHPath
+	mux := http.NewServeMux()
+	mux.HandleFunc(DoHPath, s.serveDoH)
+	s.http = &http.Server{Handler: mux, ErrorLog: discardLog}
+	go s.http.Serve(doh)
+
+	return s.listenDoQ(cert)
+}
+
+// listenPair 在同一端口上监听 TCP 和 UDP，与真实的DNS服务器一样，
+// 经代理转发或截断后改用TCP的UDP查询会连接 UDPAddr 的TCP端口
+func listenPair() (net.Listener, net.PacketConn, error) {
+	var err error
+	for range 10 {
+		var tcp net.Listener
+		tcp, err = net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return nil, nil, err
+		}
+		var pc net.PacketConn
+		pc, err = net.ListenPacket("udp", tcp.Addr().String())
+		if err == nil {
+			return tcp, pc, nil
+		}
+		tcp.Close()
+	}
+	return nil, nil, err
+}
+
+// serveDNS 在后台运行 miekg/dns 服务器，返回时服务器已开始接受查询
+func (s *Server) serveDNS(srv *dns.Server) {
+	started := make(chan struct{})
+	srv.NotifyStartedFunc = func() { close(started) }
+	s.servers = append(s.servers, srv)
+	go srv.ActivateAndServe()
+	<-started
+}
+
+// Close 关闭全部监听，挂起中的请求随之返回
+func (s *Server) Close() error {
+	select {
+	case <-s.closed:
+		return nil
+	default:
+	}
+	close(s.closed)
+	var errs []error
+	for _, srv := range s.servers {
+		errs = append(errs, srv.Shutdown())
+	}
+	if s.http != nil {
+		errs = append(errs, s.http.Close())
+	}
+	if s.doq != nil {
+		errs = append(errs, s.doq.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// Handle 编排问题 name/qtype 的应答：replies 按收到查询的顺序依次使用，用完后重复最后一个
+// 名称不区分大小写，末尾的点可省略；再次调用会替换之前的编排并从头开始
+func (s *Server) Handle(name string, qtype uint16, replies ...Reply) {
+	if len(replies) == 0 {
+		replies = []Reply{{}}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scripts[question{canonical(name), qtype}] = &script{replies: replies}
+}
+
+// Answer 编排问题 name/qtype 始终应答给定的记录，records 为区域文件格式，例如 "example.com. 300 IN A 192.0.2.1"
+func (s *Server) Answer(name string, qtype uint16, records ...string) {
+	rrs := make([]dns.RR, len(records))
+	for i, record := range records {
+		rrs[i] = RR(record)
+	}
+	s.Handle(name, qtype, Reply{Answer: rrs})
+}
+
+// SetDefault 设置未编排问题的应答
+func (s *Server) SetDefault(reply Reply) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fallback = reply
+}
+
+// Queries 返回收到的全部查询，按到达顺序排列
+func (s *Server) Queries() []Query {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Query(nil), s.queries...)
+}
+
+// Reset 清空编排、默认应答和查询记录
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scripts = make(map[question]*script)
+	s.fallback = Reply{Rcode: dns.RcodeNameError}
+	s.queries = nil
+}
+
+// CA 返回签发服务器证书的自签名CA
+func (s *Server) CA() *x509.Certificate {
+	return s.ca
+}
+
+// CertPool 返回只包含该CA的证书池
+func (s *Server) CertPool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(s.ca)
+	return pool
+}
+
+// ClientTLSConfig 返回信任该CA的客户端TLS配置，ServerName 为证书中的主机名
+func (s *Server) ClientTLSConfig() *tls.Config {
+	return &tls.Config{RootCAs: s.CertPool(), ServerName: s.ServerName}
+}
+
+// RR 解析区域文件格式的记录，格式错误时 panic，只用于编写测试
+func RR(record string) dns.RR {
+	rr, err := dns.NewRR(record)
+	if err != nil {
+		panic("testserver: " + err.Error())
+	}
+	return rr
+}
+
+// reply 记录查询并按编排取得应答，延迟在此完成；返回 nil 表示丢弃
+func (s *Server) reply(protocol, method, remote string, req *dns.Msg) *dns.Msg {
+	s.mu.Lock()
+	s.queries = append(s.queries, Query{Protocol: protocol, Method: method, Msg: req.Copy(), Remote: remote})
+	r := s.fallback
+	if len(req.Question) > 0 {
+		q := req.Question[0]
+		if sc, ok := s.scripts[question{canonical(q.Name), q.Qtype}]; ok {
+			r = sc.replies[min(sc.next, len(sc.replies)-1)]
+			sc.next++
+		}
+	}
+	s.mu.Unlock()
+
+	if r.Delay > 0 {
+		timer := time.NewTimer(r.Delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-s.closed:
+			return nil
+		}
+	}
+	if r.Drop {
+		return nil
+	}
+
+	m := new(dns.Msg)
+	m.SetRcode(req, r.Rcode)
+	m.Authoritative = !r.NotAuthoritative
+	m.RecursionAvailable = !r.NoRecursion
+	m.AuthenticatedData = r.AuthenticatedData
+	if r.Truncate && protocol == UDP {
+		m.Truncated = true
+	} else {
+		m.Answer = copyRRs(r.Answer)
+		m.Ns = copyRRs(r.Authority)
+		m.Extra = copyRRs(r.Additional)
+	}
+	if r.WrongID {
+		m.Id = req.Id + 1
+	}
+	return m
+}
+
+// copyRRs 深拷贝记录，编排的记录可被多次应答复用
+func copyRRs(rrs []dns.RR) []dns.RR {
+	var out []dns.RR
+	for _, rr := range rrs {
+		out = append(out, dns.Copy(rr))
+	}
+	return out
+}
+
+// handler 返回 UDP/TCP/DoT 的处理函数
+func (s *Server) handler(protocol string) dns.Handler {
+	return dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		if m := s.reply(protocol, "", w.RemoteAddr().String(), req); m != nil {
+			w.WriteMsg(m)
+		}
+	})
+}
+
+// serveDoH 按 RFC 8484 处理 GET 和 POST 请求
+func (s *Server) serveDoH(w http.ResponseWriter, r *http.Request) {
+	var wire []byte
+	var err error
+	switch r.Method {
+	case http.MethodGet:
+		wire, err = base64.RawURLEncoding.DecodeString(r.URL.Query().Get("dns"))
+	case http.MethodPost:
+		if !strings.EqualFold(r.Header.Get("Content-Type"), "application/dns-message") {
+			http.Error(w, "unsupported content type", http.StatusUnsupportedMediaType)
+			return
+		}
+		wire, err = io.ReadAll(io.LimitReader(r.Body, dns.MaxMsgSize))
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	req := new(dns.Msg)
+	if err == nil {
+		err = req.Unpack(wire)
+	}
+	if err != nil {
+		http.Error(w, "malformed DNS message", http.StatusBadRequest)
+		return
+	}
+
+	m := s.reply(DoH, r.Method, r.RemoteAddr, req)
+	if m == nil {
+		waitDone(r.Context(), s.closed)
+		return
+	}
+	out, err := m.Pack()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/dns-message")
+	w.Write(out)
+}
+
+// waitDone 等待请求（或连接）结束或服务器关闭
+func waitDone(ctx context.Context, closed <-chan struct{}) {
+	select {
+	case <-ctx.Done():
+	case <-closed:
+	}
+}
+
+// canonical 返回小写、带末尾点的名称
+func canonical(name string) string {
+	return strings.ToLower(dns.Fqdn(name))
+}
@@ -0,0 +1,101 @@
+package godns
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+)
+
+const (
+	// defaultMaxAnswers 默认的单次应答记录数上限
+	defaultMaxAnswers = 4096
+	// defaultMaxResponseBytes 默认的应答大小上限，即TCP长度前缀可表示的最大值
+	defaultMaxResponseBytes = 65535
+)
+
+// ResponseTooLargeError 应答大小超过 WithMaxResponseBytes 设置的上限
+type ResponseTooLargeError struct {
+	Size  int // 服务器声明或实际的应答大小
+	Limit int // 配置的上限
+}
+
+func (e *ResponseTooLargeError) Error() string {
+	return fmt.Sprintf("dns response of %d bytes exceeds limit of %d bytes", e.Size, e.Limit)
+}
+
+// WithMaxAnswers 设置单次应答解析出的记录数上限，超出部分被丢弃并在结果中标记 TruncatedByClient
+func WithMaxAnswers(n int) Option {
+	return func(c *Config) {
+		c.MaxAnswers = n
+	}
+}
+
+// WithMaxResponseBytes 设置TCP/DoT/DoH应答的大小上限，超出时直接拒绝该应答
+// 并返回 *ResponseTooLargeError，避免读取异常巨大的应答
+func WithMaxResponseBytes(n int) Option {
+	return func(c *Config) {
+		c.MaxResponseBytes = n
+	}
+}
+
+// maxAnswers 返回本次查询的记录数上限，context 中的设置优先
+func (c *Client) maxAnswers(ctx context.Context) int {
+	if n, ok := maxAnswersFromContext(ctx); ok && n > 0 {
+		return n
+	}
+	return c.config.MaxAnswers
+}
+
+// limitConn 在TCP/DoT连接上按长度前缀检查每个DNS消息的大小，
+// 在读取消息体之前拒绝超过上限的消息
+type limitConn struct {
+	net.Conn
+	limit int
+
+	hdr       [2]byte
+	hdrRead   int
+	remaining int   // 当前消息体剩余的字节数
+	err       error // 超过上限后的后续读取均返回该错误
+}
+
+// newLimitConn 包装连接，limit 不大于 0 时不做限制
+func newLimitConn(conn net.Conn, limit int) net.Conn {
+	if limit <= 0 {
+		return conn
+	}
+	return &limitConn{Conn: conn, limit: limit}
+}
+
+func (l *limitConn) Read(b []byte) (int, error) {
+	if l.err != nil {
+		return 0, l.err
+	}
+	if l.remaining > 0 {
+		if len(b) > l.remaining {
+			b = b[:l.remaining]
+		}
+		n, err := l.Conn.Read(b)
+		l.remaining -= n
+		return n, err
+	}
+
+	// 读取长度前缀，每次不越过前缀边界
+	if len(b) > len(l.hdr)-l.hdrRead {
+		b = b[:len(l.hdr)-l.hdrRead]
+	}
+	n, err := l.Conn.Read(b)
+	copy(l.hdr[l.hdrRead:], b[:n])
+	l.hdrRead += n
+	if l.hdrRead == len(l.hdr) {
+		l.hdrRead = 0
+		size := int(binary.BigEndian.Uint16(l.hdr[:]))
+		if size > l.limit {
+			// io.ReadFull 读满前缀时会忽略同时返回的错误，因此在读取消息体时返回
+			l.err = &ResponseTooLargeError{Size: size, Limit: l.limit}
+			return n, nil
+		}
+		l.remaining = size
+	}
+	return n, err
+}
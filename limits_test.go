@@ -0,0 +1,101 @@
+package godns_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+	"github.com/zan8in/godns/internal/testserver"
+)
+
+// answerBig 编排 big.test 返回 n 条A记录
+func answerBig(s *testserver.Server, n int) {
+	records := make([]string, n)
+	for i := range records {
+		records[i] = fmt.Sprintf("big.test. 60 IN A 10.0.%d.%d", i/256, i%256)
+	}
+	s.Answer("big.test", dns.TypeA, records...)
+}
+
+// TestMaxResponseBytes 流式协议和DoH在读取之前拒绝超过上限的应答
+func TestMaxResponseBytes(t *testing.T) {
+	s := startServer(t)
+	answerBig(s, 200) // 约 3.2KB
+	tests := []struct {
+		protocol godns.Protocol
+		server   string
+	}{
+		{godns.TCP, s.TCPAddr},
+		{godns.DoT, s.DoTAddr},
+		{godns.DoH, s.DoHURL},
+		{godns.DoQ, s.DoQAddr},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.protocol), func(t *testing.T) {
+			for _, limit := range []int{512, 8192} {
+				c := godns.New(
+					godns.WithProtocol(tt.protocol),
+					godns.WithServers(tt.server),
+					godns.WithTLSConfig(s.ClientTLSConfig()),
+					godns.WithMaxResponseBytes(limit),
+					godns.WithRetries(0),
+				)
+				res, err := c.QueryA(context.Background(), "big.test")
+				c.Close()
+				if limit > 4096 {
+					if err != nil || len(res.Records) != 200 {
+						t.Errorf("limit %d: err = %v, records = %d", limit, err, len(res.Records))
+					}
+					continue
+				}
+				var tooLarge *godns.ResponseTooLargeError
+				if !errors.As(err, &tooLarge) {
+					t.Fatalf("limit %d: err = %v, want *ResponseTooLargeError", limit, err)
+				}
+				if tooLarge.Limit != limit || tooLarge.Size <= limit {
+					t.Errorf("error = %+v", tooLarge)
+				}
+			}
+		})
+	}
+}
+
+// TestMaxAnswers 超过记录数上限的应答被截断并标记 TruncatedByClient，context 中的上限优先
+func TestMaxAnswers(t *testing.T) {
+	s := startServer(t)
+	answerBig(s, 50)
+	c := godns.New(godns.WithProtocol(godns.TCP), godns.WithServers(s.TCPAddr), godns.WithMaxAnswers(10))
+	defer c.Close()
+
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		records   int
+		truncated bool
+	}{
+		{"client-limit", context.Background(), 10, true},
+		{"query-limit", godns.WithQueryMaxAnswers(context.Background(), 5), 5, true},
+		{"query-limit-above-answer", godns.WithQueryMaxAnswers(context.Background(), 100), 50, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := c.QueryA(tt.ctx, "big.test")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(res.Records) != tt.records || res.TruncatedByClient != tt.truncated {
+				t.Errorf("records = %d, TruncatedByClient = %v, want %d and %v", len(res.Records), res.TruncatedByClient, tt.records, tt.truncated)
+			}
+			warned := false
+			for _, w := range res.Warnings {
+				warned = warned || w.Code == godns.WarnTruncatedByClient
+			}
+			if warned != tt.truncated {
+				t.Errorf("WarnTruncatedByClient = %v, want %v", warned, tt.truncated)
+			}
+		})
+	}
+}
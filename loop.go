@@ -0,0 +1,38 @@
+package godns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrResolutionLoop 客户端在自身的传输层中（连接DoH端点、代理等）又需要通过自己解析主机名，
+// 通常是把客户端安装为 HTTPClient 或 Transport 的拨号器/解析器造成的，继续解析会无限递归或死锁
+// 基础设施主机名应使用IP地址，或交给另一个客户端解析
+// 检测只覆盖客户端自身的查询流程；本模块不包含转发处理器，也就没有转发到自身监听地址的检查
+var ErrResolutionLoop = errors.New("godns: resolution loop")
+
+// loopGuard 标识一个客户端及其路由、专用代理子客户端，子客户端的传输层回到父客户端同样构成循环
+type loopGuard struct {
+	name string // 根客户端名称，用于错误信息
+}
+
+// infraKey 标记 context 处于某个客户端的传输层中，值为该客户端的 *loopGuard
+type infraKey struct{}
+
+// withInfrastructure 返回标记为基础设施解析的 context，传输层的拨号和解析钩子会收到该 context
+func (c *Client) withInfrastructure(ctx context.Context) context.Context {
+	if ctx.Value(infraKey{}) == c.guard {
+		return ctx
+	}
+	return context.WithValue(ctx, infraKey{}, c.guard)
+}
+
+// checkLoop 查询是否发生在同一客户端的传输层中，是则返回 ErrResolutionLoop
+// IP地址不经过查询流程，不受影响
+func (c *Client) checkLoop(ctx context.Context, domain string) error {
+	if ctx.Value(infraKey{}) != c.guard {
+		return nil
+	}
+	return fmt.Errorf("%w: %s would be resolved through client %s's own transport", ErrResolutionLoop, domain, c.guard.name)
+}
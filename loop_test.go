@@ -0,0 +1,126 @@
+package godns_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+)
+
+// transportFunc 以函数实现 Transport
+type transportFunc func(ctx context.Context, msg *dns.Msg, server string) (*dns.Msg, error)
+
+func (f transportFunc) Exchange(ctx context.Context, msg *dns.Msg, server string) (*dns.Msg, error) {
+	return f(ctx, msg, server)
+}
+
+// withinWatchdog 在看门狗超时内运行 fn，超时说明发生了递归或死锁
+func withinWatchdog(t *testing.T, fn func() error) error {
+	t.Helper()
+	done := make(chan error, 1)
+	go func() { done <- fn() }()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("query did not return: resolution loop was not detected")
+		return nil
+	}
+}
+
+func TestResolutionLoop(t *testing.T) {
+	tests := []struct {
+		name   string
+		client func(self **godns.Client) *godns.Client
+	}{
+		{"doh-dialer", func(self **godns.Client) *godns.Client {
+			// DoH 端点的主机名通过客户端自身的 DialContext 解析
+			hc := &http.Client{Transport: &http.Transport{
+				DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+					return (*self).DialContext(ctx, network, addr)
+				},
+			}}
+			return godns.New(
+				godns.WithProtocol(godns.DoH),
+				godns.WithServers("https://doh.invalid/dns-query"),
+				godns.WithHTTPClient(hc),
+				godns.WithRetries(0),
+			)
+		}},
+		{"transport", func(self **godns.Client) *godns.Client {
+			return godns.New(
+				godns.WithServers("resolver.invalid:53"),
+				godns.WithTransport(transportFunc(func(ctx context.Context, msg *dns.Msg, server string) (*dns.Msg, error) {
+					host, _, _ := net.SplitHostPort(server)
+					_, err := (*self).QueryA(ctx, host)
+					return nil, err
+				})),
+				godns.WithRetries(0),
+			)
+		}},
+		{"route-to-parent", func(self **godns.Client) *godns.Client {
+			// 路由子客户端的传输层回到父客户端同样构成循环
+			return godns.New(
+				godns.WithServers("192.0.2.53:53"),
+				godns.WithRouting(godns.RouteRule{Name: "corp", Suffix: "corp.example", Servers: []string{"dns.corp.example:53"}}),
+				godns.WithTransport(transportFunc(func(ctx context.Context, msg *dns.Msg, server string) (*dns.Msg, error) {
+					host, _, _ := net.SplitHostPort(server)
+					_, err := (*self).QueryA(ctx, host)
+					return nil, err
+				})),
+				godns.WithRetries(0),
+			)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c *godns.Client
+			c = tt.client(&c)
+			defer c.Close()
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			defer cancel()
+			err := withinWatchdog(t, func() error {
+				_, err := c.QueryA(ctx, "www.corp.example")
+				return err
+			})
+			if !errors.Is(err, godns.ErrResolutionLoop) {
+				t.Fatalf("err = %v, want ErrResolutionLoop", err)
+			}
+		})
+	}
+}
+
+// TestResolutionLoopOtherClient 基础设施主机名交给另一个客户端解析不构成循环
+func TestResolutionLoopOtherClient(t *testing.T) {
+	s := startServer(t)
+	s.Answer("resolver.test", dns.TypeA, "resolver.test. 60 IN A 127.0.0.1")
+	bootstrap := godns.New(godns.WithServers(s.UDPAddr), godns.WithRetries(0))
+	defer bootstrap.Close()
+
+	c := godns.New(
+		godns.WithServers("resolver.test:53"),
+		godns.WithTransport(transportFunc(func(ctx context.Context, msg *dns.Msg, server string) (*dns.Msg, error) {
+			host, _, _ := net.SplitHostPort(server)
+			if _, err := bootstrap.QueryA(ctx, host); err != nil {
+				return nil, err
+			}
+			reply := new(dns.Msg)
+			reply.SetReply(msg)
+			return reply, nil
+		})),
+		godns.WithRetries(0),
+	)
+	defer c.Close()
+	err := withinWatchdog(t, func() error {
+		_, err := c.QueryA(context.Background(), "www.example.com")
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,163 @@
+package godns
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+// MailHost 投递邮件时可连接的主机
+type MailHost struct {
+	Host       string       // MX记录中的交换主机名（不带末尾的点）
+	Preference uint16       // MX优先级，越小越优先
+	IPs        []netip.Addr // 交换主机的 A/AAAA 地址，IPv4 在前
+	Implicit   bool         // 域名没有MX记录，按 RFC 5321 §5.1 以域名自身作为隐式MX
+	CNAME      bool         // 交换主机名是CNAME（违反 RFC 2181 §10.3），已跟随至 Target 解析
+	Target     string       // 跟随CNAME后的主机名，不是CNAME时与 Host 相同
+	Error      *ErrorInfo   // 地址解析失败时的错误信息
+}
+
+// MailRoute ResolveMX 的结果
+type MailRoute struct {
+	Domain string
+	NullMX bool       // 域名发布了 RFC 7505 的 null MX（唯一一条目标为 "." 的MX），明确不接收邮件
+	Hosts  []MailHost // 按优先级排序，相同优先级按主机名排序；RFC 5321 建议调用方在相同优先级内随机选择
+
+	Warnings []Warning // Record 为相关主机在 Hosts 中的下标
+}
+
+// ResolveMX 回答"投递 domain 的邮件应连接到哪里"：查询MX并按优先级排序，并发解析各交换主机的 A/AAAA，
+// 识别 null MX（RFC 7505），没有MX记录时回退到以域名自身为目标的隐式MX（RFC 5321 §5.1）
+// 目标为CNAME的交换主机会被跟随并在 MailHost.CNAME 中标记；单个主机解析失败不影响其他主机，
+// 错误记录在 MailHost.Error 中；仅当MX查询本身失败（包括 domain 不存在，返回包装 ErrNXDomain 的错误）时返回错误
+func (c *Client) ResolveMX(ctx context.Context, domain string) (*MailRoute, error) {
+	domain = strings.TrimSuffix(domain, ".")
+	res, err := c.Query(ctx, domain, dns.TypeMX)
+	if err != nil {
+		return nil, err
+	}
+
+	route := &MailRoute{Domain: domain}
+	var hosts []MailHost
+	for _, record := range ownedBy(res, domain, dns.TypeMX) {
+		if mx, ok := record.RR().(*dns.MX); ok {
+			hosts = append(hosts, MailHost{Host: strings.TrimSuffix(mx.Mx, "."), Preference: mx.Preference})
+		}
+	}
+	switch {
+	case len(hosts) == 1 && hosts[0].Host == "":
+		route.NullMX = true
+		return route, nil
+	case len(hosts) == 0:
+		hosts = []MailHost{{Host: domain, Implicit: true}}
+	}
+	// null MX 与其他MX混合发布属于错误配置，忽略目标为 "." 的记录
+	hosts = dropNullMX(hosts)
+	sort.SliceStable(hosts, func(i, j int) bool {
+		if hosts[i].Preference != hosts[j].Preference {
+			return hosts[i].Preference < hosts[j].Preference
+		}
+		return CanonicalName(hosts[i].Host) < CanonicalName(hosts[j].Host)
+	})
+
+	var wg sync.WaitGroup
+	for i := range hosts {
+		wg.Add(1)
+		go func(host *MailHost) {
+			defer wg.Done()
+			c.resolveMailHost(ctx, host)
+		}(&hosts[i])
+	}
+	wg.Wait()
+
+	route.Hosts = hosts
+	for i, host := range hosts {
+		if host.CNAME {
+			route.Warnings = append(route.Warnings, Warning{
+				Code:    WarnMXTargetCNAME,
+				Message: fmt.Sprintf("MX target %s is a CNAME to %s", host.Host, host.Target),
+				Record:  i,
+			})
+		}
+	}
+	return route, ctx.Err()
+}
+
+// dropNullMX 去掉目标为 "." 的MX
+func dropNullMX(hosts []MailHost) []MailHost {
+	kept := hosts[:0]
+	for _, host := range hosts {
+		if host.Host != "" {
+			kept = append(kept, host)
+		}
+	}
+	return kept
+}
+
+// resolveMailHost 解析交换主机的地址
+func (c *Client) resolveMailHost(ctx context.Context, host *MailHost) {
+	var err error
+	host.IPs, host.Target, err = c.resolveHost(ctx, host.Host)
+	host.CNAME = !equalNames(host.Target, host.Host)
+	if err != nil {
+		host.Error = c.newErrorInfo(err, "", 0)
+	}
+}
+
+// resolveHost 并发查询主机的 A/AAAA 记录，返回地址（IPv4 在前）和跟随应答中CNAME链后的主机名
+// 仅当两种查询都失败时返回错误
+func (c *Client) resolveHost(ctx context.Context, host string) ([]netip.Addr, string, error) {
+	qtypes := []uint16{dns.TypeA, dns.TypeAAAA}
+	results := make([]*QueryResult, len(qtypes))
+	errs := make([]error, len(qtypes))
+
+	var wg sync.WaitGroup
+	for i, qtype := range qtypes {
+		wg.Add(1)
+		go func(i int, qtype uint16) {
+			defer wg.Done()
+			results[i], errs[i] = c.Query(ctx, host, qtype)
+		}(i, qtype)
+	}
+	wg.Wait()
+
+	var addrs []netip.Addr
+	target := host
+	for i, res := range results {
+		// 名称不存在时仍可从应答中得到CNAME链的末端
+		if res == nil || ignoreNXDomain(errs[i]) != nil {
+			continue
+		}
+		if t := cnameTarget(host, res.Records); !equalNames(t, host) {
+			target = strings.TrimSuffix(t, ".")
+		}
+		addrs = append(addrs, res.IPAddrs()...)
+	}
+	if errs[0] != nil && errs[1] != nil {
+		return addrs, target, errs[0]
+	}
+	return addrs, target, nil
+}
+
+// cnameTarget 沿记录中的CNAME链从 name 出发，返回链末端的名称
+func cnameTarget(name string, records []Record) string {
+	for hops := 0; hops < len(records); hops++ {
+		next := ""
+		for _, record := range records {
+			if record.Type == dns.TypeCNAME && equalNames(record.Name, name) {
+				next = record.Value()
+				break
+			}
+		}
+		if next == "" {
+			break
+		}
+		name = next
+	}
+	return name
+}
@@ -0,0 +1,151 @@
+package godns_test
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"slices"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+	"github.com/zan8in/godns/internal/testserver"
+)
+
+// TestResolveMXOrdering 交换主机按优先级排序，相同优先级按主机名排序，各主机的地址 IPv4 在前；
+// 目标为CNAME的主机被跟随并产生警告，单个主机解析失败只记录在该主机上
+func TestResolveMXOrdering(t *testing.T) {
+	s := startServer(t)
+	s.Answer("example.test", dns.TypeMX,
+		"example.test. 300 IN MX 20 backup.example.test.",
+		"example.test. 300 IN MX 10 mx2.example.test.",
+		"example.test. 300 IN MX 10 MX1.example.test.",
+		"example.test. 300 IN MX 30 alias.example.test.",
+		"example.test. 300 IN MX 40 broken.example.test.",
+	)
+	s.Answer("mx1.example.test", dns.TypeA, "mx1.example.test. 300 IN A 192.0.2.1")
+	s.Answer("mx1.example.test", dns.TypeAAAA, "mx1.example.test. 300 IN AAAA 2001:db8::1")
+	s.Answer("mx2.example.test", dns.TypeA, "mx2.example.test. 300 IN A 192.0.2.2")
+	s.Answer("backup.example.test", dns.TypeAAAA, "backup.example.test. 300 IN AAAA 2001:db8::3")
+	s.Answer("alias.example.test", dns.TypeA,
+		"alias.example.test. 300 IN CNAME real.example.test.",
+		"real.example.test. 300 IN A 192.0.2.4",
+	)
+	s.Answer("alias.example.test", dns.TypeAAAA, "alias.example.test. 300 IN CNAME real.example.test.")
+	s.Handle("broken.example.test", dns.TypeA, testserver.Reply{Rcode: dns.RcodeServerFailure})
+	s.Handle("broken.example.test", dns.TypeAAAA, testserver.Reply{Rcode: dns.RcodeServerFailure})
+	c := godns.New(godns.WithServers(s.UDPAddr), godns.WithRetries(0))
+	defer c.Close()
+
+	route, err := c.ResolveMX(context.Background(), "example.test.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if route.Domain != "example.test" || route.NullMX {
+		t.Errorf("route = %+v", route)
+	}
+	type want struct {
+		host   string
+		pref   uint16
+		ips    []string
+		target string
+	}
+	wants := []want{
+		{"MX1.example.test", 10, []string{"192.0.2.1", "2001:db8::1"}, "MX1.example.test"},
+		{"mx2.example.test", 10, []string{"192.0.2.2"}, "mx2.example.test"},
+		{"backup.example.test", 20, []string{"2001:db8::3"}, "backup.example.test"},
+		{"alias.example.test", 30, []string{"192.0.2.4"}, "real.example.test"},
+		{"broken.example.test", 40, nil, "broken.example.test"},
+	}
+	if len(route.Hosts) != len(wants) {
+		t.Fatalf("got %d hosts, want %d: %+v", len(route.Hosts), len(wants), route.Hosts)
+	}
+	for i, w := range wants {
+		h := route.Hosts[i]
+		var ips []string
+		for _, ip := range h.IPs {
+			ips = append(ips, ip.String())
+		}
+		if h.Host != w.host || h.Preference != w.pref || !slices.Equal(ips, w.ips) || h.Target != w.target || h.Implicit {
+			t.Errorf("host %d = %+v, want %s pref %d ips %v target %s", i, h, w.host, w.pref, w.ips, w.target)
+		}
+		if h.CNAME != (w.host == "alias.example.test") {
+			t.Errorf("%s: CNAME = %v", h.Host, h.CNAME)
+		}
+		if wantErr := w.host == "broken.example.test"; (h.Error != nil) != wantErr {
+			t.Errorf("%s: error = %v, want error %v", h.Host, h.Error, wantErr)
+		}
+	}
+	if h := route.Hosts[4]; h.Error != nil && h.Error.Kind != godns.KindServFail {
+		t.Errorf("broken host error kind = %s, want %s", h.Error.Kind, godns.KindServFail)
+	}
+	if len(route.Warnings) != 1 || route.Warnings[0].Code != godns.WarnMXTargetCNAME || route.Warnings[0].Record != 3 {
+		t.Errorf("warnings = %+v, want one %s for host 3", route.Warnings, godns.WarnMXTargetCNAME)
+	}
+}
+
+// TestResolveMXNullMX 唯一一条目标为 "." 的MX表示不接收邮件，不解析任何地址；
+// 与其他MX混合发布时忽略目标为 "." 的记录
+func TestResolveMXNullMX(t *testing.T) {
+	s := startServer(t)
+	s.Answer("nomail.test", dns.TypeMX, "nomail.test. 300 IN MX 0 .")
+	s.Answer("mixed.test", dns.TypeMX, "mixed.test. 300 IN MX 0 .", "mixed.test. 300 IN MX 10 mx.mixed.test.")
+	s.Answer("mx.mixed.test", dns.TypeA, "mx.mixed.test. 300 IN A 192.0.2.1")
+	c := godns.New(godns.WithServers(s.UDPAddr), godns.WithRetries(0))
+	defer c.Close()
+
+	route, err := c.ResolveMX(context.Background(), "nomail.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !route.NullMX || len(route.Hosts) != 0 {
+		t.Errorf("route = %+v, want null MX without hosts", route)
+	}
+	if n := len(s.Queries()); n != 1 {
+		t.Errorf("server saw %d queries, want only the MX query", n)
+	}
+
+	route, err = c.ResolveMX(context.Background(), "mixed.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if route.NullMX || len(route.Hosts) != 1 || route.Hosts[0].Host != "mx.mixed.test" {
+		t.Errorf("route = %+v, want only mx.mixed.test", route)
+	}
+}
+
+// TestResolveMXImplicit 没有MX记录时以域名自身作为隐式MX
+func TestResolveMXImplicit(t *testing.T) {
+	s := startServer(t)
+	s.Handle("plain.test", dns.TypeMX, testserver.Reply{})
+	s.Answer("plain.test", dns.TypeA, "plain.test. 300 IN A 192.0.2.9")
+	c := godns.New(godns.WithServers(s.UDPAddr), godns.WithRetries(0))
+	defer c.Close()
+
+	route, err := c.ResolveMX(context.Background(), "plain.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := netip.MustParseAddr("192.0.2.9")
+	if len(route.Hosts) != 1 {
+		t.Fatalf("hosts = %+v, want the implicit MX", route.Hosts)
+	}
+	if h := route.Hosts[0]; !h.Implicit || h.Host != "plain.test" || h.Preference != 0 || !slices.Equal(h.IPs, []netip.Addr{want}) {
+		t.Errorf("host = %+v, want implicit plain.test with %s", h, want)
+	}
+}
+
+// TestResolveMXQueryFails MX查询本身失败（包括域名不存在）时返回错误
+func TestResolveMXQueryFails(t *testing.T) {
+	s := startServer(t)
+	s.Handle("sick.test", dns.TypeMX, testserver.Reply{Rcode: dns.RcodeServerFailure})
+	c := godns.New(godns.WithServers(s.UDPAddr), godns.WithRetries(0))
+	defer c.Close()
+
+	if _, err := c.ResolveMX(context.Background(), "missing.test"); !errors.Is(err, godns.ErrNXDomain) {
+		t.Errorf("missing domain: err = %v, want ErrNXDomain", err)
+	}
+	if _, err := c.ResolveMX(context.Background(), "sick.test"); !errors.Is(err, godns.ErrServFail) {
+		t.Errorf("sick domain: err = %v, want ErrServFail", err)
+	}
+}
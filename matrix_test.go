@@ -0,0 +1,183 @@
+package godns_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+	"github.com/zan8in/godns/internal/testserver"
+)
+
+// matrixProxy 矩阵中的代理维度
+type matrixProxy struct {
+	name  string
+	start func() (*testserver.Proxy, error)
+	opt   func(addr string) godns.Option
+}
+
+var matrixProxies = []matrixProxy{
+	{name: "direct"},
+	{
+		name:  "socks5",
+		start: func() (*testserver.Proxy, error) { return testserver.StartSOCKS5("user", "pass") },
+		opt: func(addr string) godns.Option {
+			return godns.WithSOCKS5Proxy(addr, &godns.ProxyAuth{Username: "user", Password: "pass"})
+		},
+	},
+	{
+		name:  "http",
+		start: func() (*testserver.Proxy, error) { return testserver.StartHTTPConnect("user", "pass") },
+		opt: func(addr string) godns.Option {
+			return godns.WithHTTPProxy(addr, &godns.ProxyAuth{Username: "user", Password: "pass"})
+		},
+	},
+}
+
+// matrixSupported 协议与代理的组合是否受支持：HTTP CONNECT 只能承载 DoH，DoQ 不能经代理
+func matrixSupported(protocol godns.Protocol, proxy string) bool {
+	switch proxy {
+	case "http":
+		return protocol == godns.DoH
+	case "socks5":
+		return protocol != godns.DoQ
+	}
+	return true
+}
+
+// matrixServer 返回协议对应的服务器地址和代理应连接的目标
+func matrixServer(s *testserver.Server, protocol godns.Protocol) (server, target string) {
+	switch protocol {
+	case godns.UDP, godns.TCP:
+		return s.UDPAddr, s.UDPAddr
+	case godns.DoT:
+		return s.DoTAddr, s.DoTAddr
+	case godns.DoH:
+		return s.DoHURL, s.DoHURL[len("https://") : len(s.DoHURL)-len(testserver.DoHPath)]
+	default:
+		return s.DoQAddr, s.DoQAddr
+	}
+}
+
+// matrixScenario 矩阵中的重试与校验维度
+type matrixScenario struct {
+	name     string
+	replies  []testserver.Reply
+	attempts int   // 期望的尝试次数
+	rcode    int   // 期望的响应码
+	err      error // 期望的错误类别，nil 表示成功
+}
+
+func matrixScenarios() []matrixScenario {
+	answer := testserver.Reply{Answer: []dns.RR{testserver.RR("matrix.test. 300 IN A 192.0.2.1")}}
+	return []matrixScenario{
+		{name: "answer", replies: []testserver.Reply{answer}, attempts: 1},
+		{name: "retry-after-drop", replies: []testserver.Reply{{Drop: true}, answer}, attempts: 2},
+		{name: "retry-after-wrong-id", replies: []testserver.Reply{{WrongID: true, Answer: answer.Answer}, answer}, attempts: 2},
+		{name: "truncated", replies: []testserver.Reply{{Truncate: true, Answer: answer.Answer}}, attempts: 1},
+		{name: "servfail", replies: []testserver.Reply{{Rcode: dns.RcodeServerFailure}}, attempts: 1,
+			rcode: dns.RcodeServerFailure, err: godns.ErrServFail},
+	}
+}
+
+// TestSupportMatrix 对每种受支持的 协议×代理×场景 组合发起一次查询，校验结果和元数据
+func TestSupportMatrix(t *testing.T) {
+	s, err := testserver.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	protocols := []godns.Protocol{godns.UDP, godns.TCP, godns.DoT, godns.DoH, godns.DoQ}
+	for _, protocol := range protocols {
+		for _, px := range matrixProxies {
+			if !matrixSupported(protocol, px.name) {
+				continue
+			}
+			for _, sc := range matrixScenarios() {
+				name := fmt.Sprintf("%s/%s/%s", protocol, px.name, sc.name)
+				t.Run(name, func(t *testing.T) {
+					runMatrixCase(t, s, protocol, px, sc)
+				})
+			}
+		}
+	}
+}
+
+func runMatrixCase(t *testing.T, s *testserver.Server, protocol godns.Protocol, px matrixProxy, sc matrixScenario) {
+	s.Reset()
+	s.Handle("matrix.test", dns.TypeA, sc.replies...)
+	server, target := matrixServer(s, protocol)
+
+	opts := []godns.Option{
+		godns.WithProtocol(protocol),
+		godns.WithServers(server),
+		godns.WithTLSConfig(s.ClientTLSConfig()),
+		godns.WithTimeout(500 * time.Millisecond),
+		godns.WithRetries(1),
+	}
+	var proxy *testserver.Proxy
+	if px.start != nil {
+		var err error
+		if proxy, err = px.start(); err != nil {
+			t.Fatal(err)
+		}
+		defer proxy.Close()
+		opts = append(opts, px.opt(proxy.Addr))
+	}
+	c := godns.New(opts...)
+	defer c.Close()
+
+	res, err := c.QueryA(context.Background(), "matrix.test")
+	if sc.err != nil {
+		if !errors.Is(err, sc.err) {
+			t.Fatalf("err = %v, want %v", err, sc.err)
+		}
+	} else if err != nil {
+		t.Fatalf("query failed: %v\n%s", err, res.Path)
+	}
+	if res.Rcode != sc.rcode {
+		t.Errorf("Rcode = %s, want %s", res.RcodeString(), dns.RcodeToString[sc.rcode])
+	}
+	// 只有直连的UDP会收到截断应答（SOCKS5 下UDP查询经TCP转发），普通查询不自动改用TCP，由调用方根据 Truncated 决定
+	truncated := sc.name == "truncated" && protocol == godns.UDP && proxy == nil
+	if res.Truncated != truncated {
+		t.Errorf("Truncated = %v, want %v", res.Truncated, truncated)
+	}
+	if sc.err == nil && !truncated {
+		if len(res.Records) != 1 || res.Records[0].Value() != "192.0.2.1" {
+			t.Errorf("records = %v", res.Records)
+		}
+		if !res.Authoritative || !res.RecursionAvailable {
+			t.Errorf("header flags AA=%v RA=%v, want both set", res.Authoritative, res.RecursionAvailable)
+		}
+		if res.Path.Source != godns.SourceNetwork {
+			t.Errorf("source = %q", res.Path.Source)
+		}
+	}
+	if n := len(res.Path.Attempts); n != sc.attempts {
+		t.Errorf("attempts = %d, want %d\n%s", n, sc.attempts, res.Path)
+	}
+	last := res.Path.Attempts[len(res.Path.Attempts)-1]
+	if last.Protocol != protocol {
+		t.Errorf("attempt protocol = %s, want %s", last.Protocol, protocol)
+	}
+
+	if len(s.Queries()) == 0 {
+		t.Fatal("server saw no queries")
+	}
+	if proxy != nil {
+		if !slices.Contains(proxy.Targets(), target) {
+			t.Errorf("proxy targets = %v, want %s", proxy.Targets(), target)
+		}
+		if last.Proxy == godns.NoProxy {
+			t.Error("attempt does not record the proxy")
+		}
+	} else if res.Error == nil && last.RespondedBy == "" {
+		t.Error("RespondedBy is empty for a direct query")
+	}
+}
@@ -0,0 +1,95 @@
+package godns
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// MXRecord MX记录的各字段
+type MXRecord struct {
+	Preference uint16 // 优先级，越小越优先
+	Host       string // 交换主机名（不带末尾的点），null MX（RFC 7505）的目标 "." 为空字符串
+}
+
+// String 返回 "优先级 主机名"，主机名带末尾的点，与MX记录的 Record.Value 相同
+func (m *MXRecord) String() string {
+	return fmt.Sprintf("%d %s", m.Preference, dns.Fqdn(m.Host))
+}
+
+// MX 返回MX记录的各字段，记录不是MX类型时返回 false
+// 反序列化得到的 Record 从 Value 中解析
+func (r Record) MX() (*MXRecord, bool) {
+	if mx, ok := r.rr.(*dns.MX); ok {
+		return &MXRecord{Preference: mx.Preference, Host: strings.TrimSuffix(mx.Mx, ".")}, true
+	}
+	if r.Type != dns.TypeMX {
+		return nil, false
+	}
+	m := &MXRecord{}
+	if _, err := fmt.Sscan(r.Value(), &m.Preference, &m.Host); err != nil {
+		return nil, false
+	}
+	m.Host = strings.TrimSuffix(m.Host, ".")
+	return m, true
+}
+
+// MXRecords 返回结果中的MX记录，按优先级升序、相同优先级按主机名排列
+func (r *QueryResult) MXRecords() []MXRecord {
+	var records []MXRecord
+	for _, record := range r.Records {
+		if mx, ok := record.MX(); ok {
+			records = append(records, *mx)
+		}
+	}
+	sortMX(records)
+	return records
+}
+
+// MXRecords 汇总各服务器成功应答中的MX记录，优先级和主机名（不区分大小写）相同的记录只保留一条，
+// 排序规则同 QueryResult.MXRecords
+func (r *MultiQueryResult) MXRecords() []MXRecord {
+	var records []MXRecord
+	seen := make(map[MXRecord]bool)
+	for i := range r.Results {
+		if r.Results[i].Error != nil {
+			continue
+		}
+		for _, mx := range r.Results[i].MXRecords() {
+			key := MXRecord{Preference: mx.Preference, Host: CanonicalName(mx.Host)}
+			if !seen[key] {
+				seen[key] = true
+				records = append(records, mx)
+			}
+		}
+	}
+	sortMX(records)
+	return records
+}
+
+// sortMX 按优先级升序排序，相同优先级按规范化的主机名排序，使不同服务器的应答顺序一致
+func sortMX(records []MXRecord) {
+	sort.SliceStable(records, func(i, j int) bool {
+		if records[i].Preference != records[j].Preference {
+			return records[i].Preference < records[j].Preference
+		}
+		return CanonicalName(records[i].Host) < CanonicalName(records[j].Host)
+	})
+}
+
+// LookupMX 查询MX记录并按优先级升序返回，与 net.LookupMX 的顺序规则相同但相同优先级内不随机排列
+// 没有MX记录时返回空切片而不是错误，名称不存在时返回包装 ErrNXDomain 的错误；需要地址解析、null MX 和隐式MX处理时使用 ResolveMX
+func (c *Client) LookupMX(ctx context.Context, domain string) ([]MXRecord, error) {
+	res, err := c.QueryMX(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+	records := res.MXRecords()
+	if records == nil {
+		records = []MXRecord{}
+	}
+	return records, nil
+}
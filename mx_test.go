@@ -0,0 +1,116 @@
+package godns_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+	"github.com/zan8in/godns/internal/testserver"
+)
+
+// TestLookupMX 按优先级升序、相同优先级按主机名排列，主机名不带末尾的点；
+// 没有MX记录时返回空切片，名称不存在时返回 ErrNXDomain
+func TestLookupMX(t *testing.T) {
+	s := startServer(t)
+	s.Answer("example.test", dns.TypeMX,
+		"example.test. 300 IN MX 20 mx3.example.test.",
+		"example.test. 300 IN MX 10 mx2.example.test.",
+		"example.test. 300 IN MX 10 MX1.example.test.",
+	)
+	s.Handle("nomx.test", dns.TypeMX, testserver.Reply{})
+	c := godns.New(godns.WithServers(s.UDPAddr), godns.WithRetries(0))
+	defer c.Close()
+	ctx := context.Background()
+
+	got, err := c.LookupMX(ctx, "example.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []godns.MXRecord{
+		{Preference: 10, Host: "MX1.example.test"},
+		{Preference: 10, Host: "mx2.example.test"},
+		{Preference: 20, Host: "mx3.example.test"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("LookupMX = %+v, want %+v", got, want)
+	}
+	if s := want[0].String(); s != "10 MX1.example.test." {
+		t.Errorf("String = %q", s)
+	}
+
+	got, err = c.LookupMX(ctx, "nomx.test")
+	if err != nil || got == nil || len(got) != 0 {
+		t.Errorf("LookupMX without MX records = %v, %v; want an empty slice", got, err)
+	}
+	if _, err := c.LookupMX(ctx, "missing.test"); !errors.Is(err, godns.ErrNXDomain) {
+		t.Errorf("err = %v, want ErrNXDomain", err)
+	}
+}
+
+// TestMXRecordFromValue 反序列化得到的记录从 Value 中解析，null MX 的主机名为空字符串
+func TestMXRecordFromValue(t *testing.T) {
+	s := startServer(t)
+	s.Answer("example.test", dns.TypeMX, "example.test. 300 IN MX 10 mx.example.test.")
+	s.Answer("null.test", dns.TypeMX, "null.test. 300 IN MX 0 .")
+	c := godns.New(godns.WithServers(s.UDPAddr), godns.WithRetries(0))
+	defer c.Close()
+
+	for name, want := range map[string]godns.MXRecord{
+		"example.test": {Preference: 10, Host: "mx.example.test"},
+		"null.test":    {Preference: 0, Host: ""},
+	} {
+		res, err := c.QueryMX(context.Background(), name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := json.Marshal(res.Records[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded godns.Record
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		for _, record := range []godns.Record{res.Records[0], decoded} {
+			if got, ok := record.MX(); !ok || *got != want {
+				t.Errorf("%s: MX = %+v, %v; want %+v", name, got, ok, want)
+			}
+		}
+	}
+}
+
+// TestMultiQueryMXRecords 汇总各服务器的MX记录，主机名不区分大小写去重，失败的服务器不参与汇总
+func TestMultiQueryMXRecords(t *testing.T) {
+	a, b, failing := startServer(t), startServer(t), startServer(t)
+	a.Answer("example.test", dns.TypeMX,
+		"example.test. 300 IN MX 10 mx1.example.test.",
+		"example.test. 300 IN MX 20 mx2.example.test.",
+	)
+	b.Answer("example.test", dns.TypeMX,
+		"example.test. 300 IN MX 10 MX1.EXAMPLE.TEST.",
+		"example.test. 300 IN MX 5 mx0.example.test.",
+	)
+	failing.Handle("example.test", dns.TypeMX, testserver.Reply{Rcode: dns.RcodeServerFailure})
+	c := godns.New(godns.WithServers(a.UDPAddr, b.UDPAddr, failing.UDPAddr), godns.WithRetries(0))
+	defer c.Close()
+
+	res, err := c.MultiQuery(context.Background(), "example.test", dns.TypeMX)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := res.MXRecords()
+	if len(got) != 3 {
+		t.Fatalf("MXRecords = %+v, want 3 distinct hosts", got)
+	}
+	if got[0] != (godns.MXRecord{Preference: 5, Host: "mx0.example.test"}) || got[2] != (godns.MXRecord{Preference: 20, Host: "mx2.example.test"}) {
+		t.Errorf("MXRecords = %+v", got)
+	}
+	// 两个服务器给出的 mx1 大小写不同，保留先汇总到的一条
+	if got[1].Preference != 10 || godns.CanonicalName(got[1].Host) != godns.CanonicalName("mx1.example.test") {
+		t.Errorf("MXRecords[1] = %+v, want mx1.example.test in either case", got[1])
+	}
+}
@@ -0,0 +1,123 @@
+package godns_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+	"github.com/zan8in/godns/internal/testserver"
+)
+
+// TestClientNameInErrors 客户端名称附加在每条错误路径产生的 ErrorInfo 上，并随结果序列化；
+// 路由规则的子客户端以 "父名称/规则名" 标识
+func TestClientNameInErrors(t *testing.T) {
+	s := startServer(t)
+	s.Handle("sick.test", dns.TypeA, testserver.Reply{Rcode: dns.RcodeServerFailure})
+	s.Handle("sick.corp.test", dns.TypeA, testserver.Reply{Rcode: dns.RcodeServerFailure})
+	c := godns.New(
+		godns.WithName("tenant-a"),
+		godns.WithServers(s.UDPAddr),
+		godns.WithRouting(godns.RouteRule{Name: "corp", Suffix: "corp.test", Servers: []string{s.UDPAddr}}),
+		godns.WithRetries(0),
+	)
+	defer c.Close()
+	if c.Name() != "tenant-a" {
+		t.Fatalf("Name() = %q", c.Name())
+	}
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		query  func() error
+		client string
+	}{
+		{"query", func() error {
+			_, err := c.QueryA(ctx, "sick.test")
+			return err
+		}, "tenant-a"},
+		{"multi-query", func() error {
+			res, err := c.MultiQuery(ctx, "sick.test", dns.TypeA)
+			if err != nil {
+				return err
+			}
+			return res.Results[0].Error
+		}, "tenant-a"},
+		{"route", func() error {
+			_, err := c.QueryA(ctx, "sick.corp.test")
+			return err
+		}, "tenant-a/corp"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var info *godns.ErrorInfo
+			if err := tt.query(); !errors.As(err, &info) {
+				t.Fatalf("err = %v, want an *ErrorInfo", err)
+			}
+			if info.Client != tt.client {
+				t.Errorf("Client = %q, want %q", info.Client, tt.client)
+			}
+			data, err := json.Marshal(info)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var decoded godns.ErrorInfo
+			if err := json.Unmarshal(data, &decoded); err != nil {
+				t.Fatal(err)
+			}
+			if decoded.Client != tt.client {
+				t.Errorf("Client after JSON round trip = %q, want %q", decoded.Client, tt.client)
+			}
+		})
+	}
+}
+
+// TestClientNameInLogs 客户端输出的每条日志都以客户端名称开头，同一进程内的多个客户端可以区分
+func TestClientNameInLogs(t *testing.T) {
+	s := startServer(t)
+	s.Answer("example.test", dns.TypeA, "example.test. 60 IN A 192.0.2.1")
+	corrupt := filepath.Join(t.TempDir(), "cache.json")
+	if err := os.WriteFile(corrupt, []byte("{not json"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	logs := captureLog(t)
+
+	debug := godns.New(godns.WithName("tenant-a"), godns.WithServers(s.UDPAddr), godns.WithDebug())
+	defer debug.Close()
+	if _, err := debug.QueryA(context.Background(), "example.test"); err != nil {
+		t.Fatal(err)
+	}
+	persisted := godns.New(godns.WithName("tenant-b"), godns.WithServers(s.UDPAddr), godns.WithCachePersistence(corrupt))
+	defer persisted.Close()
+
+	out := logs.String()
+	for _, want := range []string{"godns[tenant-a]: config:", "godns[tenant-b]: ignoring cache snapshot"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("log = %q, want a line containing %q", out, want)
+		}
+	}
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		if !strings.Contains(line, "godns[tenant-a]") && !strings.Contains(line, "godns[tenant-b]") {
+			t.Errorf("log line without a client name: %q", line)
+		}
+	}
+}
+
+// TestClientNameDefault 未设置名称的客户端得到各不相同的自动生成的短ID
+func TestClientNameDefault(t *testing.T) {
+	seen := make(map[string]bool)
+	for range 10 {
+		c := godns.New()
+		name := c.Name()
+		c.Close()
+		if name == "" || seen[name] {
+			t.Fatalf("Name() = %q, want a non-empty unique ID", name)
+		}
+		seen[name] = true
+	}
+}
@@ -0,0 +1,164 @@
+package godns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// CanonicalName 返回域名的规范形式：完全限定、ASCII 字母小写、转义统一
+// 名称先转换为线路格式再还原，因此 "\065.example" 与 "a.example" 得到相同结果，
+// 而 "a\.b.example"（单个标签中含点）与 "a.b.example" 保持不同
+// 无法转换的名称（例如标签过长）退化为小写的完全限定形式
+func CanonicalName(name string) string {
+	fqdn := dns.Fqdn(name)
+	buf := make([]byte, 256)
+	n, err := dns.PackDomainName(fqdn, buf, 0, nil, false)
+	if err != nil {
+		return strings.ToLower(fqdn)
+	}
+	// 线路格式中标签长度不超过63，不会落在 'A'~'Z' 范围内，可以直接逐字节转换
+	for i := 0; i < n; i++ {
+		if 'A' <= buf[i] && buf[i] <= 'Z' {
+			buf[i] += 'a' - 'A'
+		}
+	}
+	canonical, _, err := dns.UnpackDomainName(buf[:n], 0)
+	if err != nil {
+		return strings.ToLower(fqdn)
+	}
+	return canonical
+}
+
+// equalNames 按 DNS 规则比较两个域名是否相同
+func equalNames(a, b string) bool {
+	return CanonicalName(a) == CanonicalName(b)
+}
+
+// isSubdomain child 是否等于 parent 或位于 parent 之下，按标签比较，转义的点不视为标签分隔
+func isSubdomain(child, parent string) bool {
+	return dns.IsSubDomain(CanonicalName(parent), CanonicalName(child))
+}
+
+// matchQuestion 检查应答的问题部分是否与查询一致，不带问题部分的应答不做检查
+func matchQuestion(query, response *dns.Msg) error {
+	if len(query.Question) == 0 || len(response.Question) == 0 {
+		return nil
+	}
+	q, r := query.Question[0], response.Question[0]
+	if q.Qtype != r.Qtype || q.Qclass != r.Qclass || !equalNames(q.Name, r.Name) {
+		return fmt.Errorf("response question %s %s does not match query %s %s",
+			r.Name, dns.TypeToString[r.Qtype], q.Name, dns.TypeToString[q.Qtype])
+	}
+	return nil
+}
+
+// ErrInvalidName 查询名称不符合客户端或单次查询的名称策略
+var ErrInvalidName = errors.New("godns: invalid name")
+
+// NamePolicy 查询名称的校验策略，按从宽到严排列
+type NamePolicy int
+
+const (
+	// Permissive 任何可编码为线路格式的名称（标签不超过63字节、总长不超过255字节），默认策略
+	Permissive NamePolicy = iota
+	// ServiceLabels 在主机名规则的基础上允许以下划线开头的标签（_dmarc、_443._tcp、_dns.resolver.arpa 等），
+	// 并允许最左侧的通配符标签 "*"；下划线标签只能连续出现在名称最左侧（通配符之后），
+	// 例如 www._x.example.com 不符合该策略
+	ServiceLabels
+	// StrictHostname 主机名规则（RFC 952/1123）：标签仅含字母、数字和连字符，且不以连字符开头或结尾
+	StrictHostname
+)
+
+// String 返回策略名称
+func (p NamePolicy) String() string {
+	switch p {
+	case Permissive:
+		return "permissive"
+	case ServiceLabels:
+		return "service-labels"
+	case StrictHostname:
+		return "strict-hostname"
+	}
+	return fmt.Sprintf("NamePolicy(%d)", int(p))
+}
+
+// Check 按策略校验名称，不符合时返回包装了 ErrInvalidName 的错误
+func (p NamePolicy) Check(name string) error {
+	fqdn := dns.Fqdn(name)
+	if _, ok := dns.IsDomainName(fqdn); !ok {
+		return fmt.Errorf("%w: %q is not a valid domain name", ErrInvalidName, name)
+	}
+	if p == Permissive || fqdn == "." {
+		return nil
+	}
+	labels := strings.Split(strings.TrimSuffix(fqdn, "."), ".")
+	leftmost := true // 仍处于名称最左侧的下划线标签序列中
+	for i, label := range labels {
+		if p == ServiceLabels {
+			if i == 0 && label == "*" {
+				continue
+			}
+			if rest, ok := strings.CutPrefix(label, "_"); ok && (rest == "" || ldhLabel(rest)) {
+				if leftmost {
+					continue
+				}
+				return fmt.Errorf("%w: service label %q of %q must be in the leftmost run of underscore labels", ErrInvalidName, label, name)
+			}
+			leftmost = false
+		}
+		if !ldhLabel(label) {
+			return fmt.Errorf("%w: label %q of %q violates %s policy", ErrInvalidName, label, name, p)
+		}
+	}
+	return nil
+}
+
+// ldhLabel 标签是否仅由字母、数字和连字符组成，且不以连字符开头或结尾
+func ldhLabel(label string) bool {
+	if label == "" || label[0] == '-' || label[len(label)-1] == '-' {
+		return false
+	}
+	for i := 0; i < len(label); i++ {
+		ch := label[i]
+		if !('a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || '0' <= ch && ch <= '9' || ch == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// WithNamePolicy 设置客户端的名称校验策略，不符合策略的查询不会发出并返回 ErrInvalidName
+// 内置的辅助方法（ACME 检查、域名画像等）按其构造名称的需要自动放宽策略
+func WithNamePolicy(p NamePolicy) Option {
+	return func(c *Config) {
+		c.NamePolicy = p
+	}
+}
+
+// namePolicyKey 单次查询的名称校验策略
+type namePolicyKey struct{}
+
+// ContextWithNamePolicy 返回覆盖名称校验策略的 context
+func ContextWithNamePolicy(ctx context.Context, p NamePolicy) context.Context {
+	return context.WithValue(ctx, namePolicyKey{}, p)
+}
+
+// namePolicy 返回本次查询生效的名称校验策略，context 中的设置优先
+func (c *Client) namePolicy(ctx context.Context) NamePolicy {
+	if p, ok := ctx.Value(namePolicyKey{}).(NamePolicy); ok {
+		return p
+	}
+	return c.config.NamePolicy
+}
+
+// relaxNamePolicy 确保生效的策略不严于 required，供自行构造名称的辅助方法使用
+func (c *Client) relaxNamePolicy(ctx context.Context, required NamePolicy) context.Context {
+	if c.namePolicy(ctx) <= required {
+		return ctx
+	}
+	return ContextWithNamePolicy(ctx, required)
+}
@@ -0,0 +1,131 @@
+package godns
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestNamePolicyCheck(t *testing.T) {
+	long := strings.Repeat("a", 64)
+	tests := []struct {
+		name                          string
+		permissive, service, hostname bool
+	}{
+		{"www.example.com", true, true, true},
+		{"www.example.com.", true, true, true},
+		{".", true, true, true},
+		{"xn--nxasmq6b.example", true, true, true},
+		{"_dmarc.example.com", true, true, false},
+		{"_443._tcp.www.example.com", true, true, false},
+		{"_dns.resolver.arpa", true, true, false},
+		{"_.example.com", true, true, false},
+		{"*.example.com", true, true, false},
+		{"*._tcp.example.com", true, true, false},
+		// 下划线标签只能连续出现在最左侧
+		{"www._x.example.com", true, false, false},
+		{"_a.b._c.example.com", true, false, false},
+		{"example._tcp", true, false, false},
+		{"www.*.example.com", true, false, false},
+		{"_-bad.example.com", true, false, false},
+		{"-lead.example.com", true, false, false},
+		{"trail-.example.com", true, false, false},
+		{"sp ace.example.com", true, false, false},
+		{long + ".example.com", false, false, false},
+		{"a..example.com", false, false, false},
+	}
+	for _, tt := range tests {
+		for _, c := range []struct {
+			policy NamePolicy
+			ok     bool
+		}{{Permissive, tt.permissive}, {ServiceLabels, tt.service}, {StrictHostname, tt.hostname}} {
+			err := c.policy.Check(tt.name)
+			if (err == nil) != c.ok {
+				t.Errorf("%s.Check(%q) = %v, want ok=%v", c.policy, tt.name, err, c.ok)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidName) {
+				t.Errorf("%s.Check(%q) error %v does not wrap ErrInvalidName", c.policy, tt.name, err)
+			}
+		}
+	}
+}
+
+// TestCanonicalNameEscapes 转义的名称按线路格式规范化：\065 与 A、a 相同，转义的点属于标签内容而不是分隔符
+func TestCanonicalNameEscapes(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"WWW.Example.COM", "www.example.com."},
+		{`\065.example.`, "a.example."},
+		{`\097.EXAMPLE`, "a.example."},
+		{`A\.B.example.`, `a\.b.example.`},
+		{`a\046b.example.`, `a\.b.example.`},
+		{`a\.b.example.`, `a\.b.example.`},
+		{"a.b.example.", "a.b.example."},
+	}
+	for _, tt := range tests {
+		if got := CanonicalName(tt.name); got != tt.want {
+			t.Errorf("CanonicalName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestEscapedNameComparison 名称比较、子域判断、问题部分校验和缓存键不会混淆不同的名称，也不会拆分相同的名称
+func TestEscapedNameComparison(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		equal bool
+	}{
+		{`\065.example.`, "a.example.", true},
+		{`\065.example.`, "A.EXAMPLE.", true},
+		{`a\.b.example.`, `A\046B.Example.`, true},
+		// 单个标签 "a.b" 与两个标签 a、b 是不同的名称
+		{`a\.b.example.`, "a.b.example.", false},
+		{`\065.example.`, "b.example.", false},
+	}
+	for _, tt := range tests {
+		if got := equalNames(tt.a, tt.b); got != tt.equal {
+			t.Errorf("equalNames(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.equal)
+		}
+
+		query, response := new(dns.Msg), new(dns.Msg)
+		query.SetQuestion(tt.a, dns.TypeA)
+		response.SetQuestion(tt.b, dns.TypeA)
+		if err := matchQuestion(query, response); (err == nil) != tt.equal {
+			t.Errorf("matchQuestion(%q, %q) = %v, want match %v", tt.a, tt.b, err, tt.equal)
+		}
+		if got := CacheKey(UDP, query) == CacheKey(UDP, response); got != tt.equal {
+			t.Errorf("CacheKey(%q) == CacheKey(%q) is %v, want %v", tt.a, tt.b, got, tt.equal)
+		}
+	}
+
+	// 转义的点不是标签分隔符：a\.b.example 位于 example 之下，但不在 b.example 之下
+	if !isSubdomain(`a\.b.example.`, "example.") || isSubdomain(`a\.b.example.`, "b.example.") {
+		t.Error("isSubdomain split an escaped dot into two labels")
+	}
+	if !isSubdomain("www.a.b.example.", "A.B.example.") || isSubdomain("www.a.b.example.", `a\.b.example.`) {
+		t.Error("isSubdomain confused a.b.example with the single label a\\.b")
+	}
+}
+
+// TestEscapedNameCache 以转义形式与普通形式查询同一名称时共用缓存条目，不同名称互不命中
+func TestEscapedNameCache(t *testing.T) {
+	rc := newResponseCache(systemClock{}, 0, 0, nil)
+	rc.set(UDP, cacheQuery(`\065.example`), cacheReply(`\065.example`))
+	rc.set(UDP, cacheQuery(`a\.b.example`), cacheReply(`a\.b.example`))
+
+	for name, want := range map[string]bool{
+		"a.example":      true,
+		"A.EXAMPLE":      true,
+		`A\046B.example`: true,
+		"a.b.example":    false,
+		`\066.example`:   false,
+	} {
+		if _, _, ok := rc.get(UDP, cacheQuery(name)); ok != want {
+			t.Errorf("cache hit for %q = %v, want %v", name, ok, want)
+		}
+	}
+}
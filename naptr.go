@@ -0,0 +1,123 @@
+package godns
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// NAPTRRecord NAPTR记录的各字段（RFC 3403），用于 ENUM 和 SIP 服务定位
+type NAPTRRecord struct {
+	Order       uint16 // 处理顺序，越小越先处理
+	Preference  uint16 // 相同 Order 内的偏好，越小越优先
+	Flags       string // 例如 "U"（终结，Regexp 给出URI）、"S"（下一步查询SRV）
+	Service     string // 例如 "E2U+sip"、"SIP+D2U"
+	Regexp      string // 替换表达式，例如 "!^.*$!sip:info@example.com!"
+	Replacement string // 下一步查询的名称，"." 表示不使用
+}
+
+// String 返回区域文件格式的 RDATA，与NAPTR记录的 Record.Value 相同
+func (n *NAPTRRecord) String() string {
+	return fmt.Sprintf("%d %d %q %q %q %s", n.Order, n.Preference, n.Flags, n.Service, n.Regexp, n.Replacement)
+}
+
+// newNAPTRRecord 从资源记录构建 NAPTRRecord
+func newNAPTRRecord(rr *dns.NAPTR) *NAPTRRecord {
+	return &NAPTRRecord{
+		Order:       rr.Order,
+		Preference:  rr.Preference,
+		Flags:       rr.Flags,
+		Service:     rr.Service,
+		Regexp:      rr.Regexp,
+		Replacement: rr.Replacement,
+	}
+}
+
+// NAPTR 返回NAPTR记录的各字段，记录不是NAPTR类型时返回 false
+// 反序列化得到的 Record 从 Value 中解析
+func (r Record) NAPTR() (*NAPTRRecord, bool) {
+	if naptr, ok := r.rr.(*dns.NAPTR); ok {
+		return newNAPTRRecord(naptr), true
+	}
+	if r.Type != dns.TypeNAPTR {
+		return nil, false
+	}
+
+	n := &NAPTRRecord{}
+	rest := r.Value()
+	for _, field := range []*uint16{&n.Order, &n.Preference} {
+		token, tail, _ := strings.Cut(rest, " ")
+		v, err := strconv.ParseUint(token, 10, 16)
+		if err != nil {
+			return nil, false
+		}
+		*field, rest = uint16(v), tail
+	}
+	// Flags、Service、Regexp 为带引号的字符串，可能包含空格
+	for _, field := range []*string{&n.Flags, &n.Service, &n.Regexp} {
+		quoted, err := strconv.QuotedPrefix(rest)
+		if err != nil {
+			return nil, false
+		}
+		if *field, err = strconv.Unquote(quoted); err != nil {
+			return nil, false
+		}
+		rest = strings.TrimPrefix(rest[len(quoted):], " ")
+	}
+	if rest == "" || strings.Contains(rest, " ") {
+		return nil, false
+	}
+	n.Replacement = rest
+	return n, true
+}
+
+// NAPTRRecords 返回结果中的NAPTR记录，按 Order 升序、相同 Order 按 Preference 升序排列
+func (r *QueryResult) NAPTRRecords() []NAPTRRecord {
+	var records []NAPTRRecord
+	for _, record := range r.Records {
+		if naptr, ok := record.NAPTR(); ok {
+			records = append(records, *naptr)
+		}
+	}
+	sortNAPTR(records)
+	return records
+}
+
+// NAPTRRecords 汇总各服务器成功应答中的NAPTR记录，内容相同的记录只保留一条，排序规则同 QueryResult.NAPTRRecords
+func (r *MultiQueryResult) NAPTRRecords() []NAPTRRecord {
+	var records []NAPTRRecord
+	seen := make(map[NAPTRRecord]bool)
+	for i := range r.Results {
+		if r.Results[i].Error != nil {
+			continue
+		}
+		for _, naptr := range r.Results[i].NAPTRRecords() {
+			if !seen[naptr] {
+				seen[naptr] = true
+				records = append(records, naptr)
+			}
+		}
+	}
+	sortNAPTR(records)
+	return records
+}
+
+// sortNAPTR 按 RFC 3403 §4.1 的处理顺序排序
+func sortNAPTR(records []NAPTRRecord) {
+	sort.SliceStable(records, func(i, j int) bool {
+		if records[i].Order != records[j].Order {
+			return records[i].Order < records[j].Order
+		}
+		return records[i].Preference < records[j].Preference
+	})
+}
+
+// QueryNAPTR 向所有配置的服务器查询NAPTR记录，汇总后的结构化字段可通过 MultiQueryResult.NAPTRRecords 获取
+// domain 按原样查询，ENUM 查询需自行转换为 e164.arpa 下的名称
+func (c *Client) QueryNAPTR(ctx context.Context, domain string) (*MultiQueryResult, error) {
+	return c.MultiQuery(ctx, domain, dns.TypeNAPTR)
+}
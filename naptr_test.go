@@ -0,0 +1,110 @@
+package godns_test
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+	"github.com/zan8in/godns/internal/testserver"
+)
+
+const enumName = "4.3.2.1.5.5.5.0.0.8.1.e164.arpa."
+
+// TestQueryNAPTR 各服务器的NAPTR记录汇总去重，按 Order、Preference 排序，失败的服务器不参与汇总；
+// 反序列化得到的记录从 Value 中解析出相同的字段，包括含空格的正则表达式
+func TestQueryNAPTR(t *testing.T) {
+	a, b, failing := startServer(t), startServer(t), startServer(t)
+	a.Answer(enumName, dns.TypeNAPTR,
+		enumName+` 300 IN NAPTR 100 20 "U" "E2U+mailto" "!^.*$!mailto:info@example.test!" .`,
+		enumName+` 300 IN NAPTR 100 10 "U" "E2U+sip" "!^.*$!sip:info@example.test!" .`,
+	)
+	b.Answer(enumName, dns.TypeNAPTR,
+		enumName+` 300 IN NAPTR 100 10 "U" "E2U+sip" "!^.*$!sip:info@example.test!" .`,
+		enumName+` 300 IN NAPTR 50 50 "S" "SIP+D2U" "" _sip._udp.example.test.`,
+	)
+	failing.Handle(enumName, dns.TypeNAPTR, testserver.Reply{Rcode: dns.RcodeServerFailure})
+	c := godns.New(godns.WithServers(a.UDPAddr, b.UDPAddr, failing.UDPAddr), godns.WithRetries(0))
+	defer c.Close()
+
+	res, err := c.QueryNAPTR(context.Background(), enumName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Results) != 3 {
+		t.Fatalf("got %d results, want one per server", len(res.Results))
+	}
+	want := []godns.NAPTRRecord{
+		{Order: 50, Preference: 50, Flags: "S", Service: "SIP+D2U", Regexp: "", Replacement: "_sip._udp.example.test."},
+		{Order: 100, Preference: 10, Flags: "U", Service: "E2U+sip", Regexp: "!^.*$!sip:info@example.test!", Replacement: "."},
+		{Order: 100, Preference: 20, Flags: "U", Service: "E2U+mailto", Regexp: "!^.*$!mailto:info@example.test!", Replacement: "."},
+	}
+	if got := res.NAPTRRecords(); !reflect.DeepEqual(got, want) {
+		t.Errorf("NAPTRRecords = %+v, want %+v", got, want)
+	}
+
+	for _, r := range res.Results {
+		if r.Server != a.UDPAddr {
+			continue
+		}
+		if got := r.NAPTRRecords(); !reflect.DeepEqual(got, want[1:]) {
+			t.Errorf("%s: NAPTRRecords = %+v, want %+v", r.Server, got, want[1:])
+		}
+		for _, record := range r.Records {
+			live, _ := record.NAPTR()
+			if live.String() != record.Value() {
+				t.Errorf("String = %q, Value = %q", live.String(), record.Value())
+			}
+			data, err := json.Marshal(record)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var decoded godns.Record
+			if err := json.Unmarshal(data, &decoded); err != nil {
+				t.Fatal(err)
+			}
+			if parsed, ok := decoded.NAPTR(); !ok || *parsed != *live {
+				t.Errorf("after JSON round trip %q parsed as %+v, want %+v", decoded.Value(), parsed, live)
+			}
+		}
+	}
+}
+
+// TestNAPTRRegexpWithSpaces 含空格和引号的字段经 Value 往返后保持不变，非NAPTR记录不解析
+func TestNAPTRRegexpWithSpaces(t *testing.T) {
+	s := startServer(t)
+	s.Answer("example.test", dns.TypeNAPTR, `example.test. 300 IN NAPTR 10 10 "U" "E2U+web" "!^.*$!http://example.test/a b\"c!" .`)
+	s.Answer("example.test", dns.TypeA, "example.test. 300 IN A 192.0.2.1")
+	c := godns.New(godns.WithServers(s.UDPAddr), godns.WithRetries(0))
+	defer c.Close()
+
+	res, err := c.Query(context.Background(), "example.test", dns.TypeNAPTR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded godns.QueryResult
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := decoded.NAPTRRecords(), res.NAPTRRecords(); len(got) != 1 || !reflect.DeepEqual(got, want) {
+		t.Errorf("after JSON round trip NAPTRRecords = %+v, want %+v", got, want)
+	}
+	// Regexp 与 miekg/dns 一致，保留区域文件格式中的转义
+	if want := `!^.*$!http://example.test/a b\"c!`; res.NAPTRRecords()[0].Regexp != want {
+		t.Errorf("Regexp = %q, want %q", res.NAPTRRecords()[0].Regexp, want)
+	}
+
+	a, err := c.QueryA(context.Background(), "example.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := a.Records[0].NAPTR(); ok {
+		t.Error("A record parsed as NAPTR")
+	}
+}
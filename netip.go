@@ -0,0 +1,145 @@
+package godns
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+
+	"github.com/miekg/dns"
+)
+
+// ParseAddrs 将字符串形式的IP地址转换为 netip.Addr，无法解析的值被跳过
+// IPv4 映射的 IPv6 地址（::ffff:a.b.c.d）保持原样，需要时可调用 Addr.Unmap
+func ParseAddrs(ips []string) []netip.Addr {
+	addrs := make([]netip.Addr, 0, len(ips))
+	for _, ip := range ips {
+		if addr, err := netip.ParseAddr(ip); err == nil {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
+// AddrStrings 将 netip.Addr 转换为字符串形式
+func AddrStrings(addrs []netip.Addr) []string {
+	ips := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		if addr.IsValid() {
+			ips = append(ips, addr.String())
+		}
+	}
+	return ips
+}
+
+// recordAddr 返回 A/AAAA 记录的地址，有原始资源记录时直接取用，不生成 Value
+func recordAddr(record Record) (netip.Addr, bool) {
+	switch rr := record.rr.(type) {
+	case *dns.A:
+		addr, ok := netip.AddrFromSlice(rr.A.To4())
+		return addr, ok
+	case *dns.AAAA:
+		// 与 Value 一致，IPv4 映射地址按 IPv4 地址处理
+		addr, ok := netip.AddrFromSlice(rr.AAAA.To16())
+		return addr.Unmap(), ok
+	}
+	if record.Type != dns.TypeA && record.Type != dns.TypeAAAA {
+		return netip.Addr{}, false
+	}
+	addr, err := netip.ParseAddr(record.Value())
+	return addr.Unmap(), err == nil
+}
+
+// recordAddrs 返回记录中的所有 A/AAAA 地址
+func recordAddrs(records []Record) []netip.Addr {
+	addrs := make([]netip.Addr, 0, len(records))
+	for _, record := range records {
+		if addr, ok := recordAddr(record); ok {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
+// IPAddrs 返回结果中 A/AAAA 记录的地址
+func (r *QueryResult) IPAddrs() []netip.Addr {
+	return recordAddrs(r.Records)
+}
+
+// IPAddrs 返回 AllIPs 的 netip.Addr 形式
+func (r *MultiQueryResult) IPAddrs() []netip.Addr {
+	return ParseAddrs(r.AllIPs)
+}
+
+// IPAddrs 返回胜出应答集合中地址的 netip.Addr 形式
+func (r *ConfidenceResult) IPAddrs() []netip.Addr {
+	return ParseAddrs(r.IPs)
+}
+
+// LookupNetIP 解析主机名的IP地址，network 为 "ip"、"ip4" 或 "ip6"，用法与 net.Resolver.LookupNetIP 一致
+// host 本身是IP地址时直接返回；"ip" 同时查询A和AAAA，任一地址族有结果即视为成功
+// 没有地址时，名称不存在返回包装 ErrNXDomain 的 *RcodeError，否则返回包装 ErrNoRecords 的错误
+func (c *Client) LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error) {
+	var qtypes []uint16
+	switch network {
+	case "ip":
+		qtypes = []uint16{dns.TypeA, dns.TypeAAAA}
+	case "ip4":
+		qtypes = []uint16{dns.TypeA}
+	case "ip6":
+		qtypes = []uint16{dns.TypeAAAA}
+	default:
+		return nil, fmt.Errorf("unsupported network %q", network)
+	}
+
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if network == "ip4" && !addr.Unmap().Is4() || network == "ip6" && !addr.Is6() {
+			return nil, fmt.Errorf("address %s does not match network %s", host, network)
+		}
+		if network == "ip4" {
+			addr = addr.Unmap()
+		}
+		return []netip.Addr{addr}, nil
+	}
+
+	type answer struct {
+		addrs []netip.Addr
+		err   error
+	}
+	answers := make([]answer, len(qtypes))
+	done := make(chan struct{}, len(qtypes))
+	for i, qtype := range qtypes {
+		go func(i int, qtype uint16) {
+			defer func() { done <- struct{}{} }()
+			res, err := c.Query(ctx, host, qtype)
+			if res != nil {
+				answers[i].addrs = res.IPAddrs()
+			}
+			answers[i].err = err
+		}(i, qtype)
+	}
+	for range qtypes {
+		<-done
+	}
+
+	var addrs []netip.Addr
+	var firstErr error
+	seen := make(map[netip.Addr]bool)
+	for _, ans := range answers {
+		if ans.err != nil && firstErr == nil {
+			firstErr = ans.err
+		}
+		for _, addr := range ans.addrs {
+			if !seen[addr] {
+				seen[addr] = true
+				addrs = append(addrs, addr)
+			}
+		}
+	}
+	if len(addrs) == 0 {
+		if firstErr != nil {
+			return nil, firstErr
+		}
+		return nil, fmt.Errorf("%w: no addresses found for %s", ErrNoRecords, host)
+	}
+	return addrs, nil
+}
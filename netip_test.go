@@ -0,0 +1,110 @@
+package godns_test
+
+import (
+	"context"
+	"net/netip"
+	"slices"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+)
+
+func TestParseAddrsAndAddrStrings(t *testing.T) {
+	in := []string{"192.0.2.1", "::ffff:192.0.2.1", "2001:db8::1", "fe80::1%eth0", "bogus", "", "192.0.2.256"}
+	addrs := godns.ParseAddrs(in)
+	want := []netip.Addr{
+		netip.MustParseAddr("192.0.2.1"),
+		netip.MustParseAddr("::ffff:192.0.2.1"),
+		netip.MustParseAddr("2001:db8::1"),
+		netip.MustParseAddr("fe80::1%eth0"),
+	}
+	if !slices.Equal(addrs, want) {
+		t.Fatalf("ParseAddrs = %v, want %v", addrs, want)
+	}
+	// IPv4 映射地址保持原样，与普通 IPv4 地址不相等；区域标识保留
+	if !addrs[1].Is4In6() || addrs[1] == addrs[0] || addrs[1].Unmap() != addrs[0] {
+		t.Errorf("v4-mapped address = %v, want it kept mapped and equal to %v once unmapped", addrs[1], addrs[0])
+	}
+	if addrs[3].Zone() != "eth0" {
+		t.Errorf("zone = %q, want eth0", addrs[3].Zone())
+	}
+
+	// 往返转换保留可解析的值，零值地址被跳过
+	if got := godns.AddrStrings(append(addrs, netip.Addr{})); !slices.Equal(got, in[:4]) {
+		t.Errorf("AddrStrings = %v, want %v", got, in[:4])
+	}
+}
+
+// TestRecordAddrsUnmapV4Mapped AAAA 记录中的 IPv4 映射地址按 IPv4 地址处理，与 Value 一致，无论记录是否带原始RR
+func TestRecordAddrsUnmapV4Mapped(t *testing.T) {
+	s := startServer(t)
+	s.Answer("mapped.test", dns.TypeAAAA, "mapped.test. 60 IN AAAA ::ffff:192.0.2.9", "mapped.test. 60 IN AAAA 2001:db8::9")
+	c := godns.New(godns.WithServers(s.UDPAddr))
+	defer c.Close()
+
+	res, err := c.QueryAAAA(context.Background(), "mapped.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []netip.Addr{netip.MustParseAddr("192.0.2.9"), netip.MustParseAddr("2001:db8::9")}
+	if got := res.IPAddrs(); !slices.Equal(got, want) {
+		t.Errorf("IPAddrs = %v, want %v", got, want)
+	}
+	if got := res.Records[0].Value(); got != "192.0.2.9" {
+		t.Errorf("Value = %q, want the unmapped form", got)
+	}
+
+	valueOnly := godns.QueryResult{Records: []godns.Record{
+		godns.NewValueRecord("mapped.test.", dns.TypeAAAA, 60, "::ffff:192.0.2.9"),
+		godns.NewValueRecord("mapped.test.", dns.TypeAAAA, 60, "2001:db8::9"),
+		godns.NewValueRecord("mapped.test.", dns.TypeTXT, 60, "192.0.2.10"),
+	}}
+	if got := valueOnly.IPAddrs(); !slices.Equal(got, want) {
+		t.Errorf("IPAddrs of value records = %v, want %v", got, want)
+	}
+}
+
+// TestLookupNetIPAddressFamilies 字面量地址按网络类型检查地址族，IPv4 映射地址在 ip4 下转换为 IPv4，区域标识保留；
+// A 与 AAAA 中以映射形式出现的同一地址只返回一次
+func TestLookupNetIPAddressFamilies(t *testing.T) {
+	s := startServer(t)
+	s.Answer("dual.test", dns.TypeA, "dual.test. 60 IN A 192.0.2.1")
+	s.Answer("dual.test", dns.TypeAAAA, "dual.test. 60 IN AAAA ::ffff:192.0.2.1", "dual.test. 60 IN AAAA 2001:db8::1")
+	c := godns.New(godns.WithServers(s.UDPAddr), godns.WithRetries(0))
+	defer c.Close()
+
+	tests := []struct {
+		network, host string
+		want          []string // nil 表示应返回错误
+	}{
+		{"ip4", "192.0.2.1", []string{"192.0.2.1"}},
+		{"ip4", "::ffff:192.0.2.1", []string{"192.0.2.1"}},
+		{"ip", "::ffff:192.0.2.1", []string{"::ffff:192.0.2.1"}},
+		{"ip6", "::ffff:192.0.2.1", []string{"::ffff:192.0.2.1"}},
+		{"ip6", "fe80::1%eth0", []string{"fe80::1%eth0"}},
+		{"ip4", "fe80::1%eth0", nil},
+		{"ip6", "192.0.2.1", nil},
+		{"ip", "dual.test", []string{"192.0.2.1", "2001:db8::1"}},
+		{"ip4", "dual.test", []string{"192.0.2.1"}},
+		{"ip6", "dual.test", []string{"192.0.2.1", "2001:db8::1"}},
+		{"tcp", "dual.test", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.network+"/"+tt.host, func(t *testing.T) {
+			addrs, err := c.LookupNetIP(context.Background(), tt.network, tt.host)
+			if tt.want == nil {
+				if err == nil {
+					t.Fatalf("LookupNetIP = %v, want an error", addrs)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := godns.AddrStrings(addrs); !slices.Equal(got, tt.want) {
+				t.Errorf("LookupNetIP = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
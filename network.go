@@ -0,0 +1,138 @@
+package godns
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// ErrInvalidConfig 客户端配置无效
+var ErrInvalidConfig = errors.New("godns: invalid config")
+
+// NetworkFamily 限定连接服务器时使用的IP地址族
+type NetworkFamily string
+
+const (
+	AnyFamily NetworkFamily = ""  // 不限定地址族
+	IPv4Only  NetworkFamily = "4" // 只使用IPv4，对应 udp4/tcp4
+	IPv6Only  NetworkFamily = "6" // 只使用IPv6，对应 udp6/tcp6
+)
+
+// WithNetworkFamily 限定连接服务器时使用的IP地址族，对所有协议生效（DoH 通过HTTP传输层的拨号限定）
+func WithNetworkFamily(family NetworkFamily) Option {
+	return func(c *Config) {
+		c.NetworkFamily = family
+	}
+}
+
+// valid 协议是否为已知的协议
+func (p Protocol) valid() bool {
+	switch p {
+	case UDP, TCP, DoT, DoH, DoQ:
+		return true
+	}
+	return false
+}
+
+// encrypted 协议是否加密传输（DoT、DoH、DoQ）
+func (p Protocol) encrypted() bool {
+	return p == DoT || p == DoH || p == DoQ
+}
+
+// network 返回协议在 dns.Client（DoH 为 net.Dialer）中对应的网络类型
+func (p Protocol) network(family NetworkFamily) (string, error) {
+	if family != AnyFamily && family != IPv4Only && family != IPv6Only {
+		return "", fmt.Errorf("%w: unknown network family %q", ErrInvalidConfig, family)
+	}
+	switch p {
+	case UDP, DoQ:
+		return "udp" + string(family), nil
+	case TCP, DoH:
+		return "tcp" + string(family), nil
+	case DoT:
+		return "tcp" + string(family) + "-tls", nil
+	default:
+		return "", fmt.Errorf("%w: unsupported protocol %q", ErrInvalidConfig, p)
+	}
+}
+
+// network 返回协议在客户端配置的地址族下对应的网络类型，配置无效时退回不限定地址族，
+// 查询时由 exchange 返回错误
+func (c *Client) network(p Protocol) string {
+	if n, err := p.network(c.config.NetworkFamily); err == nil {
+		return n
+	}
+	n, _ := p.network(AnyFamily)
+	return n
+}
+
+// newHTTPTransport 构建DoH使用的HTTP传输层，应用代理和地址族配置
+// http.Transport 启用 HTTP/2 时会改写 TLSClientConfig.NextProtos，因此使用独立副本，避免影响同一配置上的DoT查询
+func (c *Client) newHTTPTransport(tlsConfig *tls.Config) *http.Transport {
+	transport := &http.Transport{TLSClientConfig: tlsConfig.Clone()}
+	if c.transports.proxyURL != nil {
+		transport.Proxy = http.ProxyURL(c.transports.proxyURL)
+	}
+	if c.config.NetworkFamily != AnyFamily {
+		network := c.network(DoH)
+		dialer := &net.Dialer{Timeout: c.config.Timeout}
+		transport.DialContext = func(ctx context.Context, _, addr string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, addr)
+		}
+	}
+	return transport
+}
+
+// Validate 检查配置，返回包装了 ErrInvalidConfig 的错误
+// 未知的协议（包括在 Protocol 中直接使用 "udp4" 等网络类型，应改用 WithNetworkFamily）和地址族会被拒绝，
+// WithServerTLSConfig 覆盖了不在服务器列表中的服务器时同样被拒绝
+func (c *Config) Validate() error {
+	if _, err := c.Protocol.network(c.NetworkFamily); err != nil {
+		return err
+	}
+	for _, p := range c.FallbackProtocols {
+		if !p.valid() {
+			return fmt.Errorf("%w: unsupported fallback protocol %q", ErrInvalidConfig, p)
+		}
+	}
+	for _, spec := range c.MixedRace {
+		if !spec.Protocol.valid() {
+			return fmt.Errorf("%w: unsupported protocol %q for server %s", ErrInvalidConfig, spec.Protocol, spec.Address)
+		}
+	}
+	for _, info := range c.ServerInfos {
+		if info.Protocol != "" && !info.Protocol.valid() {
+			return fmt.Errorf("%w: unsupported protocol %q for server %s", ErrInvalidConfig, info.Protocol, info.Address)
+		}
+	}
+	for _, rule := range c.Routes {
+		if rule.Protocol != "" && !rule.Protocol.valid() {
+			return fmt.Errorf("%w: unsupported protocol %q for route %s", ErrInvalidConfig, rule.Protocol, rule.Suffix)
+		}
+	}
+	if unknown := c.unknownTLSOverrides(); len(unknown) > 0 {
+		return unknownTLSOverridesError(unknown)
+	}
+	if c.Timeout < 0 {
+		return fmt.Errorf("%w: negative timeout %v", ErrInvalidConfig, c.Timeout)
+	}
+	if c.Retries < 0 {
+		return fmt.Errorf("%w: negative retries %d", ErrInvalidConfig, c.Retries)
+	}
+	return nil
+}
+
+// NewWithValidation 创建客户端前校验配置，配置无效时立即返回错误而不是在查询时才失败
+func NewWithValidation(opts ...Option) (*Client, error) {
+	config := defaultConfig()
+	for _, opt := range opts {
+		opt(config)
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return newClient(config), nil
+}
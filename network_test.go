@@ -0,0 +1,93 @@
+package godns_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+)
+
+// TestInvalidProtocolFailsFast 未知的协议和地址族在 NewWithValidation 时即被拒绝；
+// New 创建的客户端在查询时返回同一错误，且不会发出任何请求
+func TestInvalidProtocolFailsFast(t *testing.T) {
+	s := startServer(t)
+	s.Answer("www.test", dns.TypeA, "www.test. 60 IN A 192.0.2.1")
+
+	tests := []struct {
+		name string
+		opt  godns.Option
+		// 查询时是否同样失败；回退协议和路由的协议只在对应路径上使用，不检查查询时的行为
+		queryFails bool
+	}{
+		{"bogus", godns.WithProtocol(godns.Protocol("bogus")), true},
+		// 地址族应通过 WithNetworkFamily 指定，而不是直接写入 Protocol
+		{"udp4-as-protocol", godns.WithProtocol(godns.Protocol("udp4")), true},
+		{"tcp6-as-protocol", godns.WithProtocol(godns.Protocol("tcp6")), true},
+		{"uppercase", godns.WithProtocol(godns.Protocol("UDP")), true},
+		{"bogus-family", godns.WithNetworkFamily(godns.NetworkFamily("5")), true},
+		{"bogus-fallback", godns.WithProtocolFallback(godns.Protocol("udp4")), false},
+		{"bogus-route", godns.WithRouting(godns.RouteRule{Suffix: "test", Servers: []string{s.UDPAddr}, Protocol: godns.Protocol("quic")}), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := []godns.Option{godns.WithServers(s.UDPAddr), tt.opt}
+			c, err := godns.NewWithValidation(opts...)
+			if c != nil || !errors.Is(err, godns.ErrInvalidConfig) {
+				t.Fatalf("NewWithValidation = %v, %v, want ErrInvalidConfig", c, err)
+			}
+			if !tt.queryFails {
+				return
+			}
+
+			s.Reset()
+			c = godns.New(opts...)
+			defer c.Close()
+			if _, err := c.QueryA(context.Background(), "www.test"); !errors.Is(err, godns.ErrInvalidConfig) {
+				t.Errorf("query err = %v, want ErrInvalidConfig", err)
+			}
+			if q := s.Queries(); len(q) != 0 {
+				t.Errorf("server saw %d queries", len(q))
+			}
+		})
+	}
+}
+
+// TestNetworkFamily 地址族通过 WithNetworkFamily 生效：IPv4 服务器在 IPv4Only 下可达，在 IPv6Only 下不可达
+func TestNetworkFamily(t *testing.T) {
+	s := startServer(t)
+	s.Answer("www.test", dns.TypeA, "www.test. 60 IN A 192.0.2.1")
+
+	for _, protocol := range []godns.Protocol{godns.UDP, godns.TCP} {
+		addr := s.UDPAddr
+		if protocol == godns.TCP {
+			addr = s.TCPAddr
+		}
+		for _, family := range []godns.NetworkFamily{godns.AnyFamily, godns.IPv4Only, godns.IPv6Only} {
+			name := "any"
+			if family != godns.AnyFamily {
+				name = "ipv" + string(family)
+			}
+			t.Run(string(protocol)+"/"+name, func(t *testing.T) {
+				c, err := godns.NewWithValidation(
+					godns.WithProtocol(protocol),
+					godns.WithServers(addr),
+					godns.WithNetworkFamily(family),
+					godns.WithRetries(0),
+				)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer c.Close()
+				_, err = c.QueryA(context.Background(), "www.test")
+				if wantErr := family == godns.IPv6Only; (err != nil) != wantErr {
+					t.Errorf("err = %v, want error = %v", err, wantErr)
+				}
+				if err != nil && errors.Is(err, godns.ErrInvalidConfig) {
+					t.Errorf("err = %v, want a dial error", err)
+				}
+			})
+		}
+	}
+}
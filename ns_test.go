@@ -0,0 +1,98 @@
+package godns_test
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+)
+
+var zoneNS = []string{
+	"example.test. 3600 IN NS ns1.example.test.",
+	"example.test. 3600 IN NS ns2.example.net.",
+	"example.test. 3600 IN NS NS3.Example.ORG.",
+}
+
+// TestQueryNS 多条NS记录：Record.Value 与 CNAME 一致保留末尾的点，Nameservers 去掉末尾的点并保持顺序
+func TestQueryNS(t *testing.T) {
+	s := startServer(t)
+	s.Answer("example.test", dns.TypeNS, zoneNS...)
+	s.Answer("alias.example.test", dns.TypeCNAME, "alias.example.test. 60 IN CNAME www.example.test.")
+	c := godns.New(godns.WithServers(s.UDPAddr))
+	defer c.Close()
+
+	res, err := c.QueryNS(context.Background(), "example.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q := s.Queries(); len(q) != 1 || q[0].Msg.Question[0].Qtype != dns.TypeNS {
+		t.Fatalf("server saw %+v", q)
+	}
+	var values []string
+	for _, r := range res.Records {
+		if r.Type != dns.TypeNS {
+			t.Errorf("record type = %d", r.Type)
+		}
+		values = append(values, r.Value())
+	}
+	want := []string{"ns1.example.test.", "ns2.example.net.", "NS3.Example.ORG."}
+	if !slices.Equal(values, want) {
+		t.Errorf("values = %v, want %v", values, want)
+	}
+	if got := res.Nameservers(); !slices.Equal(got, []string{"ns1.example.test", "ns2.example.net", "NS3.Example.ORG"}) {
+		t.Errorf("Nameservers = %v", got)
+	}
+
+	cname, err := c.QueryCNAME(context.Background(), "alias.example.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cname.Records[0].Value(); got != "www.example.test." {
+		t.Errorf("CNAME value = %q, want the same trailing-dot form as NS", got)
+	}
+}
+
+// TestMultiQueryNS 通过 MultiQuery 查询NS：每个配置的服务器各有一条结果，
+// 各结果保留该服务器给出的名称服务器，AllIPs 只收集地址，不包含名称服务器
+func TestMultiQueryNS(t *testing.T) {
+	var servers []string
+	want := map[string][]string{}
+	for i := range 4 {
+		s := startServer(t)
+		records := zoneNS
+		if i == 3 {
+			// 最后一个服务器只知道其中两个名称服务器
+			records = zoneNS[:2]
+		}
+		s.Answer("example.test", dns.TypeNS, records...)
+		servers = append(servers, s.UDPAddr)
+		for _, record := range records {
+			want[s.UDPAddr] = append(want[s.UDPAddr], strings.TrimSuffix(mustRR(record).(*dns.NS).Ns, "."))
+		}
+	}
+	c := godns.New(godns.WithServers(servers...))
+	defer c.Close()
+
+	res, err := c.MultiQuery(context.Background(), "example.test", dns.TypeNS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Domain != "example.test" || res.Type != dns.TypeNS || len(res.AllIPs) != 0 {
+		t.Errorf("domain = %s, type = %d, AllIPs = %v", res.Domain, res.Type, res.AllIPs)
+	}
+	if len(res.Results) != len(servers) {
+		t.Fatalf("results = %d, want %d", len(res.Results), len(servers))
+	}
+	for _, r := range res.Results {
+		if r.Error != nil {
+			t.Errorf("%s: %v", r.Server, r.Error)
+			continue
+		}
+		if got := r.Nameservers(); !slices.Equal(got, want[r.Server]) {
+			t.Errorf("%s: nameservers = %v, want %v", r.Server, got, want[r.Server])
+		}
+	}
+}
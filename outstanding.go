@@ -0,0 +1,137 @@
+package godns
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// ErrClientSaturated 并发查询数已达 WithMaxOutstanding 上限，且在 WithQueueTimeout 时间内未等到空闲名额
+var ErrClientSaturated = errors.New("godns: client saturated")
+
+// WithMaxOutstanding 限制客户端同时进行的网络交互数量，避免无限制的并发查询耗尽文件描述符和临时端口
+// 超出上限的查询按到达顺序排队等待，等待期间遵循调用方 context 的取消和截止时间；
+// 排队时间不计入单次尝试的超时预算，记录在 Attempt.QueueWait 中
+func WithMaxOutstanding(n int) Option {
+	return func(c *Config) {
+		c.MaxOutstanding = n
+	}
+}
+
+// WithQueueTimeout 设置排队等待的时间上限，超时返回 ErrClientSaturated，0 表示一直等待
+// 需配合 WithMaxOutstanding 使用
+func WithQueueTimeout(d time.Duration) Option {
+	return func(c *Config) {
+		c.QueueTimeout = d
+	}
+}
+
+// WithOnSaturated 设置查询因达到 WithMaxOutstanding 上限而排队的回调，在排队结束（获得名额、超时或 context 结束）时调用
+// 回调在查询所在的协程中同步执行，不应阻塞
+func WithOnSaturated(fn func(ctx context.Context, ev SaturationEvent)) Option {
+	return func(c *Config) {
+		c.OnSaturated = fn
+	}
+}
+
+// SaturationEvent 一次因达到并发上限而发生的排队
+type SaturationEvent struct {
+	Limit   int           // WithMaxOutstanding 上限
+	Waiting int           // 开始排队时正在排队的查询数，包括本次
+	Wait    time.Duration // 排队时间
+	Err     error         // 排队的结果：nil 表示获得名额，否则为 ErrClientSaturated 或 context 的错误
+}
+
+// OutstandingStats 并发查询上限的统计信息
+type OutstandingStats struct {
+	InFlight  int    // 正在进行的网络交互数
+	Waiting   int    // 正在排队的查询数
+	Saturated uint64 // 因达到上限而需要排队的次数
+	Rejected  uint64 // 排队超时返回 ErrClientSaturated 的次数
+}
+
+// OutstandingStats 返回并发查询上限的统计信息，未启用 WithMaxOutstanding 时为零值
+func (c *Client) OutstandingStats() OutstandingStats {
+	if c.outstanding == nil {
+		return OutstandingStats{}
+	}
+	return c.outstanding.stats()
+}
+
+// outstandingLimiter 客户端级的网络交互信号量
+// 名额已满时阻塞在带缓冲 channel 上的发送者按先进先出的顺序被唤醒，保证排队大致公平
+type outstandingLimiter struct {
+	slots   chan struct{}
+	timeout time.Duration
+
+	waiting   atomic.Int64
+	saturated atomic.Uint64
+	rejected  atomic.Uint64
+}
+
+func newOutstandingLimiter(n int, timeout time.Duration) *outstandingLimiter {
+	return &outstandingLimiter{
+		slots:   make(chan struct{}, n),
+		timeout: timeout,
+	}
+}
+
+// slotHeldKey 标记在已持有名额的尝试内部发起的查询（例如DANE校验前的TLSA查询），
+// 这类查询不再排队，否则上限为1时会等待永远不会释放的名额
+type slotHeldKey struct{}
+
+// withSlotHeld 返回标记已持有名额的 context
+func withSlotHeld(ctx context.Context) context.Context {
+	return context.WithValue(ctx, slotHeldKey{}, true)
+}
+
+// acquireSlot 获取一个网络交互名额，返回释放函数和排队时间
+func (c *Client) acquireSlot(ctx context.Context) (func(), time.Duration, error) {
+	l := c.outstanding
+	if l == nil || ctx.Value(slotHeldKey{}) != nil {
+		return func() {}, 0, nil
+	}
+	release := func() { <-l.slots }
+	select {
+	case l.slots <- struct{}{}:
+		return release, 0, nil
+	default:
+	}
+
+	l.saturated.Add(1)
+	waiting := l.waiting.Add(1)
+	defer l.waiting.Add(-1)
+
+	var timeout <-chan time.Time
+	if l.timeout > 0 {
+		timeout = c.after(l.timeout)
+	}
+	start := c.config.Clock.Now()
+	var err error
+	select {
+	case l.slots <- struct{}{}:
+	case <-timeout:
+		l.rejected.Add(1)
+		err = ErrClientSaturated
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	wait := c.config.Clock.Now().Sub(start)
+	if c.config.OnSaturated != nil {
+		c.config.OnSaturated(ctx, SaturationEvent{Limit: cap(l.slots), Waiting: int(waiting), Wait: wait, Err: err})
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	return release, wait, nil
+}
+
+func (l *outstandingLimiter) stats() OutstandingStats {
+	return OutstandingStats{
+		InFlight:  len(l.slots),
+		Waiting:   int(l.waiting.Load()),
+		Saturated: l.saturated.Load(),
+		Rejected:  l.rejected.Load(),
+	}
+}
@@ -0,0 +1,203 @@
+package godns_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+	"github.com/zan8in/godns/internal/testserver"
+)
+
+// slowName 让服务器延迟 delay 后应答 name
+func slowName(s *testserver.Server, name string, delay time.Duration) {
+	s.Handle(name, dns.TypeA, testserver.Reply{
+		Answer: []dns.RR{testserver.RR(name + ". 60 IN A 192.0.2.1")},
+		Delay:  delay,
+	})
+}
+
+// waitFor 轮询直到 cond 成立
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+type queueOrderKey struct{}
+
+// TestMaxOutstandingFIFO 名额为1时排队的查询按到达顺序获得名额，每次排队都产生事件
+func TestMaxOutstandingFIFO(t *testing.T) {
+	const queued = 8
+	s := startServer(t)
+	slowName(s, "holder.test", 100*time.Millisecond)
+	for i := range queued {
+		slowName(s, fmt.Sprintf("q%d.test", i), 5*time.Millisecond)
+	}
+
+	var mu sync.Mutex
+	var order []int
+	var events []godns.SaturationEvent
+	c := godns.New(
+		godns.WithServers(s.UDPAddr),
+		godns.WithRetries(0),
+		godns.WithMaxOutstanding(1),
+		godns.WithOnSaturated(func(ctx context.Context, ev godns.SaturationEvent) {
+			mu.Lock()
+			defer mu.Unlock()
+			events = append(events, ev)
+			order = append(order, ctx.Value(queueOrderKey{}).(int))
+		}),
+	)
+	defer c.Close()
+
+	var wg sync.WaitGroup
+	query := func(ctx context.Context, name string) {
+		defer wg.Done()
+		if _, err := c.QueryA(ctx, name); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	wg.Add(1)
+	go query(context.WithValue(context.Background(), queueOrderKey{}, -1), "holder.test")
+	waitFor(t, "holder to take the slot", func() bool { return c.OutstandingStats().InFlight == 1 })
+	// 逐个加入队列，确定到达顺序
+	for i := range queued {
+		wg.Add(1)
+		go query(context.WithValue(context.Background(), queueOrderKey{}, i), fmt.Sprintf("q%d.test", i))
+		waitFor(t, "query to queue", func() bool { return c.OutstandingStats().Waiting == i+1 })
+	}
+	wg.Wait()
+
+	want := make([]int, queued)
+	for i := range want {
+		want[i] = i
+	}
+	if !slices.Equal(order, want) {
+		t.Errorf("slots granted in order %v, want %v", order, want)
+	}
+	for i, ev := range events {
+		if ev.Err != nil || ev.Limit != 1 || ev.Wait <= 0 {
+			t.Errorf("event %d = %+v", i, ev)
+		}
+	}
+	if st := c.OutstandingStats(); st.Saturated != queued || st.InFlight != 0 || st.Waiting != 0 {
+		t.Errorf("stats = %+v", st)
+	}
+}
+
+// TestMaxOutstandingCancel 排队中的查询在 context 取消或排队超时后立即返回
+func TestMaxOutstandingCancel(t *testing.T) {
+	s := startServer(t)
+	slowName(s, "holder.test", 2*time.Second)
+
+	events := make(chan godns.SaturationEvent, 2)
+	c := godns.New(
+		godns.WithServers(s.UDPAddr),
+		godns.WithRetries(0),
+		godns.WithTimeout(5*time.Second),
+		godns.WithMaxOutstanding(1),
+		godns.WithQueueTimeout(time.Second),
+		godns.WithOnSaturated(func(_ context.Context, ev godns.SaturationEvent) { events <- ev }),
+	)
+	holderCtx, stopHolder := context.WithCancel(context.Background())
+	defer stopHolder()
+	go c.QueryA(holderCtx, "holder.test")
+	waitFor(t, "holder to take the slot", func() bool { return c.OutstandingStats().InFlight == 1 })
+	defer c.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.QueryA(ctx, "queued.test")
+		done <- err
+	}()
+	waitFor(t, "query to queue", func() bool { return c.OutstandingStats().Waiting == 1 })
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("cancelled query = %v, want context.Canceled", err)
+		}
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("cancelled query stayed blocked in the queue")
+	}
+	if ev := <-events; !errors.Is(ev.Err, context.Canceled) || ev.Waiting != 1 {
+		t.Errorf("cancel event = %+v", ev)
+	}
+
+	// 排队超时
+	start := time.Now()
+	if _, err := c.QueryA(context.Background(), "queued.test"); !errors.Is(err, godns.ErrClientSaturated) {
+		t.Errorf("timed out query = %v, want ErrClientSaturated", err)
+	}
+	if elapsed := time.Since(start); elapsed > 1500*time.Millisecond {
+		t.Errorf("queue timeout took %v", elapsed)
+	}
+	if ev := <-events; !errors.Is(ev.Err, godns.ErrClientSaturated) {
+		t.Errorf("timeout event = %+v", ev)
+	}
+	if st := c.OutstandingStats(); st.Rejected != 1 || st.Saturated != 2 || st.Waiting != 0 {
+		t.Errorf("stats = %+v", st)
+	}
+}
+
+// TestMaxOutstandingSharedWithChildren 路由规则和专用代理服务器的子客户端共用父客户端的名额，
+// 排队时间计入 QueryResult.Duration
+func TestMaxOutstandingSharedWithChildren(t *testing.T) {
+	s := startServer(t)
+	slowName(s, "holder.direct.test", 300*time.Millisecond)
+	slowName(s, "queued.test", 0)
+	proxy, err := testserver.StartSOCKS5("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer proxy.Close()
+
+	c := godns.New(
+		godns.WithProtocol(godns.TCP),
+		godns.WithServerInfos(godns.ServerInfo{Address: s.TCPAddr, Proxy: "socks5://" + proxy.Addr}),
+		godns.WithRouting(godns.RouteRule{Name: "direct", Suffix: "direct.test", Servers: []string{s.TCPAddr}}),
+		godns.WithRetries(0),
+		godns.WithMaxOutstanding(1),
+	)
+	defer c.Close()
+
+	holderDone := make(chan error, 1)
+	go func() {
+		_, err := c.QueryA(context.Background(), "holder.direct.test")
+		holderDone <- err
+	}()
+	waitFor(t, "routed query to take the slot", func() bool { return c.OutstandingStats().InFlight == 1 })
+
+	res, err := c.QueryA(context.Background(), "queued.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := <-holderDone; err != nil {
+		t.Fatal(err)
+	}
+	if targets := proxy.Targets(); len(targets) != 1 || targets[0] != s.TCPAddr {
+		t.Errorf("proxy targets = %v", targets)
+	}
+	if st := c.OutstandingStats(); st.Saturated != 1 {
+		t.Errorf("stats = %+v, want one saturation shared by both children", st)
+	}
+	wait := res.Path.Attempts[0].QueueWait
+	if wait < 200*time.Millisecond {
+		t.Errorf("queue wait = %v, want the holder's remaining time", wait)
+	}
+	if res.Duration < wait+res.Path.Attempts[0].RTT {
+		t.Errorf("duration = %v, want at least queue wait %v plus RTT %v", res.Duration, wait, res.Path.Attempts[0].RTT)
+	}
+}
@@ -0,0 +1,253 @@
+package godns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// errPipelineClosed 管道连接已关闭
+var errPipelineClosed = errors.New("pipelined connection closed")
+
+// pipeResult 管道连接上的单个响应
+type pipeResult struct {
+	response *dns.Msg
+	err      error
+}
+
+// pipeConn 支持在单个TCP/DoT连接上并发发送多个查询，并按消息ID分发响应
+type pipeConn struct {
+	conn  *dns.Conn
+	newID func() uint16
+
+	wmu sync.Mutex // 保护写操作
+
+	mu      sync.Mutex
+	pending map[uint16]chan pipeResult
+	err     error
+}
+
+func newPipeConn(conn *dns.Conn, newID func() uint16) *pipeConn {
+	p := &pipeConn{
+		conn:    conn,
+		newID:   newID,
+		pending: make(map[uint16]chan pipeResult),
+	}
+	go p.readLoop()
+	return p
+}
+
+// exchange 在管道连接上发送查询并等待ID匹配的响应
+func (p *pipeConn) exchange(ctx context.Context, msg *dns.Msg) (*dns.Msg, error) {
+	ch := make(chan pipeResult, 1)
+
+	// 分配连接内唯一的消息ID，避免与其他在途查询冲突
+	p.mu.Lock()
+	if p.err != nil {
+		p.mu.Unlock()
+		return nil, p.err
+	}
+	id := msg.Id
+	for {
+		if _, used := p.pending[id]; !used {
+			break
+		}
+		id = p.newID()
+	}
+	p.pending[id] = ch
+	p.mu.Unlock()
+
+	req := msg
+	if id != msg.Id {
+		req = msg.Copy()
+		req.Id = id
+	}
+
+	// 写入使用本次查询的截止时间，写完后清除，避免影响连接上的后续查询
+	p.wmu.Lock()
+	if deadline, ok := ctx.Deadline(); ok {
+		p.conn.SetWriteDeadline(deadline)
+	}
+	err := p.conn.WriteMsg(req)
+	p.conn.SetWriteDeadline(time.Time{})
+	p.wmu.Unlock()
+	if err != nil {
+		p.fail(fmt.Errorf("failed to write DNS message: %v", err))
+		return nil, err
+	}
+
+	select {
+	case res := <-ch:
+		if res.response != nil {
+			res.response.Id = msg.Id
+			setRespondedBy(ctx, p.conn.RemoteAddr())
+		}
+		return res.response, res.err
+	case <-ctx.Done():
+		p.mu.Lock()
+		delete(p.pending, id)
+		p.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+// readLoop 持续读取响应并按ID分发给等待者
+func (p *pipeConn) readLoop() {
+	for {
+		response, err := p.conn.ReadMsg()
+		if err != nil {
+			p.fail(fmt.Errorf("failed to read DNS response: %w", err))
+			return
+		}
+
+		p.mu.Lock()
+		ch, ok := p.pending[response.Id]
+		if ok {
+			delete(p.pending, response.Id)
+		}
+		p.mu.Unlock()
+
+		// 未知ID的响应（例如调用方已放弃）直接丢弃
+		if ok {
+			ch <- pipeResult{response: response}
+		}
+	}
+}
+
+// fail 关闭连接并通知所有在途查询
+func (p *pipeConn) fail(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return
+	}
+	p.err = err
+	p.conn.Close()
+	for id, ch := range p.pending {
+		ch <- pipeResult{err: err}
+		delete(p.pending, id)
+	}
+}
+
+// broken 连接是否已不可用
+func (p *pipeConn) broken() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err != nil
+}
+
+// pipelinePool 按服务器维护管道连接
+type pipelinePool struct {
+	mu      sync.Mutex
+	conns   map[string]*pipeConn
+	dialing map[string]*pipeDial // 进行中的拨号，同一服务器的并发查询共用
+	closed  bool
+	limit   int           // 应答字节数上限
+	newID   func() uint16 // 消息ID冲突时生成新ID
+}
+
+// pipeDial 进行中的一次拨号，done 关闭后 p 和 err 可读
+type pipeDial struct {
+	done chan struct{}
+	p    *pipeConn
+	err  error
+}
+
+func newPipelinePool(limit int, newID func() uint16) *pipelinePool {
+	return &pipelinePool{
+		conns:   make(map[string]*pipeConn),
+		dialing: make(map[string]*pipeDial),
+		limit:   limit,
+		newID:   newID,
+	}
+}
+
+// get 获取或建立到指定服务器的管道连接
+// 拨号（含DoT握手）在锁外进行，慢服务器不会阻塞其他服务器的查询；同一服务器的并发查询等待同一次拨号
+func (pp *pipelinePool) get(ctx context.Context, client *dns.Client, server string) (*pipeConn, error) {
+	key := client.Net + "|" + server
+	for {
+		pp.mu.Lock()
+		if pp.closed {
+			pp.mu.Unlock()
+			return nil, errPipelineClosed
+		}
+		if p, ok := pp.conns[key]; ok && !p.broken() {
+			pp.mu.Unlock()
+			return p, nil
+		}
+		d, waiting := pp.dialing[key]
+		if !waiting {
+			d = &pipeDial{done: make(chan struct{})}
+			pp.dialing[key] = d
+		}
+		pp.mu.Unlock()
+
+		if !waiting {
+			return pp.dial(ctx, client, server, key, d)
+		}
+		select {
+		case <-d.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		// 发起拨号的查询被取消时自行重新拨号，其余错误直接共用
+		if d.err != nil && !errors.Is(d.err, context.Canceled) && !errors.Is(d.err, context.DeadlineExceeded) {
+			return nil, d.err
+		}
+	}
+}
+
+// dial 建立管道连接并通知等待同一次拨号的查询
+func (pp *pipelinePool) dial(ctx context.Context, client *dns.Client, server, key string, d *pipeDial) (*pipeConn, error) {
+	conn, err := client.DialContext(ctx, server)
+	if err == nil {
+		conn.Conn = newLimitConn(conn.Conn, pp.limit)
+		d.p = newPipeConn(conn, pp.newID)
+	}
+	d.err = err
+
+	pp.mu.Lock()
+	delete(pp.dialing, key)
+	if d.p != nil {
+		if pp.closed {
+			d.p.fail(errPipelineClosed)
+			d.p, d.err = nil, errPipelineClosed
+		} else {
+			pp.conns[key] = d.p
+		}
+	}
+	pp.mu.Unlock()
+	close(d.done)
+	return d.p, d.err
+}
+
+// exchange 通过管道连接发送查询
+func (pp *pipelinePool) exchange(ctx context.Context, client *dns.Client, msg *dns.Msg, server string) (*dns.Msg, error) {
+	p, err := pp.get(ctx, client, server)
+	if err != nil {
+		return nil, err
+	}
+
+	if client.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, client.Timeout)
+		defer cancel()
+	}
+	return p.exchange(ctx, msg)
+}
+
+// close 关闭所有管道连接
+func (pp *pipelinePool) close() {
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+	pp.closed = true
+	for key, p := range pp.conns {
+		p.fail(errPipelineClosed)
+		delete(pp.conns, key)
+	}
+}
@@ -0,0 +1,173 @@
+package godns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns/internal/testserver"
+)
+
+// reverseResponder 在一个TCP连接上读满 batch 个查询后按相反顺序应答，
+// 每个应答的A记录取查询名称中的序号，用于验证管道连接按消息ID分发乱序的应答
+type reverseResponder struct {
+	ln      net.Listener
+	batch   int
+	accepts atomic.Int32
+}
+
+func startReverseResponder(t *testing.T, batch int) *reverseResponder {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &reverseResponder{ln: ln, batch: batch}
+	t.Cleanup(func() { ln.Close() })
+	go r.serve()
+	return r
+}
+
+func (r *reverseResponder) serve() {
+	for {
+		conn, err := r.ln.Accept()
+		if err != nil {
+			return
+		}
+		r.accepts.Add(1)
+		go func() {
+			defer conn.Close()
+			dc := &dns.Conn{Conn: conn}
+			var queries []*dns.Msg
+			for len(queries) < r.batch {
+				q, err := dc.ReadMsg()
+				if err != nil {
+					return
+				}
+				queries = append(queries, q)
+			}
+			for i := len(queries) - 1; i >= 0; i-- {
+				q := queries[i]
+				m := new(dns.Msg)
+				m.SetReply(q)
+				var n int
+				fmt.Sscanf(q.Question[0].Name, "q%d.", &n)
+				m.Answer = []dns.RR{&dns.A{
+					Hdr: dns.RR_Header{Name: q.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60},
+					A:   net.IPv4(192, 0, 2, byte(n)),
+				}}
+				if err := dc.WriteMsg(m); err != nil {
+					return
+				}
+			}
+		}()
+	}
+}
+
+func TestPipelineOutOfOrderResponses(t *testing.T) {
+	const n = 8
+	r := startReverseResponder(t, n)
+	c := New(WithServers(r.ln.Addr().String()), WithProtocol(TCP), WithPipelining(), WithRetries(0), WithTimeout(5*time.Second))
+	defer c.Close()
+
+	var wg sync.WaitGroup
+	errs := make([]error, n)
+	for i := 1; i <= n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			name := fmt.Sprintf("q%d.example", i)
+			res, err := c.QueryA(context.Background(), name)
+			if err != nil {
+				errs[i-1] = err
+				return
+			}
+			if want := fmt.Sprintf("192.0.2.%d", i); len(res.Records) != 1 || res.Records[0].Value() != want {
+				errs[i-1] = fmt.Errorf("%s: records = %v, want %s", name, res.Records, want)
+			}
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	// 所有并发查询共用一次拨号建立的连接
+	if got := r.accepts.Load(); got != 1 {
+		t.Errorf("responder accepted %d connections, want 1", got)
+	}
+}
+
+// TestPipelineDialOutsideLock 一个服务器的DoT握手挂起时，到其他服务器的管道查询不受影响
+func TestPipelineDialOutsideLock(t *testing.T) {
+	blackhole, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer blackhole.Close()
+	var held []net.Conn
+	var mu sync.Mutex
+	go func() {
+		for {
+			conn, err := blackhole.Accept()
+			if err != nil {
+				return
+			}
+			// 接受连接但从不完成TLS握手
+			mu.Lock()
+			held = append(held, conn)
+			mu.Unlock()
+		}
+	}()
+	defer func() {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, conn := range held {
+			conn.Close()
+		}
+	}()
+
+	s, err := testserver.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.Answer("example.com", dns.TypeA, "example.com. 60 IN A 192.0.2.1")
+
+	c := New(
+		WithServers(blackhole.Addr().String(), s.DoTAddr),
+		WithProtocol(DoT),
+		WithTLSConfig(s.ClientTLSConfig()),
+		WithPipelining(),
+		WithRetries(0),
+		WithTimeout(3*time.Second),
+	)
+	defer c.Close()
+
+	stuck := make(chan struct{})
+	go func() {
+		defer close(stuck)
+		c.QueryA(ContextWithServers(context.Background(), blackhole.Addr().String()), "example.com")
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	start := time.Now()
+	res, err := c.QueryA(ContextWithServers(context.Background(), s.DoTAddr), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("query to a healthy server took %v while another dial was pending", elapsed)
+	}
+	if res.Records[0].Value() != "192.0.2.1" {
+		t.Errorf("records = %v", res.Records)
+	}
+	c.Close()
+	<-stuck
+}
@@ -0,0 +1,79 @@
+package godns
+
+import "context"
+
+// RetryPlacement 重试时选择服务器的策略
+type RetryPlacement int
+
+const (
+	// SameServer 每次重试都使用同一服务器，失败后再由 MaxForwarders 转向下一服务器
+	SameServer RetryPlacement = iota
+	// NextServer 每次重试轮换到服务器列表中的下一个服务器
+	NextServer
+	// SpreadThenRepeat 先在每个服务器上各尝试一次（即使超出重试次数），剩余的重试回到首个服务器
+	SpreadThenRepeat
+)
+
+// String 返回策略名称
+func (p RetryPlacement) String() string {
+	switch p {
+	case NextServer:
+		return "next-server"
+	case SpreadThenRepeat:
+		return "spread-then-repeat"
+	default:
+		return "same-server"
+	}
+}
+
+// WithRetryPlacement 设置重试时的服务器选择策略，非 SameServer 策略下 Query 在配置的全部服务器间轮换重试，
+// 用服务器的多样性而不是重复请求来消化单个服务器的故障
+func WithRetryPlacement(p RetryPlacement) Option {
+	return func(c *Config) {
+		c.RetryPlacement = p
+	}
+}
+
+// retryServersKey 可供重试轮换的服务器列表
+type retryServersKey struct{}
+
+// withRetryServers 返回携带轮换服务器列表的 context，列表首项为首次尝试的服务器
+func withRetryServers(ctx context.Context, servers []string) context.Context {
+	return context.WithValue(ctx, retryServersKey{}, servers)
+}
+
+// retryServers 返回从 server 开始的重试候选服务器，仅当 server 是轮换列表的首项时轮换，
+// 例如协议回退使用的转换地址不参与轮换
+func (c *Client) retryServers(ctx context.Context, server string) []string {
+	if c.config.RetryPlacement == SameServer {
+		return []string{server}
+	}
+	servers, ok := ctx.Value(retryServersKey{}).([]string)
+	if !ok || len(servers) == 0 || servers[0] != server {
+		return []string{server}
+	}
+	return servers
+}
+
+// attemptPlan 返回总尝试次数，以及第 attempt 次尝试（从0开始）使用的服务器序号
+func (c *Client) attemptPlan(servers int) (attempts int, pick func(attempt int) int) {
+	attempts = c.config.Retries + 1
+	switch {
+	case servers <= 1:
+		return attempts, func(int) int { return 0 }
+	case c.config.RetryPlacement == NextServer:
+		return attempts, func(attempt int) int { return attempt % servers }
+	case c.config.RetryPlacement == SpreadThenRepeat:
+		if attempts < servers {
+			attempts = servers
+		}
+		return attempts, func(attempt int) int {
+			if attempt < servers {
+				return attempt
+			}
+			return 0
+		}
+	default:
+		return attempts, func(int) int { return 0 }
+	}
+}
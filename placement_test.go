@@ -0,0 +1,75 @@
+package godns_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+	"github.com/zan8in/godns/internal/testserver"
+)
+
+// TestRetryPlacement 一个不应答的服务器和一个正常服务器：NextServer 第二次尝试即成功，
+// SameServer 在不应答的服务器上耗尽重试次数，尝试记录逐次给出实际使用的服务器
+func TestRetryPlacement(t *testing.T) {
+	dead, live := startServer(t), startServer(t)
+
+	tests := []struct {
+		placement godns.RetryPlacement
+		retries   int
+		servers   []string // 各次尝试使用的服务器
+		ok        bool
+	}{
+		{godns.SameServer, 2, []string{dead.UDPAddr, dead.UDPAddr, dead.UDPAddr}, false},
+		{godns.NextServer, 2, []string{dead.UDPAddr, live.UDPAddr}, true},
+		// 重试次数少于服务器数时仍在每个服务器上各尝试一次
+		{godns.SpreadThenRepeat, 0, []string{dead.UDPAddr, live.UDPAddr}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.placement.String(), func(t *testing.T) {
+			dead.Reset()
+			live.Reset()
+			dead.Handle("www.test", dns.TypeA, testserver.Reply{Drop: true})
+			live.Answer("www.test", dns.TypeA, "www.test. 60 IN A 192.0.2.1")
+			c := godns.New(
+				godns.WithServers(dead.UDPAddr, live.UDPAddr),
+				godns.WithTimeout(100*time.Millisecond),
+				godns.WithRetries(tt.retries),
+				godns.WithRetryPlacement(tt.placement),
+			)
+			defer c.Close()
+
+			res, err := c.QueryA(context.Background(), "www.test")
+			if tt.ok {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if res.Server != live.UDPAddr || len(res.Records) != 1 {
+					t.Errorf("server = %s, records = %v", res.Server, res.Records)
+				}
+			} else if !errors.Is(err, godns.ErrTimeout) {
+				t.Fatalf("err = %v, want ErrTimeout", err)
+			}
+
+			var servers []string
+			for i, a := range res.Path.Attempts {
+				if a.Number != i+1 {
+					t.Errorf("attempt %d numbered %d", i+1, a.Number)
+				}
+				if failed := i < len(res.Path.Attempts)-1 || !tt.ok; failed == (a.Error == "") {
+					t.Errorf("attempt %d: error = %q", a.Number, a.Error)
+				}
+				servers = append(servers, a.Server)
+			}
+			if fmt.Sprint(servers) != fmt.Sprint(tt.servers) {
+				t.Errorf("attempt servers = %v, want %v", servers, tt.servers)
+			}
+			if n := len(live.Queries()); tt.ok != (n == 1) {
+				t.Errorf("healthy server saw %d queries", n)
+			}
+		})
+	}
+}
@@ -0,0 +1,231 @@
+package godns
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// ProfileQuery 域名画像中的一项查询
+type ProfileQuery struct {
+	Prefix string // 拼接在域名前的标签，例如 "_dmarc."，为空表示域名本身
+	Type   uint16
+}
+
+// Name 返回该项查询针对 domain 的完整名称
+func (q ProfileQuery) Name(domain string) string {
+	return q.Prefix + domain
+}
+
+// key 返回该项查询在 DomainProfile.Results 中的键
+func (q ProfileQuery) key() string {
+	return q.Prefix + dns.TypeToString[q.Type]
+}
+
+// DefaultProfileQueries 默认的域名画像查询集合
+var DefaultProfileQueries = []ProfileQuery{
+	{Type: dns.TypeA},
+	{Type: dns.TypeAAAA},
+	{Type: dns.TypeCNAME},
+	{Type: dns.TypeMX},
+	{Type: dns.TypeTXT},
+	{Type: dns.TypeNS},
+	{Type: dns.TypeSOA},
+	{Type: dns.TypeCAA},
+	{Type: dns.TypeDNSKEY},
+	{Type: dns.TypeHTTPS},
+	{Prefix: "_dmarc.", Type: dns.TypeTXT},
+	{Prefix: "_sip._tcp.", Type: dns.TypeSRV},
+	{Prefix: "_xmpp-server._tcp.", Type: dns.TypeSRV},
+	{Prefix: "_autodiscover._tcp.", Type: dns.TypeSRV},
+}
+
+// profileConfig 域名画像配置
+type profileConfig struct {
+	queries     []ProfileQuery
+	concurrency int
+	timeout     time.Duration // 整体截止时间
+	perQuery    time.Duration // 每项查询的时间预算
+}
+
+// ProfileOption 域名画像配置选项
+type ProfileOption func(*profileConfig)
+
+// WithProfileQueries 设置要查询的类型集合
+func WithProfileQueries(queries ...ProfileQuery) ProfileOption {
+	return func(p *profileConfig) {
+		p.queries = queries
+	}
+}
+
+// WithProfileConcurrency 设置并发查询数量上限
+func WithProfileConcurrency(n int) ProfileOption {
+	return func(p *profileConfig) {
+		p.concurrency = n
+	}
+}
+
+// WithProfileTimeout 设置整体截止时间和每项查询的时间预算
+func WithProfileTimeout(overall, perQuery time.Duration) ProfileOption {
+	return func(p *profileConfig) {
+		p.timeout = overall
+		p.perQuery = perQuery
+	}
+}
+
+// DomainProfile 域名记录覆盖情况及安全配置概览
+type DomainProfile struct {
+	Domain string
+
+	Present map[string]bool         // 各项查询是否存在记录，键如 "MX"、"_dmarc.TXT"
+	Results map[string]*QueryResult // 各项查询的原始结果
+
+	SPF           string // SPF 记录内容，为空表示未发布
+	DMARCPolicy   string // DMARC 策略（none/quarantine/reject），为空表示未发布
+	CAARestricted bool   // 是否存在限制证书签发的 CAA 记录
+	DNSSECSigned  bool   // 是否发布了 DNSKEY
+}
+
+// Profile 并发查询一组常见记录类型，汇总域名的记录覆盖情况和安全配置
+func (c *Client) Profile(ctx context.Context, domain string, opts ...ProfileOption) (*DomainProfile, error) {
+	cfg := &profileConfig{
+		queries:     DefaultProfileQueries,
+		concurrency: 4,
+		perQuery:    c.config.Timeout,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if cfg.concurrency <= 0 {
+		cfg.concurrency = 1
+	}
+
+	if cfg.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.timeout)
+		defer cancel()
+	}
+
+	// 画像查询包含 _dmarc、_sip._tcp 等服务标签
+	ctx = c.relaxNamePolicy(ctx, ServiceLabels)
+	domain = strings.TrimSuffix(domain, ".")
+	profile := &DomainProfile{
+		Domain:  domain,
+		Present: make(map[string]bool, len(cfg.queries)),
+		Results: make(map[string]*QueryResult, len(cfg.queries)),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, cfg.concurrency)
+
+	for _, q := range cfg.queries {
+		wg.Add(1)
+		go func(q ProfileQuery) {
+			defer wg.Done()
+
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				mu.Lock()
+				profile.Results[q.key()] = &QueryResult{
+					Domain: q.Name(domain),
+					Type:   q.Type,
+					Error:  c.newErrorInfo(ctx.Err(), "", 0),
+				}
+				mu.Unlock()
+				return
+			}
+
+			qctx := ctx
+			if cfg.perQuery > 0 {
+				var cancel context.CancelFunc
+				qctx, cancel = context.WithTimeout(ctx, cfg.perQuery)
+				defer cancel()
+			}
+
+			res, err := c.Query(qctx, q.Name(domain), q.Type)
+			if res == nil {
+				res = &QueryResult{Domain: q.Name(domain), Type: q.Type, Error: c.newErrorInfo(err, "", 0)}
+			}
+
+			mu.Lock()
+			profile.Results[q.key()] = res
+			profile.Present[q.key()] = hasRecordType(res, q.Type)
+			mu.Unlock()
+		}(q)
+	}
+	wg.Wait()
+
+	profile.summarize()
+	// 套接字读超时与截止时间相同，最后一项失败时 context 的定时器可能尚未触发
+	if ctx.Err() == nil && pastDeadline(ctx) {
+		return profile, context.DeadlineExceeded
+	}
+	return profile, ctx.Err()
+}
+
+// hasRecordType 结果中是否包含指定类型的记录
+func hasRecordType(res *QueryResult, qtype uint16) bool {
+	for _, record := range res.Records {
+		if record.Type == qtype {
+			return true
+		}
+	}
+	return false
+}
+
+// summarize 根据原始结果计算安全配置概览
+func (p *DomainProfile) summarize() {
+	if res := p.Results["TXT"]; res != nil {
+		for _, record := range res.Records {
+			if txt, ok := record.RR().(*dns.TXT); ok {
+				value := strings.Join(txt.Txt, "")
+				if strings.HasPrefix(strings.ToLower(value), "v=spf1") {
+					p.SPF = value
+					break
+				}
+			}
+		}
+	}
+
+	if res := p.Results["_dmarc.TXT"]; res != nil {
+		for _, record := range res.Records {
+			if txt, ok := record.RR().(*dns.TXT); ok {
+				if policy := dmarcPolicy(strings.Join(txt.Txt, "")); policy != "" {
+					p.DMARCPolicy = policy
+					break
+				}
+			}
+		}
+	}
+
+	if res := p.Results["CAA"]; res != nil {
+		for _, record := range res.Records {
+			if caa, ok := record.RR().(*dns.CAA); ok && (caa.Tag == "issue" || caa.Tag == "issuewild") {
+				p.CAARestricted = true
+				break
+			}
+		}
+	}
+
+	p.DNSSECSigned = p.Present["DNSKEY"]
+}
+
+// dmarcPolicy 解析 DMARC 记录中的 p= 策略
+func dmarcPolicy(record string) string {
+	if !strings.HasPrefix(strings.ToLower(record), "v=dmarc1") {
+		return ""
+	}
+	for _, tag := range strings.Split(record, ";") {
+		k, v, ok := strings.Cut(strings.TrimSpace(tag), "=")
+		if ok && strings.EqualFold(strings.TrimSpace(k), "p") {
+			return strings.ToLower(strings.TrimSpace(v))
+		}
+	}
+	return ""
+}
@@ -0,0 +1,188 @@
+package godns_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+	"github.com/zan8in/godns/internal/testserver"
+)
+
+// TestProfileDefaults 默认查询集合覆盖每一项，汇总 SPF、DMARC、CAA 和 DNSSEC 状态，原始结果可逐项查看
+func TestProfileDefaults(t *testing.T) {
+	s := startServer(t)
+	s.Answer("example.test", dns.TypeA, "example.test. 300 IN A 192.0.2.1")
+	s.Answer("example.test", dns.TypeMX, "example.test. 300 IN MX 10 mx.example.test.")
+	s.Answer("example.test", dns.TypeTXT,
+		`example.test. 300 IN TXT "google-site-verification=abc"`,
+		`example.test. 300 IN TXT "v=spf1 include:_spf.example.net " "-all"`,
+	)
+	s.Answer("example.test", dns.TypeCAA,
+		`example.test. 300 IN CAA 0 iodef "mailto:security@example.test"`,
+		`example.test. 300 IN CAA 0 issue "letsencrypt.org"`,
+	)
+	s.Answer("example.test", dns.TypeDNSKEY, "example.test. 300 IN DNSKEY 257 3 13 mdsswUyr3DPW132mOi8V9xESWE8jTo0dxCjjnopKl+GqJxpVXckHAeF+KkxLbxILfDLUT0rAK9iUzy1L53eKGQ==")
+	s.Answer("_dmarc.example.test", dns.TypeTXT, `_dmarc.example.test. 300 IN TXT "v=DMARC1; p=Reject; rua=mailto:d@example.test"`)
+	s.Answer("_sip._tcp.example.test", dns.TypeSRV, "_sip._tcp.example.test. 300 IN SRV 10 10 5060 sip.example.test.")
+	c := godns.New(godns.WithServers(s.UDPAddr), godns.WithRetries(0))
+	defer c.Close()
+
+	p, err := c.Profile(context.Background(), "example.test.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Domain != "example.test" {
+		t.Errorf("Domain = %q", p.Domain)
+	}
+	if len(p.Results) != len(godns.DefaultProfileQueries) {
+		t.Errorf("got %d results, want one per default query (%d)", len(p.Results), len(godns.DefaultProfileQueries))
+	}
+	present := map[string]bool{"A": true, "MX": true, "TXT": true, "CAA": true, "DNSKEY": true, "_dmarc.TXT": true, "_sip._tcp.SRV": true}
+	for _, q := range godns.DefaultProfileQueries {
+		key := q.Prefix + dns.TypeToString[q.Type]
+		if p.Results[key] == nil {
+			t.Errorf("no result for %s", key)
+			continue
+		}
+		if p.Present[key] != present[key] {
+			t.Errorf("Present[%s] = %v, want %v", key, p.Present[key], present[key])
+		}
+	}
+	if p.SPF != "v=spf1 include:_spf.example.net -all" {
+		t.Errorf("SPF = %q", p.SPF)
+	}
+	if p.DMARCPolicy != "reject" || !p.CAARestricted || !p.DNSSECSigned {
+		t.Errorf("DMARC %q, CAA restricted %v, DNSSEC %v", p.DMARCPolicy, p.CAARestricted, p.DNSSECSigned)
+	}
+}
+
+// TestProfileNothingPublished 未发布任何安全相关记录时各项概览均为空，CAA 仅有 iodef 不算限制签发
+func TestProfileNothingPublished(t *testing.T) {
+	s := startServer(t)
+	s.Answer("bare.test", dns.TypeA, "bare.test. 300 IN A 192.0.2.1")
+	s.Answer("bare.test", dns.TypeCAA, `bare.test. 300 IN CAA 0 iodef "mailto:security@bare.test"`)
+	s.Answer("_dmarc.bare.test", dns.TypeTXT, `_dmarc.bare.test. 300 IN TXT "not a dmarc record"`)
+	c := godns.New(godns.WithServers(s.UDPAddr), godns.WithRetries(0))
+	defer c.Close()
+
+	p, err := c.Profile(context.Background(), "bare.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.SPF != "" || p.DMARCPolicy != "" || p.CAARestricted || p.DNSSECSigned {
+		t.Errorf("profile = %+v, want no security posture", p)
+	}
+	if !p.Present["A"] || p.Present["MX"] {
+		t.Errorf("Present = %v", p.Present)
+	}
+}
+
+// TestProfileCustomQueries 自定义查询集合只查询指定的项，并发数不超过上限
+func TestProfileCustomQueries(t *testing.T) {
+	var inFlight, peak atomic.Int32
+	var names []string
+	queries := make(chan string, 16)
+	transport := transportFunc(func(ctx context.Context, msg *dns.Msg, server string) (*dns.Msg, error) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		queries <- msg.Question[0].Name + " " + dns.TypeToString[msg.Question[0].Qtype]
+		time.Sleep(20 * time.Millisecond)
+		reply := new(dns.Msg)
+		reply.SetReply(msg)
+		return reply, nil
+	})
+	c := godns.New(godns.WithServers("192.0.2.53:53"), godns.WithTransport(transport), godns.WithRetries(0))
+	defer c.Close()
+
+	custom := []godns.ProfileQuery{
+		{Type: dns.TypeA},
+		{Type: dns.TypeAAAA},
+		{Type: dns.TypeMX},
+		{Prefix: "_mta-sts.", Type: dns.TypeTXT},
+		{Prefix: "_smtp._tls.", Type: dns.TypeTXT},
+	}
+	p, err := c.Profile(context.Background(), "example.test",
+		godns.WithProfileQueries(custom...),
+		godns.WithProfileConcurrency(2),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	close(queries)
+	for q := range queries {
+		names = append(names, q)
+	}
+	if len(names) != len(custom) || len(p.Results) != len(custom) {
+		t.Errorf("queried %v, got %d results; want exactly the %d custom queries", names, len(p.Results), len(custom))
+	}
+	for _, key := range []string{"A", "AAAA", "MX", "_mta-sts.TXT", "_smtp._tls.TXT"} {
+		if p.Results[key] == nil {
+			t.Errorf("no result for %s", key)
+		}
+	}
+	if n := peak.Load(); n > 2 {
+		t.Errorf("peak concurrency = %d, want at most 2", n)
+	}
+}
+
+// TestProfileDeadlines 单项查询超出预算时只影响该项；整体截止时间到达后返回已有结果和上下文错误，
+// 每一项都有结果，未来得及查询的项以错误结果占位
+func TestProfileDeadlines(t *testing.T) {
+	s := startServer(t)
+	s.Answer("example.test", dns.TypeA, "example.test. 300 IN A 192.0.2.1")
+	s.Handle("example.test", dns.TypeMX, testserver.Reply{Drop: true})
+	c := godns.New(godns.WithServers(s.UDPAddr), godns.WithRetries(0), godns.WithTimeout(5*time.Second))
+	defer c.Close()
+
+	t.Run("per-query", func(t *testing.T) {
+		start := time.Now()
+		p, err := c.Profile(context.Background(), "example.test",
+			godns.WithProfileQueries(godns.ProfileQuery{Type: dns.TypeA}, godns.ProfileQuery{Type: dns.TypeMX}),
+			godns.WithProfileTimeout(0, 100*time.Millisecond),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("profile took %v, want the MX query cut at its budget", elapsed)
+		}
+		if !p.Present["A"] || p.Results["MX"].Error == nil {
+			t.Errorf("A present %v, MX error %v; want only MX to fail", p.Present["A"], p.Results["MX"].Error)
+		}
+	})
+
+	t.Run("overall", func(t *testing.T) {
+		start := time.Now()
+		p, err := c.Profile(context.Background(), "example.test",
+			godns.WithProfileQueries(godns.ProfileQuery{Type: dns.TypeMX}, godns.ProfileQuery{Type: dns.TypeA}),
+			godns.WithProfileConcurrency(1),
+			godns.WithProfileTimeout(150*time.Millisecond, 0),
+		)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("err = %v, want the overall deadline", err)
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("profile took %v, want it to stop at the overall deadline", elapsed)
+		}
+		if len(p.Results) != 2 {
+			t.Fatalf("results = %v, want a placeholder for each query", p.Results)
+		}
+		// A 可能在 MX 占用唯一的并发名额之前完成，也可能在截止时间后才轮到而以错误占位
+		if res := p.Results["MX"]; res == nil || res.Error == nil || p.Present["MX"] {
+			t.Errorf("MX: result %+v, want a failed result", res)
+		}
+		if res := p.Results["A"]; res == nil || (res.Error == nil) != p.Present["A"] {
+			t.Errorf("A: result %+v, present %v", res, p.Present["A"])
+		}
+	})
+}
@@ -0,0 +1,142 @@
+package godns_test
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+)
+
+// TestQueryPTR IPv4/IPv6 地址转换为反向解析名称后查询PTR记录
+func TestQueryPTR(t *testing.T) {
+	tests := []struct {
+		ip, arpa string
+	}{
+		{"192.0.2.10", "10.2.0.192.in-addr.arpa."},
+		{"2001:db8::1", "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			s := startServer(t)
+			s.Answer(tt.arpa, dns.TypePTR, tt.arpa+" 300 IN PTR host.example.test.")
+			c := godns.New(godns.WithServers(s.UDPAddr), godns.WithRetries(0))
+			defer c.Close()
+
+			res, err := c.QueryPTR(context.Background(), tt.ip)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Domain != tt.arpa {
+				t.Errorf("Domain = %q, want %q", res.Domain, tt.arpa)
+			}
+			if len(res.Records) != 1 || res.Records[0].Value() != "host.example.test" {
+				t.Errorf("records = %v, want host.example.test", res.Records)
+			}
+			if q := s.Queries(); len(q) != 1 || q[0].Msg.Question[0].Name != tt.arpa || q[0].Msg.Question[0].Qtype != dns.TypePTR {
+				t.Errorf("queries = %v, want one PTR query for %s", q, tt.arpa)
+			}
+		})
+	}
+}
+
+// TestQueryPTRInvalidIP 非法的IP地址返回错误，不发出查询
+func TestQueryPTRInvalidIP(t *testing.T) {
+	s := startServer(t)
+	c := godns.New(godns.WithServers(s.UDPAddr), godns.WithRetries(0))
+	defer c.Close()
+
+	for _, ip := range []string{"", "example.test", "192.0.2", "192.0.2.256", "2001:db8::g"} {
+		if _, err := c.QueryPTR(context.Background(), ip); err == nil || !strings.Contains(err.Error(), "invalid IP address") {
+			t.Errorf("QueryPTR(%q) err = %v, want invalid IP address", ip, err)
+		}
+		if _, err := c.MultiQueryPTR(context.Background(), ip); err == nil {
+			t.Errorf("MultiQueryPTR(%q) succeeded", ip)
+		}
+	}
+	if n := len(s.Queries()); n != 0 {
+		t.Errorf("server saw %d queries, want none", n)
+	}
+}
+
+// TestMultiQueryPTR 多服务器交叉核对PTR记录
+func TestMultiQueryPTR(t *testing.T) {
+	const arpa = "10.2.0.192.in-addr.arpa."
+	a, b := startServer(t), startServer(t)
+	a.Answer(arpa, dns.TypePTR, arpa+" 300 IN PTR host.example.test.")
+	b.Answer(arpa, dns.TypePTR, arpa+" 300 IN PTR stale.example.test.")
+	c := godns.New(godns.WithServers(a.UDPAddr, b.UDPAddr), godns.WithRetries(0))
+	defer c.Close()
+
+	res, err := c.MultiQueryPTR(context.Background(), "192.0.2.10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{a.UDPAddr: "host.example.test", b.UDPAddr: "stale.example.test"}
+	if len(res.Results) != len(want) {
+		t.Fatalf("got %d results, want %d", len(res.Results), len(want))
+	}
+	for _, r := range res.Results {
+		if r.Error != nil || len(r.Records) != 1 || r.Records[0].Value() != want[r.Server] {
+			t.Errorf("%s: records %v, err %v; want %s", r.Server, r.Records, r.Error, want[r.Server])
+		}
+	}
+}
+
+// TestQueryPTRZonesAndNetIP IPv6 区域后缀被忽略，IPv4 映射的 IPv6 地址按 IPv4 处理，
+// net.IP 与字符串形式得到相同的查询
+func TestQueryPTRZonesAndNetIP(t *testing.T) {
+	const (
+		v4 = "10.2.0.192.in-addr.arpa."
+		v6 = "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.e.f.ip6.arpa."
+	)
+	s := startServer(t)
+	s.Answer(v4, dns.TypePTR, v4+" 300 IN PTR host.example.test.")
+	s.Answer(v6, dns.TypePTR, v6+" 300 IN PTR link.example.test.")
+	c := godns.New(godns.WithServers(s.UDPAddr), godns.WithRetries(0))
+	defer c.Close()
+	ctx := context.Background()
+
+	queries := map[string]func() (*godns.QueryResult, error){
+		"zone":       func() (*godns.QueryResult, error) { return c.QueryPTR(ctx, "fe80::1%eth0") },
+		"mapped":     func() (*godns.QueryResult, error) { return c.QueryPTR(ctx, "::ffff:192.0.2.10") },
+		"net.IP v4":  func() (*godns.QueryResult, error) { return c.QueryPTRIP(ctx, net.ParseIP("192.0.2.10")) },
+		"net.IP v6":  func() (*godns.QueryResult, error) { return c.QueryPTRIP(ctx, net.ParseIP("fe80::1")) },
+		"multi zone": func() (*godns.QueryResult, error) { return firstResult(c.MultiQueryPTR(ctx, "fe80::1%eth0")) },
+	}
+	want := map[string]string{
+		"zone":       "link.example.test",
+		"mapped":     "host.example.test",
+		"net.IP v4":  "host.example.test",
+		"net.IP v6":  "link.example.test",
+		"multi zone": "link.example.test",
+	}
+	for name, query := range queries {
+		res, err := query()
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if len(res.Records) != 1 || res.Records[0].Value() != want[name] {
+			t.Errorf("%s: records %v, want %s", name, res.Records, want[name])
+		}
+	}
+
+	if _, err := c.QueryPTRIP(ctx, nil); err == nil {
+		t.Error("QueryPTRIP(nil) succeeded")
+	}
+}
+
+// firstResult 取多服务器查询中唯一服务器的结果
+func firstResult(res *godns.MultiQueryResult, err error) (*godns.QueryResult, error) {
+	if err != nil {
+		return nil, err
+	}
+	r := res.Results[0]
+	if r.Error != nil {
+		return nil, r.Error
+	}
+	return &r, nil
+}
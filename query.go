@@ -2,45 +2,302 @@ package godns
 
 import (
     "context"
+    "encoding/json"
+    "errors"
     "fmt"
     "net"
+    "net/netip"
+    "strconv"
     "strings"
+    "sync"
+    "time"
 
     "github.com/miekg/dns"
 )
 
 // QueryResult 查询结果
 type QueryResult struct {
-    Domain   string
-    Type     uint16
-    Records  []Record
-    Error    error
-    Server   string
+    Domain      string
+    Type        uint16
+    Records     []Record
+    Authority   []Record        // 应答的授权部分，例如委派的NS记录、否定应答中的SOA
+    Additional  []Record        // 应答的附加部分，例如委派的粘连A/AAAA记录，不含EDNS的OPT伪记录
+    Error       *ErrorInfo      // 查询失败时的错误信息，可序列化
+    Server      string
+    AD          bool            // 响应的AD位，表示递归服务器已完成DNSSEC验证
+    Path        *ResolutionPath // 最终应答的获取路径
+    Tag         string          // 服务器所属标签
+    SentQuery   string          // 实际发送的查询消息（需开启 WithSentQuery）
+    Route       string          // 命中的路由规则名称
+    Synthetic   bool            // 占位结果：服务器在截止时间前未应答
+    RespondedBy string          // 应答的实际来源地址（IP:端口），可用于发现欺骗或确认任播实例
+    Nameserver  string          // 权威查询时对应的NS主机名
+    Warnings    []Warning       // 解析、校验和策略层产生的非致命问题
+    
+    TruncatedByClient  bool // 应答记录数超过 WithMaxAnswers 上限，Records 已被截断
+    Authoritative      bool // 响应的AA位，表示应答来自该区域的权威服务器
+    Truncated          bool // 响应的TC位，UDP应答超过大小限制被服务器截断，可改用TCP重新查询
+    RecursionAvailable bool // 响应的RA位，表示服务器支持递归查询
+    Rcode              int  // 应答的响应码（dns.RcodeSuccess、dns.RcodeNameError 等），未收到应答时为 0
+    
+    QueriedAt           time.Time // 网络交互完成的时间
+    OriginallyQueriedAt time.Time // 缓存应答最初从网络获得的时间，非缓存应答为零值
+    ValidUntil          time.Time // 应答按最小TTL计算的过期时间
+    Duration            time.Duration // 网络查询的往返时间（RTT）加上该次尝试因 WithMaxOutstanding 排队的时间，取自最后一次成功的尝试，全部失败时为最后一次尝试的值；缓存应答为 0
+    
+    answerHash string // AnswerHash 的缓存
+}
+
+// Err 返回查询错误，可配合 errors.Is 判断 ErrTimeout、ErrNXDomain 等类别
+func (r *QueryResult) Err() error {
+    if r == nil || r.Error == nil {
+        return nil
+    }
+    return r.Error
+}
+
+// RcodeString 返回响应码的助记符，例如 "NOERROR"、"NXDOMAIN"、"SERVFAIL"，未知的响应码返回 "RCODE<n>"
+func (r *QueryResult) RcodeString() string {
+    if s, ok := dns.RcodeToString[r.Rcode]; ok {
+        return s
+    }
+    return "RCODE" + strconv.Itoa(r.Rcode)
+}
+
+// IsNXDOMAIN 服务器是否应答名称不存在，用于区分 NXDOMAIN 与存在名称但没有该类型记录的空应答
+// NXDOMAIN 时 Error 同时记录包装 ErrNXDomain 的错误
+func (r *QueryResult) IsNXDOMAIN() bool {
+    return r != nil && r.Rcode == dns.RcodeNameError
+}
+
+// answered 服务器是否给出了确定的应答：成功或 NXDOMAIN
+func (r *QueryResult) answered() bool {
+    return r.Error == nil || r.Error.Kind == KindNXDomain
 }
 
 // Record DNS记录
+// 记录值的字符串形式在首次调用 Value（或序列化）时才生成并缓存，只使用类型化访问方法的调用方不承担格式化开销
 type Record struct {
-    Name  string
-    Type  uint16
-    TTL   uint32
-    Value string
+    Name   string
+    Type   uint16
+    TTL    uint32
+    Values []string // TXT记录的原始字符串（每段不超过255字节），按应答中的顺序排列，其他类型为 nil
+    
+    rr    dns.RR       // 原始资源记录，供类型化访问使用，不参与序列化
+    value *recordValue // 惰性生成的记录值，Record 的副本共享同一份缓存
+}
+
+// recordValue 惰性生成的记录值，并发调用 Value 时只格式化一次
+type recordValue struct {
+    once sync.Once
+    text string
+}
+
+// NewRecord 将资源记录转换为 Record，Value 的格式与查询结果一致
+// 可用于在自定义 Resolver 实现或测试中构建结果
+func NewRecord(rr dns.RR) Record {
+    return newRecord(rr, &recordValue{})
+}
+
+// newRecord 使用预先分配的 value 构建 Record，批量转换时可一次分配全部记录的缓存
+func newRecord(rr dns.RR, value *recordValue) Record {
+    r := Record{
+        Name:  rr.Header().Name,
+        Type:  rr.Header().Rrtype,
+        TTL:   rr.Header().Ttl,
+        rr:    rr,
+        value: value,
+    }
+    if txt, ok := rr.(*dns.TXT); ok {
+        r.Values = txt.Txt
+    }
+    return r
+}
+
+// NewValueRecord 以字符串形式的记录值构建 Record，用于从序列化数据还原结果或在测试中构建结果
+// 这样构建的 Record 没有原始资源记录，RR 返回 nil，类型化访问方法从记录值中解析
+func NewValueRecord(name string, qtype uint16, ttl uint32, value string) Record {
+    v := &recordValue{}
+    v.once.Do(func() { v.text = value })
+    return Record{Name: name, Type: qtype, TTL: ttl, value: v}
+}
+
+// Value 返回记录值的字符串形式，首次调用时生成并缓存，可并发调用
+// A/AAAA 为IP地址，CNAME/NS 为带末尾点的名称，PTR 为不带末尾点的主机名，MX 为 "优先级 主机名"，
+// TXT 为各字符串直接拼接的结果（RFC 7208 §3.3，拆分存放的SPF/DKIM记录由此还原），各段见 Values，其余类型为区域文件格式的 RDATA 或完整的记录文本
+func (r Record) Value() string {
+    if r.value == nil {
+        return ""
+    }
+    r.value.once.Do(func() { r.value.text = formatValue(r.rr) })
+    return r.value.text
+}
+
+// formatValue 生成资源记录的 Record.Value
+func formatValue(rr dns.RR) string {
+    switch v := rr.(type) {
+    case *dns.A:
+        return v.A.String()
+    case *dns.AAAA:
+        return v.AAAA.String()
+    case *dns.CNAME:
+        return v.Target
+    case *dns.MX:
+        return strconv.Itoa(int(v.Preference)) + " " + v.Mx
+    case *dns.NS:
+        return v.Ns
+    case *dns.PTR:
+        return strings.TrimSuffix(v.Ptr, ".")
+    case *dns.SRV:
+        return fmt.Sprintf("%d %d %d %s", v.Priority, v.Weight, v.Port, v.Target)
+    case *dns.CAA:
+        return fmt.Sprintf("%d %s %q", v.Flag, v.Tag, v.Value)
+    case *dns.SOA:
+        return newSOARecord(v).String()
+    case *dns.NAPTR:
+        return newNAPTRRecord(v).String()
+    case *dns.SVCB:
+        return newSVCBRecord(v).String()
+    case *dns.HTTPS:
+        return newSVCBRecord(&v.SVCB).String()
+    case *dns.DNSKEY:
+        return newDNSKEYRecord(v).String()
+    case *dns.DS:
+        return newDSRecord(v).String()
+    case *dns.TLSA:
+        return newTLSARecord(v).String()
+    case *dns.TXT:
+        return strings.Join(v.Txt, "")
+    case nil:
+        return ""
+    default:
+        return rr.String()
+    }
+}
+
+// recordJSON Record 的序列化形式，与 Value 改为方法之前的字段一致，Values 只在TXT记录中出现
+type recordJSON struct {
+    Name   string
+    Type   uint16
+    TTL    uint32
+    Value  string
+    Values []string `json:",omitempty"`
+}
+
+// MarshalJSON 序列化为 {"Name","Type","TTL","Value","Values"}，会生成并缓存记录值
+func (r Record) MarshalJSON() ([]byte, error) {
+    return json.Marshal(recordJSON{Name: r.Name, Type: r.Type, TTL: r.TTL, Value: r.Value(), Values: r.Values})
+}
+
+// UnmarshalJSON 从 MarshalJSON 的输出还原，得到的 Record 与 NewValueRecord 构建的相同
+func (r *Record) UnmarshalJSON(data []byte) error {
+    var v recordJSON
+    if err := json.Unmarshal(data, &v); err != nil {
+        return err
+    }
+    *r = NewValueRecord(v.Name, v.Type, v.TTL, v.Value)
+    r.Values = v.Values
+    return nil
+}
+
+// GobEncode 与 MarshalJSON 相同，使 Record 可以通过 gob 跨进程传递
+func (r Record) GobEncode() ([]byte, error) {
+    return r.MarshalJSON()
+}
+
+// GobDecode 与 UnmarshalJSON 相同
+func (r *Record) GobDecode(data []byte) error {
+    return r.UnmarshalJSON(data)
+}
+
+// Nameservers 返回结果中NS记录的名称服务器主机名（不带末尾的点），保持应答中的顺序
+func (r *QueryResult) Nameservers() []string {
+    var names []string
+    for _, record := range r.Records {
+        if record.Type == dns.TypeNS {
+            names = append(names, strings.TrimSuffix(record.Value(), "."))
+        }
+    }
+    return names
+}
+
+// RR 返回原始资源记录，反序列化得到的 Record 返回 nil
+func (r Record) RR() dns.RR {
+    return r.rr
 }
 
 // MultiQueryResult 多DNS查询结果
 type MultiQueryResult struct {
-    Domain  string
-    Type    uint16
-    Results []QueryResult
-    AllIPs  []string // 所有查询到的IP地址
+    Domain     string
+    Type       uint16
+    Results    []QueryResult
+    AllIPs     []string  // 所有查询到的IP地址，SVCB/HTTPS查询时为记录中的地址提示
+    StartedAt  time.Time     // 开始查询的时间
+    FinishedAt time.Time     // 汇总完成的时间
+    Elapsed    time.Duration // 从调用开始到结果汇总完成的耗时
+    Partial    bool          // 截止时间到达时仍有服务器未应答（需开启 WithPartialResults）
+    Durations  map[string]time.Duration // 各服务器网络查询的往返时间（即 QueryResult.Duration），缓存应答和未收到应答的服务器不在其中
+    Fastest    string                   // 往返时间最短的成功服务器，没有来自网络的成功应答时为空
+    
+    // 法定一致模式（需开启 WithQuorum）
+    QuorumReached bool     // 是否有足够多的服务器返回相同应答
+    Agreeing      []string // 返回一致应答的服务器
+    Dissenters    []string // 截止前返回不同应答的服务器
+    
+    WarningCounts map[WarningCode]int // 各结果中每类警告的数量
 }
 
+// dnssecUDPSize 设置DO位时通告的EDNS UDP缓冲区大小
+const dnssecUDPSize = 1232
+
 // Query 单个DNS查询
 func (c *Client) Query(ctx context.Context, domain string, qtype uint16) (*QueryResult, error) {
+    if err := checkOverrides(ctx); err != nil {
+        return nil, err
+    }
+    if servers, ok := serversFromContext(ctx); ok {
+        return c.queryFailover(c.adHocContext(ctx), domain, qtype, c.normalizeServers(servers))
+    }
+    
+    if rt := c.route(domain); rt != nil {
+        res, err := rt.client.Query(ctx, domain, qtype)
+        if res != nil {
+            res.Route = rt.rule.Name
+        }
+        return res, err
+    }
+    
     if len(c.config.Servers) == 0 {
-        return nil, fmt.Errorf("no DNS servers configured")
+        return nil, ErrNoServers
+    }
+    
+    return c.queryFailover(ctx, domain, qtype, c.config.Servers)
+}
+
+// queryFailover 按顺序查询服务器，失败时转向下一个，最多咨询 MaxForwarders 个服务器
+func (c *Client) queryFailover(ctx context.Context, domain string, qtype uint16, servers []string) (*QueryResult, error) {
+    limit := c.config.MaxForwarders
+    if limit <= 0 || limit > len(servers) {
+        limit = len(servers)
+    }
+    
+    ctx, _ = withPathRecorder(ctx)
+    
+    // 轮换重试策略下由重试在全部服务器间轮换，不再逐个故障转移
+    if c.config.RetryPlacement != SameServer && len(servers) > 1 {
+        return c.queryServer(withRetryServers(ctx, servers), domain, qtype, c.serverInfo(servers[0]))
     }
     
-    return c.queryServer(ctx, domain, qtype, c.config.Servers[0])
+    var res *QueryResult
+    var err error
+    for _, server := range servers[:limit] {
+        res, err = c.queryServer(ctx, domain, qtype, c.serverInfo(server))
+        // NXDOMAIN 是确定的应答，不再询问其他服务器
+        if err == nil || errors.Is(err, ErrNXDomain) || ctx.Err() != nil {
+            break
+        }
+    }
+    return res, err
 }
 
 // QueryA 查询A记录
@@ -58,7 +315,7 @@ func (c *Client) QueryCNAME(ctx context.Context, domain string) (*QueryResult, e
     return c.Query(ctx, domain, dns.TypeCNAME)
 }
 
-// QueryMX 查询MX记录
+// QueryMX 查询MX记录，结构化的字段可通过 QueryResult.MXRecords 获取
 func (c *Client) QueryMX(ctx context.Context, domain string) (*QueryResult, error) {
     return c.Query(ctx, domain, dns.TypeMX)
 }
@@ -68,57 +325,336 @@ func (c *Client) QueryTXT(ctx context.Context, domain string) (*QueryResult, err
     return c.Query(ctx, domain, dns.TypeTXT)
 }
 
+// QuerySOA 查询SOA记录，结构化的字段可通过 QueryResult.SOA 获取
+func (c *Client) QuerySOA(ctx context.Context, domain string) (*QueryResult, error) {
+    return c.Query(ctx, domain, dns.TypeSOA)
+}
+
+// QueryPTR 反向解析IP地址：转换为 in-addr.arpa 或 ip6.arpa 名称后查询PTR记录，
+// PTR记录的 Record.Value 为不带末尾点的主机名
+// IPv6 地址的区域后缀（如 "%eth0"）被忽略，IPv4 映射的 IPv6 地址按 IPv4 处理；
+// ip 不是合法的IPv4/IPv6地址时返回错误，不发出查询
+func (c *Client) QueryPTR(ctx context.Context, ip string) (*QueryResult, error) {
+    arpa, err := reverseName(ip)
+    if err != nil {
+        return nil, err
+    }
+    return c.Query(ctx, arpa, dns.TypePTR)
+}
+
+// QueryPTRIP 与 QueryPTR 相同，接受 net.IP
+func (c *Client) QueryPTRIP(ctx context.Context, ip net.IP) (*QueryResult, error) {
+    return c.QueryPTR(ctx, ip.String())
+}
+
+// MultiQueryPTR 多DNS服务器反向解析IP地址，用于交叉核对各解析器的PTR记录
+func (c *Client) MultiQueryPTR(ctx context.Context, ip string) (*MultiQueryResult, error) {
+    arpa, err := reverseName(ip)
+    if err != nil {
+        return nil, err
+    }
+    return c.MultiQuery(ctx, arpa, dns.TypePTR)
+}
+
+// reverseName 返回IP地址的反向解析名称
+func reverseName(ip string) (string, error) {
+    addr, err := netip.ParseAddr(ip)
+    if err != nil {
+        return "", fmt.Errorf("invalid IP address %q: %w", ip, err)
+    }
+    return dns.ReverseAddr(addr.WithZone("").Unmap().String())
+}
+
+// QueryNS 查询NS记录，Record.Value 为带末尾点的名称服务器主机名
+func (c *Client) QueryNS(ctx context.Context, domain string) (*QueryResult, error) {
+    return c.Query(ctx, domain, dns.TypeNS)
+}
+
+// QueryWithTag 仅使用指定标签的服务器查询
+// 缓存不区分服务器，不同标签的服务器可能给出不同应答（例如内外网视图），因此按标签的查询不读写缓存
+func (c *Client) QueryWithTag(ctx context.Context, domain string, qtype uint16, tag string) (*QueryResult, error) {
+    servers := c.config.TaggedServers[tag]
+    if len(servers) == 0 {
+        return nil, fmt.Errorf("%w with tag %q", ErrNoServers, tag)
+    }
+    
+    res, err := c.queryFailover(ContextWithNoCache(ctx), domain, qtype, servers)
+    if res != nil {
+        res.Tag = tag
+    }
+    return res, err
+}
+
+// MultiQueryWithTag 并发查询指定标签的所有服务器，与 QueryWithTag 一样不读写缓存
+func (c *Client) MultiQueryWithTag(ctx context.Context, domain string, qtype uint16, tag string) (*MultiQueryResult, error) {
+    servers := c.config.TaggedServers[tag]
+    if len(servers) == 0 {
+        return nil, fmt.Errorf("%w with tag %q", ErrNoServers, tag)
+    }
+    
+    result := c.multiQuery(ContextWithNoCache(ctx), nil, domain, qtype, servers)
+    for i := range result.Results {
+        result.Results[i].Tag = tag
+    }
+    return result, nil
+}
+
 // MultiQuery 多DNS服务器查询
 func (c *Client) MultiQuery(ctx context.Context, domain string, qtype uint16) (*MultiQueryResult, error) {
+    return c.multiQueryInto(ctx, nil, domain, qtype)
+}
+
+// firstAnswerKey 标记 multiQuery 在收到首个成功且非空的应答后立即返回
+type firstAnswerKey struct{}
+
+// MultiQueryFirst 与 MultiQuery 一样同时查询所有服务器，但在收到首个成功且非空的应答后立即返回并取消其余查询
+// 胜出的结果位于 Results[0]，其后是在它之前返回的失败或空应答；没有服务器给出非空应答时等同于 MultiQuery
+func (c *Client) MultiQueryFirst(ctx context.Context, domain string, qtype uint16) (*MultiQueryResult, error) {
+    return c.multiQueryInto(context.WithValue(ctx, firstAnswerKey{}, true), nil, domain, qtype)
+}
+
+// multiQueryInto 多DNS服务器查询，rc 为 nil 时结果分配在新的 MultiQueryResult 中
+func (c *Client) multiQueryInto(ctx context.Context, rc *ResultCollector, domain string, qtype uint16) (*MultiQueryResult, error) {
+    if err := checkOverrides(ctx); err != nil {
+        return nil, err
+    }
+    if servers, ok := serversFromContext(ctx); ok {
+        return c.multiQuery(c.adHocContext(ctx), rc, domain, qtype, c.normalizeServers(servers)), nil
+    }
+    
+    if rt := c.route(domain); rt != nil {
+        result, err := rt.client.multiQueryInto(ctx, rc, domain, qtype)
+        if result != nil {
+            for i := range result.Results {
+                result.Results[i].Route = rt.rule.Name
+            }
+        }
+        return result, err
+    }
+    
+    if len(c.config.MixedRace) > 0 {
+        return c.mixedRace(ctx, rc, domain, qtype)
+    }
+    
     if len(c.config.Servers) == 0 {
-        return nil, fmt.Errorf("no DNS servers configured")
+        return nil, ErrNoServers
     }
     
-    result := &MultiQueryResult{
-        Domain:  domain,
-        Type:    qtype,
-        Results: make([]QueryResult, 0, len(c.config.Servers)),
-        AllIPs:  make([]string, 0),
+    return c.multiQuery(ctx, rc, domain, qtype, c.config.Servers), nil
+}
+
+// multiQuery 并发查询指定的服务器列表，结果收集到 rc 中
+func (c *Client) multiQuery(ctx context.Context, rc *ResultCollector, domain string, qtype uint16, servers []string) *MultiQueryResult {
+    if rc == nil {
+        rc = newPooledCollector()
+        defer rc.release()
     }
+    result := rc.begin(domain, qtype, len(servers), c.config.Clock.Now())
+    start := time.Now()
     
-    // 并发查询所有DNS服务器
-    resultChan := make(chan QueryResult, len(c.config.Servers))
+    // 缓存按协议而不是服务器区分，命中缓存会让各服务器"一致"地返回同一份应答，因此法定一致模式下每个服务器都查询网络
+    if c.config.Quorum > 0 {
+        ctx = ContextWithNoCache(ctx)
+    }
+    deadline := ctx.Done()
+    ctx, cancel := context.WithCancel(ctx)
+    defer cancel()
     
-    for _, server := range c.config.Servers {
-        go func(srv string) {
-            res, err := c.queryServer(ctx, domain, qtype, srv)
-            if res == nil {
-                res = &QueryResult{
-                    Domain: domain,
-                    Type:   qtype,
-                    Server: srv,
-                    Error:  err,
-                }
-            }
-            resultChan <- *res
-        }(server)
+    // 交错扇出时按排名分波次查询，否则一次查询所有服务器
+    staggered := c.config.FanoutWaveSize > 0 && c.config.FanoutWaveSize < len(servers)
+    waveSize := len(servers)
+    if staggered {
+        servers = c.rankServers(servers)
+        waveSize = c.config.FanoutWaveSize
     }
     
-    // 收集结果
-    ipSet := make(map[string]bool)
-    for i := 0; i < len(c.config.Servers); i++ {
-        res := <-resultChan
-        result.Results = append(result.Results, res)
-        
-        // 收集所有IP地址
-        if res.Error == nil {
-            for _, record := range res.Records {
-                if (qtype == dns.TypeA || qtype == dns.TypeAAAA) && net.ParseIP(record.Value) != nil {
-                    if !ipSet[record.Value] {
-                        ipSet[record.Value] = true
-                        result.AllIPs = append(result.AllIPs, record.Value)
+    type indexedResult struct {
+        index int
+        res   QueryResult
+    }
+    resultChan := make(chan indexedResult, len(servers))
+    var wg sync.WaitGroup
+    
+    launched := 0
+    launchWave := func() {
+        end := min(launched+waveSize, len(servers))
+        for ; launched < end; launched++ {
+            wg.Add(1)
+            go func(i int, srv string) {
+                defer wg.Done()
+                res, err := c.queryServer(ctx, domain, qtype, c.serverInfo(srv))
+                if res == nil {
+                    res = &QueryResult{
+                        Domain: domain,
+                        Type:   qtype,
+                        Server: srv,
+                        Error:  c.newErrorInfo(err, srv, 0),
+                        Tag:    c.serverTags[srv],
                     }
                 }
+                resultChan <- indexedResult{i, *res}
+            }(launched, servers[launched])
+        }
+    }
+    var wave <-chan time.Time
+    nextWave := func() {
+        launchWave()
+        wave = nil
+        if launched < len(servers) {
+            wave = c.after(c.config.FanoutStagger)
+        }
+    }
+    nextWave()
+    
+    // 仅在部分结果模式下响应截止时间，否则等待所有服务器返回
+    if !c.config.PartialResults {
+        deadline = nil
+    }
+    
+    first := ctx.Value(firstAnswerKey{}) != nil
+    
+    var quorum *quorumTracker
+    if c.config.Quorum > 0 {
+        quorum = newQuorumTracker(c.config.Quorum)
+    }
+    
+    // 收集结果
+    answered := make([]bool, len(servers))
+    settled := false // 已达成一致或交错扇出已获得成功应答
+collect:
+    for received := 0; received < launched; {
+        select {
+        case r := <-resultChan:
+            // 套接字读超时与截止时间相同，截止时间到达时返回的失败由截止时间本身导致，按未应答处理
+            if deadline != nil && r.res.Error != nil && pastDeadline(ctx) {
+                break collect
+            }
+            received++
+            answered[r.index] = true
+            rc.add(r.res)
+            if quorum != nil && quorum.add(r.res) {
+                // 已达成一致，取消其余查询
+                cancel()
+                wg.Wait()
+                result.QuorumReached = true
+                result.Agreeing, result.Dissenters = quorum.result()
+                settled = true
+                break collect
+            }
+            if first && r.res.Error == nil && len(r.res.Records) > 0 {
+                // 首个非空应答胜出，取消其余查询并把它移到最前
+                cancel()
+                wg.Wait()
+                last := len(result.Results) - 1
+                winner := result.Results[last]
+                copy(result.Results[1:], result.Results[:last])
+                result.Results[0] = winner
+                settled = true
+                break collect
+            }
+            if staggered && quorum == nil && r.res.answered() {
+                // 交错扇出以首个成功应答为准，取消进行中的查询且不再启动后续波次
+                cancel()
+                wg.Wait()
+                settled = true
+                break collect
+            }
+            if staggered && received == launched {
+                // 已启动的服务器全部失败，无需等待间隔
+                nextWave()
             }
+        case <-wave:
+            nextWave()
+        case <-deadline:
+            break collect
         }
     }
     
-    return result, nil
+    // 截止时间已到：取消并回收未应答的查询，为其生成超时占位结果
+    if deadline != nil && !settled && pastDeadline(ctx) {
+        cancel()
+        wg.Wait()
+        for i, srv := range servers[:launched] {
+            if answered[i] {
+                continue
+            }
+            result.Partial = true
+            result.Results = append(result.Results, QueryResult{
+                Domain:    domain,
+                Type:      qtype,
+                Server:    srv,
+                Tag:       c.serverTags[srv],
+                Synthetic: true,
+                Error: &ErrorInfo{
+                    Message: "no response before deadline",
+                    Kind:    KindTimeout,
+                    Server:  srv,
+                    Client:  c.name,
+                },
+            })
+        }
+    }
+    
+    result.FinishedAt = c.config.Clock.Now()
+    result.Elapsed = time.Since(start)
+    return result
+}
+
+// pastDeadline context 是否已结束或已到达截止时间
+func pastDeadline(ctx context.Context) bool {
+    if ctx.Err() != nil {
+        return true
+    }
+    deadline, ok := ctx.Deadline()
+    return ok && !time.Now().Before(deadline)
+}
+
+// addResult 追加单个服务器的结果并汇总IP地址
+func (r *MultiQueryResult) addResult(res QueryResult, ipSet map[netip.Addr]bool) {
+    r.Results = append(r.Results, res)
+    for _, w := range res.Warnings {
+        if r.WarningCounts == nil {
+            r.WarningCounts = make(map[WarningCode]int)
+        }
+        r.WarningCounts[w.Code]++
+    }
+    
+    if res.Duration > 0 {
+        if r.Durations == nil {
+            r.Durations = make(map[string]time.Duration)
+        }
+        r.Durations[res.Server] = res.Duration
+        if res.answered() && (r.Fastest == "" || res.Duration < r.Durations[r.Fastest]) {
+            r.Fastest = res.Server
+        }
+    }
+    
+    // 只收集 NOERROR 应答中的地址，NXDOMAIN 等否定应答中的记录（例如CNAME链上的地址）不代表该名称的地址
+    if res.Error != nil || res.Rcode != dns.RcodeSuccess {
+        return
+    }
+    
+    // 收集所有IP地址，按 netip.Addr 去重，避免同一地址的不同文本形式重复出现
+    if r.Type == dns.TypeA || r.Type == dns.TypeAAAA {
+        for _, record := range res.Records {
+            if addr, ok := recordAddr(record); ok && !ipSet[addr] {
+                ipSet[addr] = true
+                r.AllIPs = append(r.AllIPs, record.Value())
+            }
+        }
+    }
+    
+    // SVCB/HTTPS 的地址提示等同于地址记录，一并收集
+    if r.Type == dns.TypeSVCB || r.Type == dns.TypeHTTPS {
+        for _, svcb := range res.SVCBRecords() {
+            for _, addr := range svcb.IPHints() {
+                if !ipSet[addr] {
+                    ipSet[addr] = true
+                    r.AllIPs = append(r.AllIPs, addr.String())
+                }
+            }
+        }
+    }
 }
 
 // MultiQueryA 多DNS服务器查询A记录
@@ -131,65 +667,323 @@ func (c *Client) MultiQueryAAAA(ctx context.Context, domain string) (*MultiQuery
     return c.MultiQuery(ctx, domain, dns.TypeAAAA)
 }
 
-// queryServer 查询指定DNS服务器
-func (c *Client) queryServer(ctx context.Context, domain string, qtype uint16, server string) (*QueryResult, error) {
-    msg := new(dns.Msg)
-    msg.SetQuestion(dns.Fqdn(domain), qtype)
-    msg.RecursionDesired = true
+// queryServer 按服务器的结构化定义使用其协议查询，失败时返回的错误即 result.Error，可用 errors.Is 判断错误类别
+func (c *Client) queryServer(ctx context.Context, domain string, qtype uint16, info ServerInfo) (*QueryResult, error) {
+    server, protocol := info.Address, info.Protocol
+    c.logConfigOnce()
+    ctx, leave, err := c.drain.enter(ctx)
+    if err != nil {
+        info := c.newErrorInfo(err, server, 0)
+        return &QueryResult{Domain: domain, Type: qtype, Server: server, Error: info}, info
+    }
+    defer leave()
+    defer c.sched.enterForeground(ctx)()
+    ctx, rec := withPathRecorder(ctx)
+    msg := c.newQueryMsg(ctx, domain, qtype)
+    
+    result := &QueryResult{
+        Domain: domain,
+        Type:   qtype,
+        Server: server,
+        Tag:    c.serverTags[server],
+    }
+    
+    if err := c.namePolicy(ctx).Check(domain); err != nil {
+        result.Error = c.newErrorInfo(err, server, 0)
+        return result, result.Error
+    }
+    if c.subnetErr != nil {
+        result.Error = c.newErrorInfo(c.subnetErr, server, 0)
+        return result, result.Error
+    }
+    if err := c.checkLoop(ctx, domain); err != nil {
+        result.Error = c.newErrorInfo(err, server, 0)
+        return result, result.Error
+    }
     
     var response *dns.Msg
-    var err error
     
-    switch c.config.Protocol {
-    case UDP, TCP:
-        response, err = c.queryUDPTCP(ctx, msg, server)
-    case DoT:
-        response, err = c.queryDoT(ctx, msg, server)
-    case DoH:
-        response, err = c.queryDoH(ctx, msg, server)
-    default:
-        return nil, fmt.Errorf("unsupported protocol: %s", c.config.Protocol)
+    useCache := c.cache != nil && !isAdHoc(ctx) && !noCacheFromContext(ctx)
+    if useCache {
+        if cached, storedAt, ok := c.cache.get(protocol, msg); ok {
+            response = cached
+            result.OriginallyQueriedAt = storedAt
+            rec.setSource(SourceCache)
+        }
     }
     
-    if err != nil {
-        return &QueryResult{
-            Domain: domain,
-            Type:   qtype,
-            Server: server,
-            Error:  err,
-        }, err
+    errKey := errorCacheKey(server, domain, qtype)
+    useErrCache := response == nil && c.errCache != nil && ctx.Value(noErrorCacheKey{}) == nil
+    if useErrCache {
+        if cachedErr, ok := c.errCache.get(errKey); ok {
+            err = cachedErr
+            rec.setSource(SourceErrorCache)
+        }
+    }
+    
+    if response == nil && err == nil {
+        err = c.refusedClient(server)
     }
     
-    records := make([]Record, 0, len(response.Answer))
-    for _, rr := range response.Answer {
-        record := Record{
-            Name: rr.Header().Name,
-            Type: rr.Header().Rrtype,
-            TTL:  rr.Header().Ttl,
+    if response == nil && err == nil {
+        // 故障转移时此前的服务器可能命中了错误缓存，最终来源以本次网络查询为准
+        rec.setSource(SourceNetwork)
+        // 已知不支持EDNS的服务器直接发送不带EDNS的查询
+        if c.edns.disabled(server) && stripEDNS(msg) {
+            rec.markEDNSDowngraded()
         }
         
-        switch v := rr.(type) {
-        case *dns.A:
-            record.Value = v.A.String()
-        case *dns.AAAA:
-            record.Value = v.AAAA.String()
-        case *dns.CNAME:
-            record.Value = v.Target
-        case *dns.MX:
-            record.Value = fmt.Sprintf("%d %s", v.Preference, v.Mx)
-        case *dns.TXT:
-            record.Value = strings.Join(v.Txt, " ")
-        default:
-            record.Value = rr.String()
+        // 所有对查询消息的修改都必须在此之前完成
+        if c.config.OnRequest != nil {
+            c.config.OnRequest(ctx, server, msg)
+        }
+        if c.config.RecordSentQuery {
+            result.SentQuery = msg.String()
         }
         
-        records = append(records, record)
+        var sent *dns.Msg
+        sent, response, err = c.exchangeEDNSAware(ctx, protocol, msg, server)
+        if err == nil {
+            response, result.Warnings, err = c.checkResponse(ctx, msg, response, server)
+        }
+        if err == nil && response.Rcode == dns.RcodeRefused {
+            err = c.classifyRefusal(ctx, protocol, server, domain)
+        }
+        // 被截断的应答不完整，不缓存
+        if err == nil && useCache && !response.Truncated {
+            c.cache.set(protocol, sent, response)
+        }
+        if c.errCache != nil {
+            if err == nil {
+                c.errCache.clear(errKey)
+            } else if cacheableError(ctx, err) {
+                c.errCache.set(errKey, err)
+            }
+        }
+    }
+    
+    result.Path = rec.snapshot()
+    result.QueriedAt = c.config.Clock.Now()
+    if result.Path.Source == SourceNetwork {
+        result.Duration = result.Path.roundTrip()
+    }
+    if n := len(result.Path.Attempts); n > 0 && result.Path.Source == SourceNetwork {
+        // 轮换重试时应答可能来自其他服务器
+        if last := result.Path.Attempts[n-1].Server; last != server && len(c.retryServers(ctx, server)) > 1 {
+            result.Server = last
+            result.Tag = c.serverTags[last]
+        }
+    }
+    if c.config.Debug {
+        c.logf("%s %s: %s", domain, dns.TypeToString[qtype], result.Path.logSummary())
+    }
+    if err != nil {
+        if response != nil {
+            copyHeader(result, response)
+        }
+        result.Rcode = failedRcode(response, err)
+        result.Error = c.newErrorInfo(err, result.Server, len(result.Path.Attempts))
+        return result, result.Error
     }
     
-    return &QueryResult{
-        Domain:  domain,
-        Type:    qtype,
-        Records: records,
-        Server:  server,
-    }, nil
+    c.fillRecords(ctx, result, response)
+    // NXDOMAIN 是确定的否定应答：结果照常填充（含授权部分的SOA），同时返回可用 errors.Is(err, ErrNXDomain) 判断的错误
+    if response.Rcode == dns.RcodeNameError {
+        result.Error = c.newErrorInfo(&RcodeError{Rcode: dns.RcodeNameError, Server: result.Server}, result.Server, len(result.Path.Attempts))
+        return result, result.Error
+    }
+    return result, nil
+}
+
+// newQueryMsg 按客户端配置和 context 构建查询消息
+func (c *Client) newQueryMsg(ctx context.Context, domain string, qtype uint16) *dns.Msg {
+    msg := new(dns.Msg)
+    msg.SetQuestion(dns.Fqdn(domain), qtype)
+    msg.Id = c.randomID()
+    msg.RecursionDesired = ctx.Value(noRecursionKey{}) == nil
+    if c.config.RequireAD {
+        // RFC 6840 §5.7: 在查询中设置AD位，请求服务器返回验证状态
+        msg.AuthenticatedData = true
+    }
+    if do, ok := doFromContext(ctx); ok && do {
+        msg.SetEdns0(dnssecUDPSize, true)
+    } else if largeAnswer(ctx) {
+        msg.SetEdns0(dnssecUDPSize, false)
+    }
+    if c.subnet != nil {
+        setClientSubnet(msg, c.subnet)
+    }
+    return msg
+}
+
+// checkResponse 校验网络应答的问题部分与查询一致，经应答拦截器处理后按配置校验应答并限制记录TTL，
+// 返回（可能被拦截器替换的）应答和产生的警告；拦截器改写的响应码与服务器发送的一样经过后续的分类
+func (c *Client) checkResponse(ctx context.Context, query, response *dns.Msg, server string) (*dns.Msg, []Warning, error) {
+    if err := matchQuestion(query, response); err != nil {
+        return response, nil, fmt.Errorf("invalid response from %s: %w", server, err)
+    }
+    response, err := c.interceptResponse(ctx, response)
+    if err != nil {
+        return nil, nil, err
+    }
+    // SERVFAIL 表示服务器无法完成解析，作为失败返回以便故障转移，而不是当作空应答
+    if response.Rcode == dns.RcodeServerFailure {
+        return response, nil, &RcodeError{Rcode: dns.RcodeServerFailure, Server: server}
+    }
+    if c.config.RequireAD && !response.AuthenticatedData {
+        return response, nil, fmt.Errorf("response from %s is not DNSSEC validated (AD=0)", server)
+    }
+    return response, c.clampTTLs(response), nil
+}
+
+// failedRcode 返回失败查询的响应码，未收到应答时根据错误类别推断
+func failedRcode(response *dns.Msg, err error) int {
+    if response != nil {
+        return response.Rcode
+    }
+    var rcodeErr *RcodeError
+    if errors.As(err, &rcodeErr) {
+        return rcodeErr.Rcode
+    }
+    // 应答拦截器等返回的错误可能只包装了哨兵值
+    if errors.Is(err, ErrServFail) {
+        return dns.RcodeServerFailure
+    }
+    if errors.Is(err, ErrRefused) {
+        return dns.RcodeRefused
+    }
+    return dns.RcodeSuccess
+}
+
+// copyHeader 将应答头部的标志位复制到结果中
+func copyHeader(result *QueryResult, response *dns.Msg) {
+    result.AD = response.AuthenticatedData
+    result.Authoritative = response.Authoritative
+    result.Truncated = response.Truncated
+    result.RecursionAvailable = response.RecursionAvailable
+}
+
+// interceptResponse 调用配置的应答拦截器
+func (c *Client) interceptResponse(ctx context.Context, response *dns.Msg) (*dns.Msg, error) {
+    if c.config.ResponseInterceptor == nil {
+        return response, nil
+    }
+    replaced, err := c.config.ResponseInterceptor(ctx, response)
+    if err != nil {
+        return nil, err
+    }
+    if replaced == nil {
+        return response, nil
+    }
+    return replaced, nil
+}
+
+// fillRecords 将应答解析为结果中的记录
+func (c *Client) fillRecords(ctx context.Context, result *QueryResult, response *dns.Msg) {
+    result.Rcode = response.Rcode
+    answers := response.Answer
+    if limit := c.maxAnswers(ctx); limit > 0 && len(answers) > limit {
+        answers = answers[:limit]
+        result.TruncatedByClient = true
+    }
+    
+    // 截断后不再存在的记录对应的警告一并丢弃
+    warnings := result.Warnings[:0]
+    for _, w := range result.Warnings {
+        if w.Record < len(answers) {
+            warnings = append(warnings, w)
+        }
+    }
+    if result.TruncatedByClient {
+        warnings = append(warnings, Warning{
+            Code:    WarnTruncatedByClient,
+            Message: fmt.Sprintf("answer truncated to %d of %d records", len(answers), len(response.Answer)),
+            Record:  -1,
+        })
+    }
+    if result.Path != nil && result.Path.EDNSDowngraded {
+        warnings = append(warnings, Warning{
+            Code:    WarnEDNSDowngraded,
+            Message: fmt.Sprintf("server %s does not support EDNS, query resent without it", result.Server),
+            Record:  -1,
+        })
+    }
+    
+    records := make([]Record, 0, len(answers))
+    values := make([]recordValue, len(answers))
+    for i, rr := range answers {
+        records = append(records, newRecord(rr, &values[i]))
+        warnings = append(warnings, recordWarnings(i, rr)...)
+    }
+    
+    result.Records = records
+    if len(warnings) > 0 {
+        result.Warnings = warnings
+    } else {
+        result.Warnings = nil
+    }
+    result.Authority = sectionRecords(response.Ns)
+    result.Additional = sectionRecords(response.Extra)
+    copyHeader(result, response)
+    result.RespondedBy = result.Path.respondedBy()
+    if ttl, ok := minTTL(records); ok {
+        result.ValidUntil = result.QueriedAt.Add(time.Duration(ttl) * time.Second)
+    }
+}
+
+// sectionRecords 将授权或附加部分转换为记录，跳过OPT伪记录，没有记录时返回 nil
+func sectionRecords(rrs []dns.RR) []Record {
+    var records []Record
+    var values []recordValue
+    for _, rr := range rrs {
+        if rr.Header().Rrtype == dns.TypeOPT {
+            continue
+        }
+        if values == nil {
+            values = make([]recordValue, len(rrs))
+        }
+        records = append(records, newRecord(rr, &values[len(records)]))
+    }
+    return records
+}
+
+// clampTTLs 将应答记录的TTL限制在配置的范围内，返回被调整记录的警告
+func (c *Client) clampTTLs(response *dns.Msg) []Warning {
+    min, max := c.config.TTLMin, c.config.TTLMax
+    if min == 0 && max == 0 {
+        return nil
+    }
+    var warnings []Warning
+    for i, rr := range response.Answer {
+        hdr := rr.Header()
+        original := hdr.Ttl
+        if hdr.Ttl < min {
+            hdr.Ttl = min
+        }
+        if max > 0 && hdr.Ttl > max {
+            hdr.Ttl = max
+        }
+        if hdr.Ttl != original {
+            warnings = append(warnings, Warning{
+                Code:    WarnTTLClamped,
+                Message: fmt.Sprintf("TTL of %s %s clamped from %d to %d", hdr.Name, dns.TypeToString[hdr.Rrtype], original, hdr.Ttl),
+                Record:  i,
+            })
+        }
+    }
+    return warnings
+}
+
+// minTTL 返回记录中的最小TTL
+func minTTL(records []Record) (uint32, bool) {
+    if len(records) == 0 {
+        return 0, false
+    }
+    ttl := records[0].TTL
+    for _, record := range records[1:] {
+        if record.TTL < ttl {
+            ttl = record.TTL
+        }
+    }
+    return ttl, true
 }
\ No newline at end of file
@@ -0,0 +1,126 @@
+package godns_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+)
+
+// TestQueryWithTagBypassesCache 不同标签的服务器给出不同应答，按标签的查询不能互相命中缓存
+func TestQueryWithTagBypassesCache(t *testing.T) {
+	internal, public := startServer(t), startServer(t)
+	internal.Answer("app.example.com", dns.TypeA, "app.example.com. 300 IN A 10.0.0.1")
+	public.Answer("app.example.com", dns.TypeA, "app.example.com. 300 IN A 192.0.2.1")
+	c := godns.New(
+		godns.WithTaggedServers(map[string][]string{
+			"internal": {internal.UDPAddr},
+			"public":   {public.UDPAddr},
+		}),
+		godns.WithCache(time.Minute),
+	)
+	defer c.Close()
+	ctx := context.Background()
+
+	// 普通查询写入缓存后，按标签的查询依然到达各自的服务器
+	if _, err := c.QueryA(ctx, "app.example.com"); err != nil {
+		t.Fatal(err)
+	}
+	for round := 0; round < 2; round++ {
+		for tag, want := range map[string]string{"internal": "10.0.0.1", "public": "192.0.2.1"} {
+			res, err := c.QueryWithTag(ctx, "app.example.com", dns.TypeA, tag)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Tag != tag || res.Path.Source != godns.SourceNetwork || res.Records[0].Value() != want {
+				t.Errorf("tag %s: tag = %q, source = %q, records = %v", tag, res.Tag, res.Path.Source, res.Records)
+			}
+		}
+		multi, err := c.MultiQueryWithTag(ctx, "app.example.com", dns.TypeA, "public")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r := multi.Results[0]; r.Path.Source != godns.SourceNetwork || r.Records[0].Value() != "192.0.2.1" {
+			t.Errorf("MultiQueryWithTag: source = %q, records = %v", r.Path.Source, r.Records)
+		}
+	}
+	if n := len(public.Queries()); n != 4 {
+		t.Errorf("public server saw %d queries, want 4", n)
+	}
+
+	// 按标签的查询也不写入缓存：普通查询仍命中第一次写入的条目
+	res, err := c.QueryA(ctx, "app.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Path.Source != godns.SourceCache {
+		t.Errorf("untagged source = %q, want cache", res.Path.Source)
+	}
+}
+
+// TestMultiQueryPartialResults 截止时间到达时快服务器的结果是真实的，慢服务器以超时占位结果表示
+func TestMultiQueryPartialResults(t *testing.T) {
+	tests := []struct {
+		name      string
+		partial   bool
+		slowDelay time.Duration
+		wantPart  bool
+	}{
+		{"deadline-hits", true, 5 * time.Second, true},
+		{"all-answer-in-time", true, 0, false},
+		{"mode-off", false, 5 * time.Second, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, addrs := startAnswering(t,
+				aReply("192.0.2.1", 0),
+				aReply("192.0.2.2", 20*time.Millisecond),
+				aReply("192.0.2.3", tt.slowDelay),
+			)
+			fast, slow := addrs[:2], addrs[2]
+			opts := []godns.Option{godns.WithServers(addrs...), godns.WithRetries(0), godns.WithTimeout(10 * time.Second)}
+			if tt.partial {
+				opts = append(opts, godns.WithPartialResults())
+			}
+			c := godns.New(opts...)
+			defer c.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+			defer cancel()
+			start := time.Now()
+			res, err := c.MultiQuery(ctx, "example.com", dns.TypeA)
+			if err != nil {
+				t.Fatalf("err = %v, want nil", err)
+			}
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Errorf("MultiQuery took %v past a 300ms deadline", elapsed)
+			}
+			if res.Partial != tt.wantPart {
+				t.Errorf("Partial = %v, want %v", res.Partial, tt.wantPart)
+			}
+			if len(res.Results) != 3 {
+				t.Fatalf("results = %d, want 3", len(res.Results))
+			}
+			for _, r := range res.Results {
+				isFast := r.Server == fast[0] || r.Server == fast[1]
+				switch {
+				case isFast || tt.slowDelay == 0:
+					if r.Synthetic || r.Error != nil || len(r.Records) != 1 {
+						t.Errorf("%s: synthetic = %v, err = %v, records = %v, want a real answer", r.Server, r.Synthetic, r.Error, r.Records)
+					}
+				case tt.partial:
+					if !r.Synthetic || r.Error == nil || r.Error.Kind != godns.KindTimeout || len(r.Records) != 0 {
+						t.Errorf("%s: synthetic = %v, err = %v, want a synthetic timeout", r.Server, r.Synthetic, r.Error)
+					}
+				default:
+					// 未开启部分结果模式时慢服务器的查询被 context 取消，结果是真实的失败
+					if r.Server != slow || r.Synthetic || r.Error == nil {
+						t.Errorf("%s: synthetic = %v, err = %v, want a real failure", r.Server, r.Synthetic, r.Error)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package godns
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"time"
+)
+
+// defaultRacePreferWindow 非优先服务器先应答后，等待优先服务器应答的默认窗口
+const defaultRacePreferWindow = 50 * time.Millisecond
+
+// ServerSpec 指定协议的服务器
+type ServerSpec struct {
+	Address   string
+	Protocol  Protocol
+	Preferred bool        // 在偏好窗口内同时应答时优先采用该服务器的应答，例如可信的DoH上游
+	TLSConfig *tls.Config // 该服务器的TLS覆盖配置，未设置的字段继承全局配置
+}
+
+// WithMixedRace MultiQuery 同时以各自的协议查询 specs 中的服务器，返回最先成功的应答；
+// 若先到的应答来自非优先服务器，则在偏好窗口内等待优先服务器的应答
+func WithMixedRace(specs []ServerSpec) Option {
+	return func(c *Config) {
+		c.MixedRace = specs
+		if c.RacePreferWindow == 0 {
+			c.RacePreferWindow = defaultRacePreferWindow
+		}
+	}
+}
+
+// WithRacePreferWindow 设置混合竞速的偏好窗口
+func WithRacePreferWindow(window time.Duration) Option {
+	return func(c *Config) {
+		c.RacePreferWindow = window
+	}
+}
+
+// mixedRace 混合协议竞速查询，胜出的结果位于 Results[0]，AllIPs 仅来自胜出的结果
+func (c *Client) mixedRace(ctx context.Context, rc *ResultCollector, domain string, qtype uint16) (*MultiQueryResult, error) {
+	specs := c.config.MixedRace
+	if rc == nil {
+		rc = newPooledCollector()
+		defer rc.release()
+	}
+	result := rc.begin(domain, qtype, len(specs), c.config.Clock.Now())
+	start := time.Now()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type raceResult struct {
+		spec ServerSpec
+		res  QueryResult
+	}
+	resultChan := make(chan raceResult, len(specs))
+	for _, spec := range specs {
+		go func(spec ServerSpec) {
+			res, err := c.queryServer(ctx, domain, qtype, c.specInfo(spec))
+			if res == nil {
+				res = &QueryResult{
+					Domain: domain,
+					Type:   qtype,
+					Server: spec.Address,
+					Error:  c.newErrorInfo(err, spec.Address, 0),
+				}
+			}
+			resultChan <- raceResult{spec, *res}
+		}(spec)
+	}
+
+	var winner *QueryResult
+	var window <-chan time.Time
+	others := make([]QueryResult, 0, len(specs))
+
+race:
+	for received := 0; received < len(specs); received++ {
+		select {
+		case r := <-resultChan:
+			if !r.res.answered() || winner != nil && !r.spec.Preferred {
+				others = append(others, r.res)
+				continue
+			}
+			if winner != nil {
+				// 偏好窗口内优先服务器应答，替换先到的应答
+				others = append(others, *winner)
+			}
+			res := r.res
+			winner = &res
+			if r.spec.Preferred {
+				break race
+			}
+			window = time.After(c.config.RacePreferWindow)
+		case <-window:
+			break race
+		case <-ctx.Done():
+			break race
+		}
+	}
+
+	if winner == nil {
+		result.Results = append(result.Results, others...)
+		result.FinishedAt = c.config.Clock.Now()
+		result.Elapsed = time.Since(start)
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		return result, fmt.Errorf("all servers failed in mixed race")
+	}
+
+	rc.add(*winner)
+	result.Results = append(result.Results, others...)
+	result.FinishedAt = c.config.Clock.Now()
+	result.Elapsed = time.Since(start)
+	return result, nil
+}
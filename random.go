@@ -0,0 +1,75 @@
+package godns
+
+import (
+	"encoding/hex"
+	"math/rand"
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+// RandSource 随机数来源，*math/rand.Rand 与 *math/rand/v2.Rand 均满足该接口
+type RandSource interface {
+	Uint64() uint64
+}
+
+// WithRandSource 使用指定的随机数来源驱动所有随机决策（消息ID、随机源端口、客户端ID等），
+// 用于复现问题或编写确定性测试。客户端会对其加锁，来源无需并发安全
+// 注意：默认使用加密安全的随机数生成消息ID和源端口，替换为可预测的来源会削弱抗DNS欺骗能力，
+// 不应在生产环境使用
+func WithRandSource(r RandSource) Option {
+	return func(c *Config) {
+		c.Rand = r
+	}
+}
+
+// WithDeterministic 使用固定种子的伪随机来源，相同种子下随机决策序列相同，仅用于测试
+func WithDeterministic(seed int64) Option {
+	return WithRandSource(rand.New(rand.NewSource(seed)))
+}
+
+// lockedRand 为注入的随机数来源加锁
+type lockedRand struct {
+	mu  sync.Mutex
+	src RandSource
+}
+
+func (r *lockedRand) Uint64() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.src.Uint64()
+}
+
+// randomID 返回消息ID，未注入随机数来源时使用加密安全的随机数
+func (c *Client) randomID() uint16 {
+	if c.rand == nil {
+		return dns.Id()
+	}
+	return uint16(c.rand.Uint64())
+}
+
+// randomIntn 返回 [0, n) 内的随机数，未注入随机数来源时返回 -1，由调用方使用默认来源
+func (c *Client) randomIntn(n int) int {
+	if c.rand == nil {
+		return -1
+	}
+	return int(c.rand.Uint64() % uint64(n))
+}
+
+// intn 返回 [0, n) 内的随机数，未注入随机数来源时使用 math/rand
+func (c *Client) intn(n int) int {
+	if i := c.randomIntn(n); i >= 0 {
+		return i
+	}
+	return rand.Intn(n)
+}
+
+// seededClientID 使用注入的随机数来源生成客户端短ID
+func (c *Client) seededClientID() string {
+	var b [4]byte
+	v := c.rand.Uint64()
+	for i := range b {
+		b[i] = byte(v >> (8 * i))
+	}
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,96 @@
+package godns_test
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+	"github.com/zan8in/godns/internal/testserver"
+)
+
+// TestDeterministicScenario 相同种子下，包含重试轮换、超时、随机源端口和SRV加权排序的场景
+// 两次运行得到完全相同的随机决策和尝试序列；不同种子得到不同的序列
+func TestDeterministicScenario(t *testing.T) {
+	dead, good := startServer(t), startServer(t)
+
+	run := func(seed int64) []string {
+		for _, s := range []*testserver.Server{dead, good} {
+			s.Reset()
+		}
+		dead.Handle("www.example.com", dns.TypeA, testserver.Reply{Drop: true})
+		good.Answer("www.example.com", dns.TypeA, "www.example.com. 60 IN A 192.0.2.1")
+		for _, s := range []*testserver.Server{dead, good} {
+			s.Answer(sipName, dns.TypeSRV,
+				sipName+". 60 IN SRV 10 10 5060 a.example.test.",
+				sipName+". 60 IN SRV 10 20 5060 b.example.test.",
+				sipName+". 60 IN SRV 10 30 5060 c.example.test.",
+				sipName+". 60 IN SRV 20 0 5060 d.example.test.",
+			)
+		}
+
+		var mu sync.Mutex
+		var trace []string
+		c := godns.New(
+			godns.WithServers(dead.UDPAddr, good.UDPAddr),
+			godns.WithRetryPlacement(godns.NextServer),
+			godns.WithRetries(1),
+			godns.WithTimeout(100*time.Millisecond),
+			godns.WithSourcePortRandomization(),
+			godns.WithDeterministic(seed),
+			godns.WithOnRequest(func(_ context.Context, server string, msg *dns.Msg) {
+				mu.Lock()
+				defer mu.Unlock()
+				trace = append(trace, fmt.Sprintf("request %s id=%d %s", server, msg.Id, msg.Question[0].Name))
+			}),
+		)
+		defer c.Close()
+		trace = append(trace, "client "+c.Name())
+
+		for range 3 {
+			res, err := c.QueryA(context.Background(), "www.example.com")
+			if err != nil {
+				t.Fatal(err)
+			}
+			// 超时的错误文本取决于套接字读超时和 context 截止时间哪个先到，只记录是否失败
+			for _, a := range res.Path.Attempts {
+				trace = append(trace, fmt.Sprintf("attempt %d %s failed=%v", a.Number, a.Server, a.Error != ""))
+			}
+		}
+		for range 3 {
+			records, err := c.LookupSRV(context.Background(), sipName)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, r := range records {
+				trace = append(trace, "srv "+r.Target)
+			}
+		}
+		for _, s := range []*testserver.Server{dead, good} {
+			trace = append(trace, sourcePorts(t, s.Queries())...)
+		}
+		return trace
+	}
+
+	first, second := run(7), run(7)
+	if !slices.Equal(first, second) {
+		t.Errorf("same seed produced different sequences:\n%v\n%v", first, second)
+	}
+	// 三次查询都先在失效的服务器上超时，再轮换到正常的服务器
+	attempts := 0
+	for _, line := range first {
+		if len(line) > 8 && line[:8] == "attempt " {
+			attempts++
+		}
+	}
+	if attempts != 6 {
+		t.Errorf("recorded %d attempts, want 6", attempts)
+	}
+	if other := run(8); slices.Equal(first, other) {
+		t.Error("different seeds produced identical sequences")
+	}
+}
@@ -0,0 +1,71 @@
+package godns
+
+import (
+	"context"
+	"errors"
+
+	"github.com/miekg/dns"
+)
+
+// Exchange 发送调用方构建的查询消息并返回原始应答，适合构造 NOTIFY、UPDATE、多问题或携带自定义EDNS选项的消息
+// 消息按原样发送，ID、标志位和EDNS均不做修改；与 Query 共用协议、代理、重试和故障转移机制，
+// 按第一个问题的名称匹配路由规则，支持 ContextWithServers 临时指定服务器
+// 不经过缓存、名称策略、OnRequest 和应答校验，UDP应答被截断时也不改用TCP；应答的 Rcode 不视为错误
+func (c *Client) Exchange(ctx context.Context, msg *dns.Msg) (*dns.Msg, error) {
+	if msg == nil {
+		return nil, errors.New("godns: nil message")
+	}
+	if err := checkOverrides(ctx); err != nil {
+		return nil, err
+	}
+	if servers, ok := serversFromContext(ctx); ok {
+		return c.exchangeFailover(c.adHocContext(ctx), msg, c.normalizeServers(servers))
+	}
+	if len(msg.Question) > 0 {
+		if rt := c.route(msg.Question[0].Name); rt != nil {
+			return rt.client.Exchange(ctx, msg)
+		}
+	}
+	if len(c.config.Servers) == 0 {
+		return nil, ErrNoServers
+	}
+	return c.exchangeFailover(ctx, msg, c.config.Servers)
+}
+
+// exchangeFailover 与 queryFailover 相同：按顺序发送到各服务器，失败时转向下一个，轮换重试策略下交由重试轮换
+func (c *Client) exchangeFailover(ctx context.Context, msg *dns.Msg, servers []string) (*dns.Msg, error) {
+	limit := c.config.MaxForwarders
+	if limit <= 0 || limit > len(servers) {
+		limit = len(servers)
+	}
+	if c.config.RetryPlacement != SameServer && len(servers) > 1 {
+		return c.ExchangeWithServer(withRetryServers(ctx, servers), msg, servers[0])
+	}
+
+	var response *dns.Msg
+	var err error
+	for _, server := range servers[:limit] {
+		response, err = c.ExchangeWithServer(ctx, msg, server)
+		if err == nil || ctx.Err() != nil {
+			break
+		}
+	}
+	return response, err
+}
+
+// ExchangeWithServer 将调用方构建的查询消息按原样发送到指定服务器并返回原始应答
+// server 的格式与 WithServers 相同，可带协议前缀；其余行为与 Exchange 相同
+func (c *Client) ExchangeWithServer(ctx context.Context, msg *dns.Msg, server string) (*dns.Msg, error) {
+	if msg == nil {
+		return nil, errors.New("godns: nil message")
+	}
+	c.logConfigOnce()
+	ctx, leave, err := c.drain.enter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer leave()
+	defer c.sched.enterForeground(ctx)()
+	info := c.serverInfo(server)
+	return c.exchange(ctx, info.Protocol, msg, info.Address)
+}
@@ -0,0 +1,136 @@
+package godns_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+	"github.com/zan8in/godns/internal/testserver"
+)
+
+// TestRcodeCarriedThrough 各协议下应答码都原样记录在结果中，NXDOMAIN 与空应答、SERVFAIL 可以区分
+// 失败的应答码以可用 errors.Is 判断的错误返回，结果照常带有应答码
+func TestRcodeCarriedThrough(t *testing.T) {
+	s := startServer(t)
+	// 未编排的名称得到 NXDOMAIN
+	s.Handle("nodata.test", dns.TypeA, testserver.Reply{})
+	s.Handle("servfail.test", dns.TypeA, testserver.Reply{Rcode: dns.RcodeServerFailure})
+
+	tests := []struct {
+		domain string
+		rcode  int
+		err    error
+	}{
+		{"nonexistent.test", dns.RcodeNameError, godns.ErrNXDomain},
+		{"nodata.test", dns.RcodeSuccess, nil},
+		{"servfail.test", dns.RcodeServerFailure, godns.ErrServFail},
+	}
+	for _, p := range []struct {
+		protocol godns.Protocol
+		addr     string
+	}{
+		{godns.UDP, s.UDPAddr},
+		{godns.TCP, s.TCPAddr},
+		{godns.DoT, s.DoTAddr},
+		{godns.DoH, s.DoHURL},
+	} {
+		c := godns.New(
+			godns.WithProtocol(p.protocol),
+			godns.WithServers(p.addr),
+			godns.WithTLSConfig(s.ClientTLSConfig()),
+			godns.WithRetries(0),
+		)
+		defer c.Close()
+		for _, tt := range tests {
+			t.Run(string(p.protocol)+"/"+tt.domain, func(t *testing.T) {
+				res, err := c.QueryA(context.Background(), tt.domain)
+				if tt.err == nil && err != nil || tt.err != nil && !errors.Is(err, tt.err) {
+					t.Fatalf("err = %v, want %v", err, tt.err)
+				}
+				if res.Rcode != tt.rcode || res.RcodeString() != dns.RcodeToString[tt.rcode] {
+					t.Errorf("rcode = %d (%s), want %d", res.Rcode, res.RcodeString(), tt.rcode)
+				}
+				if res.IsNXDOMAIN() != (tt.rcode == dns.RcodeNameError) {
+					t.Errorf("IsNXDOMAIN = %v", res.IsNXDOMAIN())
+				}
+				if len(res.Records) != 0 {
+					t.Errorf("records = %v", res.Records)
+				}
+
+				// 应答码在序列化后保留
+				data, err := json.Marshal(res)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var decoded godns.QueryResult
+				if err := json.Unmarshal(data, &decoded); err != nil {
+					t.Fatal(err)
+				}
+				if decoded.Rcode != tt.rcode {
+					t.Errorf("decoded rcode = %d, want %d", decoded.Rcode, tt.rcode)
+				}
+			})
+		}
+	}
+
+	var nilResult *godns.QueryResult
+	if nilResult.IsNXDOMAIN() {
+		t.Error("nil result reports NXDOMAIN")
+	}
+}
+
+// TestRcodeFromCacheAndMultiQuery 负缓存命中和 MultiQuery 的各个结果同样带有应答码
+func TestRcodeFromCacheAndMultiQuery(t *testing.T) {
+	s := startServer(t)
+	c := godns.New(godns.WithServers(s.UDPAddr), godns.WithCache(time.Minute), godns.WithNegativeCache(time.Minute))
+	defer c.Close()
+
+	for _, source := range []string{godns.SourceNetwork, godns.SourceCache} {
+		res, err := c.QueryA(context.Background(), "nonexistent.test")
+		if !errors.Is(err, godns.ErrNXDomain) {
+			t.Fatalf("err = %v, want ErrNXDomain", err)
+		}
+		if res.Path.Source != source || !res.IsNXDOMAIN() {
+			t.Errorf("source = %q, rcode = %s, want NXDOMAIN from %s", res.Path.Source, res.RcodeString(), source)
+		}
+	}
+
+	other := startServer(t)
+	other.Answer("exists-once.test", dns.TypeA, "exists-once.test. 60 IN A 192.0.2.1")
+	mc := godns.New(godns.WithServers(s.UDPAddr, other.UDPAddr))
+	defer mc.Close()
+	res, err := mc.MultiQueryA(context.Background(), "exists-once.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int{s.UDPAddr: dns.RcodeNameError, other.UDPAddr: dns.RcodeSuccess}
+	for _, r := range res.Results {
+		if r.Rcode != want[r.Server] {
+			t.Errorf("%s: rcode = %s", r.Server, r.RcodeString())
+		}
+	}
+}
+
+// TestNXDomainStopsFailover NXDOMAIN 是确定的应答，不会转向下一个服务器
+func TestNXDomainStopsFailover(t *testing.T) {
+	first, second := startServer(t), startServer(t)
+	second.Answer("nonexistent.test", dns.TypeA, "nonexistent.test. 60 IN A 192.0.2.1")
+	c := godns.New(godns.WithServers(first.UDPAddr, second.UDPAddr), godns.WithRetries(0), godns.WithMaxForwarders(2))
+	defer c.Close()
+
+	res, err := c.QueryA(context.Background(), "nonexistent.test")
+	var rcodeErr *godns.RcodeError
+	if !errors.As(err, &rcodeErr) || rcodeErr.Rcode != dns.RcodeNameError || rcodeErr.Server != first.UDPAddr {
+		t.Fatalf("err = %v, want NXDOMAIN from %s", err, first.UDPAddr)
+	}
+	if res.Server != first.UDPAddr || !res.IsNXDOMAIN() {
+		t.Errorf("result from %s with rcode %s", res.Server, res.RcodeString())
+	}
+	if n := len(second.Queries()); n != 0 {
+		t.Errorf("second server saw %d queries, want 0", n)
+	}
+}
@@ -0,0 +1,112 @@
+package godns
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+// recordBenchMsg 构建包含 n 条 A/MX/SRV/TXT 记录的应答
+func recordBenchMsg(n int) *dns.Msg {
+	msg := new(dns.Msg)
+	msg.Answer = make([]dns.RR, 0, n)
+	for i := range n {
+		hdr := func(t uint16) dns.RR_Header {
+			return dns.RR_Header{Name: "example.com.", Rrtype: t, Class: dns.ClassINET, Ttl: 300}
+		}
+		var rr dns.RR
+		switch i % 4 {
+		case 0:
+			rr = &dns.A{Hdr: hdr(dns.TypeA), A: []byte{192, 0, 2, byte(i)}}
+		case 1:
+			rr = &dns.MX{Hdr: hdr(dns.TypeMX), Preference: uint16(i), Mx: "mx.example.com."}
+		case 2:
+			rr = &dns.SRV{Hdr: hdr(dns.TypeSRV), Priority: 10, Weight: 5, Port: uint16(i), Target: "sip.example.com."}
+		default:
+			rr = &dns.TXT{Hdr: hdr(dns.TypeTXT), Txt: []string{"v=spf1 -all"}}
+		}
+		msg.Answer = append(msg.Answer, rr)
+	}
+	return msg
+}
+
+// BenchmarkRecordConversion 一百万条记录的转换：只读类型化字段时不格式化 Value
+func BenchmarkRecordConversion(b *testing.B) {
+	const n = 1_000_000
+	c := New(WithMaxAnswers(n))
+	defer c.Close()
+	msg := recordBenchMsg(n)
+	ctx := context.Background()
+
+	b.Run("without-value", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			var res QueryResult
+			c.fillRecords(ctx, &res, msg)
+			if len(res.Records) != n {
+				b.Fatalf("converted %d records, want %d", len(res.Records), n)
+			}
+		}
+	})
+	b.Run("with-value", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			var res QueryResult
+			c.fillRecords(ctx, &res, msg)
+			for _, r := range res.Records {
+				_ = r.Value()
+			}
+		}
+	})
+}
+
+// TestRecordValueConcurrent 并发读取同一记录（及其副本）的 Value 和 JSON 时只格式化一次，结果一致
+func TestRecordValueConcurrent(t *testing.T) {
+	c := New()
+	defer c.Close()
+	var res QueryResult
+	c.fillRecords(context.Background(), &res, recordBenchMsg(8))
+	want := []string{"192.0.2.0", "1 mx.example.com.", "10 5 2 sip.example.com.", "v=spf1 -all"}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 64*len(res.Records))
+	for range 64 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i, r := range res.Records {
+				copied := r
+				if got := copied.Value(); i < len(want) && got != want[i] {
+					errs <- fmt.Errorf("record %d Value = %q, want %q", i, got, want[i])
+				}
+				data, err := json.Marshal(r)
+				if err != nil {
+					errs <- err
+					continue
+				}
+				if !strings.Contains(string(data), fmt.Sprintf("%q", r.Value())) {
+					errs <- fmt.Errorf("record %d JSON %s lacks its value", i, data)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	// 副本共享同一份缓存：在副本上读取后原记录已有格式化结果
+	var fresh QueryResult
+	c.fillRecords(context.Background(), &fresh, recordBenchMsg(1))
+	copied := fresh.Records[0]
+	copied.Value()
+	if fresh.Records[0].value.text != "192.0.2.0" {
+		t.Errorf("memoized value on the original = %q, want it shared with the copy", fresh.Records[0].value.text)
+	}
+}
@@ -0,0 +1,171 @@
+package godns
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	// refusalTTL 拒绝分类的有效期
+	refusalTTL = time.Minute
+	// refusalProbeInterval 同一服务器两次探测之间的最小间隔
+	refusalProbeInterval = 10 * time.Second
+)
+
+// 服务器应答 REFUSED 时的分类，可与 errors.Is 配合使用，两者都满足 errors.Is(err, ErrRefused)
+var (
+	// ErrRefusedName 服务器拒绝解析该名称（例如策略拦截），但仍为本客户端服务，应换用其他服务器而不是重试
+	ErrRefusedName = fmt.Errorf("%w for this name", ErrRefused)
+	// ErrRefusedClient 服务器拒绝为本客户端服务（例如访问控制），分类有效期内发往该服务器的查询直接失败
+	ErrRefusedClient = fmt.Errorf("%w for this client", ErrRefused)
+)
+
+// WithoutRefusalProbe 关闭 REFUSED 探测：服务器应答 REFUSED 时不再发送探测查询，一律按 ErrRefusedName 处理
+func WithoutRefusalProbe() Option {
+	return func(c *Config) {
+		c.SkipRefusalProbe = true
+	}
+}
+
+// ServerHealth 服务器的健康状态
+type ServerHealth struct {
+	Server  string
+	Usable  bool      // 为 false 表示服务器拒绝为本客户端服务
+	Refusal error     // 最近一次 REFUSED 的分类：ErrRefusedName 或 ErrRefusedClient
+	Until   time.Time // 分类的过期时间
+}
+
+// refusal 单个服务器的 REFUSED 分类
+type refusal struct {
+	scope     error // ErrRefusedName 或 ErrRefusedClient，未完成分类时为 nil
+	expires   time.Time
+	lastProbe time.Time
+}
+
+// refusalTracker 按服务器记录 REFUSED 分类，限制探测频率
+type refusalTracker struct {
+	mu      sync.Mutex
+	clock   Clock
+	servers map[string]*refusal
+}
+
+// newRefusalTracker 创建 REFUSED 分类记录
+func newRefusalTracker(clock Clock) *refusalTracker {
+	return &refusalTracker{clock: clock, servers: make(map[string]*refusal)}
+}
+
+// lookup 返回未过期的分类，没有分类时返回 nil
+func (t *refusalTracker) lookup(server string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	r, ok := t.servers[server]
+	if !ok || !t.clock.Now().Before(r.expires) {
+		return nil
+	}
+	return r.scope
+}
+
+// beginProbe 探测间隔内未探测过该服务器时记录探测时间并返回 true
+func (t *refusalTracker) beginProbe(server string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.clock.Now()
+	r, ok := t.servers[server]
+	if !ok {
+		r = &refusal{}
+		t.servers[server] = r
+	}
+	if !r.lastProbe.IsZero() && now.Sub(r.lastProbe) < refusalProbeInterval {
+		return false
+	}
+	r.lastProbe = now
+	return true
+}
+
+// set 保存分类
+func (t *refusalTracker) set(server string, scope error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	r, ok := t.servers[server]
+	if !ok {
+		r = &refusal{}
+		t.servers[server] = r
+	}
+	r.scope = scope
+	r.expires = t.clock.Now().Add(refusalTTL)
+}
+
+// health 返回各服务器未过期的分类，按服务器排序
+func (t *refusalTracker) health() []ServerHealth {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.clock.Now()
+	var states []ServerHealth
+	for server, r := range t.servers {
+		if r.scope == nil || !now.Before(r.expires) {
+			continue
+		}
+		states = append(states, ServerHealth{
+			Server:  server,
+			Usable:  r.scope != ErrRefusedClient,
+			Refusal: r.scope,
+			Until:   r.expires,
+		})
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Server < states[j].Server })
+	return states
+}
+
+// ServerHealth 返回近期应答过 REFUSED 的服务器及其分类，未出现在结果中的服务器视为可用
+func (c *Client) ServerHealth() []ServerHealth {
+	return c.refusals.health()
+}
+
+// refusedClient 服务器在分类有效期内拒绝为本客户端服务时返回 ErrRefusedClient
+func (c *Client) refusedClient(server string) error {
+	if c.refusals.lookup(server) == ErrRefusedClient {
+		return refusedError(server, fmt.Errorf("%w: %s (cached)", ErrRefusedClient, server))
+	}
+	return nil
+}
+
+// refusedError 将 REFUSED 的分类包装为 *RcodeError
+func refusedError(server string, err error) error {
+	return &RcodeError{Rcode: dns.RcodeRefused, Server: server, Err: err}
+}
+
+// classifyRefusal 对应答 REFUSED 的服务器分类：向同一服务器查询已知可用的名称（同 WithSelfTestQuery），
+// 探测也被拒绝说明服务器拒绝的是客户端，否则拒绝的只是该名称
+// 探测失败、被限频或已关闭时不缓存分类，按 ErrRefusedName 返回
+func (c *Client) classifyRefusal(ctx context.Context, protocol Protocol, server, domain string) error {
+	if scope := c.refusals.lookup(server); scope != nil {
+		return refusedError(server, fmt.Errorf("%w: %s refused %s", scope, server, domain))
+	}
+	if c.config.SkipRefusalProbe || !c.refusals.beginProbe(server) {
+		return refusedError(server, fmt.Errorf("%w: %s refused %s", ErrRefusedName, server, domain))
+	}
+
+	name, qtype := c.config.SelfTestName, c.config.SelfTestType
+	if name == "" {
+		name, qtype = ".", dns.TypeNS
+	}
+	// 探测只针对该服务器，不参与重试轮换；使用独立的解析路径，不混入本次查询的尝试记录
+	probeCtx := context.WithValue(withRetryServers(ctx, nil), pathRecorderKey{}, (*pathRecorder)(nil))
+	msg := c.newQueryMsg(probeCtx, name, qtype)
+	response, err := c.exchange(probeCtx, protocol, msg, server)
+	if err != nil {
+		return refusedError(server, fmt.Errorf("%w: %s refused %s (probe failed: %v)", ErrRefusedName, server, domain, err))
+	}
+
+	scope := ErrRefusedName
+	if response.Rcode == dns.RcodeRefused {
+		scope = ErrRefusedClient
+	}
+	c.refusals.set(server, scope)
+	return refusedError(server, fmt.Errorf("%w: %s refused %s", scope, server, domain))
+}
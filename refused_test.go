@@ -0,0 +1,105 @@
+package godns_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+	"github.com/zan8in/godns/internal/testserver"
+)
+
+// TestRefusalClassification 探测成功时 REFUSED 归为 ErrRefusedName，探测也被拒绝时归为 ErrRefusedClient，
+// 探测不计入本次查询的解析路径
+func TestRefusalClassification(t *testing.T) {
+	tests := []struct {
+		name     string
+		setup    func(*testserver.Server)
+		opts     []godns.Option
+		want     error
+		usable   bool
+		cached   bool // 分类是否被缓存
+		wantSeen int  // 服务器收到的查询数（含探测）
+	}{
+		{
+			name: "name",
+			setup: func(s *testserver.Server) {
+				s.Handle("blocked.test", dns.TypeA, testserver.Reply{Rcode: dns.RcodeRefused})
+				s.Answer(".", dns.TypeNS, ". 60 IN NS a.root-servers.net.")
+			},
+			want:     godns.ErrRefusedName,
+			usable:   true,
+			cached:   true,
+			wantSeen: 2,
+		},
+		{
+			name: "client",
+			setup: func(s *testserver.Server) {
+				s.SetDefault(testserver.Reply{Rcode: dns.RcodeRefused})
+			},
+			want:     godns.ErrRefusedClient,
+			cached:   true,
+			wantSeen: 2,
+		},
+		{
+			name: "no-probe",
+			setup: func(s *testserver.Server) {
+				s.SetDefault(testserver.Reply{Rcode: dns.RcodeRefused})
+			},
+			opts:     []godns.Option{godns.WithoutRefusalProbe()},
+			want:     godns.ErrRefusedName,
+			wantSeen: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := startServer(t)
+			tt.setup(s)
+			c := godns.New(append([]godns.Option{
+				godns.WithServers(s.UDPAddr),
+				godns.WithRetries(0),
+				godns.WithTimeout(2 * time.Second),
+			}, tt.opts...)...)
+			defer c.Close()
+
+			res, err := c.QueryA(context.Background(), "blocked.test")
+			if !errors.Is(err, tt.want) || !errors.Is(err, godns.ErrRefused) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+			var rcodeErr *godns.RcodeError
+			if !errors.As(err, &rcodeErr) || rcodeErr.Rcode != dns.RcodeRefused || rcodeErr.Server != s.UDPAddr {
+				t.Errorf("RcodeError = %+v", rcodeErr)
+			}
+			if n := len(res.Path.Attempts); n != 1 {
+				t.Errorf("attempts = %d, want 1 (the probe is not part of the path)", n)
+			}
+			if n := len(s.Queries()); n != tt.wantSeen {
+				t.Errorf("server saw %d queries, want %d", n, tt.wantSeen)
+			}
+
+			health := c.ServerHealth()
+			if !tt.cached {
+				if len(health) != 0 {
+					t.Errorf("ServerHealth = %+v, want none", health)
+				}
+				return
+			}
+			if len(health) != 1 || health[0].Server != s.UDPAddr || health[0].Usable != tt.usable || health[0].Refusal != tt.want {
+				t.Fatalf("ServerHealth = %+v", health)
+			}
+
+			// 拒绝客户端的服务器在有效期内不再收到查询，只拒绝名称的服务器照常查询
+			s.Reset()
+			_, err = c.QueryA(context.Background(), "other.test")
+			if tt.want == godns.ErrRefusedClient {
+				if !errors.Is(err, godns.ErrRefusedClient) || len(s.Queries()) != 0 {
+					t.Errorf("cached refusal: err = %v, server saw %d queries", err, len(s.Queries()))
+				}
+			} else if len(s.Queries()) != 1 {
+				t.Errorf("server saw %d queries after a name refusal, want 1", len(s.Queries()))
+			}
+		})
+	}
+}
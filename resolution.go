@@ -0,0 +1,264 @@
+package godns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 解析来源
+const (
+	SourceNetwork = "network" // 通过网络查询获得
+)
+
+// Attempt 单次查询尝试的描述
+type Attempt struct {
+	Number    int           // 尝试序号，从1开始
+	Server    string        // 实际查询的服务器
+	Protocol  Protocol      // 使用的传输协议
+	Proxy     ProxyType     // 使用的代理类型，NoProxy 表示直连
+	Duration  time.Duration // 本次尝试耗时，不含排队时间
+	RTT       time.Duration // 查询往返时间：UDP/TCP/DoT 直连时为传输层测得的值（不含建连），其余情况等于 Duration
+	QueueWait time.Duration // 因 WithMaxOutstanding 上限排队等待的时间
+	Error     string        // 失败原因，成功时为空
+
+	RespondedBy   string // 应答的实际来源地址，经代理时为空
+	TLS           string // DoT/DoH/DoQ 实际使用的TLS配置摘要
+	Transmissions int    // 启用 WithUDPRetransmit 时本次尝试发送查询的次数
+}
+
+// ResolutionPath 记录最终应答是如何获得的
+type ResolutionPath struct {
+	Attempts []Attempt // 按发生顺序排列的尝试记录
+	Source   string    // 应答来源
+
+	EDNSDowngraded bool // 服务器不支持EDNS，查询已去掉EDNS重发
+}
+
+// Summary 返回解析路径的简要描述
+func (p *ResolutionPath) Summary() string {
+	if p == nil || len(p.Attempts) == 0 {
+		return p.source()
+	}
+	last := p.Attempts[len(p.Attempts)-1]
+	outcome := "succeeded"
+	if last.Error != "" {
+		outcome = "failed"
+	}
+	via := string(last.Protocol)
+	if last.Proxy != NoProxy {
+		via += " via " + string(last.Proxy) + " proxy"
+	}
+	summary := fmt.Sprintf("%s: %s on attempt %d/%d at %s over %s",
+		p.source(), outcome, last.Number, len(p.Attempts), last.Server, via)
+	if p.EDNSDowngraded {
+		summary += " (EDNS downgraded)"
+	}
+	return summary
+}
+
+// logSummary 返回写入调试日志的摘要，服务器地址中的密码被隐藏
+func (p *ResolutionPath) logSummary() string {
+	if p == nil || len(p.Attempts) == 0 {
+		return p.Summary()
+	}
+	redacted := *p
+	redacted.Attempts = slices.Clone(p.Attempts)
+	last := &redacted.Attempts[len(redacted.Attempts)-1]
+	last.Server = redactURL(last.Server)
+	return redacted.Summary()
+}
+
+// String 返回完整的解析路径
+func (p *ResolutionPath) String() string {
+	if p == nil {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString(p.Summary())
+	for _, a := range p.Attempts {
+		fmt.Fprintf(&b, "\n  #%d %s %s %v", a.Number, a.Server, a.Protocol, a.Duration)
+		if a.TLS != "" {
+			b.WriteString(" tls[" + a.TLS + "]")
+		}
+		if a.Error != "" {
+			b.WriteString(" error: " + a.Error)
+		}
+	}
+	return b.String()
+}
+
+func (p *ResolutionPath) source() string {
+	if p == nil || p.Source == "" {
+		return SourceNetwork
+	}
+	return p.Source
+}
+
+// pathRecorder 在一次解析过程中收集尝试记录
+type pathRecorder struct {
+	mu   sync.Mutex
+	path ResolutionPath
+}
+
+type pathRecorderKey struct{}
+
+// withPathRecorder 在 context 中挂载尝试记录器，已存在时复用，
+// 使故障转移等跨服务器的尝试记录在同一条路径中
+func withPathRecorder(ctx context.Context) (context.Context, *pathRecorder) {
+	if rec := recorderFrom(ctx); rec != nil {
+		return ctx, rec
+	}
+	rec := &pathRecorder{path: ResolutionPath{Source: SourceNetwork}}
+	return context.WithValue(ctx, pathRecorderKey{}, rec), rec
+}
+
+// recorderFrom 获取 context 中的尝试记录器
+func recorderFrom(ctx context.Context) *pathRecorder {
+	rec, _ := ctx.Value(pathRecorderKey{}).(*pathRecorder)
+	return rec
+}
+
+// record 追加一次尝试
+func (r *pathRecorder) record(a Attempt) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	a.Number = len(r.path.Attempts) + 1
+	r.path.Attempts = append(r.path.Attempts, a)
+}
+
+// setSource 设置应答来源
+func (r *pathRecorder) setSource(source string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.path.Source = source
+}
+
+// attemptInfo 传输层在单次尝试中回填的信息
+type attemptInfo struct {
+	mu            sync.Mutex
+	respondedBy   string
+	transmissions int
+	rtt           time.Duration
+}
+
+type attemptInfoKey struct{}
+
+// withAttemptInfo 为单次尝试挂载回填信息
+func withAttemptInfo(ctx context.Context) (context.Context, *attemptInfo) {
+	info := &attemptInfo{}
+	return context.WithValue(ctx, attemptInfoKey{}, info), info
+}
+
+// setRespondedBy 记录应答的来源地址
+func setRespondedBy(ctx context.Context, addr net.Addr) {
+	info, _ := ctx.Value(attemptInfoKey{}).(*attemptInfo)
+	if info == nil || addr == nil {
+		return
+	}
+	info.mu.Lock()
+	info.respondedBy = addr.String()
+	info.mu.Unlock()
+}
+
+// setTransmissions 记录本次尝试发送查询的次数
+func setTransmissions(ctx context.Context, n int) {
+	info, _ := ctx.Value(attemptInfoKey{}).(*attemptInfo)
+	if info == nil {
+		return
+	}
+	info.mu.Lock()
+	info.transmissions = n
+	info.mu.Unlock()
+}
+
+// setRTT 记录传输层测得的往返时间
+func setRTT(ctx context.Context, rtt time.Duration) {
+	info, _ := ctx.Value(attemptInfoKey{}).(*attemptInfo)
+	if info == nil {
+		return
+	}
+	info.mu.Lock()
+	info.rtt = rtt
+	info.mu.Unlock()
+}
+
+// peer 返回记录的来源地址
+func (i *attemptInfo) peer() string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.respondedBy
+}
+
+// measured 返回传输层测得的往返时间，未测得时返回 0
+func (i *attemptInfo) measured() time.Duration {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.rtt
+}
+
+// sent 返回记录的发送次数
+func (i *attemptInfo) sent() int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.transmissions
+}
+
+// respondedBy 返回最后一次成功尝试的应答来源地址
+func (p *ResolutionPath) respondedBy() string {
+	if p == nil {
+		return ""
+	}
+	for i := len(p.Attempts) - 1; i >= 0; i-- {
+		if p.Attempts[i].Error == "" {
+			return p.Attempts[i].RespondedBy
+		}
+	}
+	return ""
+}
+
+// roundTrip 返回最后一次成功尝试的往返时间与排队时间之和，全部失败时取最后一次尝试
+func (p *ResolutionPath) roundTrip() time.Duration {
+	if p == nil || len(p.Attempts) == 0 {
+		return 0
+	}
+	for i := len(p.Attempts) - 1; i >= 0; i-- {
+		if p.Attempts[i].Error == "" {
+			return p.Attempts[i].RTT + p.Attempts[i].QueueWait
+		}
+	}
+	last := p.Attempts[len(p.Attempts)-1]
+	return last.RTT + last.QueueWait
+}
+
+// markEDNSDowngraded 记录EDNS降级
+func (r *pathRecorder) markEDNSDowngraded() {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.path.EDNSDowngraded = true
+}
+
+// snapshot 返回当前路径的副本
+func (r *pathRecorder) snapshot() *ResolutionPath {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	path := r.path
+	path.Attempts = append([]Attempt(nil), r.path.Attempts...)
+	return &path
+}
@@ -0,0 +1,249 @@
+package godns_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+	"github.com/zan8in/godns/internal/testserver"
+)
+
+// scriptedTransport 按服务器依次返回预设结果，脚本用完后重复最后一项；
+// "ok" 表示正常应答，"nxdomain" 表示 NXDOMAIN 应答，其余为 SERVFAIL 错误
+type scriptedTransport struct {
+	mu     sync.Mutex
+	script map[string][]string
+	sent   []string
+}
+
+func (s *scriptedTransport) Exchange(ctx context.Context, msg *dns.Msg, server string) (*dns.Msg, error) {
+	s.mu.Lock()
+	s.sent = append(s.sent, server)
+	steps := s.script[server]
+	step := "servfail"
+	if len(steps) > 0 {
+		step = steps[0]
+		if len(steps) > 1 {
+			s.script[server] = steps[1:]
+		}
+	}
+	s.mu.Unlock()
+
+	time.Sleep(time.Millisecond)
+	reply := new(dns.Msg)
+	reply.SetReply(msg)
+	switch step {
+	case "ok":
+	case "nxdomain":
+		reply.Rcode = dns.RcodeNameError
+		return reply, nil
+	default:
+		return nil, fmt.Errorf("%s from %s: %w", step, server, godns.ErrServFail)
+	}
+	reply.Answer = []dns.RR{mustRR(msg.Question[0].Name + " 60 IN A 192.0.2.1")}
+	return reply, nil
+}
+
+// attemptLog 以 "服务器:ok/err" 的形式描述尝试记录
+func attemptLog(path *godns.ResolutionPath) []string {
+	var log []string
+	for _, a := range path.Attempts {
+		outcome := "ok"
+		if a.Error != "" {
+			outcome = "err"
+		}
+		log = append(log, a.Server+":"+outcome)
+	}
+	return log
+}
+
+func TestResolutionPath(t *testing.T) {
+	tests := []struct {
+		name     string
+		script   map[string][]string
+		opts     []godns.Option
+		attempts []string
+		failed   bool
+		summary  string
+	}{
+		{
+			name:     "first-try",
+			script:   map[string][]string{"a": {"ok"}},
+			opts:     []godns.Option{godns.WithRetries(2)},
+			attempts: []string{"a:ok"},
+			summary:  "network: succeeded on attempt 1/1 at a over udp",
+		},
+		{
+			name:     "retry-same-server",
+			script:   map[string][]string{"a": {"servfail", "timeout", "ok"}},
+			opts:     []godns.Option{godns.WithRetries(2)},
+			attempts: []string{"a:err", "a:err", "a:ok"},
+			summary:  "network: succeeded on attempt 3/3 at a over udp",
+		},
+		{
+			name:     "failover",
+			script:   map[string][]string{"a": {"servfail"}, "b": {"ok"}},
+			opts:     []godns.Option{godns.WithRetries(1), godns.WithMaxForwarders(2)},
+			attempts: []string{"a:err", "a:err", "b:ok"},
+			summary:  "network: succeeded on attempt 3/3 at b over udp",
+		},
+		{
+			name:     "next-server",
+			script:   map[string][]string{"a": {"servfail"}, "b": {"ok"}},
+			opts:     []godns.Option{godns.WithRetries(1), godns.WithRetryPlacement(godns.NextServer)},
+			attempts: []string{"a:err", "b:ok"},
+			summary:  "network: succeeded on attempt 2/2 at b over udp",
+		},
+		{
+			name:     "all-fail",
+			script:   map[string][]string{"a": {"servfail"}, "b": {"servfail"}},
+			opts:     []godns.Option{godns.WithRetries(1), godns.WithMaxForwarders(2)},
+			attempts: []string{"a:err", "a:err", "b:err", "b:err"},
+			failed:   true,
+			summary:  "network: failed on attempt 4/4 at b over udp",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := &scriptedTransport{script: tt.script}
+			opts := append([]godns.Option{godns.WithTransport(transport), godns.WithServers("a", "b")}, tt.opts...)
+			c := godns.New(opts...)
+			defer c.Close()
+
+			res, err := c.QueryA(context.Background(), "www.example.com")
+			if (err != nil) != tt.failed {
+				t.Fatalf("err = %v, want failure %v", err, tt.failed)
+			}
+			if got := attemptLog(res.Path); !slices.Equal(got, tt.attempts) {
+				t.Errorf("attempts = %v, want %v", got, tt.attempts)
+			}
+			if got := res.Path.Summary(); got != tt.summary {
+				t.Errorf("summary = %q, want %q", got, tt.summary)
+			}
+			if res.Duration <= 0 {
+				t.Errorf("duration = %v, want the network round trip", res.Duration)
+			}
+			for i, a := range res.Path.Attempts {
+				if a.Number != i+1 || a.Protocol != godns.UDP || a.Duration <= 0 {
+					t.Errorf("attempt %d = %+v", i, a)
+				}
+				if a.Error != "" && !strings.Contains(res.Path.String(), a.Error) {
+					t.Errorf("path string misses attempt error %q", a.Error)
+				}
+			}
+		})
+	}
+}
+
+// TestResolutionPathErrorCacheFailover 首个服务器命中错误缓存、故障转移后经网络获得的应答来源为网络
+func TestResolutionPathErrorCacheFailover(t *testing.T) {
+	transport := &scriptedTransport{script: map[string][]string{"a": {"servfail"}, "b": {"nxdomain", "ok"}}}
+	c := godns.New(
+		godns.WithTransport(transport),
+		godns.WithServers("a", "b"),
+		godns.WithRetries(0),
+		godns.WithMaxForwarders(2),
+		godns.WithErrorCaching(time.Minute),
+	)
+	defer c.Close()
+
+	// 第一次查询记住服务器 a 的失败
+	res, err := c.QueryA(context.Background(), "www.example.com")
+	if !errors.Is(err, godns.ErrNXDomain) || !res.IsNXDOMAIN() {
+		t.Fatalf("priming query: rcode = %d, err = %v", res.Rcode, err)
+	}
+
+	res, err = c.QueryA(context.Background(), "www.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Server != "b" || res.Path.Source != godns.SourceNetwork {
+		t.Errorf("server = %s, source = %q, want b over the network", res.Server, res.Path.Source)
+	}
+	if res.Duration <= 0 {
+		t.Errorf("duration = %v, want the network round trip", res.Duration)
+	}
+	if got := attemptLog(res.Path); !slices.Equal(got, []string{"b:ok"}) {
+		t.Errorf("attempts = %v, want [b:ok]", got)
+	}
+	if want := []string{"a", "b", "b"}; !slices.Equal(transport.sent, want) {
+		t.Errorf("exchanges = %v, want %v", transport.sent, want)
+	}
+	if st := c.ErrorCacheStats(); st.Suppressed != 1 {
+		t.Errorf("error cache stats = %+v, want 1 suppressed", st)
+	}
+}
+
+// TestResolutionPathSources 缓存应答的来源为缓存且没有尝试记录；经代理的尝试记录代理类型
+func TestResolutionPathSources(t *testing.T) {
+	s := startServer(t)
+	s.Answer("www.example.com", dns.TypeA, "www.example.com. 60 IN A 192.0.2.1")
+	proxy, err := testserver.StartSOCKS5("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer proxy.Close()
+	c := godns.New(
+		godns.WithServers("tcp://"+s.TCPAddr),
+		godns.WithSOCKS5Proxy(proxy.Addr, nil),
+		godns.WithCache(time.Minute),
+	)
+	defer c.Close()
+
+	res, err := c.QueryA(context.Background(), "www.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Path.Attempts) != 1 || res.Path.Attempts[0].Proxy != godns.SOCKS5 || res.Path.Attempts[0].Protocol != godns.TCP {
+		t.Errorf("attempts = %+v, want one TCP attempt via SOCKS5", res.Path.Attempts)
+	}
+	if want := "via socks5 proxy"; !strings.Contains(res.Path.Summary(), want) {
+		t.Errorf("summary = %q, want it to contain %q", res.Path.Summary(), want)
+	}
+
+	res, err = c.QueryA(context.Background(), "www.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Path.Source != godns.SourceCache || len(res.Path.Attempts) != 0 || res.Path.Summary() != godns.SourceCache {
+		t.Errorf("cached path = %+v (%q), want the cache without attempts", res.Path, res.Path.Summary())
+	}
+}
+
+// TestResolutionPathOutput 解析路径随结果序列化为JSON，调试日志中每次查询输出路径摘要
+func TestResolutionPathOutput(t *testing.T) {
+	logs := captureLog(t)
+	transport := &scriptedTransport{script: map[string][]string{"a": {"servfail", "ok"}}}
+	c := godns.New(godns.WithTransport(transport), godns.WithServers("a"), godns.WithRetries(1), godns.WithName("paths"), godns.WithDebug())
+	defer c.Close()
+
+	res, err := c.QueryA(context.Background(), "www.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded godns.QueryResult
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Path == nil || !reflect.DeepEqual(*decoded.Path, *res.Path) {
+		t.Errorf("path after JSON round trip = %+v, want %+v", decoded.Path, res.Path)
+	}
+
+	want := "godns[paths]: www.example.com A: network: succeeded on attempt 2/2 at a over udp"
+	if !strings.Contains(logs.String(), want) {
+		t.Errorf("log = %q, want a line containing %q", logs.String(), want)
+	}
+}
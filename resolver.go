@@ -0,0 +1,22 @@
+package godns
+
+import (
+	"context"
+	"net/netip"
+)
+
+// Resolver godns 稳定的查询接口，供下游应用依赖接口而非具体的 *Client，以便在测试中替换
+// 该接口刻意保持精简，作为兼容性承诺的范围，不会随新增的便捷方法扩展；
+// 测试替身见 godnstest.FakeResolver
+type Resolver interface {
+	Query(ctx context.Context, domain string, qtype uint16) (*QueryResult, error)
+	QueryA(ctx context.Context, domain string) (*QueryResult, error)
+	QueryAAAA(ctx context.Context, domain string) (*QueryResult, error)
+	QueryCNAME(ctx context.Context, domain string) (*QueryResult, error)
+	QueryMX(ctx context.Context, domain string) (*QueryResult, error)
+	QueryTXT(ctx context.Context, domain string) (*QueryResult, error)
+	MultiQuery(ctx context.Context, domain string, qtype uint16) (*MultiQueryResult, error)
+	LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error)
+}
+
+var _ Resolver = (*Client)(nil)
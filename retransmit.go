@@ -0,0 +1,78 @@
+package godns
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// WithUDPRetransmit 在单次UDP尝试内按 schedule 重发查询：发送后等待 schedule[0]，未收到应答则在同一套接字上
+// 以相同的消息ID重发，再等待 schedule[1]，依此类推；任何一次发送的应答（包括迟到的早先发送的应答）
+// 通过校验即视为成功并停止重发
+// 与尝试级重试相比无需重新建立连接，在丢包率较高的链路上能显著降低尾延迟
+// 重发发生在单次尝试的超时时间内，不会延长 WithTimeout×(WithRetries+1) 的总体预算；
+// 每次尝试的发送次数记录在 Attempt.Transmissions 中
+func WithUDPRetransmit(schedule ...time.Duration) Option {
+	return func(c *Config) {
+		c.UDPRetransmit = schedule
+	}
+}
+
+// exchangeRetransmit 在已建立的UDP连接上发送查询并按配置的间隔重发，返回第一个通过校验的应答
+func (c *Client) exchangeRetransmit(ctx context.Context, client *dns.Client, conn *dns.Conn, msg *dns.Msg) (*dns.Msg, error) {
+	conn.UDPSize = client.UDPSize
+	if opt := msg.IsEdns0(); opt != nil && opt.UDPSize() >= dns.MinMsgSize {
+		conn.UDPSize = opt.UDPSize()
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	type readResult struct {
+		response *dns.Msg
+		err      error
+	}
+	responses := make(chan readResult, 1)
+	// 读取协程在连接关闭或截止时间到达时退出
+	go func() {
+		for {
+			p, err := conn.ReadMsgHeader(nil)
+			if errors.Is(err, dns.ErrShortRead) {
+				continue
+			}
+			if err != nil {
+				responses <- readResult{nil, err}
+				return
+			}
+			// 忽略无法解包、ID或问题不匹配的数据报，可能是损坏或伪造的应答，继续读取直到截止时间
+			response := new(dns.Msg)
+			if response.Unpack(p) != nil || response.Id != msg.Id || matchQuestion(msg, response) != nil {
+				continue
+			}
+			responses <- readResult{response, nil}
+			return
+		}
+	}()
+
+	for sent := 0; ; {
+		if err := conn.WriteMsg(msg); err != nil {
+			return nil, err
+		}
+		sent++
+		setTransmissions(ctx, sent)
+
+		var retransmit <-chan time.Time
+		if sent <= len(c.config.UDPRetransmit) {
+			retransmit = c.after(c.config.UDPRetransmit[sent-1])
+		}
+		select {
+		case res := <-responses:
+			return res.response, res.err
+		case <-retransmit:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package godns_test
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+)
+
+// lossyServer 丢弃每个查询的第一个数据报；应答前先发送损坏和伪造的数据报
+type lossyServer struct {
+	pc       net.PacketConn
+	mu       sync.Mutex
+	received int
+}
+
+func startLossyServer(t *testing.T) *lossyServer {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &lossyServer{pc: pc}
+	t.Cleanup(func() { pc.Close() })
+	go s.serve()
+	return s
+}
+
+func (s *lossyServer) serve() {
+	buf := make([]byte, 512)
+	for {
+		n, addr, err := s.pc.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		query := new(dns.Msg)
+		if query.Unpack(buf[:n]) != nil {
+			continue
+		}
+		s.mu.Lock()
+		s.received++
+		first := s.received == 1
+		s.mu.Unlock()
+		if first {
+			continue
+		}
+
+		// 不足一个报头的数据报
+		s.pc.WriteTo([]byte{0x01, 0x02, 0x03}, addr)
+		// 报头完整但无法解包的数据报
+		garbage := make([]byte, 20)
+		garbage[0], garbage[1] = byte(query.Id>>8), byte(query.Id)
+		garbage[5] = 1 // QDCOUNT=1，问题部分被截断
+		s.pc.WriteTo(garbage, addr)
+		// ID不匹配的伪造应答
+		spoofed := new(dns.Msg)
+		spoofed.SetReply(query)
+		spoofed.Id = query.Id + 1
+		spoofed.Answer = []dns.RR{mustRR("lossy.test. 60 IN A 198.51.100.66")}
+		if out, err := spoofed.Pack(); err == nil {
+			s.pc.WriteTo(out, addr)
+		}
+
+		reply := new(dns.Msg)
+		reply.SetReply(query)
+		reply.Answer = []dns.RR{mustRR("lossy.test. 60 IN A 192.0.2.1")}
+		if out, err := reply.Pack(); err == nil {
+			s.pc.WriteTo(out, addr)
+		}
+	}
+}
+
+func mustRR(s string) dns.RR {
+	rr, err := dns.NewRR(s)
+	if err != nil {
+		panic(err)
+	}
+	return rr
+}
+
+func TestUDPRetransmitDropFirstDatagram(t *testing.T) {
+	s := startLossyServer(t)
+	c := godns.New(
+		godns.WithServers(s.pc.LocalAddr().String()),
+		godns.WithUDPRetransmit(50*time.Millisecond, 100*time.Millisecond),
+		godns.WithTimeout(2*time.Second),
+		godns.WithRetries(0),
+	)
+	defer c.Close()
+
+	res, err := c.QueryA(context.Background(), "lossy.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Records) != 1 || res.Records[0].Value() != "192.0.2.1" {
+		t.Errorf("records = %v", res.Records)
+	}
+	if n := len(res.Path.Attempts); n != 1 {
+		t.Fatalf("attempts = %d, want 1", n)
+	}
+	if n := res.Path.Attempts[0].Transmissions; n != 2 {
+		t.Errorf("transmissions = %d, want 2", n)
+	}
+}
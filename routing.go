@@ -0,0 +1,126 @@
+package godns
+
+import (
+	"sort"
+
+	"github.com/miekg/dns"
+)
+
+// RouteRule 按域名后缀将查询路由到指定服务器
+type RouteRule struct {
+	Name    string   // 规则名称，记录在 QueryResult.Route 中
+	Suffix  string   // 域名后缀，按标签匹配，corp.example 不会匹配 evilcorp.example
+	Servers []string // 匹配该规则的查询使用的服务器
+
+	// 可选：为该规则单独指定协议和代理，为空时沿用客户端配置
+	Protocol  Protocol
+	ProxyType ProxyType
+	ProxyAddr string
+	ProxyAuth *ProxyAuth
+}
+
+// WithRouting 设置按域名后缀的路由规则，最长后缀匹配优先，未匹配的域名使用默认配置
+func WithRouting(rules ...RouteRule) Option {
+	return func(c *Config) {
+		c.Routes = rules
+	}
+}
+
+// route 路由规则及其对应的子客户端
+type route struct {
+	rule   RouteRule
+	suffix string // 规范化后的后缀
+	labels int
+	client *Client
+}
+
+// router 不可变的路由表，通过原子指针整体替换
+type router struct {
+	routes []*route // 按标签数降序，保证最长后缀优先
+}
+
+// SetRoutes 在运行时替换路由规则，旧规则持有的连接随之关闭
+// 只有路由表可以热替换：客户端的默认服务器列表在创建后固定，不提供 SetServers，
+// 需要同时更换默认服务器时应创建新的客户端
+func (c *Client) SetRoutes(rules ...RouteRule) {
+	old := c.router.Swap(c.buildRouter(rules))
+	if old != nil {
+		old.close()
+	}
+}
+
+// buildRouter 为每条规则构建继承客户端配置的子客户端
+func (c *Client) buildRouter(rules []RouteRule) *router {
+	r := &router{routes: make([]*route, 0, len(rules))}
+	for _, rule := range rules {
+		cfg := *c.config
+		cfg.Routes = nil
+		cfg.Servers = rule.Servers
+		cfg.serversExplicit = true
+		cfg.TaggedServers = nil
+		cfg.CachePersistPath = ""
+		cfg.ServerTLSConfigs = nil
+		cfg.ServerInfos = nil
+		cfg.Debug = false // 父客户端的快照已包含子客户端的服务器
+		if c.rand != nil {
+			// 共享父客户端加锁后的随机数来源
+			cfg.Rand = c.rand
+		}
+		for _, server := range rule.Servers {
+			if tlsConfig, ok := c.config.ServerTLSConfigs[server]; ok {
+				WithServerTLSConfig(server, tlsConfig)(&cfg)
+			}
+		}
+		cfg.Name = c.name + "/" + rule.Name
+		if rule.Protocol != "" {
+			cfg.Protocol = rule.Protocol
+		}
+		if rule.ProxyType != NoProxy {
+			cfg.ProxyType = rule.ProxyType
+			cfg.ProxyAddr = rule.ProxyAddr
+			cfg.ProxyAuth = rule.ProxyAuth
+		}
+
+		child := newClient(&cfg)
+		child.guard = c.guard
+		child.drain = c.drain
+		child.outstanding = c.outstanding // 并发上限作用于整个客户端，不按路由倍增
+		suffix := CanonicalName(rule.Suffix)
+		r.routes = append(r.routes, &route{
+			rule:   rule,
+			suffix: suffix,
+			labels: dns.CountLabel(suffix),
+			client: child,
+		})
+	}
+	sort.SliceStable(r.routes, func(i, j int) bool {
+		return r.routes[i].labels > r.routes[j].labels
+	})
+	return r
+}
+
+// match 返回域名匹配的最长后缀规则
+func (r *router) match(domain string) *route {
+	if r == nil || len(r.routes) == 0 {
+		return nil
+	}
+	name := CanonicalName(domain)
+	for _, rt := range r.routes {
+		if dns.IsSubDomain(rt.suffix, name) {
+			return rt
+		}
+	}
+	return nil
+}
+
+// close 关闭所有子客户端
+func (r *router) close() {
+	for _, rt := range r.routes {
+		rt.client.Close()
+	}
+}
+
+// route 查找域名对应的路由
+func (c *Client) route(domain string) *route {
+	return c.router.Load().match(domain)
+}
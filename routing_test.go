@@ -0,0 +1,107 @@
+package godns_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+)
+
+func TestRoutingSuffixMatch(t *testing.T) {
+	public, corp, dev := startServer(t), startServer(t), startServer(t)
+	c := godns.New(
+		godns.WithServers(public.UDPAddr),
+		godns.WithRetries(0),
+		godns.WithRouting(
+			godns.RouteRule{Name: "corp", Suffix: "corp.example", Servers: []string{corp.UDPAddr}},
+			godns.RouteRule{Name: "dev", Suffix: "dev.corp.example.", Servers: []string{dev.UDPAddr}},
+		),
+	)
+	defer c.Close()
+
+	tests := []struct {
+		domain, route, server string
+	}{
+		{"corp.example", "corp", corp.UDPAddr},
+		{"host.corp.example", "corp", corp.UDPAddr},
+		{"HOST.Corp.Example.", "corp", corp.UDPAddr},
+		{"host.dev.corp.example", "dev", dev.UDPAddr},
+		{"dev.corp.example", "dev", dev.UDPAddr},
+		// 按标签匹配：只共享字符串后缀的名称不命中规则
+		{"evilcorp.example", "", public.UDPAddr},
+		{"host.evilcorp.example", "", public.UDPAddr},
+		{"host.devdev.corp.example", "corp", corp.UDPAddr},
+		{"corp.example.com", "", public.UDPAddr},
+		{"example", "", public.UDPAddr},
+	}
+	for _, tt := range tests {
+		t.Run(tt.domain, func(t *testing.T) {
+			// 服务器未编排这些名称，应答 NXDOMAIN
+			res, err := c.Query(context.Background(), tt.domain, dns.TypeA)
+			if err != nil && !errors.Is(err, godns.ErrNXDomain) {
+				t.Fatal(err)
+			}
+			if res.Route != tt.route || res.Server != tt.server {
+				t.Errorf("route = %q via %s, want %q via %s", res.Route, res.Server, tt.route, tt.server)
+			}
+		})
+	}
+}
+
+func TestRoutingMultiQueryStaysInRule(t *testing.T) {
+	public, corp1, corp2 := startServer(t), startServer(t), startServer(t)
+	c := godns.New(
+		godns.WithServers(public.UDPAddr),
+		godns.WithRetries(0),
+		godns.WithRouting(godns.RouteRule{Name: "corp", Suffix: "corp.example", Servers: []string{corp1.UDPAddr, corp2.UDPAddr}}),
+	)
+	defer c.Close()
+
+	res, err := c.MultiQuery(context.Background(), "host.corp.example", dns.TypeA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Results) != 2 {
+		t.Fatalf("results = %d, want 2", len(res.Results))
+	}
+	for _, r := range res.Results {
+		if r.Route != "corp" || (r.Server != corp1.UDPAddr && r.Server != corp2.UDPAddr) {
+			t.Errorf("result from %s, route %q", r.Server, r.Route)
+		}
+	}
+	if q := public.Queries(); len(q) != 0 {
+		t.Errorf("default server saw %d queries", len(q))
+	}
+}
+
+func TestSetRoutes(t *testing.T) {
+	public, corp := startServer(t), startServer(t)
+	c := godns.New(godns.WithServers(public.UDPAddr), godns.WithRetries(0))
+	defer c.Close()
+
+	query := func() *godns.QueryResult {
+		t.Helper()
+		res, err := c.Query(context.Background(), "host.corp.example", dns.TypeA)
+		if err != nil && !errors.Is(err, godns.ErrNXDomain) {
+			t.Fatal(err)
+		}
+		return res
+	}
+	if res := query(); res.Route != "" || res.Server != public.UDPAddr {
+		t.Fatalf("before SetRoutes: route = %q via %s", res.Route, res.Server)
+	}
+	c.SetRoutes(godns.RouteRule{Name: "corp", Suffix: "corp.example", Servers: []string{corp.UDPAddr}})
+	if res := query(); res.Route != "corp" || res.Server != corp.UDPAddr {
+		t.Errorf("after SetRoutes: route = %q via %s", res.Route, res.Server)
+	}
+	c.SetRoutes()
+	if res := query(); res.Route != "" || res.Server != public.UDPAddr {
+		t.Errorf("after clearing routes: route = %q via %s", res.Route, res.Server)
+	}
+	// 默认服务器列表不随路由替换而改变
+	if got := c.Servers(); len(got) != 1 || got[0] != public.UDPAddr {
+		t.Errorf("servers = %v", got)
+	}
+}
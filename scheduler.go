@@ -0,0 +1,230 @@
+package godns
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// defaultBackgroundConcurrency 后台任务的默认并发数
+	defaultBackgroundConcurrency = 2
+	// defaultBackgroundQPS 后台任务每秒启动数量的默认上限
+	defaultBackgroundQPS = 20
+	// backgroundQueueSize 后台任务队列长度，队列满时新任务被丢弃
+	backgroundQueueSize = 256
+	// foregroundYield 有前台查询进行时后台任务的让步间隔
+	foregroundYield = 10 * time.Millisecond
+	// maxForegroundDefer 后台任务为前台查询让步的最长时间，避免持续的前台流量使后台任务饿死
+	maxForegroundDefer = time.Second
+)
+
+// WithBackgroundConcurrency 设置后台任务（缓存快照等）的并发数
+func WithBackgroundConcurrency(n int) Option {
+	return func(c *Config) {
+		c.BackgroundConcurrency = n
+	}
+}
+
+// WithBackgroundQPS 设置后台任务每秒启动数量的上限，避免后台刷新挤占前台查询或触发上游限速
+// 小于 0 表示不限制
+func WithBackgroundQPS(qps float64) Option {
+	return func(c *Config) {
+		c.BackgroundQPS = qps
+	}
+}
+
+// BackgroundStats 后台任务调度器的统计信息
+type BackgroundStats struct {
+	Queued    int    // 队列中等待执行的任务数
+	Running   int    // 正在执行的任务数
+	Completed uint64 // 已完成的任务数
+	Dropped   uint64 // 因队列已满或客户端已关闭而丢弃的任务数
+}
+
+// BackgroundStats 返回后台任务调度器的统计信息
+func (c *Client) BackgroundStats() BackgroundStats {
+	return c.sched.stats()
+}
+
+// backgroundKey 标记由后台任务发起的查询，不计入前台查询
+type backgroundKey struct{}
+
+// scheduler 客户端内所有后台任务共用的调度器：固定数量的工作协程、有界队列、
+// 全局启动速率上限，有前台查询进行时后台任务让步，Close 时统一停止
+// 工作协程在首次提交任务时才启动，没有后台任务的客户端不产生额外协程
+type scheduler struct {
+	concurrency int
+	interval    time.Duration // 相邻后台任务启动的最小间隔，0 表示不限制
+
+	jobs   chan func(context.Context)
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	startOnce sync.Once
+	closeOnce sync.Once
+
+	foreground atomic.Int64
+	running    atomic.Int64
+	completed  atomic.Uint64
+	dropped    atomic.Uint64
+
+	mu   sync.Mutex
+	next time.Time // 下一个后台任务最早的启动时间
+}
+
+// newScheduler 创建后台任务调度器
+func newScheduler(concurrency int, qps float64) *scheduler {
+	if concurrency <= 0 {
+		concurrency = defaultBackgroundConcurrency
+	}
+	if qps == 0 {
+		qps = defaultBackgroundQPS
+	}
+	s := &scheduler{
+		concurrency: concurrency,
+		jobs:        make(chan func(context.Context), backgroundQueueSize),
+	}
+	if qps > 0 {
+		s.interval = time.Duration(float64(time.Second) / qps)
+	}
+	s.ctx, s.cancel = context.WithCancel(context.WithValue(context.Background(), backgroundKey{}, true))
+	return s
+}
+
+// start 启动工作协程
+func (s *scheduler) start() {
+	s.startOnce.Do(func() {
+		for i := 0; i < s.concurrency; i++ {
+			s.wg.Add(1)
+			go s.work()
+		}
+	})
+}
+
+// submit 提交后台任务，队列已满或调度器已关闭时丢弃任务并返回 false
+func (s *scheduler) submit(job func(context.Context)) bool {
+	if s.ctx.Err() != nil {
+		s.dropped.Add(1)
+		return false
+	}
+	s.start()
+	select {
+	case s.jobs <- job:
+		return true
+	default:
+		s.dropped.Add(1)
+		return false
+	}
+}
+
+// every 按 interval 周期性提交后台任务，直到调度器关闭
+func (s *scheduler) every(interval time.Duration, job func(context.Context)) {
+	s.start()
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.submit(job)
+			case <-s.ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// work 工作协程主循环
+func (s *scheduler) work() {
+	defer s.wg.Done()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case job := <-s.jobs:
+			if !s.wait() {
+				s.dropped.Add(1)
+				return
+			}
+			s.running.Add(1)
+			job(s.ctx)
+			s.running.Add(-1)
+			s.completed.Add(1)
+		}
+	}
+}
+
+// wait 在启动后台任务前为前台查询让步并遵守速率上限，调度器关闭时返回 false
+func (s *scheduler) wait() bool {
+	// 关闭与取出任务同时发生时 select 随机选择，这里保证关闭后不再启动队列中的任务
+	if s.ctx.Err() != nil {
+		return false
+	}
+	deadline := time.Now().Add(maxForegroundDefer)
+	for s.foreground.Load() > 0 && time.Now().Before(deadline) {
+		select {
+		case <-time.After(foregroundYield):
+		case <-s.ctx.Done():
+			return false
+		}
+	}
+
+	if s.interval <= 0 {
+		return true
+	}
+	s.mu.Lock()
+	now := time.Now()
+	start := s.next
+	if start.Before(now) {
+		start = now
+	}
+	s.next = start.Add(s.interval)
+	s.mu.Unlock()
+
+	if delay := start.Sub(now); delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-s.ctx.Done():
+			return false
+		}
+	}
+	return true
+}
+
+// enterForeground 标记一次前台查询开始，返回结束时调用的函数；后台任务发起的查询不计入
+func (s *scheduler) enterForeground(ctx context.Context) func() {
+	if ctx.Value(backgroundKey{}) != nil {
+		return func() {}
+	}
+	s.foreground.Add(1)
+	return func() { s.foreground.Add(-1) }
+}
+
+// close 停止周期任务和工作协程，等待正在执行的任务结束，队列中剩余的任务被丢弃
+func (s *scheduler) close() {
+	s.closeOnce.Do(func() {
+		s.cancel()
+		s.wg.Wait()
+		for len(s.jobs) > 0 {
+			<-s.jobs
+			s.dropped.Add(1)
+		}
+	})
+}
+
+// stats 返回统计信息
+func (s *scheduler) stats() BackgroundStats {
+	return BackgroundStats{
+		Queued:    len(s.jobs),
+		Running:   int(s.running.Load()),
+		Completed: s.completed.Load(),
+		Dropped:   s.dropped.Load(),
+	}
+}
@@ -0,0 +1,192 @@
+package godns
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns/internal/testserver"
+)
+
+func startSchedulerServer(t *testing.T) *testserver.Server {
+	t.Helper()
+	s, err := testserver.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	s.Answer("fg.test", dns.TypeA, "fg.test. 60 IN A 192.0.2.1")
+	s.Handle("bg.test", dns.TypeA, testserver.Reply{
+		Answer: []dns.RR{testserver.RR("bg.test. 60 IN A 192.0.2.2")},
+		Delay:  5 * time.Millisecond,
+	})
+	return s
+}
+
+// foregroundP95 顺序发起 n 次前台查询，返回耗时的95分位
+func foregroundP95(t *testing.T, c *Client, n int) time.Duration {
+	t.Helper()
+	durations := make([]time.Duration, n)
+	for i := range durations {
+		start := time.Now()
+		if _, err := c.QueryA(context.Background(), "fg.test"); err != nil {
+			t.Fatal(err)
+		}
+		durations[i] = time.Since(start)
+	}
+	slices.Sort(durations)
+	return durations[n*95/100]
+}
+
+// TestSchedulerForegroundLatency 队列始终排满、不限速的后台查询不影响前台查询的延迟，
+// 超出队列长度的任务被丢弃并计数
+func TestSchedulerForegroundLatency(t *testing.T) {
+	s := startSchedulerServer(t)
+	c := New(WithServers(s.UDPAddr), WithBackgroundConcurrency(4), WithBackgroundQPS(-1))
+	defer c.Close()
+
+	baseline := foregroundP95(t, c, 200)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var churn sync.WaitGroup
+	churn.Add(1)
+	go func() {
+		defer churn.Done()
+		for ctx.Err() == nil {
+			c.sched.submit(func(ctx context.Context) {
+				c.QueryA(ctx, "bg.test")
+			})
+			time.Sleep(50 * time.Microsecond)
+		}
+	}()
+	waitForStats(t, c, "a full background queue", func(st BackgroundStats) bool { return st.Queued == backgroundQueueSize })
+
+	loaded := foregroundP95(t, c, 200)
+	cancel()
+	churn.Wait()
+
+	st := c.BackgroundStats()
+	if st.Completed == 0 || st.Dropped == 0 {
+		t.Errorf("background stats = %+v, want completed and dropped jobs", st)
+	}
+	if limit := 3*baseline + 5*time.Millisecond; loaded > limit {
+		t.Errorf("foreground p95 under background load = %v, baseline %v", loaded, baseline)
+	}
+	t.Logf("foreground p95: baseline %v, under load %v; background %+v", baseline, loaded, st)
+}
+
+// TestSchedulerYieldsToForeground 前台查询进行期间不启动新的后台任务
+func TestSchedulerYieldsToForeground(t *testing.T) {
+	s := startSchedulerServer(t)
+	s.Handle("slow.test", dns.TypeA, testserver.Reply{Delay: 300 * time.Millisecond})
+	c := New(WithServers(s.UDPAddr))
+	defer c.Close()
+
+	foregroundDone := make(chan time.Time, 1)
+	go func() {
+		c.QueryA(context.Background(), "slow.test")
+		foregroundDone <- time.Now()
+	}()
+	for c.sched.foreground.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	started := make(chan time.Time, 1)
+	c.sched.submit(func(context.Context) { started <- time.Now() })
+	end, start := <-foregroundDone, <-started
+	// 两个时间点分别在不同协程中取得，允许一次让步间隔的误差
+	if start.Before(end.Add(-foregroundYield)) {
+		t.Errorf("background job started %v before the foreground query finished", end.Sub(start))
+	}
+}
+
+func TestSchedulerLimits(t *testing.T) {
+	t.Run("concurrency", func(t *testing.T) {
+		sched := newScheduler(2, -1)
+		defer sched.close()
+		var running, peak atomic.Int64
+		var done sync.WaitGroup
+		for range 20 {
+			done.Add(1)
+			sched.submit(func(context.Context) {
+				defer done.Done()
+				n := running.Add(1)
+				for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+				}
+				time.Sleep(5 * time.Millisecond)
+				running.Add(-1)
+			})
+		}
+		done.Wait()
+		if p := peak.Load(); p != 2 {
+			t.Errorf("peak concurrency = %d, want 2", p)
+		}
+	})
+
+	t.Run("qps", func(t *testing.T) {
+		sched := newScheduler(4, 50) // 相邻任务至少间隔 20ms
+		defer sched.close()
+		var done sync.WaitGroup
+		start := time.Now()
+		for range 10 {
+			done.Add(1)
+			sched.submit(func(context.Context) { done.Done() })
+		}
+		done.Wait()
+		if elapsed := time.Since(start); elapsed < 180*time.Millisecond {
+			t.Errorf("10 jobs at 50 qps finished in %v", elapsed)
+		}
+	})
+
+	t.Run("queue-and-close", func(t *testing.T) {
+		sched := newScheduler(1, -1)
+		release := make(chan struct{})
+		sched.submit(func(context.Context) { <-release })
+		for sched.running.Load() == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		for i := range backgroundQueueSize + 5 {
+			if ok := sched.submit(func(context.Context) {}); ok != (i < backgroundQueueSize) {
+				t.Fatalf("submit %d accepted = %v", i, ok)
+			}
+		}
+		if st := sched.stats(); st.Queued != backgroundQueueSize || st.Running != 1 || st.Dropped != 5 {
+			t.Errorf("stats = %+v", st)
+		}
+
+		// 关闭时等待正在执行的任务，丢弃队列中的任务，之后提交的任务也被丢弃
+		closed := make(chan struct{})
+		go func() {
+			sched.close()
+			close(closed)
+		}()
+		select {
+		case <-closed:
+			t.Fatal("close returned while a job was running")
+		case <-time.After(20 * time.Millisecond):
+		}
+		close(release)
+		<-closed
+		if sched.submit(func(context.Context) {}) {
+			t.Error("submit accepted after close")
+		}
+		if st := sched.stats(); st.Queued != 0 || st.Running != 0 || st.Dropped != uint64(5+backgroundQueueSize+1) {
+			t.Errorf("stats after close = %+v", st)
+		}
+	})
+}
+
+func waitForStats(t *testing.T, c *Client, what string, cond func(BackgroundStats) bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond(c.BackgroundStats()) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s: %+v", what, c.BackgroundStats())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
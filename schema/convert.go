@@ -0,0 +1,256 @@
+package schema
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+)
+
+// ToSchema 将查询结果转换为当前版本的格式
+func ToSchema(res *godns.QueryResult) QueryResult {
+	out := toQueryResult(res)
+	out.SchemaVersion = SchemaVersion
+	return out
+}
+
+// FromSchema 将存储的查询结果还原为 godns.QueryResult，版本不受支持时返回错误
+// 还原得到的记录不包含原始资源记录，Record.RR() 返回 nil
+func FromSchema(in QueryResult) (*godns.QueryResult, error) {
+	if err := checkVersion(in.SchemaVersion); err != nil {
+		return nil, err
+	}
+	return fromQueryResult(in)
+}
+
+// checkVersion 检查存储的版本号是否可以读取
+func checkVersion(version int) error {
+	if version < minSchemaVersion || version > SchemaVersion {
+		return fmt.Errorf("schema: unsupported schema version %d", version)
+	}
+	return nil
+}
+
+// ToSchemaMulti 将多服务器查询结果转换为当前版本的格式
+func ToSchemaMulti(res *godns.MultiQueryResult) MultiQueryResult {
+	out := MultiQueryResult{
+		SchemaVersion: SchemaVersion,
+		Domain:        res.Domain,
+		Type:          typeString(res.Type),
+		Results:       make([]QueryResult, 0, len(res.Results)),
+		AllIPs:        append([]string{}, res.AllIPs...),
+		StartedAt:     res.StartedAt,
+		FinishedAt:    res.FinishedAt,
+		ElapsedMillis: float64(res.Elapsed) / float64(time.Millisecond),
+		Partial:       res.Partial,
+		QuorumReached: res.QuorumReached,
+		Agreeing:      res.Agreeing,
+		Dissenters:    res.Dissenters,
+	}
+	for i := range res.Results {
+		out.Results = append(out.Results, toQueryResult(&res.Results[i]))
+	}
+	return out
+}
+
+// FromSchemaMulti 将存储的多服务器查询结果还原为 godns.MultiQueryResult
+func FromSchemaMulti(in MultiQueryResult) (*godns.MultiQueryResult, error) {
+	if err := checkVersion(in.SchemaVersion); err != nil {
+		return nil, err
+	}
+	qtype, err := parseType(in.Type)
+	if err != nil {
+		return nil, err
+	}
+	out := &godns.MultiQueryResult{
+		Domain:        in.Domain,
+		Type:          qtype,
+		Results:       make([]godns.QueryResult, 0, len(in.Results)),
+		AllIPs:        append([]string{}, in.AllIPs...),
+		StartedAt:     in.StartedAt,
+		FinishedAt:    in.FinishedAt,
+		Elapsed:       time.Duration(in.ElapsedMillis * float64(time.Millisecond)),
+		Partial:       in.Partial,
+		QuorumReached: in.QuorumReached,
+		Agreeing:      in.Agreeing,
+		Dissenters:    in.Dissenters,
+	}
+	for _, r := range in.Results {
+		res, err := fromQueryResult(r)
+		if err != nil {
+			return nil, err
+		}
+		out.Results = append(out.Results, *res)
+	}
+	return out, nil
+}
+
+// toQueryResult 转换单个结果，不设置版本号
+func toQueryResult(res *godns.QueryResult) QueryResult {
+	out := QueryResult{
+		Domain:             res.Domain,
+		Type:               typeString(res.Type),
+		Server:             res.Server,
+		Records:            make([]Record, 0, len(res.Records)),
+		AD:                 res.AD,
+		Authoritative:      res.Authoritative,
+		Truncated:          res.Truncated,
+		RecursionAvailable: res.RecursionAvailable,
+		Tag:                res.Tag,
+		Route:              res.Route,
+		RespondedBy:        res.RespondedBy,
+		Nameserver:         res.Nameserver,
+		Synthetic:          res.Synthetic,
+		TruncatedByClient:  res.TruncatedByClient,
+		QueriedAt:          res.QueriedAt,
+		ValidUntil:         res.ValidUntil,
+		RTTMillis:          float64(res.Duration) / float64(time.Millisecond),
+	}
+	if res.Error == nil || res.Rcode != dns.RcodeSuccess {
+		out.Rcode = rcodeString(res.Rcode)
+	}
+	for _, record := range res.Records {
+		out.Records = append(out.Records, toRecord(record))
+	}
+	out.Authority = toRecords(res.Authority)
+	out.Additional = toRecords(res.Additional)
+	if res.Error != nil {
+		out.Error = &Error{
+			Message: res.Error.Message,
+			Kind:    string(res.Error.Kind),
+			Server:  res.Error.Server,
+			Attempt: res.Error.Attempt,
+			Client:  res.Error.Client,
+		}
+	}
+	return out
+}
+
+// fromQueryResult 还原单个结果
+func fromQueryResult(in QueryResult) (*godns.QueryResult, error) {
+	qtype, err := parseType(in.Type)
+	if err != nil {
+		return nil, err
+	}
+	out := &godns.QueryResult{
+		Domain:             in.Domain,
+		Type:               qtype,
+		Server:             in.Server,
+		Records:            make([]godns.Record, 0, len(in.Records)),
+		AD:                 in.AD,
+		Authoritative:      in.Authoritative,
+		Truncated:          in.Truncated,
+		RecursionAvailable: in.RecursionAvailable,
+		Tag:                in.Tag,
+		Route:              in.Route,
+		RespondedBy:        in.RespondedBy,
+		Nameserver:         in.Nameserver,
+		Synthetic:          in.Synthetic,
+		TruncatedByClient:  in.TruncatedByClient,
+		QueriedAt:          in.QueriedAt,
+		ValidUntil:         in.ValidUntil,
+		Duration:           time.Duration(in.RTTMillis * float64(time.Millisecond)),
+	}
+	if in.Rcode != "" {
+		if out.Rcode, err = parseRcode(in.Rcode); err != nil {
+			return nil, err
+		}
+	}
+	if out.Records, err = fromRecords(out.Records, in.Records); err != nil {
+		return nil, err
+	}
+	if out.Authority, err = fromRecords(nil, in.Authority); err != nil {
+		return nil, err
+	}
+	if out.Additional, err = fromRecords(nil, in.Additional); err != nil {
+		return nil, err
+	}
+	if in.Error != nil {
+		out.Error = &godns.ErrorInfo{
+			Message: in.Error.Message,
+			Kind:    godns.ErrorKind(in.Error.Kind),
+			Server:  in.Error.Server,
+			Attempt: in.Error.Attempt,
+			Client:  in.Error.Client,
+		}
+	}
+	return out, nil
+}
+
+// typeString 返回记录类型的助记符，未知类型使用 RFC 3597 的 TYPEnnn 形式
+func typeString(t uint16) string {
+	if s, ok := dns.TypeToString[t]; ok {
+		return s
+	}
+	return fmt.Sprintf("TYPE%d", t)
+}
+
+// parseType 解析记录类型助记符
+func parseType(s string) (uint16, error) {
+	if t, ok := dns.StringToType[s]; ok {
+		return t, nil
+	}
+	var t uint16
+	if _, err := fmt.Sscanf(s, "TYPE%d", &t); err == nil {
+		return t, nil
+	}
+	return 0, fmt.Errorf("schema: unknown record type %q", s)
+}
+
+// rcodeString 返回响应码助记符，未知的响应码使用 RCODEnnn 形式
+func rcodeString(rcode int) string {
+	if s, ok := dns.RcodeToString[rcode]; ok {
+		return s
+	}
+	return fmt.Sprintf("RCODE%d", rcode)
+}
+
+// parseRcode 解析响应码助记符
+func parseRcode(s string) (int, error) {
+	if rcode, ok := dns.StringToRcode[s]; ok {
+		return rcode, nil
+	}
+	var rcode int
+	if _, err := fmt.Sscanf(s, "RCODE%d", &rcode); err == nil {
+		return rcode, nil
+	}
+	return 0, fmt.Errorf("schema: unknown rcode %q", s)
+}
+
+// toRecord 转换单条记录
+func toRecord(record godns.Record) Record {
+	return Record{
+		Name:   record.Name,
+		Type:   typeString(record.Type),
+		TTL:    record.TTL,
+		Value:  record.Value(),
+		Values: record.Values,
+	}
+}
+
+// toRecords 转换授权或附加部分，没有记录时返回 nil 以便序列化时省略
+func toRecords(records []godns.Record) []Record {
+	if len(records) == 0 {
+		return nil
+	}
+	out := make([]Record, len(records))
+	for i, record := range records {
+		out[i] = toRecord(record)
+	}
+	return out
+}
+
+// fromRecords 还原记录并追加到 dst
+func fromRecords(dst []godns.Record, records []Record) ([]godns.Record, error) {
+	for _, record := range records {
+		rtype, err := parseType(record.Type)
+		if err != nil {
+			return nil, err
+		}
+		r := godns.NewValueRecord(record.Name, rtype, record.TTL, record.Value)
+		r.Values = record.Values
+		dst = append(dst, r)
+	}
+	return dst, nil
+}
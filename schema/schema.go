@@ -0,0 +1,90 @@
+// Package schema 定义 godns 结果的版本化、可长期存储的 JSON 格式
+//
+// 该包中的类型与 godns 内部结构解耦，字段名和类型只会随 SchemaVersion 升级而变化，
+// 内部重构不会影响已存储的数据。使用 ToSchema/FromSchema 在两者之间转换。
+//
+// v2 格式示例：
+//
+//	{
+//	  "schema_version": 2,
+//	  "domain": "example.com",
+//	  "type": "A",
+//	  "server": "8.8.8.8:53",
+//	  "records": [{"name": "example.com.", "type": "A", "ttl": 300, "value": "93.184.216.34"}],
+//	  "ad": false,
+//	  "authoritative": false,
+//	  "queried_at": "2024-01-01T00:00:00Z"
+//	}
+package schema
+
+import "time"
+
+// SchemaVersion 当前格式版本，写入每个顶层对象的 schema_version 字段
+// 任何字段的增删或含义变化都必须提升版本号，并在 testdata/v<版本号> 下添加该版本的固定文件（go test -update 生成）
+//
+// v2：TXT记录的 value 由各字符串以空格连接改为直接拼接，新增 values 字段保存各个字符串
+const SchemaVersion = 2
+
+// minSchemaVersion 仍可读取的最低版本，v1 中TXT记录的 value 按原样还原
+const minSchemaVersion = 1
+
+// Record DNS记录
+type Record struct {
+	Name   string   `json:"name"`             // 完全限定的所有者名称
+	Type   string   `json:"type"`             // 记录类型助记符，例如 "A"、"MX"
+	TTL    uint32   `json:"ttl"`              // 秒
+	Value  string   `json:"value"`            // 与 godns.Record.Value 格式相同
+	Values []string `json:"values,omitempty"` // TXT记录的各个字符串，与 godns.Record.Values 相同
+}
+
+// Error 查询错误
+type Error struct {
+	Message string `json:"message"`
+	Kind    string `json:"kind"` // 与 godns.ErrorKind 相同，例如 timeout、nxdomain、servfail、refused、network、other
+	Server  string `json:"server,omitempty"`
+	Attempt int    `json:"attempt,omitempty"`
+	Client  string `json:"client,omitempty"`
+}
+
+// QueryResult 单个服务器的查询结果
+// 作为 MultiQueryResult 的元素时 schema_version 省略
+type QueryResult struct {
+	SchemaVersion      int       `json:"schema_version,omitempty"`
+	Domain             string    `json:"domain"`
+	Type               string    `json:"type"`
+	Server             string    `json:"server,omitempty"`
+	Records            []Record  `json:"records"`
+	Authority          []Record  `json:"authority,omitempty"`  // 应答的授权部分
+	Additional         []Record  `json:"additional,omitempty"` // 应答的附加部分，不含OPT伪记录
+	Error              *Error    `json:"error,omitempty"`
+	AD                 bool      `json:"ad"`
+	Authoritative      bool      `json:"authoritative"`
+	Truncated          bool      `json:"truncated,omitempty"`
+	RecursionAvailable bool      `json:"recursion_available,omitempty"`
+	Rcode              string    `json:"rcode,omitempty"` // 响应码助记符，例如 "NOERROR"、"NXDOMAIN"，未收到应答时省略
+	Tag                string    `json:"tag,omitempty"`
+	Route              string    `json:"route,omitempty"`
+	RespondedBy        string    `json:"responded_by,omitempty"`
+	Nameserver         string    `json:"nameserver,omitempty"`
+	Synthetic          bool      `json:"synthetic,omitempty"`
+	TruncatedByClient  bool      `json:"truncated_by_client,omitempty"`
+	QueriedAt          time.Time `json:"queried_at,omitzero"`
+	ValidUntil         time.Time `json:"valid_until,omitzero"`
+	RTTMillis          float64   `json:"rtt_ms,omitempty"` // 网络查询的往返时间，缓存应答省略
+}
+
+// MultiQueryResult 多服务器查询结果
+type MultiQueryResult struct {
+	SchemaVersion int           `json:"schema_version"`
+	Domain        string        `json:"domain"`
+	Type          string        `json:"type"`
+	Results       []QueryResult `json:"results"`
+	AllIPs        []string      `json:"all_ips"`
+	StartedAt     time.Time     `json:"started_at,omitzero"`
+	FinishedAt    time.Time     `json:"finished_at,omitzero"`
+	ElapsedMillis float64       `json:"elapsed_ms"`
+	Partial       bool          `json:"partial,omitempty"`
+	QuorumReached bool          `json:"quorum_reached,omitempty"`
+	Agreeing      []string      `json:"agreeing,omitempty"`
+	Dissenters    []string      `json:"dissenters,omitempty"`
+}
@@ -0,0 +1,218 @@
+package schema_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+	"github.com/zan8in/godns/schema"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of the current schema version")
+
+var queriedAt = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func rr(s string) godns.Record {
+	r, err := dns.NewRR(s)
+	if err != nil {
+		panic(err)
+	}
+	return godns.NewRecord(r)
+}
+
+// sampleResult 覆盖所有字段的查询结果
+func sampleResult() *godns.QueryResult {
+	return &godns.QueryResult{
+		Domain: "example.com",
+		Type:   dns.TypeTXT,
+		Server: "192.0.2.53:53",
+		Records: []godns.Record{
+			rr(`example.com. 300 IN TXT "v=spf1 " "-all"`),
+		},
+		Authority:          []godns.Record{rr("example.com. 3600 IN NS ns1.example.com.")},
+		Additional:         []godns.Record{rr("ns1.example.com. 3600 IN A 192.0.2.1")},
+		AD:                 true,
+		Authoritative:      true,
+		Truncated:          true,
+		RecursionAvailable: true,
+		Tag:                "public",
+		Route:              "example.com",
+		RespondedBy:        "192.0.2.53:53",
+		Nameserver:         "ns1.example.com",
+		Synthetic:          true,
+		TruncatedByClient:  true,
+		QueriedAt:          queriedAt,
+		ValidUntil:         queriedAt.Add(300 * time.Second),
+		Duration:           12500 * time.Microsecond,
+	}
+}
+
+// sampleFailure 失败的查询结果
+func sampleFailure() *godns.QueryResult {
+	return &godns.QueryResult{
+		Domain:    "example.com",
+		Type:      dns.TypeTXT,
+		Server:    "198.51.100.53:53",
+		Rcode:     dns.RcodeServerFailure,
+		QueriedAt: queriedAt,
+		Error: &godns.ErrorInfo{
+			Message: "dns: SERVFAIL from 198.51.100.53:53",
+			Kind:    godns.KindServFail,
+			Server:  "198.51.100.53:53",
+			Attempt: 2,
+			Client:  "test",
+		},
+	}
+}
+
+func sampleMulti() *godns.MultiQueryResult {
+	return &godns.MultiQueryResult{
+		Domain:        "example.com",
+		Type:          dns.TypeTXT,
+		Results:       []godns.QueryResult{*sampleResult(), *sampleFailure()},
+		AllIPs:        []string{"192.0.2.1"},
+		StartedAt:     queriedAt,
+		FinishedAt:    queriedAt.Add(20 * time.Millisecond),
+		Elapsed:       20 * time.Millisecond,
+		Partial:       true,
+		QuorumReached: true,
+		Agreeing:      []string{"192.0.2.53:53"},
+		Dissenters:    []string{"198.51.100.53:53"},
+	}
+}
+
+// golden 比较序列化结果与当前版本的固定文件，-update 时重写固定文件
+func golden(t *testing.T, name string, v any) {
+	t.Helper()
+	got, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = append(got, '\n')
+	path := filepath.Join("testdata", fmt.Sprintf("v%d", schema.SchemaVersion), name)
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("missing %s: add golden files for schema version %d (go test ./schema -update)", path, schema.SchemaVersion)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("serialized %s differs from %s; changing the format requires bumping SchemaVersion\ngot:\n%s", name, path, got)
+	}
+}
+
+// TestGolden 序列化格式与当前版本的固定文件完全一致，格式变化而版本号未提升时失败
+func TestGolden(t *testing.T) {
+	golden(t, "query_result.json", schema.ToSchema(sampleResult()))
+	golden(t, "multi_query_result.json", schema.ToSchemaMulti(sampleMulti()))
+}
+
+// TestReadAllVersions 每个受支持版本的固定文件都能严格解码（字段未被删除或改名）并还原
+func TestReadAllVersions(t *testing.T) {
+	for v := 1; v <= schema.SchemaVersion; v++ {
+		t.Run(fmt.Sprintf("v%d", v), func(t *testing.T) {
+			var single schema.QueryResult
+			readFixture(t, v, "query_result.json", &single)
+			if single.SchemaVersion != v {
+				t.Errorf("schema_version = %d, want %d", single.SchemaVersion, v)
+			}
+			res, err := schema.FromSchema(single)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkSample(t, res, v)
+
+			var multi schema.MultiQueryResult
+			readFixture(t, v, "multi_query_result.json", &multi)
+			mres, err := schema.FromSchemaMulti(multi)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(mres.Results) != 2 || !mres.Partial || !mres.QuorumReached || mres.Elapsed != 20*time.Millisecond {
+				t.Fatalf("multi result = %+v", mres)
+			}
+			checkSample(t, &mres.Results[0], v)
+			failure := mres.Results[1]
+			if !errors.Is(failure.Error, godns.ErrServFail) {
+				t.Errorf("failure error = %v, want ErrServFail", failure.Error)
+			}
+			if v >= 2 && failure.Rcode != dns.RcodeServerFailure {
+				t.Errorf("failure rcode = %d, want SERVFAIL", failure.Rcode)
+			}
+		})
+	}
+}
+
+func readFixture(t *testing.T, version int, name string, v any) {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", fmt.Sprintf("v%d", version), name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+}
+
+// checkSample 校验还原的 sampleResult
+// v1 的TXT值以空格连接且没有 values，也没有后来加入的应答码、往返时间、授权和附加部分等字段
+func checkSample(t *testing.T, res *godns.QueryResult, version int) {
+	t.Helper()
+	if res.Type != dns.TypeTXT || res.Server != "192.0.2.53:53" || !res.QueriedAt.Equal(queriedAt) || !res.Authoritative {
+		t.Errorf("result = %+v", res)
+	}
+	if len(res.Records) != 1 {
+		t.Fatalf("records = %v", res.Records)
+	}
+	txt := res.Records[0]
+	wantValue, wantChunks := "v=spf1 -all", 2
+	if version == 1 {
+		wantValue, wantChunks = "v=spf1  -all", 0
+	}
+	if txt.Value() != wantValue || len(txt.Values) != wantChunks {
+		t.Errorf("TXT value = %q, chunks = %q", txt.Value(), txt.Values)
+	}
+	if version == 1 {
+		return
+	}
+	if res.Duration != 12500*time.Microsecond || !res.Truncated || !res.RecursionAvailable {
+		t.Errorf("duration = %v, TC = %v, RA = %v", res.Duration, res.Truncated, res.RecursionAvailable)
+	}
+	if len(res.Authority) != 1 || len(res.Additional) != 1 {
+		t.Errorf("authority = %v, additional = %v", res.Authority, res.Additional)
+	}
+}
+
+func TestUnsupportedVersion(t *testing.T) {
+	for _, v := range []int{0, schema.SchemaVersion + 1} {
+		in := schema.ToSchema(sampleResult())
+		in.SchemaVersion = v
+		if _, err := schema.FromSchema(in); err == nil {
+			t.Errorf("version %d accepted", v)
+		}
+		multi := schema.ToSchemaMulti(sampleMulti())
+		multi.SchemaVersion = v
+		if _, err := schema.FromSchemaMulti(multi); err == nil {
+			t.Errorf("multi version %d accepted", v)
+		}
+	}
+}
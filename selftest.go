@@ -0,0 +1,221 @@
+package godns
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// selfTestTimeout 自检中单项检查的时间上限
+const selfTestTimeout = 2 * time.Second
+
+// WithSelfTestQuery 设置 SelfTest 用于验证解析的已知可用名称，默认查询根区的NS记录
+func WithSelfTestQuery(name string, qtype uint16) Option {
+	return func(c *Config) {
+		c.SelfTestName = name
+		c.SelfTestType = qtype
+	}
+}
+
+// SelfTestCheck 单项自检结果
+type SelfTestCheck struct {
+	Name     string // 检查项：proxy、tls、query
+	Target   string // 检查对象，例如服务器地址
+	OK       bool
+	Detail   string
+	Duration time.Duration
+}
+
+// SelfTestReport 连通性自检报告
+type SelfTestReport struct {
+	Checks []SelfTestCheck
+	OK     bool // 代理和TLS检查全部通过，且至少一个服务器完成了解析
+}
+
+// String 以每项一行的形式输出报告
+func (r *SelfTestReport) String() string {
+	var b strings.Builder
+	status := "PASS"
+	if !r.OK {
+		status = "FAIL"
+	}
+	fmt.Fprintf(&b, "self-test %s", status)
+	for _, check := range r.Checks {
+		mark := "ok  "
+		if !check.OK {
+			mark = "FAIL"
+		}
+		fmt.Fprintf(&b, "\n  [%s] %-5s %s (%v)", mark, check.Name, check.Target, check.Duration.Round(time.Millisecond))
+		if check.Detail != "" {
+			b.WriteString(": " + check.Detail)
+		}
+	}
+	return b.String()
+}
+
+// SelfTest 以较短的超时进行启动前的连通性自检：代理是否接受连接、加密传输的TLS校验是否通过、
+// 配置的服务器能否通过配置的协议解析已知可用的名称，返回每项检查的结构化报告
+// 开启管道化时，自检建立的连接会保留供后续查询复用
+func (c *Client) SelfTest(ctx context.Context) *SelfTestReport {
+	timeout := selfTestTimeout
+	if c.config.Timeout > 0 && c.config.Timeout < timeout {
+		timeout = c.config.Timeout
+	}
+
+	name, qtype := c.config.SelfTestName, c.config.SelfTestType
+	if name == "" {
+		name, qtype = ".", dns.TypeNS
+	}
+
+	report := &SelfTestReport{}
+	var mu sync.Mutex
+	add := func(check SelfTestCheck) {
+		mu.Lock()
+		defer mu.Unlock()
+		report.Checks = append(report.Checks, check)
+	}
+
+	if c.config.ProxyType != NoProxy {
+		add(c.checkProxy(ctx, timeout))
+	}
+
+	var wg sync.WaitGroup
+	for _, server := range c.config.Servers {
+		info := c.serverInfo(server)
+		wg.Add(1)
+		go func(info ServerInfo) {
+			defer wg.Done()
+			if (info.Protocol == DoT || info.Protocol == DoH) && c.config.ProxyType == NoProxy && c.config.Transport == nil {
+				add(c.checkTLS(ctx, info, timeout))
+			}
+			add(c.checkQuery(ctx, info, name, qtype, timeout))
+		}(info)
+	}
+	wg.Wait()
+
+	// 按配置顺序输出，同一服务器的TLS检查在查询检查之前
+	order := make(map[string]int, len(c.config.Servers))
+	for i, server := range c.config.Servers {
+		order[c.serverInfo(server).Address] = i + 1
+	}
+	rank := map[string]int{"proxy": 0, "tls": 1, "query": 2}
+	sort.SliceStable(report.Checks, func(i, j int) bool {
+		a, b := report.Checks[i], report.Checks[j]
+		if order[a.Target] != order[b.Target] {
+			return order[a.Target] < order[b.Target]
+		}
+		return rank[a.Name] < rank[b.Name]
+	})
+
+	resolved := false
+	report.OK = true
+	for _, check := range report.Checks {
+		switch {
+		case check.Name == "query" && check.OK:
+			resolved = true
+		case check.Name != "query" && !check.OK:
+			report.OK = false
+		}
+	}
+	report.OK = report.OK && resolved
+	return report
+}
+
+// checkProxy 检查代理是否接受连接
+func (c *Client) checkProxy(ctx context.Context, timeout time.Duration) SelfTestCheck {
+	check := SelfTestCheck{Name: "proxy", Target: c.config.ProxyAddr}
+	start := time.Now()
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", proxyHostPort(c.config.ProxyAddr))
+	check.Duration = time.Since(start)
+	if err != nil {
+		check.Detail = err.Error()
+		return check
+	}
+	conn.Close()
+	check.OK = true
+	return check
+}
+
+// proxyHostPort 返回代理地址的 主机:端口 部分
+func proxyHostPort(addr string) string {
+	if strings.Contains(addr, "://") {
+		if u, err := url.Parse(addr); err == nil {
+			return u.Host
+		}
+	}
+	return addr
+}
+
+// checkTLS 直接与加密传输的服务器完成TLS握手，确认证书校验通过
+func (c *Client) checkTLS(ctx context.Context, info ServerInfo, timeout time.Duration) SelfTestCheck {
+	check := SelfTestCheck{Name: "tls", Target: info.Address}
+
+	addr := normalizeServer(info.Address, DoT)
+	cfg := c.tlsConfigFor(info.Address).Clone()
+	if info.Protocol == DoH {
+		u, err := parseDoHURL(info.Address)
+		if err != nil {
+			check.Detail = err.Error()
+			return check
+		}
+		port := u.Port()
+		if port == "" {
+			port = "443"
+		}
+		addr = net.JoinHostPort(u.Hostname(), port)
+		if cfg.ServerName == "" {
+			cfg.ServerName = u.Hostname()
+		}
+	}
+	if cfg.ServerName == "" {
+		if host, _, err := net.SplitHostPort(addr); err == nil {
+			cfg.ServerName = host
+		}
+	}
+
+	start := time.Now()
+	dialer := &tls.Dialer{NetDialer: &net.Dialer{Timeout: timeout}, Config: cfg}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	conn, err := dialer.DialContext(ctx, c.network(TCP), addr)
+	check.Duration = time.Since(start)
+	if err != nil {
+		check.Detail = err.Error()
+		return check
+	}
+	defer conn.Close()
+	state := conn.(*tls.Conn).ConnectionState()
+	check.OK = true
+	check.Detail = tls.VersionName(state.Version)
+	return check
+}
+
+// checkQuery 通过服务器配置的协议解析已知可用的名称
+func (c *Client) checkQuery(ctx context.Context, info ServerInfo, name string, qtype uint16, timeout time.Duration) SelfTestCheck {
+	check := SelfTestCheck{Name: "query", Target: info.Address}
+
+	ctx, cancel := context.WithTimeout(ContextWithNoCache(ctx), timeout)
+	defer cancel()
+	start := time.Now()
+	res, err := c.queryServer(ctx, name, qtype, info)
+	check.Duration = time.Since(start)
+	switch {
+	case err != nil:
+		check.Detail = err.Error()
+	case !hasRecordType(res, qtype):
+		check.Detail = fmt.Sprintf("no %s records for %s", dns.TypeToString[qtype], name)
+	default:
+		check.OK = true
+		check.Detail = fmt.Sprintf("%d records over %s", len(res.Records), info.Protocol)
+	}
+	return check
+}
@@ -0,0 +1,130 @@
+package godns_test
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+	"github.com/zan8in/godns/internal/testserver"
+)
+
+// rootServer 返回应答根区NS查询的测试服务器
+func rootServer(t *testing.T) *testserver.Server {
+	t.Helper()
+	s := startServer(t)
+	s.Answer(".", dns.TypeNS, ". 518400 IN NS a.root-servers.net.")
+	return s
+}
+
+// TestSelfTest 默认查询根区NS；每个服务器一项查询检查，加密传输另有一项TLS检查并排在查询之前，
+// 检查按配置顺序排列；至少一个服务器完成解析时报告通过
+func TestSelfTest(t *testing.T) {
+	good, dead := rootServer(t), startServer(t)
+	dead.Handle(".", dns.TypeNS, testserver.Reply{Drop: true})
+	c := godns.New(
+		godns.WithServers(dead.UDPAddr, "tls://"+good.DoTAddr, good.UDPAddr),
+		godns.WithTLSConfig(good.ClientTLSConfig()),
+		godns.WithTimeout(200*time.Millisecond),
+		godns.WithRetries(0),
+	)
+	defer c.Close()
+
+	report := c.SelfTest(context.Background())
+	if !report.OK {
+		t.Errorf("report failed:\n%s", report)
+	}
+	want := []struct {
+		name, target string
+		ok           bool
+	}{
+		{"query", dead.UDPAddr, false},
+		{"tls", good.DoTAddr, true},
+		{"query", good.DoTAddr, true},
+		{"query", good.UDPAddr, true},
+	}
+	if len(report.Checks) != len(want) {
+		t.Fatalf("checks:\n%s", report)
+	}
+	for i, w := range want {
+		got := report.Checks[i]
+		if got.Name != w.name || got.Target != w.target || got.OK != w.ok {
+			t.Errorf("check %d = %s %s ok=%v (%s), want %s %s ok=%v", i, got.Name, got.Target, got.OK, got.Detail, w.name, w.target, w.ok)
+		}
+	}
+	if d := report.Checks[1].Detail; !strings.HasPrefix(d, "TLS ") {
+		t.Errorf("tls detail = %q, want the negotiated version", d)
+	}
+	if n := len(dead.Queries()); n != 1 {
+		t.Errorf("dead server saw %d queries, want 1", n)
+	}
+
+	text := report.String()
+	if !strings.HasPrefix(text, "self-test PASS") || !strings.Contains(text, "[FAIL] query "+dead.UDPAddr) || !strings.Contains(text, "[ok  ] tls   "+good.DoTAddr) {
+		t.Errorf("String() =\n%s", text)
+	}
+}
+
+// TestSelfTestFailures TLS校验失败或没有服务器完成解析时报告不通过
+func TestSelfTestFailures(t *testing.T) {
+	s := rootServer(t)
+	s.Handle("example.test", dns.TypeA, testserver.Reply{})
+
+	t.Run("untrusted certificate", func(t *testing.T) {
+		c := godns.New(godns.WithServers("tls://"+s.DoTAddr, s.UDPAddr), godns.WithTimeout(500*time.Millisecond), godns.WithRetries(0))
+		defer c.Close()
+		report := c.SelfTest(context.Background())
+		if report.OK || report.Checks[0].Name != "tls" || report.Checks[0].OK {
+			t.Errorf("report:\n%s\nwant the TLS check to fail the self-test", report)
+		}
+		if !strings.HasPrefix(report.String(), "self-test FAIL") {
+			t.Errorf("String() =\n%s", report)
+		}
+	})
+
+	t.Run("custom query without records", func(t *testing.T) {
+		c := godns.New(godns.WithServers(s.UDPAddr), godns.WithSelfTestQuery("example.test", dns.TypeA), godns.WithRetries(0))
+		defer c.Close()
+		report := c.SelfTest(context.Background())
+		if report.OK || len(report.Checks) != 1 || report.Checks[0].Detail != "no A records for example.test" {
+			t.Errorf("report:\n%s\nwant the empty answer to fail the query check", report)
+		}
+		if q := s.Queries(); q[len(q)-1].Msg.Question[0].Name != "example.test." {
+			t.Errorf("self-test queried %s, want the configured name", q[len(q)-1].Msg.Question[0].Name)
+		}
+	})
+}
+
+// TestSelfTestProxy 配置了代理时先检查代理是否接受连接，代理不可达时报告不通过
+func TestSelfTestProxy(t *testing.T) {
+	s := rootServer(t)
+	proxy, err := testserver.StartSOCKS5("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer proxy.Close()
+
+	c := godns.New(godns.WithServers("tcp://"+s.TCPAddr), godns.WithSOCKS5Proxy(proxy.Addr, nil), godns.WithRetries(0))
+	defer c.Close()
+	report := c.SelfTest(context.Background())
+	if !report.OK || len(report.Checks) != 2 || report.Checks[0].Name != "proxy" || report.Checks[0].Target != proxy.Addr {
+		t.Errorf("report:\n%s", report)
+	}
+
+	// 占用后立即关闭的端口上没有代理监听
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := ln.Addr().String()
+	ln.Close()
+	c = godns.New(godns.WithServers("tcp://"+s.TCPAddr), godns.WithSOCKS5Proxy(closed, nil), godns.WithTimeout(500*time.Millisecond), godns.WithRetries(0))
+	defer c.Close()
+	report = c.SelfTest(context.Background())
+	if report.OK || report.Checks[0].Name != "proxy" || report.Checks[0].OK {
+		t.Errorf("report:\n%s\nwant the unreachable proxy to fail the self-test", report)
+	}
+}
@@ -0,0 +1,227 @@
+package godns_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+	"github.com/zan8in/godns/internal/testserver"
+)
+
+func TestParseServer(t *testing.T) {
+	tests := []struct {
+		server   string
+		protocol godns.Protocol
+		want     godns.ServerInfo
+	}{
+		{"8.8.8.8:53", godns.UDP, godns.ServerInfo{Address: "8.8.8.8:53", Protocol: godns.UDP}},
+		{" 8.8.8.8:53 ", godns.TCP, godns.ServerInfo{Address: "8.8.8.8:53", Protocol: godns.TCP}},
+		{"[2001:db8::1]:53", godns.UDP, godns.ServerInfo{Address: "[2001:db8::1]:53", Protocol: godns.UDP}},
+		{"dns.example", godns.DoT, godns.ServerInfo{Address: "dns.example", Protocol: godns.DoT}},
+		{"https://dns.google/dns-query", godns.UDP, godns.ServerInfo{Address: "https://dns.google/dns-query", Protocol: godns.DoH}},
+		{"http://127.0.0.1:8053/dns-query", godns.UDP, godns.ServerInfo{Address: "http://127.0.0.1:8053/dns-query", Protocol: godns.DoH}},
+		{"udp://9.9.9.9", godns.DoT, godns.ServerInfo{Address: "9.9.9.9:53", Protocol: godns.UDP}},
+		{"tcp://9.9.9.9:5353", godns.UDP, godns.ServerInfo{Address: "9.9.9.9:5353", Protocol: godns.TCP}},
+		{"tls://1.1.1.1", godns.UDP, godns.ServerInfo{Address: "1.1.1.1:853", Protocol: godns.DoT}},
+		{"tls://[2606:4700::1111]", godns.UDP, godns.ServerInfo{Address: "[2606:4700::1111]:853", Protocol: godns.DoT}},
+		{"quic://dns.adguard.com", godns.UDP, godns.ServerInfo{Address: "dns.adguard.com:853", Protocol: godns.DoQ}},
+		{"quic://94.140.14.14:784", godns.UDP, godns.ServerInfo{Address: "94.140.14.14:784", Protocol: godns.DoQ}},
+	}
+	for _, tt := range tests {
+		if got := godns.ParseServer(tt.server, tt.protocol); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseServer(%q, %s) = %+v, want %+v", tt.server, tt.protocol, got, tt.want)
+		}
+	}
+}
+
+// TestServerStringFormsMatchServerInfos 字符串形式的服务器与 ParseServer 得到的结构化定义完全等价
+func TestServerStringFormsMatchServerInfos(t *testing.T) {
+	s := startServer(t)
+	s.Answer("example.com", dns.TypeA, "example.com. 60 IN A 192.0.2.1")
+
+	forms := []string{
+		s.UDPAddr,
+		"udp://" + s.UDPAddr,
+		"tcp://" + s.TCPAddr,
+		"tls://" + s.DoTAddr,
+		"quic://" + s.DoQAddr,
+		s.DoHURL,
+	}
+	for _, form := range forms {
+		t.Run(form, func(t *testing.T) {
+			base := []godns.Option{
+				godns.WithTLSConfig(s.ClientTLSConfig()),
+				godns.WithRetries(0),
+				godns.WithTimeout(2 * time.Second),
+			}
+			fromString := godns.New(append(base, godns.WithServers(form))...)
+			defer fromString.Close()
+			fromInfo := godns.New(append(base, godns.WithServerInfos(godns.ParseServer(form, godns.UDP)))...)
+			defer fromInfo.Close()
+
+			if got, want := fromString.ServerInfos(), fromInfo.ServerInfos(); !reflect.DeepEqual(got, want) {
+				t.Fatalf("ServerInfos() = %+v, want %+v", got, want)
+			}
+			if got, want := fromString.Servers(), fromInfo.Servers(); !reflect.DeepEqual(got, want) {
+				t.Errorf("Servers() = %v, want %v", got, want)
+			}
+			if got, want := fromString.ConfigSnapshot().Servers, fromInfo.ConfigSnapshot().Servers; !reflect.DeepEqual(got, want) {
+				t.Errorf("ConfigSnapshot().Servers = %+v, want %+v", got, want)
+			}
+
+			for name, c := range map[string]*godns.Client{"string": fromString, "info": fromInfo} {
+				res, err := c.Query(context.Background(), "example.com", dns.TypeA)
+				if err != nil {
+					t.Fatalf("%s: Query: %v", name, err)
+				}
+				if len(res.Records) != 1 || res.Records[0].Value() != "192.0.2.1" {
+					t.Errorf("%s: Records = %+v, want one A record 192.0.2.1", name, res.Records)
+				}
+				if want := fromInfo.ServerInfos()[0].Address; res.Server != want {
+					t.Errorf("%s: Server = %q, want %q", name, res.Server, want)
+				}
+			}
+		})
+	}
+}
+
+// leafPin 返回服务器证书的SPKI指纹
+func leafPin(t *testing.T, s *testserver.Server) string {
+	t.Helper()
+	conn, err := tls.Dial("tcp", s.DoTAddr, s.ClientTLSConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	sum := sha256.Sum256(conn.ConnectionState().PeerCertificates[0].RawSubjectPublicKeyInfo)
+	return "sha256/" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// TestServerInfoPinsChainVerifyConnection 证书指纹校验不会覆盖用户的 VerifyConnection 回调
+func TestServerInfoPinsChainVerifyConnection(t *testing.T) {
+	s := startServer(t)
+	s.Answer("example.com", dns.TypeA, "example.com. 60 IN A 192.0.2.1")
+	pin := leafPin(t, s)
+	errRejected := errors.New("rejected by user callback")
+
+	tests := []struct {
+		name       string
+		pins       []string
+		userErr    error
+		wantCalled bool
+		wantErr    bool
+	}{
+		{"pin-match-callback-accepts", []string{pin}, nil, true, false},
+		{"pin-match-callback-rejects", []string{pin}, errRejected, true, true},
+		{"pin-mismatch", []string{"sha256/" + base64.StdEncoding.EncodeToString(make([]byte, 32))}, nil, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var called atomic.Int32
+			cfg := s.ClientTLSConfig()
+			cfg.VerifyConnection = func(tls.ConnectionState) error {
+				called.Add(1)
+				return tt.userErr
+			}
+			c := godns.New(
+				godns.WithServerInfos(godns.ServerInfo{Address: s.DoTAddr, Protocol: godns.DoT, Pins: tt.pins, TLSConfig: cfg}),
+				godns.WithRetries(0),
+				godns.WithTimeout(2*time.Second),
+			)
+			defer c.Close()
+
+			_, err := c.Query(context.Background(), "example.com", dns.TypeA)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Query err = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.userErr != nil && !errors.Is(err, tt.userErr) {
+				t.Errorf("Query err = %v, want the callback's error", err)
+			}
+			if got := called.Load() > 0; got != tt.wantCalled {
+				t.Errorf("user VerifyConnection called = %v, want %v", got, tt.wantCalled)
+			}
+		})
+	}
+}
+
+func TestServerInfoProxySchemes(t *testing.T) {
+	s := startServer(t)
+	s.Answer("example.com", dns.TypeA, "example.com. 60 IN A 192.0.2.1")
+	p, err := testserver.StartHTTPConnect("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	tests := []struct {
+		proxy   string
+		wantErr string
+	}{
+		{"http://" + p.Addr, ""},
+		{"https://" + p.Addr, `unsupported proxy scheme "https"`},
+		{"ftp://" + p.Addr, `unsupported proxy scheme "ftp"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.proxy, func(t *testing.T) {
+			c := godns.New(
+				godns.WithServerInfos(godns.ServerInfo{Address: s.DoHURL, Proxy: tt.proxy}),
+				godns.WithTLSConfig(s.ClientTLSConfig()),
+				godns.WithRetries(0),
+				godns.WithTimeout(2*time.Second),
+			)
+			defer c.Close()
+
+			_, err := c.Query(context.Background(), "example.com", dns.TypeA)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Query: %v", err)
+				}
+				if len(p.Targets()) == 0 {
+					t.Error("query did not go through the proxy")
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Query err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestServerInfoWeightOrdersFanout 分波查询时权重高的服务器先被查询
+func TestServerInfoWeightOrdersFanout(t *testing.T) {
+	light, heavy := startServer(t), startServer(t)
+	for _, s := range []*testserver.Server{light, heavy} {
+		s.Answer("example.com", dns.TypeA, "example.com. 60 IN A 192.0.2.1")
+	}
+
+	c := godns.New(
+		godns.WithServerInfos(
+			godns.ServerInfo{Address: light.UDPAddr, Weight: 1},
+			godns.ServerInfo{Address: heavy.UDPAddr, Weight: 10},
+		),
+		godns.WithStaggeredFanout(1, time.Minute),
+		godns.WithRetries(0),
+		godns.WithTimeout(2*time.Second),
+	)
+	defer c.Close()
+
+	if _, err := c.MultiQuery(context.Background(), "example.com", dns.TypeA); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(heavy.Queries()); n != 1 {
+		t.Errorf("heavy server queries = %d, want 1", n)
+	}
+	if n := len(light.Queries()); n != 0 {
+		t.Errorf("light server queries = %d, want 0 (first wave answered)", n)
+	}
+}
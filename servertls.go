@@ -0,0 +1,204 @@
+package godns
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// WithServerTLSConfig 为指定服务器设置TLS配置，DoT/DoH 查询该服务器时优先使用，
+// 未设置的字段继承全局 TLSConfig；server 必须在服务器列表（含标签、路由规则和混合竞速）中，
+// 否则 NewWithValidation 返回 ErrInvalidConfig，New 创建的客户端在查询时返回该错误。设置了 WithHTTPClient 时DoH使用该客户端，不应用覆盖配置
+func WithServerTLSConfig(server string, cfg *tls.Config) Option {
+	return func(c *Config) {
+		if c.ServerTLSConfigs == nil {
+			c.ServerTLSConfigs = make(map[string]*tls.Config)
+		}
+		c.ServerTLSConfigs[server] = cfg
+	}
+}
+
+// mergeTLSConfig 合并全局与服务器级TLS配置：服务器级设置的字段优先，未设置的字段继承全局
+func mergeTLSConfig(global, override *tls.Config) *tls.Config {
+	merged := &tls.Config{}
+	if global != nil {
+		merged = global.Clone()
+	}
+	if override == nil {
+		return merged
+	}
+
+	if override.RootCAs != nil {
+		merged.RootCAs = override.RootCAs
+	}
+	if len(override.Certificates) > 0 {
+		merged.Certificates = override.Certificates
+	}
+	if override.GetClientCertificate != nil {
+		merged.GetClientCertificate = override.GetClientCertificate
+	}
+	if override.ServerName != "" {
+		merged.ServerName = override.ServerName
+	}
+	if override.InsecureSkipVerify {
+		merged.InsecureSkipVerify = true
+	}
+	if override.VerifyPeerCertificate != nil {
+		merged.VerifyPeerCertificate = override.VerifyPeerCertificate
+	}
+	if override.VerifyConnection != nil {
+		merged.VerifyConnection = override.VerifyConnection
+	}
+	if override.MinVersion != 0 {
+		merged.MinVersion = override.MinVersion
+	}
+	if override.MaxVersion != 0 {
+		merged.MaxVersion = override.MaxVersion
+	}
+	if len(override.CipherSuites) > 0 {
+		merged.CipherSuites = override.CipherSuites
+	}
+	if len(override.CurvePreferences) > 0 {
+		merged.CurvePreferences = override.CurvePreferences
+	}
+	if len(override.NextProtos) > 0 {
+		merged.NextProtos = override.NextProtos
+	}
+	if override.KeyLogWriter != nil {
+		merged.KeyLogWriter = override.KeyLogWriter
+	}
+	return merged
+}
+
+// describeTLS 返回TLS配置的简要描述，用于调试输出
+func describeTLS(cfg *tls.Config) string {
+	if cfg == nil {
+		return "default"
+	}
+	var parts []string
+	if cfg.ServerName != "" {
+		parts = append(parts, "sni="+cfg.ServerName)
+	}
+	if cfg.RootCAs != nil {
+		parts = append(parts, "roots=custom")
+	} else {
+		parts = append(parts, "roots=system")
+	}
+	if cfg.InsecureSkipVerify {
+		parts = append(parts, "insecure")
+	}
+	if len(cfg.Certificates) > 0 || cfg.GetClientCertificate != nil {
+		parts = append(parts, "client-cert")
+	}
+	if cfg.MinVersion != 0 {
+		parts = append(parts, "min="+tls.VersionName(cfg.MinVersion))
+	}
+	return strings.Join(parts, " ")
+}
+
+// serverTLS 服务器级的传输层对象
+type serverTLS struct {
+	config     *tls.Config
+	dotClient  *dns.Client
+	httpClient *http.Client
+}
+
+// prepareServerTLS 为设置了TLS覆盖的服务器构建独立的传输层对象，
+// 覆盖了不在服务器列表中的服务器时记录配置错误，延迟到查询时返回（NewWithValidation 在创建时即拒绝）
+func (c *Client) prepareServerTLS() {
+	t := &c.transports
+	overrides := make(map[string]*tls.Config, len(c.config.ServerTLSConfigs))
+	for server, cfg := range c.config.ServerTLSConfigs {
+		overrides[server] = cfg
+	}
+	for _, spec := range c.config.MixedRace {
+		if spec.TLSConfig != nil {
+			overrides[spec.Address] = spec.TLSConfig
+		}
+	}
+	if len(overrides) == 0 {
+		return
+	}
+
+	known := c.config.knownServers()
+	t.serverTLS = make(map[string]*serverTLS, len(overrides))
+	for server, override := range overrides {
+		if !known[server] {
+			continue
+		}
+		cfg := mergeTLSConfig(t.tlsConfig, override)
+		st := &serverTLS{
+			config: cfg,
+			dotClient: &dns.Client{
+				Net:       c.network(DoT),
+				Timeout:   c.config.Timeout,
+				TLSConfig: cfg,
+			},
+		}
+		if c.config.HTTPClient == nil {
+			st.httpClient = &http.Client{Transport: c.newHTTPTransport(cfg), Timeout: c.config.Timeout}
+		}
+		t.serverTLS[server] = st
+		// DoT 查询前会补全端口，两种写法都需要能找到
+		t.serverTLS[normalizeServer(server, DoT)] = st
+	}
+	if unknown := c.config.unknownTLSOverrides(); len(unknown) > 0 {
+		t.tlsErr = unknownTLSOverridesError(unknown)
+	}
+}
+
+// knownServers 返回配置中出现的全部服务器：服务器列表、标签、混合竞速和路由规则
+func (c *Config) knownServers() map[string]bool {
+	known := make(map[string]bool)
+	for _, server := range c.Servers {
+		known[server] = true
+	}
+	for _, servers := range c.TaggedServers {
+		for _, server := range servers {
+			known[server] = true
+		}
+	}
+	for _, spec := range c.MixedRace {
+		known[spec.Address] = true
+	}
+	for _, rule := range c.Routes {
+		for _, server := range rule.Servers {
+			known[server] = true
+		}
+	}
+	return known
+}
+
+// unknownTLSOverrides 返回 WithServerTLSConfig 覆盖了、但不在服务器列表中的服务器，按字典序排列
+func (c *Config) unknownTLSOverrides() []string {
+	known := c.knownServers()
+	var unknown []string
+	for server := range c.ServerTLSConfigs {
+		if !known[server] {
+			unknown = append(unknown, server)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+func unknownTLSOverridesError(unknown []string) error {
+	return fmt.Errorf("%w: TLS config override for servers not in the server list: %s", ErrInvalidConfig, strings.Join(unknown, ", "))
+}
+
+// TLSConfigFor 返回查询指定服务器时实际使用的TLS配置副本，便于排查问题
+func (c *Client) TLSConfigFor(server string) *tls.Config {
+	return c.tlsConfigFor(server).Clone()
+}
+
+// tlsConfigFor 返回查询指定服务器时实际使用的TLS配置
+func (c *Client) tlsConfigFor(server string) *tls.Config {
+	if st, ok := c.transports.serverTLS[server]; ok {
+		return st.config
+	}
+	return c.transports.tlsConfig
+}
@@ -0,0 +1,167 @@
+package godns_test
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+	"github.com/zan8in/godns/internal/testserver"
+)
+
+// TestServerTLSConfigTwoCAs 两个服务器的证书由不同CA签发，各自的TLS覆盖使 MultiQuery 对两者都能完成握手
+func TestServerTLSConfigTwoCAs(t *testing.T) {
+	a, b := startServer(t), startServer(t)
+	for _, s := range []*testserver.Server{a, b} {
+		s.Answer("example.com", dns.TypeA, "example.com. 60 IN A 192.0.2.1")
+	}
+
+	tests := []struct {
+		name     string
+		protocol godns.Protocol
+		addr     func(*testserver.Server) string
+		tlsA     *tls.Config
+		tlsB     *tls.Config
+		okA, okB bool
+	}{
+		{"dot", godns.DoT, dotAddr, a.ClientTLSConfig(), b.ClientTLSConfig(), true, true},
+		{"doh", godns.DoH, dohAddr, a.ClientTLSConfig(), b.ClientTLSConfig(), true, true},
+		{"dot-one-override", godns.DoT, dotAddr, a.ClientTLSConfig(), nil, true, false},
+		{"dot-swapped", godns.DoT, dotAddr, b.ClientTLSConfig(), a.ClientTLSConfig(), false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := []godns.Option{
+				godns.WithProtocol(tt.protocol),
+				godns.WithServers(tt.addr(a), tt.addr(b)),
+				godns.WithRetries(0),
+				godns.WithTimeout(2 * time.Second),
+			}
+			if tt.tlsA != nil {
+				opts = append(opts, godns.WithServerTLSConfig(tt.addr(a), tt.tlsA))
+			}
+			if tt.tlsB != nil {
+				opts = append(opts, godns.WithServerTLSConfig(tt.addr(b), tt.tlsB))
+			}
+			c, err := godns.NewWithValidation(opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+
+			res, err := c.MultiQuery(context.Background(), "example.com", dns.TypeA)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := map[string]bool{tt.addr(a): tt.okA, tt.addr(b): tt.okB}
+			for _, r := range res.Results {
+				if ok := r.Error == nil; ok != want[r.Server] {
+					t.Errorf("%s: error = %v, want success %v", r.Server, r.Error, want[r.Server])
+				}
+			}
+		})
+	}
+}
+
+func dotAddr(s *testserver.Server) string { return s.DoTAddr }
+func dohAddr(s *testserver.Server) string { return s.DoHURL }
+
+func TestServerTLSConfigUnknownServer(t *testing.T) {
+	opts := []godns.Option{
+		godns.WithProtocol(godns.DoT),
+		godns.WithServers("192.0.2.1:853"),
+		godns.WithServerTLSConfig("192.0.2.2:853", &tls.Config{ServerName: "dns.example"}),
+	}
+	if _, err := godns.NewWithValidation(opts...); !errors.Is(err, godns.ErrInvalidConfig) {
+		t.Fatalf("NewWithValidation err = %v, want ErrInvalidConfig", err)
+	}
+
+	// New 创建的客户端在查询时返回同一错误，而不是向服务器发送查询
+	c := godns.New(opts...)
+	defer c.Close()
+	if _, err := c.QueryA(context.Background(), "example.com"); !errors.Is(err, godns.ErrInvalidConfig) {
+		t.Errorf("query err = %v, want ErrInvalidConfig", err)
+	}
+
+	// 服务器出现在标签、混合竞速或路由规则中时视为已知
+	known := []godns.Option{
+		godns.WithProtocol(godns.DoT),
+		godns.WithTaggedServers(map[string][]string{"internal": {"192.0.2.2:853"}}),
+		godns.WithServerTLSConfig("192.0.2.2:853", &tls.Config{ServerName: "dns.example"}),
+	}
+	if _, err := godns.NewWithValidation(known...); err != nil {
+		t.Errorf("tagged server rejected: %v", err)
+	}
+}
+
+// TestSharedTLSConfigConcurrent 同一个 tls.Config 同时交给两个客户端并被调用方自己的 http.Client 使用，
+// 并发查询在竞态检测下无数据竞争，且 godns 不修改调用方的配置
+func TestSharedTLSConfigConcurrent(t *testing.T) {
+	s := startServer(t)
+	s.Answer("example.com", dns.TypeA, "example.com. 60 IN A 192.0.2.1")
+	shared := s.ClientTLSConfig()
+
+	servers := []string{"tls://" + s.DoTAddr, s.DoHURL, "quic://" + s.DoQAddr}
+	clients := []*godns.Client{
+		godns.New(godns.WithServers(servers...), godns.WithTLSConfig(shared), godns.WithRetries(0)),
+		godns.New(
+			godns.WithServers(servers...),
+			// 带协议前缀的地址规范化后以不带前缀的地址登记
+			godns.WithServerTLSConfig(s.DoTAddr, shared),
+			godns.WithServerTLSConfig(s.DoHURL, shared),
+			godns.WithServerTLSConfig(s.DoQAddr, shared),
+			godns.WithRetries(0),
+		),
+	}
+	own := &http.Client{Transport: &http.Transport{TLSClientConfig: shared}}
+	defer own.CloseIdleConnections()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 100)
+	for _, c := range clients {
+		defer c.Close()
+		for range 5 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				res, err := c.MultiQuery(context.Background(), "example.com", dns.TypeA)
+				if err != nil {
+					errs <- err
+					return
+				}
+				for _, r := range res.Results {
+					if r.Error != nil {
+						errs <- r.Error
+					}
+				}
+			}()
+		}
+	}
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := own.Get(s.DoHURL)
+			if err != nil {
+				errs <- err
+				return
+			}
+			resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	if shared.ServerName != s.ServerName || shared.NextProtos != nil || shared.ClientSessionCache != nil || shared.InsecureSkipVerify {
+		t.Errorf("caller's tls.Config was modified: ServerName %q, NextProtos %v, session cache %v",
+			shared.ServerName, shared.NextProtos, shared.ClientSessionCache)
+	}
+}
@@ -0,0 +1,122 @@
+package godns
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrServiceNotAvailable 服务明确声明不可用：唯一的SRV记录目标为 "."（RFC 2782）
+var ErrServiceNotAvailable = errors.New("godns: service not available")
+
+// serviceConfig ResolveService 配置
+type serviceConfig struct {
+	fallbackPort uint16 // 没有SRV记录时回退到裸名称使用的端口，0 表示不回退
+}
+
+// ServiceOption ResolveService 配置选项
+type ServiceOption func(*serviceConfig)
+
+// WithServiceFallback 没有SRV记录时回退到直接连接 name 本身的 port 端口
+func WithServiceFallback(port uint16) ServiceOption {
+	return func(s *serviceConfig) {
+		s.fallbackPort = port
+	}
+}
+
+// ServiceEndpoint 服务的一个目标主机
+type ServiceEndpoint struct {
+	Target   string // 目标主机名（不带末尾的点）
+	Port     uint16
+	Priority uint16
+	Weight   uint16
+	Addrs    []netip.Addr // 目标主机的 A/AAAA 地址，IPv4 在前
+	Error    *ErrorInfo   // 地址解析失败时的错误信息
+}
+
+// Addresses 返回该目标可直接拨号的 host:port 列表
+func (e ServiceEndpoint) Addresses() []string {
+	addrs := make([]string, 0, len(e.Addrs))
+	port := strconv.Itoa(int(e.Port))
+	for _, addr := range e.Addrs {
+		addrs = append(addrs, net.JoinHostPort(addr.String(), port))
+	}
+	return addrs
+}
+
+// ServiceResolution ResolveService 的结果
+type ServiceResolution struct {
+	Name      string            // 查询的 _service._proto.name
+	Endpoints []ServiceEndpoint // 按 RFC 2782 选择算法排列的目标，应依次尝试
+	Fallback  bool              // 没有SRV记录，Endpoints 为回退的裸名称
+}
+
+// Addresses 按尝试顺序返回所有目标的 host:port
+func (r *ServiceResolution) Addresses() []string {
+	var addrs []string
+	for _, endpoint := range r.Endpoints {
+		addrs = append(addrs, endpoint.Addresses()...)
+	}
+	return addrs
+}
+
+// ResolveService 完整的 RFC 2782 客户端算法：查询 _service._proto.name 的SRV记录，按优先级分组、
+// 组内按权重随机选择得到目标顺序（多次调用在统计上符合权重，随机数来源受 WithRandSource 控制），
+// 然后并发解析各目标的 A/AAAA 地址
+// 唯一的SRV记录目标为 "." 时返回 ErrServiceNotAvailable；没有SRV记录（包括名称不存在）时，若设置了 WithServiceFallback
+// 则回退到 name 本身，否则返回不含目标的结果
+// 单个目标解析失败不影响其他目标，错误记录在 ServiceEndpoint.Error 中
+func (c *Client) ResolveService(ctx context.Context, service, proto, name string, opts ...ServiceOption) (*ServiceResolution, error) {
+	cfg := &serviceConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	resolution := &ServiceResolution{Name: serviceName(service, proto, name)}
+	// SRV名称不存在与没有SRV记录一样处理
+	records, err := c.LookupSRV(ctx, resolution.Name)
+	if err = ignoreNXDomain(err); err != nil {
+		return nil, err
+	}
+	if len(records) == 1 && records[0].Target == "." {
+		return resolution, ErrServiceNotAvailable
+	}
+
+	for _, record := range records {
+		if record.Target == "." {
+			continue
+		}
+		resolution.Endpoints = append(resolution.Endpoints, ServiceEndpoint{
+			Target:   strings.TrimSuffix(record.Target, "."),
+			Port:     record.Port,
+			Priority: record.Priority,
+			Weight:   record.Weight,
+		})
+	}
+	if len(resolution.Endpoints) == 0 && cfg.fallbackPort != 0 {
+		resolution.Fallback = true
+		resolution.Endpoints = []ServiceEndpoint{{
+			Target: strings.TrimSuffix(name, "."),
+			Port:   cfg.fallbackPort,
+		}}
+	}
+
+	var wg sync.WaitGroup
+	for i := range resolution.Endpoints {
+		wg.Add(1)
+		go func(endpoint *ServiceEndpoint) {
+			defer wg.Done()
+			addrs, _, err := c.resolveHost(ctx, endpoint.Target)
+			endpoint.Addrs = addrs
+			if err != nil {
+				endpoint.Error = c.newErrorInfo(err, "", 0)
+			}
+		}(&resolution.Endpoints[i])
+	}
+	wg.Wait()
+	return resolution, ctx.Err()
+}
@@ -0,0 +1,132 @@
+package godns_test
+
+import (
+	"context"
+	"errors"
+	"math"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+)
+
+const sipName = "_sip._tcp.example.test"
+
+func TestLookupSRVWeightedDistribution(t *testing.T) {
+	s := startServer(t)
+	s.Answer(sipName, dns.TypeSRV,
+		sipName+". 60 IN SRV 10 60 5060 a.example.test.",
+		sipName+". 60 IN SRV 10 30 5060 b.example.test.",
+		sipName+". 60 IN SRV 10 10 5060 c.example.test.",
+		sipName+". 60 IN SRV 10 0 5060 zero.example.test.",
+		sipName+". 60 IN SRV 20 100 5060 backup.example.test.",
+	)
+	c := godns.New(godns.WithServers(s.UDPAddr), godns.WithCache(time.Minute), godns.WithDeterministic(1))
+	defer c.Close()
+
+	const rounds = 5000
+	first := map[string]int{}
+	for range rounds {
+		records, err := c.LookupSRV(context.Background(), sipName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(records) != 5 {
+			t.Fatalf("records = %v", records)
+		}
+		// 优先级组的顺序固定，权重为0的记录排在组内最后
+		if records[3].Target != "zero.example.test." || records[4].Target != "backup.example.test." {
+			t.Fatalf("order = %v", records)
+		}
+		first[records[0].Target]++
+	}
+	for target, weight := range map[string]float64{"a.example.test.": 0.6, "b.example.test.": 0.3, "c.example.test.": 0.1} {
+		got := float64(first[target]) / rounds
+		// 5000 次抽样的标准差不超过 0.007，允许约 5 倍的偏差
+		if math.Abs(got-weight) > 0.035 {
+			t.Errorf("%s chosen first %.3f of the time, want about %.1f", target, got, weight)
+		}
+	}
+	if n := len(s.Queries()); n != 1 {
+		t.Errorf("server saw %d queries, want 1 (cached)", n)
+	}
+}
+
+func TestLookupSRVSeeded(t *testing.T) {
+	s := startServer(t)
+	s.Answer(sipName, dns.TypeSRV,
+		sipName+". 60 IN SRV 10 1 5060 a.example.test.",
+		sipName+". 60 IN SRV 10 1 5060 b.example.test.",
+		sipName+". 60 IN SRV 10 1 5060 c.example.test.",
+	)
+	sequence := func(seed int64) []string {
+		c := godns.New(godns.WithServers(s.UDPAddr), godns.WithDeterministic(seed))
+		defer c.Close()
+		var targets []string
+		for range 10 {
+			records, err := c.LookupSRV(context.Background(), sipName)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, r := range records {
+				targets = append(targets, r.Target)
+			}
+		}
+		return targets
+	}
+	if a, b := sequence(7), sequence(7); !slices.Equal(a, b) {
+		t.Errorf("same seed produced different orders:\n%v\n%v", a, b)
+	}
+}
+
+func TestResolveService(t *testing.T) {
+	s := startServer(t)
+	s.Answer(sipName, dns.TypeSRV,
+		sipName+". 60 IN SRV 10 0 5060 a.example.test.",
+		sipName+". 60 IN SRV 20 0 5061 .",
+	)
+	s.Answer("a.example.test", dns.TypeA, "a.example.test. 60 IN A 192.0.2.1")
+	s.Answer("a.example.test", dns.TypeAAAA, "a.example.test. 60 IN AAAA 2001:db8::1")
+	s.Answer("_none._tcp.example.test", dns.TypeSRV, "_none._tcp.example.test. 60 IN SRV 0 0 0 .")
+	s.Answer("example.test", dns.TypeA, "example.test. 60 IN A 192.0.2.9")
+	c := godns.New(godns.WithServers(s.UDPAddr), godns.WithRetries(0))
+	defer c.Close()
+	ctx := context.Background()
+
+	t.Run("skips-dot-target", func(t *testing.T) {
+		res, err := c.ResolveService(ctx, "sip", "tcp", "example.test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []string{"192.0.2.1:5060", "[2001:db8::1]:5060"}
+		if got := res.Addresses(); !slices.Equal(got, want) || len(res.Endpoints) != 1 {
+			t.Errorf("addresses = %v, want %v", got, want)
+		}
+	})
+	t.Run("service-not-available", func(t *testing.T) {
+		_, err := c.ResolveService(ctx, "none", "tcp", "example.test", godns.WithServiceFallback(443))
+		if !errors.Is(err, godns.ErrServiceNotAvailable) {
+			t.Errorf("err = %v, want ErrServiceNotAvailable", err)
+		}
+	})
+	t.Run("fallback", func(t *testing.T) {
+		res, err := c.ResolveService(ctx, "missing", "tcp", "example.test", godns.WithServiceFallback(443))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !res.Fallback || !slices.Equal(res.Addresses(), []string{"192.0.2.9:443"}) {
+			t.Errorf("fallback = %v, addresses = %v", res.Fallback, res.Addresses())
+		}
+	})
+	t.Run("no-fallback", func(t *testing.T) {
+		res, err := c.ResolveService(ctx, "missing", "tcp", "example.test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Fallback || len(res.Endpoints) != 0 {
+			t.Errorf("endpoints = %v", res.Endpoints)
+		}
+	})
+}
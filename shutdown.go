@@ -0,0 +1,135 @@
+package godns
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrClientClosed 客户端已关闭或正在关闭，不再接受新的查询
+var ErrClientClosed = errors.New("godns: client closed")
+
+// drainKey 标记 context 中的查询已计入某个 drainer，嵌套查询（引导解析、区域切分等）不受关闭影响
+type drainKey struct{}
+
+// drainer 跟踪进行中的前台查询：关闭后拒绝新查询，等待或取消进行中的查询
+// 路由和专用代理子客户端与父客户端共享同一个 drainer，只有 owner 能关闭它，替换路由时关闭旧子客户端不影响父客户端
+type drainer struct {
+	owner   *Client
+	mu      sync.Mutex
+	closing bool
+	active  int
+	idle    chan struct{} // 关闭后进行中的查询全部结束时关闭
+
+	ctx    context.Context // 宽限期结束时取消，进行中的查询随之取消
+	cancel context.CancelFunc
+}
+
+// newDrainer 创建 drainer
+func newDrainer(owner *Client) *drainer {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &drainer{owner: owner, idle: make(chan struct{}), ctx: ctx, cancel: cancel}
+}
+
+// enter 登记一次查询，返回派生的 context 和结束时调用的函数；已关闭时返回 ErrClientClosed
+// 派生的 context 在宽限期结束或立即关闭时被取消
+func (d *drainer) enter(ctx context.Context) (context.Context, func(), error) {
+	if ctx.Value(drainKey{}) == d {
+		return ctx, func() {}, nil
+	}
+	d.mu.Lock()
+	if d.closing {
+		d.mu.Unlock()
+		return ctx, nil, ErrClientClosed
+	}
+	d.active++
+	d.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(context.WithValue(ctx, drainKey{}, d))
+	stop := context.AfterFunc(d.ctx, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+		d.mu.Lock()
+		d.active--
+		if d.closing && d.active == 0 {
+			close(d.idle)
+		}
+		d.mu.Unlock()
+	}, nil
+}
+
+// close 由 owner 调用时停止接受新查询，重复调用无副作用
+func (d *drainer) close(c *Client) {
+	if d.owner != c {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closing {
+		return
+	}
+	d.closing = true
+	if d.active == 0 {
+		close(d.idle)
+	}
+}
+
+// wait 等待进行中的查询结束；ctx 先结束时取消剩余查询并返回 ctx 的错误，不等待它们退出
+func (d *drainer) wait(c *Client, ctx context.Context) error {
+	if d.owner != c {
+		return nil
+	}
+	select {
+	case <-d.idle:
+		return nil
+	case <-ctx.Done():
+		d.cancel()
+		return ctx.Err()
+	}
+}
+
+// abort 由 owner 调用时立即取消进行中的查询
+func (d *drainer) abort(c *Client) {
+	if d.owner == c {
+		d.cancel()
+	}
+}
+
+// Shutdown 优雅关闭客户端，ctx 的截止时间即宽限期：
+//  1. 立即拒绝新查询（返回 ErrClientClosed），停止后台任务（预取、刷新等），排队中的任务被丢弃、执行中的任务被取消
+//  2. 等待进行中的前台查询结束；宽限期内未结束的查询被取消（返回 context.Canceled 或 ErrClientClosed），Shutdown 不再等待它们
+//  3. 写入持久化缓存的最终快照，启用调试日志时输出最终统计信息
+//  4. 关闭连接池和子客户端
+//
+// 宽限期耗尽时返回 ctx 的错误，其余步骤照常执行；之后调用 Close 无副作用
+// 本模块不包含转发处理器，因此没有以 REFUSED 拒绝新查询的 Drain，嵌入方应在调用 Shutdown 前停止接收请求
+func (c *Client) Shutdown(ctx context.Context) error {
+	c.drain.close(c)
+	c.sched.close()
+	err := c.drain.wait(c, ctx)
+	return errors.Join(err, c.release())
+}
+
+// release 写入最终快照、输出统计信息并关闭连接，只执行一次
+func (c *Client) release() error {
+	c.releaseOnce.Do(func() {
+		c.releaseErr = c.closeResources()
+	})
+	return c.releaseErr
+}
+
+// closeResources 写入最终快照、输出统计信息并关闭连接
+func (c *Client) closeResources() error {
+	err := c.stopCachePersistence()
+	if c.config.Debug {
+		c.logf("final stats: background=%+v errors=%+v outstanding=%+v",
+			c.BackgroundStats(), c.ErrorCacheStats(), c.OutstandingStats())
+	}
+	c.pipelines.close()
+	c.proxied.close()
+	if r := c.router.Load(); r != nil {
+		r.close()
+	}
+	return err
+}
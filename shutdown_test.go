@@ -0,0 +1,89 @@
+package godns_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+	"github.com/zan8in/godns/internal/testserver"
+)
+
+// startSlowQuery 发起一次查询并等待它到达服务器
+func startSlowQuery(t *testing.T, c *godns.Client, s *testserver.Server) <-chan error {
+	t.Helper()
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.QueryA(context.Background(), "slow.test")
+		done <- err
+	}()
+	deadline := time.Now().Add(2 * time.Second)
+	for len(s.Queries()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("query never reached the server")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return done
+}
+
+func TestShutdownGracePeriod(t *testing.T) {
+	tests := []struct {
+		name     string
+		delay    time.Duration // 上游应答延迟
+		grace    time.Duration
+		finished bool // 进行中的查询能否在宽限期内完成
+	}{
+		{"finishes-within-grace", 100 * time.Millisecond, 3 * time.Second, true},
+		{"cancelled-at-grace", 10 * time.Second, 200 * time.Millisecond, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := startServer(t)
+			s.Handle("slow.test", dns.TypeA, testserver.Reply{
+				Answer: []dns.RR{testserver.RR("slow.test. 60 IN A 192.0.2.1")},
+				Delay:  tt.delay,
+			})
+			c := godns.New(godns.WithServers(s.UDPAddr), godns.WithTimeout(30*time.Second), godns.WithRetries(0))
+			inflight := startSlowQuery(t, c, s)
+
+			ctx, cancel := context.WithTimeout(context.Background(), tt.grace)
+			defer cancel()
+			start := time.Now()
+			err := c.Shutdown(ctx)
+			elapsed := time.Since(start)
+			if elapsed > tt.grace+time.Second {
+				t.Errorf("Shutdown took %v with a %v grace period", elapsed, tt.grace)
+			}
+			if tt.finished {
+				if err != nil {
+					t.Errorf("Shutdown = %v, want nil", err)
+				}
+			} else if !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("Shutdown = %v, want DeadlineExceeded", err)
+			}
+
+			select {
+			case qerr := <-inflight:
+				if tt.finished && qerr != nil {
+					t.Errorf("in-flight query = %v, want success", qerr)
+				}
+				if !tt.finished && qerr == nil {
+					t.Error("in-flight query succeeded after the grace period")
+				}
+			case <-time.After(time.Second):
+				t.Fatal("in-flight query was not cancelled after the grace period")
+			}
+
+			// 关闭后的新查询立即被拒绝
+			if _, err := c.QueryA(context.Background(), "slow.test"); !errors.Is(err, godns.ErrClientClosed) {
+				t.Errorf("query after Shutdown = %v, want ErrClientClosed", err)
+			}
+			if err := c.Close(); err != nil {
+				t.Errorf("Close after Shutdown = %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,323 @@
+package godns
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// redactedSecret 快照中替代密码等敏感信息的占位符
+const redactedSecret = "xxxxx"
+
+// ConfigSnapshot 客户端生效配置的快照，反映选项应用、规范化和默认值填充后的结果，
+// 可直接 JSON 序列化后附在问题报告中；代理密码、地址中的用户信息等敏感内容均已脱敏
+type ConfigSnapshot struct {
+	Name          string           `json:"name"`
+	Protocol      Protocol         `json:"protocol"`
+	NetworkFamily NetworkFamily    `json:"network_family,omitempty"`
+	Servers       []ServerSnapshot `json:"servers"`
+	Routes        []RouteSnapshot  `json:"routes,omitempty"`
+
+	Timeout        string   `json:"timeout"`
+	RetryPolicy    string   `json:"retry_policy"`
+	Fallback       []string `json:"fallback,omitempty"`
+	MaxForwarders  int      `json:"max_forwarders,omitempty"`
+	MaxOutstanding int      `json:"max_outstanding,omitempty"`
+	QueueTimeout   string   `json:"queue_timeout,omitempty"`
+	NamePolicy     string   `json:"name_policy"`
+
+	Proxy ProxyType `json:"proxy_type,omitempty"`
+	// ProxyAddr 代理地址，设置了认证信息时以 URL 形式给出，密码已脱敏
+	ProxyAddr string `json:"proxy_addr,omitempty"`
+
+	Cache CacheSnapshot `json:"cache"`
+
+	// Features 启用的功能开关及自定义扩展点，按名称排序
+	Features []string `json:"features,omitempty"`
+}
+
+// ServerSnapshot 快照中的单个服务器
+type ServerSnapshot struct {
+	Address  string   `json:"address"`
+	Protocol Protocol `json:"protocol"`
+	TLSName  string   `json:"tls_name,omitempty"` // DoT/DoH 校验证书使用的服务器名称
+	Group    string   `json:"group,omitempty"`
+	Pinned   bool     `json:"pinned,omitempty"`
+	Proxy    string   `json:"proxy,omitempty"` // 该服务器专用的代理，密码已脱敏
+}
+
+// RouteSnapshot 快照中的单条路由规则
+type RouteSnapshot struct {
+	Name     string   `json:"name,omitempty"`
+	Suffix   string   `json:"suffix"`
+	Servers  []string `json:"servers"`
+	Protocol Protocol `json:"protocol,omitempty"`
+	Proxy    string   `json:"proxy,omitempty"`
+}
+
+// CacheSnapshot 快照中的缓存配置
+type CacheSnapshot struct {
+	Enabled       bool   `json:"enabled"`
+	MaxTTL        string `json:"max_ttl,omitempty"`
+	NegativeTTL   string `json:"negative_ttl,omitempty"`
+	PersistPath   string `json:"persist_path,omitempty"`
+	ErrorCacheTTL string `json:"error_cache_ttl,omitempty"`
+	TTLMin        uint32 `json:"ttl_min,omitempty"`
+	TTLMax        uint32 `json:"ttl_max,omitempty"`
+}
+
+// ConfigSnapshot 返回客户端生效配置的快照，用于排查问题
+func (c *Client) ConfigSnapshot() ConfigSnapshot {
+	cfg := c.config
+	s := ConfigSnapshot{
+		Name:           c.name,
+		Protocol:       cfg.Protocol,
+		NetworkFamily:  cfg.NetworkFamily,
+		Timeout:        cfg.Timeout.String(),
+		RetryPolicy:    c.retryPolicy(),
+		MaxForwarders:  cfg.MaxForwarders,
+		MaxOutstanding: cfg.MaxOutstanding,
+		QueueTimeout:   durationString(cfg.QueueTimeout),
+		NamePolicy:     cfg.NamePolicy.String(),
+		Proxy:          cfg.ProxyType,
+		ProxyAddr:      redactProxy(cfg.ProxyType, cfg.ProxyAddr, cfg.ProxyAuth),
+		Cache: CacheSnapshot{
+			Enabled:       cfg.CacheEnabled,
+			MaxTTL:        durationString(cfg.CacheMaxTTL),
+			NegativeTTL:   durationString(cfg.NegativeCacheTTL),
+			PersistPath:   cfg.CachePersistPath,
+			ErrorCacheTTL: durationString(cfg.ErrorCacheTTL),
+			TTLMin:        cfg.TTLMin,
+			TTLMax:        cfg.TTLMax,
+		},
+		Features: c.features(),
+	}
+	for _, p := range cfg.FallbackProtocols {
+		s.Fallback = append(s.Fallback, string(p))
+	}
+
+	seen := make(map[string]bool)
+	add := func(server string, protocol Protocol) {
+		if seen[server] {
+			return
+		}
+		seen[server] = true
+		s.Servers = append(s.Servers, c.serverSnapshot(server, protocol))
+	}
+	for _, server := range cfg.Servers {
+		add(server, "")
+	}
+	for _, tag := range sortedTags(cfg.TaggedServers) {
+		for _, server := range cfg.TaggedServers[tag] {
+			add(server, "")
+		}
+	}
+	for _, spec := range cfg.MixedRace {
+		add(spec.Address, spec.Protocol)
+	}
+	for _, rule := range cfg.Routes {
+		route := RouteSnapshot{
+			Name:     rule.Name,
+			Suffix:   rule.Suffix,
+			Protocol: rule.Protocol,
+			Proxy:    redactProxy(rule.ProxyType, rule.ProxyAddr, rule.ProxyAuth),
+		}
+		for _, server := range rule.Servers {
+			route.Servers = append(route.Servers, redactURL(server))
+			add(server, rule.Protocol)
+		}
+		s.Routes = append(s.Routes, route)
+	}
+	return s
+}
+
+// serverSnapshot 构建单个服务器的快照，protocol 为空时使用该服务器的协议
+func (c *Client) serverSnapshot(server string, protocol Protocol) ServerSnapshot {
+	info, ok := c.servers[server]
+	if protocol == "" {
+		protocol = info.Protocol
+	}
+	if protocol == "" {
+		protocol = c.config.Protocol
+	}
+	s := ServerSnapshot{
+		Address:  redactURL(normalizeServer(server, protocol)),
+		Protocol: protocol,
+		Group:    c.serverTags[server],
+	}
+	if ok {
+		s.Pinned = len(info.Pins) > 0
+		s.Proxy = redactURL(info.Proxy)
+	}
+	if protocol.encrypted() {
+		s.TLSName = c.tlsConfigFor(server).ServerName
+		if s.TLSName == "" {
+			s.TLSName = tlsHost(server, protocol)
+		}
+	}
+	return s
+}
+
+// tlsHost 返回未显式设置服务器名称时用于证书校验的主机名
+func tlsHost(server string, protocol Protocol) string {
+	if protocol == DoH {
+		if u, err := parseDoHURL(server); err == nil {
+			return u.Hostname()
+		}
+		return ""
+	}
+	host, _, err := net.SplitHostPort(normalizeServer(server, DoT))
+	if err != nil {
+		return server
+	}
+	return host
+}
+
+// retryPolicy 描述重试策略
+func (c *Client) retryPolicy() string {
+	policy := fmt.Sprintf("%d retries, %s", c.config.Retries, c.config.RetryPlacement)
+	if len(c.config.UDPRetransmit) > 0 {
+		schedule := make([]string, len(c.config.UDPRetransmit))
+		for i, d := range c.config.UDPRetransmit {
+			schedule[i] = d.String()
+		}
+		policy += ", udp retransmit " + strings.Join(schedule, "/")
+	}
+	if c.config.HedgedFallback {
+		policy += ", hedged fallback after " + c.config.HedgeDelay.String()
+	}
+	return policy
+}
+
+// features 返回启用的功能开关
+func (c *Client) features() []string {
+	cfg := c.config
+	flags := map[string]bool{
+		"pipelining":                cfg.Pipelining,
+		"require-ad":                cfg.RequireAD,
+		"source-port-randomization": cfg.SourcePortRandomization,
+		"partial-results":           cfg.PartialResults,
+		"sent-query":                cfg.RecordSentQuery,
+		"custom-transport":          cfg.Transport != nil,
+		"custom-http-client":        cfg.HTTPClient != nil,
+		"on-request-hook":           cfg.OnRequest != nil,
+		"on-saturated-hook":         cfg.OnSaturated != nil,
+		"response-interceptor":      cfg.ResponseInterceptor != nil,
+		"injected-clock":            cfg.Clock != nil && cfg.Clock != Clock(systemClock{}),
+		"injected-rand":             cfg.Rand != nil,
+		"debug":                     cfg.Debug,
+		"skip-refusal-probe":        cfg.SkipRefusalProbe,
+		"any-fallback":              cfg.ANYFallback,
+		"dane":                      cfg.DANE,
+		"doh-post":                  cfg.DoHMethod == http.MethodPost,
+		"cache-backend":             cfg.CacheBackend != nil,
+	}
+	if cfg.Quorum > 0 {
+		flags[fmt.Sprintf("quorum=%d", cfg.Quorum)] = true
+	}
+	if cfg.ClientSubnet != "" {
+		flags["client-subnet="+cfg.ClientSubnet] = true
+	}
+	if cfg.FanoutWaveSize > 0 {
+		flags[fmt.Sprintf("fanout=%d/%s", cfg.FanoutWaveSize, cfg.FanoutStagger)] = true
+	}
+
+	var features []string
+	for name, on := range flags {
+		if on {
+			features = append(features, name)
+		}
+	}
+	sort.Strings(features)
+	return features
+}
+
+// String 以紧凑的单行形式输出快照
+func (s ConfigSnapshot) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "name=%s protocol=%s", s.Name, s.Protocol)
+	if s.NetworkFamily != AnyFamily {
+		fmt.Fprintf(&b, " family=%s", s.NetworkFamily)
+	}
+
+	servers := make([]string, len(s.Servers))
+	for i, server := range s.Servers {
+		servers[i] = server.Address + "/" + string(server.Protocol)
+		if server.TLSName != "" {
+			servers[i] += "(" + server.TLSName + ")"
+		}
+		if server.Proxy != "" {
+			servers[i] += " via " + server.Proxy
+		}
+	}
+	fmt.Fprintf(&b, " servers=[%s]", strings.Join(servers, " "))
+	if len(s.Routes) > 0 {
+		fmt.Fprintf(&b, " routes=%d", len(s.Routes))
+	}
+
+	fmt.Fprintf(&b, " timeout=%s retry=%q", s.Timeout, s.RetryPolicy)
+	if len(s.Fallback) > 0 {
+		fmt.Fprintf(&b, " fallback=%s", strings.Join(s.Fallback, ","))
+	}
+	if s.Proxy != NoProxy {
+		fmt.Fprintf(&b, " proxy=%s", s.ProxyAddr)
+	}
+	if s.Cache.Enabled {
+		fmt.Fprintf(&b, " cache=on")
+		if s.Cache.MaxTTL != "" {
+			fmt.Fprintf(&b, "(max %s)", s.Cache.MaxTTL)
+		}
+		if s.Cache.NegativeTTL != "" {
+			fmt.Fprintf(&b, " negative-cache=%s", s.Cache.NegativeTTL)
+		}
+	}
+	if s.Cache.ErrorCacheTTL != "" {
+		fmt.Fprintf(&b, " error-cache=%s", s.Cache.ErrorCacheTTL)
+	}
+	if len(s.Features) > 0 {
+		fmt.Fprintf(&b, " features=%s", strings.Join(s.Features, ","))
+	}
+	return b.String()
+}
+
+// durationString 格式化时长，0 返回空字符串
+func durationString(d time.Duration) string {
+	if d == 0 {
+		return ""
+	}
+	return d.String()
+}
+
+// redactProxy 返回脱敏后的代理地址，设置了认证信息时以 URL 形式给出用户名
+func redactProxy(proxyType ProxyType, addr string, auth *ProxyAuth) string {
+	if proxyType == NoProxy || addr == "" {
+		return ""
+	}
+	if strings.Contains(addr, "://") {
+		return redactURL(addr)
+	}
+	if auth == nil {
+		return addr
+	}
+	u := &url.URL{Scheme: string(proxyType), Host: addr, User: url.User(auth.Username)}
+	if auth.Password != "" {
+		u.User = url.UserPassword(auth.Username, redactedSecret)
+	}
+	return u.String()
+}
+
+// redactURL 隐藏 URL 中的密码，无法解析的地址中若含用户信息则整体隐藏
+func redactURL(s string) string {
+	if !strings.Contains(s, "@") {
+		return s
+	}
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" {
+		return redactedSecret
+	}
+	return u.Redacted()
+}
@@ -0,0 +1,78 @@
+package godns
+
+import (
+	"fmt"
+
+	"github.com/miekg/dns"
+)
+
+// SOARecord SOA记录的各字段
+type SOARecord struct {
+	MName   string // 主名称服务器
+	RName   string // 管理员邮箱（以点代替 @）
+	Serial  uint32 // 区域序列号
+	Refresh uint32 // 辅助服务器刷新间隔（秒）
+	Retry   uint32 // 刷新失败后的重试间隔（秒）
+	Expire  uint32 // 辅助服务器停止应答前的最长时间（秒）
+	Minttl  uint32 // 否定应答的缓存时间（秒）
+}
+
+// newSOARecord 从 *dns.SOA 构建 SOARecord
+func newSOARecord(soa *dns.SOA) *SOARecord {
+	return &SOARecord{
+		MName:   soa.Ns,
+		RName:   soa.Mbox,
+		Serial:  soa.Serial,
+		Refresh: soa.Refresh,
+		Retry:   soa.Retry,
+		Expire:  soa.Expire,
+		Minttl:  soa.Minttl,
+	}
+}
+
+// String 返回区域文件格式的 RDATA，与SOA记录的 Record.Value 相同
+func (s *SOARecord) String() string {
+	return fmt.Sprintf("%s %s %d %d %d %d %d", s.MName, s.RName, s.Serial, s.Refresh, s.Retry, s.Expire, s.Minttl)
+}
+
+// SOA 返回SOA记录的各字段，记录不是SOA类型时返回 false
+// 反序列化得到的 Record 从 Value 中解析
+func (r Record) SOA() (*SOARecord, bool) {
+	if soa, ok := r.rr.(*dns.SOA); ok {
+		return newSOARecord(soa), true
+	}
+	if r.Type != dns.TypeSOA {
+		return nil, false
+	}
+	s := &SOARecord{}
+	if _, err := fmt.Sscan(r.Value(), &s.MName, &s.RName, &s.Serial, &s.Refresh, &s.Retry, &s.Expire, &s.Minttl); err != nil {
+		return nil, false
+	}
+	return s, true
+}
+
+// SOA 返回结果中第一条SOA记录，没有时返回 nil
+func (r *QueryResult) SOA() *SOARecord {
+	for _, record := range r.Records {
+		if soa, ok := record.SOA(); ok {
+			return soa
+		}
+	}
+	return nil
+}
+
+// SOASerials 返回各服务器应答中的SOA序列号，键为服务器地址，可用于发现未同步的辅助服务器
+// 失败或不含SOA记录的结果不出现在返回值中
+func (r *MultiQueryResult) SOASerials() map[string]uint32 {
+	serials := make(map[string]uint32, len(r.Results))
+	for i := range r.Results {
+		res := &r.Results[i]
+		if res.Error != nil {
+			continue
+		}
+		if soa := res.SOA(); soa != nil {
+			serials[res.Server] = soa.Serial
+		}
+	}
+	return serials
+}
@@ -0,0 +1,90 @@
+package godns_test
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+	"github.com/zan8in/godns/internal/testserver"
+)
+
+const exampleSOA = "example.test. 3600 IN SOA ns1.example.test. hostmaster.example.test. 2024010101 7200 900 1209600 300"
+
+// TestQuerySOA SOA记录的各字段以类型化的结构给出，Record.Value 为可读的区域文件格式 RDATA，
+// 反序列化得到的记录同样能解析出各字段
+func TestQuerySOA(t *testing.T) {
+	s := startServer(t)
+	s.Answer("example.test", dns.TypeSOA, exampleSOA)
+	s.Answer("example.test", dns.TypeA, "example.test. 60 IN A 192.0.2.1")
+	c := godns.New(godns.WithServers(s.UDPAddr), godns.WithRetries(0))
+	defer c.Close()
+
+	res, err := c.QuerySOA(context.Background(), "example.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := godns.SOARecord{
+		MName:   "ns1.example.test.",
+		RName:   "hostmaster.example.test.",
+		Serial:  2024010101,
+		Refresh: 7200,
+		Retry:   900,
+		Expire:  1209600,
+		Minttl:  300,
+	}
+	soa := res.SOA()
+	if soa == nil || *soa != want {
+		t.Fatalf("SOA = %+v, want %+v", soa, want)
+	}
+	const value = "ns1.example.test. hostmaster.example.test. 2024010101 7200 900 1209600 300"
+	if got := res.Records[0].Value(); got != value || soa.String() != value {
+		t.Errorf("Value = %q, String = %q, want %q", got, soa.String(), value)
+	}
+
+	data, err := json.Marshal(res.Records[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded godns.Record
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := decoded.SOA(); !ok || *got != want {
+		t.Errorf("SOA after JSON round trip = %+v, %v", got, ok)
+	}
+
+	// 不含SOA记录的结果
+	res, err = c.QueryA(context.Background(), "example.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.SOA() != nil {
+		t.Errorf("SOA of an A answer = %+v, want nil", res.SOA())
+	}
+	if _, ok := res.Records[0].SOA(); ok {
+		t.Error("A record parsed as SOA")
+	}
+}
+
+// TestSOASerials 按服务器列出SOA序列号以发现未同步的服务器，失败或不含SOA记录的服务器不出现
+func TestSOASerials(t *testing.T) {
+	primary, stale, failing, empty := startServer(t), startServer(t), startServer(t), startServer(t)
+	primary.Answer("example.test", dns.TypeSOA, exampleSOA)
+	stale.Answer("example.test", dns.TypeSOA, "example.test. 3600 IN SOA ns1.example.test. hostmaster.example.test. 2023123101 7200 900 1209600 300")
+	failing.Handle("example.test", dns.TypeSOA, testserver.Reply{Rcode: dns.RcodeServerFailure})
+	empty.Handle("example.test", dns.TypeSOA, testserver.Reply{})
+	c := godns.New(godns.WithServers(primary.UDPAddr, stale.UDPAddr, failing.UDPAddr, empty.UDPAddr), godns.WithRetries(0))
+	defer c.Close()
+
+	res, err := c.MultiQuery(context.Background(), "example.test", dns.TypeSOA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]uint32{primary.UDPAddr: 2024010101, stale.UDPAddr: 2023123101}
+	if got := res.SOASerials(); !reflect.DeepEqual(got, want) {
+		t.Errorf("SOASerials = %v, want %v", got, want)
+	}
+}
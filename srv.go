@@ -0,0 +1,125 @@
+package godns
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/miekg/dns"
+)
+
+// SRVRecord SRV记录的各字段
+type SRVRecord struct {
+	Priority uint16 // 优先级，越小越优先
+	Weight   uint16 // 相同优先级内的相对权重
+	Port     uint16
+	Target   string // 目标主机名，"." 表示该服务不可用
+}
+
+// String 返回区域文件格式的 RDATA，与SRV记录的 Record.Value 相同
+func (s *SRVRecord) String() string {
+	return fmt.Sprintf("%d %d %d %s", s.Priority, s.Weight, s.Port, s.Target)
+}
+
+// SRV 返回SRV记录的各字段，记录不是SRV类型时返回 false
+// 反序列化得到的 Record 从 Value 中解析
+func (r Record) SRV() (*SRVRecord, bool) {
+	if srv, ok := r.rr.(*dns.SRV); ok {
+		return &SRVRecord{Priority: srv.Priority, Weight: srv.Weight, Port: srv.Port, Target: srv.Target}, true
+	}
+	if r.Type != dns.TypeSRV {
+		return nil, false
+	}
+	s := &SRVRecord{}
+	if _, err := fmt.Sscan(r.Value(), &s.Priority, &s.Weight, &s.Port, &s.Target); err != nil {
+		return nil, false
+	}
+	return s, true
+}
+
+// SRVRecords 返回结果中的SRV记录，按优先级升序、相同优先级按权重降序排列
+func (r *QueryResult) SRVRecords() []SRVRecord {
+	var records []SRVRecord
+	for _, record := range r.Records {
+		if srv, ok := record.SRV(); ok {
+			records = append(records, *srv)
+		}
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		if records[i].Priority != records[j].Priority {
+			return records[i].Priority < records[j].Priority
+		}
+		return records[i].Weight > records[j].Weight
+	})
+	return records
+}
+
+// QuerySRV 查询SRV记录，name 为完整的 _service._proto.name 形式，按原样查询
+// 结构化的字段可通过 QueryResult.SRVRecords 获取
+func (c *Client) QuerySRV(ctx context.Context, name string) (*QueryResult, error) {
+	return c.Query(c.relaxNamePolicy(ctx, ServiceLabels), name, dns.TypeSRV)
+}
+
+// LookupSRV 查询SRV记录并按 RFC 2782 排序：优先级升序，相同优先级内按权重随机排列，
+// 与 net.LookupSRV 的顺序规则相同；随机数来源受 WithRandSource 控制
+// 没有SRV记录时返回空切片而不是错误，名称不存在时返回包装 ErrNXDomain 的错误
+func (c *Client) LookupSRV(ctx context.Context, name string) ([]SRVRecord, error) {
+	res, err := c.QuerySRV(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	records := res.SRVRecords()
+	if records == nil {
+		records = []SRVRecord{}
+	}
+	c.shuffleSRV(records)
+	return records, nil
+}
+
+// LookupService 查询 _service._proto.domain 的SRV记录，service 和 proto 均为空时直接查询 domain
+// 排序规则同 LookupSRV
+func (c *Client) LookupService(ctx context.Context, service, proto, domain string) ([]SRVRecord, error) {
+	return c.LookupSRV(ctx, serviceName(service, proto, domain))
+}
+
+// serviceName 构造 _service._proto.domain 形式的名称
+func serviceName(service, proto, domain string) string {
+	if service == "" && proto == "" {
+		return domain
+	}
+	return "_" + service + "._" + proto + "." + domain
+}
+
+// shuffleSRV 对已按优先级排序的记录，在每个优先级内按权重随机排列
+func (c *Client) shuffleSRV(records []SRVRecord) {
+	for start := 0; start < len(records); {
+		end := start + 1
+		for end < len(records) && records[end].Priority == records[start].Priority {
+			end++
+		}
+		c.shuffleByWeight(records[start:end])
+		start = end
+	}
+}
+
+// shuffleByWeight RFC 2782 的加权选择：依次以与权重成正比的概率选出下一条记录，
+// 权重为0的记录排在最后
+func (c *Client) shuffleByWeight(records []SRVRecord) {
+	sum := 0
+	for _, record := range records {
+		sum += int(record.Weight)
+	}
+	for sum > 0 && len(records) > 1 {
+		n := c.intn(sum)
+		s := 0
+		for i := range records {
+			s += int(records[i].Weight)
+			if s > n {
+				records[0], records[i] = records[i], records[0]
+				break
+			}
+		}
+		sum -= int(records[0].Weight)
+		records = records[1:]
+	}
+}
@@ -0,0 +1,158 @@
+package godns
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// SVCBRecord SVCB/HTTPS记录的各字段（RFC 9460）
+type SVCBRecord struct {
+	Priority uint16            // 0 表示别名模式（AliasMode），其余为服务模式下的优先级，越小越优先
+	Target   string            // 目标名称，服务模式下 "." 表示记录所有者名称本身
+	Params   map[string]string // 服务参数，键如 "alpn"、"port"、"ipv4hint"，值为区域文件中的表示形式
+}
+
+// AliasMode 是否为别名模式记录：Target 是应继续查询的名称，记录不携带服务参数
+func (s *SVCBRecord) AliasMode() bool {
+	return s.Priority == 0
+}
+
+// ALPN 返回 alpn 参数中的协议列表，例如 ["h2", "h3"]
+func (s *SVCBRecord) ALPN() []string {
+	alpn, ok := s.Params["alpn"]
+	if !ok || alpn == "" {
+		return nil
+	}
+	return strings.Split(alpn, ",")
+}
+
+// Port 返回 port 参数，未设置时返回 false
+func (s *SVCBRecord) Port() (uint16, bool) {
+	port, err := strconv.ParseUint(s.Params["port"], 10, 16)
+	if err != nil {
+		return 0, false
+	}
+	return uint16(port), true
+}
+
+// IPHints 返回 ipv4hint 和 ipv6hint 参数中的地址，IPv4 在前
+func (s *SVCBRecord) IPHints() []netip.Addr {
+	var addrs []netip.Addr
+	for _, key := range []string{"ipv4hint", "ipv6hint"} {
+		hints, ok := s.Params[key]
+		if !ok {
+			continue
+		}
+		for _, hint := range strings.Split(hints, ",") {
+			if addr, err := netip.ParseAddr(hint); err == nil {
+				addrs = append(addrs, addr.Unmap())
+			}
+		}
+	}
+	return addrs
+}
+
+// String 返回区域文件格式的 RDATA，与SVCB/HTTPS记录的 Record.Value 相同，参数按键名排序
+func (s *SVCBRecord) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d %s", s.Priority, s.Target)
+	keys := make([]string, 0, len(s.Params))
+	for key := range s.Params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(&b, " %s=%q", key, s.Params[key])
+	}
+	return b.String()
+}
+
+// newSVCBRecord 从资源记录构建 SVCBRecord
+func newSVCBRecord(rr *dns.SVCB) *SVCBRecord {
+	s := &SVCBRecord{Priority: rr.Priority, Target: rr.Target}
+	if len(rr.Value) > 0 {
+		s.Params = make(map[string]string, len(rr.Value))
+		for _, kv := range rr.Value {
+			s.Params[kv.Key().String()] = kv.String()
+		}
+	}
+	return s
+}
+
+// SVCB 返回SVCB或HTTPS记录的各字段，记录不是这两种类型时返回 false
+// 反序列化得到的 Record 从 Value 中解析
+func (r Record) SVCB() (*SVCBRecord, bool) {
+	switch v := r.rr.(type) {
+	case *dns.SVCB:
+		return newSVCBRecord(v), true
+	case *dns.HTTPS:
+		return newSVCBRecord(&v.SVCB), true
+	}
+	if r.Type != dns.TypeSVCB && r.Type != dns.TypeHTTPS {
+		return nil, false
+	}
+
+	fields := strings.SplitN(r.Value(), " ", 3)
+	if len(fields) < 2 {
+		return nil, false
+	}
+	priority, err := strconv.ParseUint(fields[0], 10, 16)
+	if err != nil {
+		return nil, false
+	}
+	s := &SVCBRecord{Priority: uint16(priority), Target: fields[1]}
+	if len(fields) == 2 {
+		return s, true
+	}
+
+	// 参数为 key="value" 形式，值可能包含转义的引号
+	s.Params = make(map[string]string)
+	rest := fields[2]
+	for rest != "" {
+		key, tail, ok := strings.Cut(rest, "=")
+		if !ok {
+			return nil, false
+		}
+		quoted, err := strconv.QuotedPrefix(tail)
+		if err != nil {
+			return nil, false
+		}
+		if s.Params[key], err = strconv.Unquote(quoted); err != nil {
+			return nil, false
+		}
+		rest = strings.TrimPrefix(tail[len(quoted):], " ")
+	}
+	return s, true
+}
+
+// SVCBRecords 返回结果中的SVCB/HTTPS记录，按优先级升序排列，别名模式记录（优先级0）在最前
+func (r *QueryResult) SVCBRecords() []SVCBRecord {
+	var records []SVCBRecord
+	for _, record := range r.Records {
+		if svcb, ok := record.SVCB(); ok {
+			records = append(records, *svcb)
+		}
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Priority < records[j].Priority
+	})
+	return records
+}
+
+// QueryHTTPS 查询HTTPS记录（类型65），结构化的字段可通过 QueryResult.SVCBRecords 获取
+// 非默认端口的服务使用 _port._https.domain 形式的名称（RFC 9460 §9.1）
+// 结果只包含别名模式记录时，客户端应对其 Target 重新查询HTTPS记录
+func (c *Client) QueryHTTPS(ctx context.Context, domain string) (*QueryResult, error) {
+	return c.Query(c.relaxNamePolicy(ctx, ServiceLabels), domain, dns.TypeHTTPS)
+}
+
+// QuerySVCB 查询SVCB记录（类型64），name 按原样查询，可包含 _dns.resolver.arpa 等服务标签
+func (c *Client) QuerySVCB(ctx context.Context, name string) (*QueryResult, error) {
+	return c.Query(c.relaxNamePolicy(ctx, ServiceLabels), name, dns.TypeSVCB)
+}
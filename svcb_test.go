@@ -0,0 +1,129 @@
+package godns_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/netip"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+)
+
+// TestQueryHTTPS HTTPS记录按优先级排列，别名模式在最前；服务参数可按类型化的方式读取，
+// 反序列化得到的记录从 Value 中解析出相同的字段
+func TestQueryHTTPS(t *testing.T) {
+	s := startServer(t)
+	s.Answer("example.test", dns.TypeHTTPS,
+		`example.test. 300 IN HTTPS 2 alt.example.test. alpn="h2"`,
+		`example.test. 300 IN HTTPS 1 . alpn="h2,h3" port="8443" ipv4hint="192.0.2.1" ipv6hint="2001:db8::1"`,
+		`example.test. 300 IN HTTPS 0 cdn.example.net.`,
+	)
+	c := godns.New(godns.WithServers(s.UDPAddr), godns.WithRetries(0))
+	defer c.Close()
+
+	res, err := c.QueryHTTPS(context.Background(), "example.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	records := res.SVCBRecords()
+	if len(records) != 3 {
+		t.Fatalf("SVCBRecords = %v, want 3 records", records)
+	}
+	if alias := records[0]; !alias.AliasMode() || alias.Target != "cdn.example.net." || alias.Params != nil {
+		t.Errorf("first record = %+v, want the AliasMode record", alias)
+	}
+
+	svc := records[1]
+	if svc.AliasMode() || svc.Priority != 1 || svc.Target != "." {
+		t.Errorf("service record = %+v", svc)
+	}
+	if alpn := svc.ALPN(); !slices.Equal(alpn, []string{"h2", "h3"}) {
+		t.Errorf("ALPN = %v", alpn)
+	}
+	if port, ok := svc.Port(); !ok || port != 8443 {
+		t.Errorf("Port = %d, %v", port, ok)
+	}
+	want := []netip.Addr{netip.MustParseAddr("192.0.2.1"), netip.MustParseAddr("2001:db8::1")}
+	if hints := svc.IPHints(); !slices.Equal(hints, want) {
+		t.Errorf("IPHints = %v, want %v", hints, want)
+	}
+	if _, ok := records[2].Port(); ok {
+		t.Error("Port reported for a record without a port parameter")
+	}
+
+	for _, record := range res.Records {
+		data, err := json.Marshal(record)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded godns.Record
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		live, _ := record.SVCB()
+		parsed, ok := decoded.SVCB()
+		if !ok || !reflect.DeepEqual(parsed, live) {
+			t.Errorf("after JSON round trip %q parsed as %+v, want %+v", decoded.Value(), parsed, live)
+		}
+		if live.String() != record.Value() {
+			t.Errorf("String = %q, Value = %q", live.String(), record.Value())
+		}
+	}
+}
+
+// TestQuerySVCB SVCB查询允许服务标签，例如 DDR 使用的 _dns.resolver.arpa（RFC 9462）
+func TestQuerySVCB(t *testing.T) {
+	s := startServer(t)
+	s.Answer("_dns.resolver.arpa", dns.TypeSVCB,
+		`_dns.resolver.arpa. 300 IN SVCB 1 dns.example.test. alpn="dot" port="853" ipv4hint="192.0.2.53"`,
+	)
+	c := godns.New(godns.WithServers(s.UDPAddr), godns.WithRetries(0))
+	defer c.Close()
+
+	res, err := c.QuerySVCB(context.Background(), "_dns.resolver.arpa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	records := res.SVCBRecords()
+	if len(records) != 1 {
+		t.Fatalf("SVCBRecords = %v", records)
+	}
+	if got := records[0]; got.Target != "dns.example.test." || !slices.Equal(got.ALPN(), []string{"dot"}) || got.Params["port"] != "853" {
+		t.Errorf("record = %+v", got)
+	}
+
+	// 非SVCB/HTTPS记录不解析
+	s.Answer("example.test", dns.TypeA, "example.test. 300 IN A 192.0.2.1")
+	a, err := c.QueryA(context.Background(), "example.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := a.Records[0].SVCB(); ok || len(a.SVCBRecords()) != 0 {
+		t.Error("A record parsed as SVCB")
+	}
+}
+
+// TestSVCBHintsInAllIPs SVCB/HTTPS的地址提示并入 MultiQueryResult.AllIPs，与其它服务器的提示去重
+func TestSVCBHintsInAllIPs(t *testing.T) {
+	a, b := startServer(t), startServer(t)
+	a.Answer("example.test", dns.TypeHTTPS, `example.test. 300 IN HTTPS 1 . ipv4hint="192.0.2.1,192.0.2.2" ipv6hint="2001:db8::1"`)
+	b.Answer("example.test", dns.TypeHTTPS,
+		`example.test. 300 IN HTTPS 1 . ipv4hint="192.0.2.2" ipv6hint="2001:db8:0::1"`,
+		`example.test. 300 IN HTTPS 0 cdn.example.net.`,
+	)
+	c := godns.New(godns.WithServers(a.UDPAddr, b.UDPAddr), godns.WithRetries(0))
+	defer c.Close()
+
+	res, err := c.MultiQuery(context.Background(), "example.test", dns.TypeHTTPS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := slices.Clone(res.AllIPs)
+	slices.Sort(got)
+	if want := []string{"192.0.2.1", "192.0.2.2", "2001:db8::1"}; !slices.Equal(got, want) {
+		t.Errorf("AllIPs = %v, want %v", got, want)
+	}
+}
@@ -1,70 +1,98 @@
 package godns
 
 import (
+	"bytes"
 	"context"
+	"crypto/rand"
 	"crypto/tls"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
+	"math/big"
+	"net"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/miekg/dns"
 	"golang.org/x/net/proxy"
 )
 
-// queryUDPTCP UDP/TCP查询 - 简化版
-func (c *Client) queryUDPTCP(ctx context.Context, msg *dns.Msg, server string) (*dns.Msg, error) {
-	client := &dns.Client{
-		Net:     string(c.config.Protocol),
-		Timeout: c.config.Timeout,
-	}
-
-	return c.withRetry(ctx, func() (*dns.Msg, error) {
-		if c.config.ProxyType != NoProxy {
-			return c.exchangeWithProxy(ctx, msg, server)
-		}
-		response, _, err := client.ExchangeContext(ctx, msg, server)
-		return response, err
-	})
+// transports 在客户端构建时一次性准备的传输层对象，查询热路径只读共享
+type transports struct {
+	udpClient  *dns.Client // UDP
+	tcpClient  *dns.Client // TCP
+	dotClient  *dns.Client // DoT
+	tlsConfig  *tls.Config
+	httpClient *http.Client // DoH
+	dohURLs    map[string]*url.URL
+
+	proxyDialer proxy.Dialer
+	proxyURL    *url.URL
+	proxyErr    error // 代理配置错误，延迟到查询时返回
+
+	serverTLS map[string]*serverTLS // 设置了TLS覆盖的服务器
+	tlsErr    error                 // TLS覆盖配置错误，延迟到查询时返回
 }
 
-// queryDoT DoT查询 - 简化版
-func (c *Client) queryDoT(ctx context.Context, msg *dns.Msg, server string) (*dns.Msg, error) {
-	tlsConfig := c.config.TLSConfig
-	if tlsConfig == nil {
-		tlsConfig = &tls.Config{}
+// prepareTransports 根据配置构建所有可复用的传输层对象
+func (c *Client) prepareTransports() {
+	t := &c.transports
+
+	t.udpClient = &dns.Client{
+		Net:     c.network(UDP),
+		Timeout: c.config.Timeout,
+	}
+	t.tcpClient = &dns.Client{
+		Net:     c.network(TCP),
+		Timeout: c.config.Timeout,
 	}
 
-	client := &dns.Client{
-		Net:       "tcp-tls",
+	t.tlsConfig = c.config.TLSConfig
+	if t.tlsConfig == nil {
+		t.tlsConfig = &tls.Config{}
+	}
+	t.dotClient = &dns.Client{
+		Net:       c.network(DoT),
 		Timeout:   c.config.Timeout,
-		TLSConfig: tlsConfig,
+		TLSConfig: t.tlsConfig,
 	}
 
-	// 确保端口
-	if !strings.Contains(server, ":") {
-		server += ":853"
+	if c.config.ProxyType != NoProxy {
+		if c.config.ProxyType == SOCKS5 {
+			t.proxyDialer, t.proxyErr = c.createDialer()
+		}
+		if t.proxyErr == nil {
+			t.proxyURL, t.proxyErr = c.getProxyURL()
+		}
 	}
 
-	return c.withRetry(ctx, func() (*dns.Msg, error) {
-		if c.config.ProxyType != NoProxy {
-			return c.exchangeDoTWithProxy(ctx, msg, server, tlsConfig)
+	if c.usesProtocol(DoH) {
+		t.dohURLs = make(map[string]*url.URL, len(c.config.Servers))
+		for _, server := range c.config.Servers {
+			if u, err := parseDoHURL(server); err == nil {
+				t.dohURLs[server] = u
+			}
 		}
-		response, _, err := client.ExchangeContext(ctx, msg, server)
-		return response, err
-	})
-}
 
-// queryDoH DoH查询 - 简化版
-func (c *Client) queryDoH(ctx context.Context, msg *dns.Msg, server string) (*dns.Msg, error) {
-	msgBytes, err := msg.Pack()
-	if err != nil {
-		return nil, fmt.Errorf("failed to pack DNS message: %v", err)
+		t.httpClient = c.config.HTTPClient
+		if t.httpClient == nil {
+			t.httpClient = &http.Client{
+				Transport: c.newHTTPTransport(c.config.TLSConfig),
+				Timeout:   c.config.Timeout,
+			}
+		}
 	}
 
-	// 构建DoH URL
+	c.prepareServerTLS()
+}
+
+// parseDoHURL 将服务器地址规范化为DoH URL
+func parseDoHURL(server string) (*url.URL, error) {
 	dohURL := server
 	if !strings.HasPrefix(server, "http") {
 		dohURL = "https://" + server + "/dns-query"
@@ -74,180 +102,407 @@ func (c *Client) queryDoH(ctx context.Context, msg *dns.Msg, server string) (*dn
 	if err != nil {
 		return nil, fmt.Errorf("invalid DoH URL: %v", err)
 	}
+	return u, nil
+}
 
-	q := u.Query()
-	q.Set("dns", base64.RawURLEncoding.EncodeToString(msgBytes))
-	u.RawQuery = q.Encode()
+// Transport 传输层接口，负责把查询发送到服务器并返回应答
+// 重试、故障转移、MultiQuery、缓存和回调等上层逻辑均构建在其之上
+type Transport interface {
+	Exchange(ctx context.Context, msg *dns.Msg, server string) (*dns.Msg, error)
+}
 
-	httpClient := c.config.HTTPClient
-	if httpClient == nil {
-		transport := &http.Transport{
-			TLSClientConfig: c.config.TLSConfig,
-		}
+// WithTransport 使用自定义传输层替代内置的协议实现，例如用于单元测试或回放录制的应答
+func WithTransport(t Transport) Option {
+	return func(c *Config) {
+		c.Transport = t
+	}
+}
+
+// Transport 返回内置协议的传输层实现，每次 Exchange 只进行一次交换（不含重试），
+// 可包装后通过 WithTransport 注入其他客户端
+func (c *Client) Transport(protocol Protocol) Transport {
+	return protocolTransport{c: c, protocol: protocol}
+}
+
+// protocolTransport 内置协议的传输层实现
+type protocolTransport struct {
+	c        *Client
+	protocol Protocol
+}
+
+// Exchange 实现 Transport 接口
+func (t protocolTransport) Exchange(ctx context.Context, msg *dns.Msg, server string) (*dns.Msg, error) {
+	info := t.c.serverInfo(server)
+	switch t.protocol {
+	case UDP, TCP:
+		return t.c.exchangeUDPTCP(ctx, t.protocol, msg, info)
+	case DoT:
+		return t.c.exchangeDoT(ctx, msg, info)
+	case DoH:
+		return t.c.exchangeDoH(ctx, msg, info)
+	case DoQ:
+		return t.c.exchangeDoQ(ctx, msg, info)
+	default:
+		return nil, fmt.Errorf("unsupported protocol: %s", t.protocol)
+	}
+}
 
-		if c.config.ProxyType != NoProxy {
-			proxyURL, err := c.getProxyURL()
-			if err != nil {
-				return nil, fmt.Errorf("failed to get proxy URL: %v", err)
+// exchange 按指定协议向服务器发送查询（含重试）
+func (c *Client) exchange(ctx context.Context, protocol Protocol, msg *dns.Msg, server string) (*dns.Msg, error) {
+	ctx = c.withInfrastructure(ctx)
+	if response, ok, err := c.proxiedExchange(ctx, protocol, msg, server); ok {
+		return response, err
+	}
+
+	servers := c.retryServers(ctx, server)
+	t := c.config.Transport
+	if t == nil {
+		if _, err := protocol.network(c.config.NetworkFamily); err != nil {
+			return nil, err
+		}
+		switch protocol {
+		case UDP, TCP, DoH:
+		case DoT, DoQ:
+			// 确保端口
+			normalized := make([]string, len(servers))
+			for i, server := range servers {
+				if !strings.Contains(server, ":") {
+					server += ":853"
+				}
+				normalized[i] = server
 			}
-			transport.Proxy = http.ProxyURL(proxyURL)
+			servers = normalized
 		}
+		t = protocolTransport{c: c, protocol: protocol}
+	}
 
-		httpClient = &http.Client{
-			Transport: transport,
-			Timeout:   c.config.Timeout,
-		}
+	return c.withRetry(ctx, protocol, servers, func(ctx context.Context, server string) (*dns.Msg, error) {
+		return t.Exchange(ctx, msg, server)
+	})
+}
+
+// exchangeUDPTCP UDP/TCP查询 - 简化版
+func (c *Client) exchangeUDPTCP(ctx context.Context, protocol Protocol, msg *dns.Msg, info ServerInfo) (*dns.Msg, error) {
+	server := info.Address
+	client := c.transports.udpClient
+	if protocol == TCP {
+		client = c.transports.tcpClient
 	}
 
-	return c.withRetry(ctx, func() (*dns.Msg, error) {
-		req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create HTTP request: %v", err)
-		}
+	if c.config.ProxyType != NoProxy {
+		return c.exchangeWithProxy(ctx, msg, info)
+	}
+	if c.config.Pipelining && protocol == TCP {
+		return c.pipelines.exchange(ctx, client, msg, server)
+	}
+	if c.config.SourcePortRandomization && protocol == UDP {
+		return c.exchangeUDPRandomPort(ctx, client, msg, server)
+	}
+	return c.exchangeDirect(ctx, client, msg, server)
+}
 
-		req.Header.Set("Accept", "application/dns-message")
-		req.Header.Set("Content-Type", "application/dns-message")
+// exchangeDirect 直连服务器完成一次交换，并记录应答的来源地址
+func (c *Client) exchangeDirect(ctx context.Context, client *dns.Client, msg *dns.Msg, server string) (*dns.Msg, error) {
+	conn, err := client.DialContext(ctx, server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if !strings.HasPrefix(client.Net, "udp") {
+		conn.Conn = newLimitConn(conn.Conn, c.config.MaxResponseBytes)
+	}
+
+	// miekg/dns 只按 ctx 的截止时间设置连接超时，取消（如 Shutdown 宽限期结束）需要主动打断读写
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	var response *dns.Msg
+	if len(c.config.UDPRetransmit) > 0 && strings.HasPrefix(client.Net, "udp") {
+		response, err = c.exchangeRetransmit(ctx, client, conn, msg)
+	} else {
+		var rtt time.Duration
+		response, rtt, err = client.ExchangeWithConnContext(ctx, msg, conn)
+		setRTT(ctx, rtt)
+	}
+	if err != nil && ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if err == nil {
+		setRespondedBy(ctx, conn.RemoteAddr())
+	}
+	return response, err
+}
 
-		resp, err := httpClient.Do(req)
+// exchangeUDPRandomPort 绑定随机源端口后进行UDP查询
+func (c *Client) exchangeUDPRandomPort(ctx context.Context, client *dns.Client, msg *dns.Msg, server string) (*dns.Msg, error) {
+	var lastErr error
+	for i := 0; i < 3; i++ {
+		port, err := c.randomSourcePort()
 		if err != nil {
 			return nil, err
 		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			return nil, fmt.Errorf("HTTP error: %d %s", resp.StatusCode, resp.Status)
+		udpClient := *client
+		udpClient.Dialer = &net.Dialer{
+			Timeout:   c.config.Timeout,
+			LocalAddr: &net.UDPAddr{Port: port},
 		}
-
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return nil, fmt.Errorf("failed to read response body: %v", err)
+		response, err := c.exchangeDirect(ctx, &udpClient, msg, server)
+		if err == nil || !errors.Is(err, syscall.EADDRINUSE) {
+			return response, err
 		}
+		// 端口被占用，换一个端口重试
+		lastErr = err
+	}
+	return nil, lastErr
+}
 
-		response := new(dns.Msg)
-		if err := response.Unpack(body); err != nil {
-			return nil, fmt.Errorf("failed to unpack DNS response: %v", err)
-		}
+// randomSourcePort 使用加密随机数在非特权端口范围内选取源端口
+func (c *Client) randomSourcePort() (int, error) {
+	const minPort, maxPort = 1024, 65535
+	if n := c.randomIntn(maxPort - minPort + 1); n >= 0 {
+		return minPort + n, nil
+	}
+	n, err := rand.Int(rand.Reader, big.NewInt(maxPort-minPort+1))
+	if err != nil {
+		return 0, fmt.Errorf("failed to pick random source port: %v", err)
+	}
+	return minPort + int(n.Int64()), nil
+}
 
-		return response, nil
-	})
+// exchangeDoT DoT查询 - 简化版
+func (c *Client) exchangeDoT(ctx context.Context, msg *dns.Msg, info ServerInfo) (*dns.Msg, error) {
+	server := info.Address
+	if c.transports.tlsErr != nil {
+		return nil, c.transports.tlsErr
+	}
+	client := c.transports.dotClient
+	tlsConfig := c.transports.tlsConfig
+	if st, ok := c.transports.serverTLS[server]; ok {
+		client = st.dotClient
+		tlsConfig = st.config
+	}
+	if cfg, err := c.daneTLSConfig(ctx, server, tlsConfig); err != nil {
+		return nil, err
+	} else if cfg != tlsConfig {
+		daneClient := *client
+		daneClient.TLSConfig = cfg
+		client, tlsConfig = &daneClient, cfg
+	}
+
+	if c.config.ProxyType != NoProxy {
+		return c.exchangeDoTWithProxy(ctx, msg, info, tlsConfig)
+	}
+	if c.config.Pipelining {
+		return c.pipelines.exchange(ctx, client, msg, server)
+	}
+	return c.exchangeDirect(ctx, client, msg, server)
 }
 
-// exchangeWithProxy 通过代理进行DNS查询
-func (c *Client) exchangeWithProxy(ctx context.Context, msg *dns.Msg, server string) (*dns.Msg, error) {
-	proxyDialer, err := c.createDialer()
+// exchangeDoH DoH查询 - 简化版
+func (c *Client) exchangeDoH(ctx context.Context, msg *dns.Msg, info ServerInfo) (*dns.Msg, error) {
+	server := info.Address
+	msgBytes, err := msg.Pack()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create proxy dialer: %v", err)
+		return nil, fmt.Errorf("failed to pack DNS message: %v", err)
 	}
 
-	// 使用 context 控制连接超时
-	conn, err := proxyDialer.Dial("tcp", server)
+	// 构建DoH URL
+	base, ok := c.transports.dohURLs[server]
+	if !ok {
+		base, err = parseDoHURL(server)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if c.transports.proxyErr != nil {
+		return nil, fmt.Errorf("failed to get proxy URL: %v", c.transports.proxyErr)
+	}
+	if c.transports.tlsErr != nil {
+		return nil, c.transports.tlsErr
+	}
+	httpClient := c.transports.httpClient
+	if st, ok := c.transports.serverTLS[server]; ok && st.httpClient != nil {
+		httpClient = st.httpClient
+	}
+	if httpClient == nil {
+		return nil, fmt.Errorf("DoH transport not configured")
+	}
+
+	req, err := newDoHRequest(ctx, c.config.DoHMethod, base, msgBytes)
 	if err != nil {
-		return nil, fmt.Errorf("failed to dial through proxy: %v", err)
+		return nil, err
 	}
-	defer conn.Close()
 
-	// 设置连接的读写超时
-	if deadline, ok := ctx.Deadline(); ok {
-		conn.SetDeadline(deadline)
+	req = req.WithContext(httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			setRespondedBy(ctx, info.Conn.RemoteAddr())
+		},
+	}))
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
 	}
+	defer resp.Body.Close()
 
-	// 使用 goroutine 和 channel 来支持 context 取消
-	type result struct {
-		response *dns.Msg
-		err      error
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP error: %d %s", resp.StatusCode, resp.Status)
 	}
 
-	resultChan := make(chan result, 1)
+	limit := c.config.MaxResponseBytes
+	if resp.ContentLength > int64(limit) {
+		return nil, &ResponseTooLargeError{Size: int(resp.ContentLength), Limit: limit}
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, int64(limit)+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %v", err)
+	}
+	if len(body) > limit {
+		return nil, &ResponseTooLargeError{Size: len(body), Limit: limit}
+	}
 
-	go func() {
-		// 手动发送DNS查询
-		dnsConn := &dns.Conn{Conn: conn}
-		err := dnsConn.WriteMsg(msg)
-		if err != nil {
-			resultChan <- result{nil, fmt.Errorf("failed to write DNS message: %v", err)}
-			return
-		}
+	response := new(dns.Msg)
+	if err := response.Unpack(body); err != nil {
+		return nil, fmt.Errorf("failed to unpack DNS response: %v", err)
+	}
+	if err := checkResponseID(response.Id, msg.Id); err != nil {
+		return nil, err
+	}
 
-		// 读取响应
-		response, err := dnsConn.ReadMsg()
-		if err != nil {
-			resultChan <- result{nil, fmt.Errorf("failed to read DNS response: %v", err)}
-			return
+	return response, nil
+}
+
+// newDoHRequest 构建DoH请求：GET 将消息编码在 dns 查询参数中，POST 将消息作为请求体发送
+func newDoHRequest(ctx context.Context, method string, base *url.URL, msgBytes []byte) (*http.Request, error) {
+	var req *http.Request
+	var err error
+	switch method {
+	case "", http.MethodGet:
+		u := *base
+		param := "dns=" + base64.RawURLEncoding.EncodeToString(msgBytes)
+		if u.RawQuery == "" {
+			u.RawQuery = param
+		} else {
+			u.RawQuery += "&" + param
 		}
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	case http.MethodPost:
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, base.String(), bytes.NewReader(msgBytes))
+	default:
+		return nil, fmt.Errorf("unsupported DoH method %q, use GET or POST", method)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %v", err)
+	}
 
-		resultChan <- result{response, nil}
-	}()
+	req.Header.Set("Accept", "application/dns-message")
+	req.Header.Set("Content-Type", "application/dns-message")
+	return req, nil
+}
+
+// exchangeWithProxy 通过代理进行DNS查询
+func (c *Client) exchangeWithProxy(ctx context.Context, msg *dns.Msg, info ServerInfo) (*dns.Msg, error) {
+	conn, err := c.dialProxy(ctx, info.Address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
 
-	// 等待结果或 context 取消
-	select {
-	case res := <-resultChan:
-		return res.response, res.err
-	case <-ctx.Done():
+	stop := closeOnDone(ctx, conn)
+	defer stop()
+	response, err := exchangeConn(newLimitConn(conn, c.config.MaxResponseBytes), msg)
+	if err != nil && ctx.Err() != nil {
 		return nil, ctx.Err()
 	}
+	return response, err
 }
 
 // exchangeDoTWithProxy 通过代理进行DoT查询
-func (c *Client) exchangeDoTWithProxy(ctx context.Context, msg *dns.Msg, server string, tlsConfig *tls.Config) (*dns.Msg, error) {
-	proxyDialer, err := c.createDialer()
+func (c *Client) exchangeDoTWithProxy(ctx context.Context, msg *dns.Msg, info ServerInfo, tlsConfig *tls.Config) (*dns.Msg, error) {
+	conn, err := c.dialProxy(ctx, info.Address)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create proxy dialer: %v", err)
+		return nil, err
 	}
+	defer conn.Close()
 
-	// 通过代理建立连接
-	conn, err := proxyDialer.Dial("tcp", server)
-	if err != nil {
-		return nil, fmt.Errorf("failed to dial through proxy: %v", err)
+	stop := closeOnDone(ctx, conn)
+	defer stop()
+	// 升级到TLS连接
+	tlsConn := tls.Client(conn, tlsConfig)
+	if err := tlsConn.Handshake(); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("TLS handshake failed: %w", err)
 	}
-	defer conn.Close()
+	response, err := exchangeConn(newLimitConn(tlsConn, c.config.MaxResponseBytes), msg)
+	if err != nil && ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return response, err
+}
 
-	// 设置连接的读写超时
-	if deadline, ok := ctx.Deadline(); ok {
-		conn.SetDeadline(deadline)
+// dialProxy 通过代理连接服务器，并设置本次尝试的读写截止时间
+// 拨号器支持 context 时拨号本身也响应取消
+func (c *Client) dialProxy(ctx context.Context, server string) (net.Conn, error) {
+	proxyDialer, err := c.proxyDialer()
+	if err != nil {
+		return nil, err
 	}
 
-	// 使用 goroutine 和 channel 来支持 context 取消
-	type result struct {
-		response *dns.Msg
-		err      error
+	var conn net.Conn
+	if cd, ok := proxyDialer.(proxy.ContextDialer); ok {
+		conn, err = cd.DialContext(ctx, "tcp", server)
+	} else {
+		conn, err = proxyDialer.Dial("tcp", server)
 	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial through proxy: %v", err)
+	}
+	conn.SetDeadline(c.attemptDeadline(ctx))
+	return conn, nil
+}
 
-	resultChan := make(chan result, 1)
+// closeOnDone context 结束时强制关闭连接，让阻塞在读写上的调用立即返回，返回的函数用于解除关联
+func closeOnDone(ctx context.Context, conn net.Conn) func() bool {
+	return context.AfterFunc(ctx, func() { conn.Close() })
+}
 
-	go func() {
-		// 升级到TLS连接
-		tlsConn := tls.Client(conn, tlsConfig)
-		err := tlsConn.Handshake()
-		if err != nil {
-			resultChan <- result{nil, fmt.Errorf("TLS handshake failed: %v", err)}
-			return
-		}
+// exchangeConn 在已建立的流式连接上发送查询并读取应答
+func exchangeConn(conn net.Conn, msg *dns.Msg) (*dns.Msg, error) {
+	dnsConn := &dns.Conn{Conn: conn}
+	if err := dnsConn.WriteMsg(msg); err != nil {
+		return nil, fmt.Errorf("failed to write DNS message: %v", err)
+	}
+	response, err := dnsConn.ReadMsg()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read DNS response: %w", err)
+	}
+	if err := checkResponseID(response.Id, msg.Id); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
 
-		// 手动发送DNS查询
-		dnsConn := &dns.Conn{Conn: tlsConn}
-		err = dnsConn.WriteMsg(msg)
-		if err != nil {
-			resultChan <- result{nil, fmt.Errorf("failed to write DNS message: %v", err)}
-			return
-		}
+// checkResponseID 校验应答的消息ID与查询一致，不一致的应答可能是伪造或串话的，按失败处理以便重试
+func checkResponseID(got, want uint16) error {
+	if got != want {
+		return fmt.Errorf("DNS response ID mismatch: got %d, want %d", got, want)
+	}
+	return nil
+}
 
-		// 读取响应
-		response, err := dnsConn.ReadMsg()
-		if err != nil {
-			resultChan <- result{nil, fmt.Errorf("failed to read DNS response: %v", err)}
-			return
+// proxyDialer 返回构建时创建的代理拨号器
+func (c *Client) proxyDialer() (proxy.Dialer, error) {
+	if c.transports.proxyDialer == nil {
+		err := c.transports.proxyErr
+		if err == nil {
+			err = fmt.Errorf("unsupported proxy type for dialer: %s", c.config.ProxyType)
 		}
-
-		resultChan <- result{response, nil}
-	}()
-
-	// 等待结果或 context 取消
-	select {
-	case res := <-resultChan:
-		return res.response, res.err
-	case <-ctx.Done():
-		return nil, ctx.Err()
+		return nil, fmt.Errorf("failed to create proxy dialer: %v", err)
 	}
+	return c.transports.proxyDialer, nil
 }
 
 // createDialer 创建代理拨号器
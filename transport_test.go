@@ -0,0 +1,389 @@
+package godns_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+	"github.com/zan8in/godns/internal/testserver"
+)
+
+// sourcePorts 返回服务器收到的各UDP查询的源端口
+func sourcePorts(t *testing.T, queries []testserver.Query) []string {
+	t.Helper()
+	var ports []string
+	for _, q := range queries {
+		if q.Protocol != testserver.UDP {
+			continue
+		}
+		_, port, err := net.SplitHostPort(q.Remote)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ports = append(ports, port)
+	}
+	return ports
+}
+
+func TestSourcePortRandomization(t *testing.T) {
+	s := startServer(t)
+	s.Answer("www.example.com", dns.TypeA, "www.example.com. 60 IN A 192.0.2.1")
+	c := godns.New(godns.WithServers(s.UDPAddr), godns.WithSourcePortRandomization(), godns.WithRetries(0))
+	defer c.Close()
+
+	const queries = 16
+	for range queries {
+		if _, err := c.QueryA(context.Background(), "www.example.com"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ports := sourcePorts(t, s.Queries())
+	if len(ports) != queries {
+		t.Fatalf("server saw %d UDP queries, want %d", len(ports), queries)
+	}
+	distinct := map[string]bool{}
+	for _, port := range ports {
+		distinct[port] = true
+	}
+	// 16 个端口取自 64512 个端口，允许一次碰撞
+	if len(distinct) < queries-1 {
+		t.Errorf("source ports %v repeat", ports)
+	}
+}
+
+// TestSourcePortFromRandSource 源端口取自客户端的随机数来源，相同种子得到相同的端口序列
+func TestSourcePortFromRandSource(t *testing.T) {
+	s := startServer(t)
+	run := func() []string {
+		s.Reset()
+		s.Answer("www.example.com", dns.TypeA, "www.example.com. 60 IN A 192.0.2.1")
+		c := godns.New(godns.WithServers(s.UDPAddr), godns.WithSourcePortRandomization(), godns.WithDeterministic(42), godns.WithRetries(0))
+		defer c.Close()
+		for range 8 {
+			if _, err := c.QueryA(context.Background(), "www.example.com"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return sourcePorts(t, s.Queries())
+	}
+	if a, b := run(), run(); !slices.Equal(a, b) {
+		t.Errorf("same seed produced source ports %v and %v", a, b)
+	}
+}
+
+// TestSourcePortRandomizationConcurrent 并发查询各自绑定随机端口，在 -race 下运行
+func TestSourcePortRandomizationConcurrent(t *testing.T) {
+	s := startServer(t)
+	s.Answer("www.example.com", dns.TypeA, "www.example.com. 60 IN A 192.0.2.1")
+	c := godns.New(godns.WithServers(s.UDPAddr), godns.WithSourcePortRandomization(), godns.WithRetries(1))
+	defer c.Close()
+
+	var wg sync.WaitGroup
+	for range 32 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 8 {
+				res, err := c.QueryA(context.Background(), "www.example.com")
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if len(res.Records) != 1 {
+					t.Errorf("records = %v", res.Records)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func BenchmarkUDPQuery(b *testing.B) {
+	s, err := testserver.Start()
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer s.Close()
+	s.Answer("www.example.com", dns.TypeA, "www.example.com. 60 IN A 192.0.2.1")
+
+	for _, bm := range []struct {
+		name string
+		opts []godns.Option
+	}{
+		{"default", nil},
+		{"random-source-port", []godns.Option{godns.WithSourcePortRandomization()}},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			c := godns.New(append([]godns.Option{godns.WithServers(s.UDPAddr)}, bm.opts...)...)
+			defer c.Close()
+			b.ReportAllocs()
+			for b.Loop() {
+				if _, err := c.QueryA(context.Background(), "www.example.com"); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkProtocolQuery 各协议单次查询的耗时和分配，传输层对象在构建客户端时已创建
+func BenchmarkProtocolQuery(b *testing.B) {
+	s, err := testserver.Start()
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer s.Close()
+	s.Answer("www.example.com", dns.TypeA, "www.example.com. 60 IN A 192.0.2.1")
+	proxy, err := testserver.StartSOCKS5("", "")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer proxy.Close()
+
+	for _, bm := range []struct {
+		name string
+		opts []godns.Option
+	}{
+		{"udp", []godns.Option{godns.WithServers(s.UDPAddr)}},
+		{"tcp", []godns.Option{godns.WithServers("tcp://" + s.TCPAddr)}},
+		{"tcp-socks5", []godns.Option{godns.WithServers("tcp://" + s.TCPAddr), godns.WithSOCKS5Proxy(proxy.Addr, nil)}},
+		{"dot", []godns.Option{godns.WithServers("tls://" + s.DoTAddr)}},
+		{"doh", []godns.Option{godns.WithServers(s.DoHURL)}},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			c := godns.New(append(bm.opts, godns.WithTLSConfig(s.ClientTLSConfig()))...)
+			defer c.Close()
+			b.ReportAllocs()
+			for b.Loop() {
+				if _, err := c.QueryA(context.Background(), "www.example.com"); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// TestPerAttemptDeadline 每次尝试的截止时间为 min(now+Timeout, ctx.Deadline())：
+// context 剩余时间远多于单次超时时，慢服务器只耗尽第一次尝试的时间，不占用重试的时间
+func TestPerAttemptDeadline(t *testing.T) {
+	s := startServer(t)
+	proxy, err := testserver.StartSOCKS5("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer proxy.Close()
+
+	tests := []struct {
+		name string
+		opts []godns.Option
+	}{
+		{"udp", []godns.Option{godns.WithServers(s.UDPAddr)}},
+		{"tcp", []godns.Option{godns.WithServers(s.TCPAddr), godns.WithProtocol(godns.TCP)}},
+		{"tcp-socks5", []godns.Option{godns.WithServers(s.TCPAddr), godns.WithProtocol(godns.TCP), godns.WithSOCKS5Proxy(proxy.Addr, nil)}},
+		{"dot", []godns.Option{godns.WithServers(s.DoTAddr), godns.WithProtocol(godns.DoT), godns.WithTLSConfig(s.ClientTLSConfig())}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s.Handle("slow.test", dns.TypeA,
+				testserver.Reply{Delay: 5 * time.Second},
+				testserver.Reply{Answer: []dns.RR{testserver.RR("slow.test. 60 IN A 192.0.2.1")}},
+			)
+			c := godns.New(append(tt.opts, godns.WithTimeout(time.Second), godns.WithRetries(1))...)
+			defer c.Close()
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			start := time.Now()
+			res, err := c.QueryA(ctx, "slow.test")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if elapsed := time.Since(start); elapsed > 2500*time.Millisecond {
+				t.Errorf("query took %v, want about one attempt timeout plus a fast retry", elapsed)
+			}
+			attempts := res.Path.Attempts
+			if len(attempts) != 2 {
+				t.Fatalf("attempts = %+v, want 2", attempts)
+			}
+			if d := attempts[0].Duration; d < 900*time.Millisecond || d > 1500*time.Millisecond {
+				t.Errorf("first attempt took %v, want it cut at the 1s attempt timeout", d)
+			}
+			if attempts[0].Error == "" || attempts[1].Error != "" {
+				t.Errorf("attempt errors = %q, %q; want the first to time out and the second to succeed", attempts[0].Error, attempts[1].Error)
+			}
+			if d := attempts[1].Duration; d > 500*time.Millisecond {
+				t.Errorf("second attempt took %v, want a fresh deadline unaffected by the first", d)
+			}
+		})
+	}
+}
+
+// TestDoHMethod GET 将消息编码在 dns 查询参数中；POST 以原始报文作为请求体，不带 dns 参数
+func TestDoHMethod(t *testing.T) {
+	type request struct {
+		method, contentType, accept, query string
+		body                               []byte
+	}
+	requests := make(chan request, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- request{r.Method, r.Header.Get("Content-Type"), r.Header.Get("Accept"), r.URL.RawQuery, body}
+
+		wire := body
+		if r.Method == http.MethodGet {
+			wire, _ = base64.RawURLEncoding.DecodeString(r.URL.Query().Get("dns"))
+		}
+		query := new(dns.Msg)
+		if err := query.Unpack(wire); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		reply := new(dns.Msg)
+		reply.SetReply(query)
+		reply.Answer = append(reply.Answer, testserver.RR(query.Question[0].Name+" 60 IN A 192.0.2.1"))
+		out, _ := reply.Pack()
+		w.Header().Set("Content-Type", "application/dns-message")
+		w.Write(out)
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		method     string
+		wantMethod string
+	}{
+		{"", http.MethodGet},
+		{"get", http.MethodGet},
+		{"POST", http.MethodPost},
+		{"post", http.MethodPost},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			var sent []byte
+			c := godns.New(
+				godns.WithServers(srv.URL+"/dns-query"),
+				godns.WithDoHMethod(tt.method),
+				godns.WithRetries(0),
+				godns.WithOnRequest(func(_ context.Context, _ string, msg *dns.Msg) {
+					sent, _ = msg.Pack()
+				}),
+			)
+			defer c.Close()
+
+			res, err := c.QueryA(context.Background(), "example.com")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(res.Records) != 1 || res.Records[0].Value() != "192.0.2.1" {
+				t.Errorf("Records = %+v, want one A record", res.Records)
+			}
+
+			req := <-requests
+			if req.method != tt.wantMethod {
+				t.Fatalf("method = %s, want %s", req.method, tt.wantMethod)
+			}
+			if req.accept != "application/dns-message" {
+				t.Errorf("Accept = %q", req.accept)
+			}
+			if tt.wantMethod == http.MethodGet {
+				if want := "dns=" + base64.RawURLEncoding.EncodeToString(sent); req.query != want {
+					t.Errorf("query string = %q, want %q", req.query, want)
+				}
+				if len(req.body) != 0 {
+					t.Errorf("GET request carried a %d byte body", len(req.body))
+				}
+				return
+			}
+			if req.contentType != "application/dns-message" {
+				t.Errorf("Content-Type = %q", req.contentType)
+			}
+			if req.query != "" {
+				t.Errorf("POST query string = %q, want none", req.query)
+			}
+			if !bytes.Equal(req.body, sent) {
+				t.Errorf("POST body is not the wire-format query:\n got %x\nwant %x", req.body, sent)
+			}
+		})
+	}
+
+	c := godns.New(godns.WithServers(srv.URL+"/dns-query"), godns.WithDoHMethod("PUT"), godns.WithRetries(0))
+	defer c.Close()
+	if _, err := c.QueryA(context.Background(), "example.com"); err == nil || !strings.Contains(err.Error(), `unsupported DoH method "PUT"`) {
+		t.Errorf("PUT err = %v, want unsupported DoH method", err)
+	}
+}
+
+// blackholeProxy 接受连接但从不应答，模拟卡住的代理
+func blackholeProxy(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { conn.Close() })
+		}
+	}()
+	return l.Addr().String()
+}
+
+// TestProxyExchangeCancel 经代理的查询在 context 取消时立即返回，不遗留 goroutine：
+// 代理卡在SOCKS5握手，或代理已连通但服务器不应答
+func TestProxyExchangeCancel(t *testing.T) {
+	s := startServer(t)
+	s.Handle("stall.test", dns.TypeA, testserver.Reply{Drop: true})
+	socks, err := testserver.StartSOCKS5("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer socks.Close()
+	blackhole := blackholeProxy(t)
+
+	tests := []struct {
+		name  string
+		proxy string
+		opts  []godns.Option
+	}{
+		{"tcp-handshake", blackhole, []godns.Option{godns.WithServers(s.TCPAddr), godns.WithProtocol(godns.TCP)}},
+		{"dot-handshake", blackhole, []godns.Option{godns.WithServers(s.DoTAddr), godns.WithProtocol(godns.DoT), godns.WithTLSConfig(s.ClientTLSConfig())}},
+		{"tcp-read", socks.Addr, []godns.Option{godns.WithServers(s.TCPAddr), godns.WithProtocol(godns.TCP)}},
+		{"dot-read", socks.Addr, []godns.Option{godns.WithServers(s.DoTAddr), godns.WithProtocol(godns.DoT), godns.WithTLSConfig(s.ClientTLSConfig())}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := godns.New(append(tt.opts, godns.WithSOCKS5Proxy(tt.proxy, nil), godns.WithTimeout(30*time.Second), godns.WithRetries(0))...)
+			defer c.Close()
+			before := runtime.NumGoroutine()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(100*time.Millisecond, cancel)
+			start := time.Now()
+			_, err := c.QueryA(ctx, "stall.test")
+			if err == nil {
+				t.Fatal("query through a stalled proxy succeeded")
+			}
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("query returned %v after start, want shortly after the 100ms cancellation", elapsed)
+			}
+			waitFor(t, "proxy exchange goroutines to exit", func() bool { return runtime.NumGoroutine() <= before })
+		})
+	}
+}
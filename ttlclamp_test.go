@@ -0,0 +1,78 @@
+package godns_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+)
+
+// TestTTLClamp 记录的TTL被限制在 [min, max] 范围内，max 为0时不设上限
+func TestTTLClamp(t *testing.T) {
+	s := startServer(t)
+	s.Answer("example.test", dns.TypeA,
+		"example.test. 0 IN A 192.0.2.1",
+		"example.test. 100 IN A 192.0.2.2",
+		"example.test. 100000 IN A 192.0.2.3",
+	)
+	tests := []struct {
+		name     string
+		min, max uint32
+		want     []uint32
+	}{
+		{"range", 30, 3600, []uint32{30, 100, 3600}},
+		{"min only", 30, 0, []uint32{30, 100, 100000}},
+		{"off", 0, 0, []uint32{0, 100, 100000}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := godns.New(godns.WithServers(s.UDPAddr), godns.WithTTLClamp(tt.min, tt.max), godns.WithRetries(0))
+			defer c.Close()
+			res, err := c.QueryA(context.Background(), "example.test")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(res.Records) != len(tt.want) {
+				t.Fatalf("records = %v", res.Records)
+			}
+			for i, record := range res.Records {
+				if record.TTL != tt.want[i] || record.RR().Header().Ttl != tt.want[i] {
+					t.Errorf("record %d TTL = %d (RR %d), want %d", i, record.TTL, record.RR().Header().Ttl, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// TestTTLClampCache 缓存按限制后的TTL过期：TTL=0 的应答也被缓存至下限，不再每次都查询网络
+func TestTTLClampCache(t *testing.T) {
+	s := startServer(t)
+	s.Answer("example.test", dns.TypeA, "example.test. 0 IN A 192.0.2.1")
+	clock := newFakeClock()
+	t0 := clock.Now()
+	c := godns.New(godns.WithServers(s.UDPAddr), godns.WithClock(clock), godns.WithCache(time.Hour), godns.WithTTLClamp(30, 0), godns.WithRetries(0))
+	defer c.Close()
+	ctx := context.Background()
+
+	res, err := c.QueryA(ctx, "example.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.ValidUntil.Equal(t0.Add(30 * time.Second)) {
+		t.Errorf("ValidUntil = %v, want %v", res.ValidUntil, t0.Add(30*time.Second))
+	}
+
+	clock.Advance(29 * time.Second)
+	if res, err = c.QueryA(ctx, "example.test"); err != nil || res.Path.Source != godns.SourceCache {
+		t.Fatalf("before the clamped TTL: source %v, err %v; want the cache", res.Path.Source, err)
+	}
+	clock.Advance(2 * time.Second)
+	if res, err = c.QueryA(ctx, "example.test"); err != nil || res.Path.Source != godns.SourceNetwork {
+		t.Fatalf("after the clamped TTL: source %v, err %v; want the network", res.Path.Source, err)
+	}
+	if n := len(s.Queries()); n != 2 {
+		t.Errorf("server saw %d queries, want 2", n)
+	}
+}
@@ -0,0 +1,104 @@
+package godns_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/gob"
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+	"github.com/zan8in/godns/internal/testserver"
+)
+
+// dkimKey 返回确定性的DKIM记录文本，公钥部分的长度接近 2048 位RSA公钥
+func dkimKey() string {
+	key := make([]byte, 294)
+	for i := range key {
+		key[i] = byte(i*31 + 7)
+	}
+	return "v=DKIM1; k=rsa; p=" + base64.StdEncoding.EncodeToString(key)
+}
+
+// chunks 按 size 字节拆分字符串
+func chunks(s string, size int) []string {
+	var parts []string
+	for len(s) > size {
+		parts = append(parts, s[:size])
+		s = s[size:]
+	}
+	return append(parts, s)
+}
+
+// TestTXTChunksRoundTrip 超过255字节被拆分存放的DKIM记录在各传输协议上逐字节还原：
+// Value 为各段直接拼接的结果，Values 保留原始分段，JSON和gob往返后保持不变
+func TestTXTChunksRoundTrip(t *testing.T) {
+	key := dkimKey()
+	if len(key) <= 255 {
+		t.Fatalf("test key is only %d bytes", len(key))
+	}
+	const name = "selector._domainkey.example.test"
+	split := [][]string{
+		chunks(key, 255),
+		// 拆分位置落在空格前后，拼接时不能插入或丢弃空格
+		append([]string{"v=DKIM1;", " k=rsa; p="}, chunks(key[len("v=DKIM1; k=rsa; p="):], 255)...),
+		chunks(key, 100),
+	}
+
+	s := startServer(t)
+	servers := map[string]string{
+		"udp": s.UDPAddr,
+		"tcp": "tcp://" + s.TCPAddr,
+		"doh": s.DoHURL,
+	}
+	for _, parts := range split {
+		rr := &dns.TXT{Hdr: dns.RR_Header{Name: name + ".", Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: 300}, Txt: parts}
+		s.Handle(name, dns.TypeTXT, testserver.Reply{Answer: []dns.RR{rr}})
+
+		for transport, server := range servers {
+			c := godns.New(godns.WithServers(server), godns.WithTLSConfig(s.ClientTLSConfig()), godns.WithRetries(0))
+			res, err := c.Query(context.Background(), name, dns.TypeTXT)
+			c.Close()
+			if err != nil {
+				t.Fatalf("%s, %d chunks: %v", transport, len(parts), err)
+			}
+			if len(res.Records) != 1 {
+				t.Fatalf("%s: records = %v", transport, res.Records)
+			}
+			record := res.Records[0]
+			if record.Value() != key {
+				t.Errorf("%s, %d chunks: Value = %q, want %q", transport, len(parts), record.Value(), key)
+			}
+			if !slices.Equal(record.Values, parts) {
+				t.Errorf("%s: Values = %q, want %q", transport, record.Values, parts)
+			}
+
+			data, err := json.Marshal(record)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var decoded godns.Record
+			if err := json.Unmarshal(data, &decoded); err != nil {
+				t.Fatal(err)
+			}
+			if decoded.Value() != key || !slices.Equal(decoded.Values, parts) {
+				t.Errorf("%s: after JSON round trip Value = %q, Values = %q", transport, decoded.Value(), decoded.Values)
+			}
+
+			var buf bytes.Buffer
+			if err := gob.NewEncoder(&buf).Encode(record); err != nil {
+				t.Fatal(err)
+			}
+			decoded = godns.Record{}
+			if err := gob.NewDecoder(&buf).Decode(&decoded); err != nil {
+				t.Fatal(err)
+			}
+			if decoded.Value() != key || !slices.Equal(decoded.Values, parts) {
+				t.Errorf("%s: after gob round trip Value = %q, Values = %q", transport, decoded.Value(), decoded.Values)
+			}
+		}
+	}
+}
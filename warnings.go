@@ -0,0 +1,52 @@
+package godns
+
+import (
+	"fmt"
+
+	"github.com/miekg/dns"
+)
+
+// WarningCode 警告类别，可用于按类别筛选
+type WarningCode string
+
+const (
+	WarnTTLClamped        WarningCode = "ttl-clamped"         // 记录TTL被 WithTTLClamp 调整
+	WarnTruncatedByClient WarningCode = "truncated-by-client" // 记录数超过 WithMaxAnswers 上限被截断
+	WarnEDNSDowngraded    WarningCode = "edns-downgraded"     // 服务器不支持EDNS，查询已去掉EDNS重发
+	WarnTXTSplit          WarningCode = "txt-split"           // TXT记录由多个字符串组成（内容超过255字节被拆分）
+	WarnMXTargetCNAME     WarningCode = "mx-target-cname"     // MX目标是CNAME（违反 RFC 2181 §10.3）
+)
+
+// Warning 附加在结果上的非致命问题
+type Warning struct {
+	Code    WarningCode
+	Message string
+	Record  int // 相关记录在 Records 中的下标，-1 表示与具体记录无关
+}
+
+// String 返回警告的可读描述
+func (w Warning) String() string {
+	return fmt.Sprintf("%s: %s", w.Code, w.Message)
+}
+
+// HasWarning 结果中是否包含指定类别的警告
+func (r *QueryResult) HasWarning(code WarningCode) bool {
+	for _, w := range r.Warnings {
+		if w.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// recordWarnings 检查单条记录并返回相关警告
+func recordWarnings(index int, rr dns.RR) []Warning {
+	if txt, ok := rr.(*dns.TXT); ok && len(txt.Txt) > 1 {
+		return []Warning{{
+			Code:    WarnTXTSplit,
+			Message: fmt.Sprintf("TXT record for %s consists of %d strings", txt.Hdr.Name, len(txt.Txt)),
+			Record:  index,
+		}}
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package godns_test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+	"github.com/zan8in/godns/internal/testserver"
+)
+
+// TestWarningProducers 每个警告来源都把警告附加在对应的结果上，Record 指向相关记录，
+// 并随结果一起序列化为JSON；MX目标为CNAME的警告见 TestResolveMXOrdering
+func TestWarningProducers(t *testing.T) {
+	longValue := strings.Repeat("k", 300)
+	tests := []struct {
+		name  string
+		setup func(s *testserver.Server)
+		opts  []godns.Option
+		ctx   func(context.Context) context.Context
+		qtype uint16
+		code  godns.WarningCode
+		// 相关记录的下标，-1 表示与具体记录无关
+		record int
+	}{
+		{
+			name: "ttl-clamped",
+			setup: func(s *testserver.Server) {
+				s.Answer("example.test", dns.TypeA,
+					"example.test. 300 IN A 192.0.2.1",
+					"example.test. 5 IN A 192.0.2.2",
+				)
+			},
+			opts:   []godns.Option{godns.WithTTLClamp(60, 3600)},
+			qtype:  dns.TypeA,
+			code:   godns.WarnTTLClamped,
+			record: 1,
+		},
+		{
+			name: "truncated-by-client",
+			setup: func(s *testserver.Server) {
+				s.Answer("example.test", dns.TypeA,
+					"example.test. 300 IN A 192.0.2.1",
+					"example.test. 300 IN A 192.0.2.2",
+					"example.test. 300 IN A 192.0.2.3",
+				)
+			},
+			opts:   []godns.Option{godns.WithMaxAnswers(2)},
+			qtype:  dns.TypeA,
+			code:   godns.WarnTruncatedByClient,
+			record: -1,
+		},
+		{
+			name: "edns-downgraded",
+			setup: func(s *testserver.Server) {
+				s.Handle("example.test", dns.TypeA,
+					testserver.Reply{Rcode: dns.RcodeFormatError},
+					testserver.Reply{Answer: []dns.RR{testserver.RR("example.test. 300 IN A 192.0.2.1")}},
+				)
+			},
+			ctx:    func(ctx context.Context) context.Context { return godns.WithDO(ctx, true) },
+			qtype:  dns.TypeA,
+			code:   godns.WarnEDNSDowngraded,
+			record: -1,
+		},
+		{
+			name: "txt-split",
+			setup: func(s *testserver.Server) {
+				s.Answer("example.test", dns.TypeTXT,
+					`example.test. 300 IN TXT "v=spf1 -all"`,
+					`example.test. 300 IN TXT "`+longValue[:255]+`" "`+longValue[255:]+`"`,
+				)
+			},
+			qtype:  dns.TypeTXT,
+			code:   godns.WarnTXTSplit,
+			record: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := startServer(t)
+			tt.setup(s)
+			c := godns.New(append([]godns.Option{godns.WithServers(s.UDPAddr), godns.WithRetries(0)}, tt.opts...)...)
+			defer c.Close()
+			ctx := context.Background()
+			if tt.ctx != nil {
+				ctx = tt.ctx(ctx)
+			}
+
+			res, err := c.Query(ctx, "example.test", tt.qtype)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(res.Warnings) != 1 || res.Warnings[0].Code != tt.code || res.Warnings[0].Record != tt.record {
+				t.Fatalf("warnings = %+v, want one %s for record %d", res.Warnings, tt.code, tt.record)
+			}
+			if !res.HasWarning(tt.code) || res.Warnings[0].Message == "" {
+				t.Errorf("HasWarning(%s) = %v, message %q", tt.code, res.HasWarning(tt.code), res.Warnings[0].Message)
+			}
+
+			data, err := json.Marshal(res)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var decoded godns.QueryResult
+			if err := json.Unmarshal(data, &decoded); err != nil {
+				t.Fatal(err)
+			}
+			if len(decoded.Warnings) != 1 || decoded.Warnings[0] != res.Warnings[0] {
+				t.Errorf("warnings after JSON round trip = %+v, want %+v", decoded.Warnings, res.Warnings)
+			}
+		})
+	}
+}
+
+// TestWarningsDroppedWithTruncatedRecords 客户端截断记录时，被丢弃记录上的警告一并丢弃
+func TestWarningsDroppedWithTruncatedRecords(t *testing.T) {
+	s := startServer(t)
+	s.Answer("example.test", dns.TypeA,
+		"example.test. 5 IN A 192.0.2.1",
+		"example.test. 300 IN A 192.0.2.2",
+		"example.test. 5 IN A 192.0.2.3",
+	)
+	c := godns.New(godns.WithServers(s.UDPAddr), godns.WithTTLClamp(60, 0), godns.WithMaxAnswers(2), godns.WithRetries(0))
+	defer c.Close()
+
+	res, err := c.QueryA(context.Background(), "example.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var codes []godns.WarningCode
+	for _, w := range res.Warnings {
+		codes = append(codes, w.Code)
+		if w.Record >= len(res.Records) {
+			t.Errorf("warning %+v refers to a dropped record", w)
+		}
+	}
+	if len(codes) != 2 || codes[0] != godns.WarnTTLClamped || codes[1] != godns.WarnTruncatedByClient {
+		t.Errorf("warnings = %+v, want the clamp of record 0 and the truncation", res.Warnings)
+	}
+}
+
+// TestMultiQueryWarningCounts 多服务器查询按类别汇总各结果中的警告数
+func TestMultiQueryWarningCounts(t *testing.T) {
+	split, plain := startServer(t), startServer(t)
+	split.Answer("example.test", dns.TypeTXT, `example.test. 300 IN TXT "a" "b"`, `example.test. 300 IN TXT "c" "d" "e"`)
+	plain.Answer("example.test", dns.TypeTXT, `example.test. 300 IN TXT "short"`)
+	c := godns.New(godns.WithServers(split.UDPAddr, plain.UDPAddr), godns.WithRetries(0))
+	defer c.Close()
+
+	res, err := c.MultiQuery(context.Background(), "example.test", dns.TypeTXT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.WarningCounts) != 1 || res.WarningCounts[godns.WarnTXTSplit] != 2 {
+		t.Errorf("WarningCounts = %v, want 2 %s", res.WarningCounts, godns.WarnTXTSplit)
+	}
+	for _, r := range res.Results {
+		if want := r.Server == split.UDPAddr; r.HasWarning(godns.WarnTXTSplit) != want {
+			t.Errorf("%s: HasWarning = %v, want %v", r.Server, !want, want)
+		}
+	}
+}
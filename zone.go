@@ -0,0 +1,272 @@
+package godns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// authServer 权威服务器
+type authServer struct {
+	Name    string // NS记录中的主机名
+	Address string // IP:53
+}
+
+// Zone 名称所在的区域
+type Zone struct {
+	Apex        string   // 区域顶点（不带末尾的点）
+	SOA         *dns.SOA // 区域顶点的SOA记录
+	Nameservers []string // 区域顶点的NS主机名（不带末尾的点）
+}
+
+// FindZone 查找 name 所在的区域，返回区域顶点、SOA记录和NS主机名
+// 区域顶点取自应答部分或授权部分中SOA记录的所有者：name 自身是区域顶点时SOA位于应答部分，
+// 名称不存在（NXDOMAIN）或没有SOA记录时递归服务器在授权部分返回所在区域的SOA，
+// 因此委派到深层子区域的名称也能一次确定；未取得SOA时逐级向上查询
+// 区域切分按SOA/NS记录的TTL缓存，同一区域内名称的重复查找无需再次查询；ContextWithNoCache 跳过该缓存
+func (c *Client) FindZone(ctx context.Context, name string) (*Zone, error) {
+	useCache := !isAdHoc(ctx) && !noCacheFromContext(ctx)
+	var walked []string
+	q := dns.Fqdn(name)
+	for {
+		if useCache {
+			if entry, ok := c.zones.get(q); ok {
+				c.zones.set(walked, entry.zone, entry.expires)
+				return entry.zone, nil
+			}
+		}
+		walked = append(walked, q)
+
+		// NXDOMAIN 应答的授权部分同样带有所在区域的SOA
+		res, err := c.Query(ctx, q, dns.TypeSOA)
+		if err = ignoreNXDomain(err); err == nil {
+			if soa := enclosingSOA(q, res); soa != nil {
+				// 区域已知时无需再次查询NS
+				if entry, ok := c.zones.get(soa.Hdr.Name); useCache && ok {
+					c.zones.set(walked, entry.zone, entry.expires)
+					return entry.zone, nil
+				}
+				zone, ttl, err := c.newZone(ctx, soa)
+				if err != nil {
+					return nil, err
+				}
+				if useCache && ttl > 0 {
+					c.zones.set(append(walked, soa.Hdr.Name), zone, c.config.Clock.Now().Add(ttl))
+				}
+				return zone, nil
+			}
+		} else if ctx.Err() != nil {
+			return nil, err
+		}
+
+		labels := dns.SplitDomainName(q)
+		if len(labels) <= 1 {
+			return nil, fmt.Errorf("no SOA found for %s", name)
+		}
+		q = dns.Fqdn(strings.Join(labels[1:], "."))
+	}
+}
+
+// enclosingSOA 返回应答部分或授权部分中所有者为 name 自身或其上级的SOA记录
+// name 是CNAME时应答中的SOA属于目标所在的区域，不予采用
+func enclosingSOA(name string, res *QueryResult) *dns.SOA {
+	for _, record := range res.Records {
+		if soa, ok := record.RR().(*dns.SOA); ok && isSubdomain(name, soa.Hdr.Name) {
+			return soa
+		}
+	}
+	for _, record := range res.Authority {
+		if soa, ok := record.RR().(*dns.SOA); ok && isSubdomain(name, soa.Hdr.Name) {
+			return soa
+		}
+	}
+	return nil
+}
+
+// newZone 查询区域顶点的NS记录，返回区域及其可缓存的时间（SOA与NS记录的最小TTL）
+func (c *Client) newZone(ctx context.Context, soa *dns.SOA) (*Zone, time.Duration, error) {
+	apex := soa.Hdr.Name
+	res, err := c.Query(ctx, apex, dns.TypeNS)
+	if err != nil {
+		return nil, 0, err
+	}
+	zone := &Zone{Apex: strings.TrimSuffix(apex, "."), SOA: soa}
+	ttl := soa.Hdr.Ttl
+	for _, record := range ownedBy(res, apex, dns.TypeNS) {
+		if ns, ok := record.RR().(*dns.NS); ok {
+			zone.Nameservers = append(zone.Nameservers, strings.TrimSuffix(ns.Ns, "."))
+			ttl = min(ttl, record.TTL)
+		}
+	}
+	if len(zone.Nameservers) == 0 {
+		return nil, 0, fmt.Errorf("no NS records found for %s", zone.Apex)
+	}
+	return zone, time.Duration(ttl) * time.Second, nil
+}
+
+// zoneEntry 缓存的区域切分
+type zoneEntry struct {
+	zone    *Zone
+	expires time.Time
+}
+
+// zoneCache 名称 -> 所在区域的缓存
+type zoneCache struct {
+	mu      sync.Mutex
+	entries map[string]zoneEntry
+	clock   Clock
+}
+
+func newZoneCache(clock Clock) *zoneCache {
+	return &zoneCache{entries: make(map[string]zoneEntry), clock: clock}
+}
+
+// get 返回名称所在的未过期区域
+func (zc *zoneCache) get(name string) (zoneEntry, bool) {
+	key := CanonicalName(name)
+	zc.mu.Lock()
+	defer zc.mu.Unlock()
+	entry, ok := zc.entries[key]
+	if ok && !zc.clock.Now().Before(entry.expires) {
+		delete(zc.entries, key)
+		ok = false
+	}
+	return entry, ok
+}
+
+// set 记录各名称所在的区域，超出容量时先清理过期条目，仍不足则不再记录
+func (zc *zoneCache) set(names []string, zone *Zone, expires time.Time) {
+	zc.mu.Lock()
+	defer zc.mu.Unlock()
+	if len(zc.entries)+len(names) > defaultCacheEntries {
+		now := zc.clock.Now()
+		for k, e := range zc.entries {
+			if !now.Before(e.expires) {
+				delete(zc.entries, k)
+			}
+		}
+		if len(zc.entries)+len(names) > defaultCacheEntries {
+			return
+		}
+	}
+	for _, name := range names {
+		zc.entries[CanonicalName(name)] = zoneEntry{zone: zone, expires: expires}
+	}
+}
+
+// authoritativeServers 解析区域各权威服务器的地址，跳过无法解析的NS主机名
+func (c *Client) authoritativeServers(ctx context.Context, zone *Zone) ([]authServer, error) {
+	var servers []authServer
+	for _, server := range c.nameservers(ctx, zone) {
+		if server.Address != "" {
+			servers = append(servers, server)
+		}
+	}
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("no authoritative servers resolved for %s", zone.Apex)
+	}
+	return servers, nil
+}
+
+// nameservers 通过配置的解析器解析区域的NS主机名（不依赖胶水记录），
+// 无法解析的主机名以 Address 为空的条目返回
+func (c *Client) nameservers(ctx context.Context, zone *Zone) []authServer {
+	var servers []authServer
+	for _, ns := range zone.Nameservers {
+		resolved := false
+		for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+			addrs, err := c.Query(ctx, ns, qtype)
+			if err != nil {
+				continue
+			}
+			for _, addr := range addrs.IPAddrs() {
+				resolved = true
+				servers = append(servers, authServer{
+					Name:    ns,
+					Address: net.JoinHostPort(addr.String(), "53"),
+				})
+			}
+		}
+		if !resolved {
+			servers = append(servers, authServer{Name: ns})
+		}
+	}
+	return servers
+}
+
+// noRecursionKey 标记查询不设置RD位
+type noRecursionKey struct{}
+
+// queryAuthoritative 直接向权威服务器发送UDP查询（RD=0），不读写缓存
+func (c *Client) queryAuthoritative(ctx context.Context, domain string, qtype uint16, server authServer) (*QueryResult, error) {
+	ctx = context.WithValue(c.adHocContext(ctx), noRecursionKey{}, true)
+	res, err := c.queryServer(ctx, domain, qtype, ServerInfo{Address: server.Address, Protocol: UDP})
+	if res != nil {
+		res.Nameserver = server.Name
+	}
+	return res, err
+}
+
+// QueryAuthoritative 绕过递归解析器，直接询问 domain 所在区域的权威服务器
+// 通过 FindZone 确定区域顶点和NS，解析各NS主机名，然后以 RD=0 向每个权威地址发送查询；
+// 每个地址的结果位于 Results 中，Nameserver 为对应的NS主机名，Authoritative 为应答的AA位
+// 无法解析的NS主机名和不可达的权威服务器以带错误的结果返回；所有权威服务器都失败时返回错误
+func (c *Client) QueryAuthoritative(ctx context.Context, domain string, qtype uint16) (*MultiQueryResult, error) {
+	zone, err := c.FindZone(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+	nameservers := c.nameservers(ctx, zone)
+
+	rc := newPooledCollector()
+	defer rc.release()
+	result := rc.begin(domain, qtype, len(nameservers), c.config.Clock.Now())
+	start := time.Now()
+
+	results := make([]QueryResult, len(nameservers))
+	var wg sync.WaitGroup
+	for i, server := range nameservers {
+		if server.Address == "" {
+			results[i] = QueryResult{
+				Domain:     domain,
+				Type:       qtype,
+				Nameserver: server.Name,
+				Error:      c.newErrorInfo(fmt.Errorf("no address found for nameserver %s", server.Name), server.Name, 0),
+			}
+			continue
+		}
+		wg.Add(1)
+		go func(i int, server authServer) {
+			defer wg.Done()
+			res, err := c.queryAuthoritative(ctx, domain, qtype, server)
+			if res == nil {
+				res = &QueryResult{
+					Domain:     domain,
+					Type:       qtype,
+					Server:     server.Address,
+					Nameserver: server.Name,
+					Error:      c.newErrorInfo(err, server.Address, 0),
+				}
+			}
+			results[i] = *res
+		}(i, server)
+	}
+	wg.Wait()
+
+	answered := false
+	for _, res := range results {
+		rc.add(res)
+		answered = answered || res.answered()
+	}
+	result.FinishedAt = c.config.Clock.Now()
+	result.Elapsed = time.Since(start)
+	if !answered {
+		return result, fmt.Errorf("no authoritative server for %s answered", zone.Apex)
+	}
+	return result, nil
+}
@@ -0,0 +1,156 @@
+package godns_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/zan8in/godns"
+	"github.com/zan8in/godns/internal/testserver"
+)
+
+// authFake 按地址把查询转发到脚本化的测试服务器，用于模拟递归服务器和监听在 53 端口的各权威服务器
+// 未登记的地址视为不可达
+type authFake map[string]*testserver.Server
+
+func (f authFake) Exchange(ctx context.Context, msg *dns.Msg, server string) (*dns.Msg, error) {
+	s, ok := f[server]
+	if !ok {
+		return nil, fmt.Errorf("dial udp %s: connect: connection refused", server)
+	}
+	client := &dns.Client{Net: "udp"}
+	reply, _, err := client.ExchangeContext(ctx, msg, s.UDPAddr)
+	return reply, err
+}
+
+const recursiveAddr = "192.0.2.53:53"
+
+// authZone 搭建 example.test 区域：递归服务器知道区域的SOA和四个NS，
+// ns1 有地址且权威应答，ns2 位于其他区域（无胶水记录）且应答不带AA位，ns3 无法解析，ns4 有地址但不可达
+func authZone(t *testing.T) (recursive, ns1, ns2 *testserver.Server, c *godns.Client) {
+	t.Helper()
+	recursive, ns1, ns2 = startServer(t), startServer(t), startServer(t)
+	soa := testserver.RR("example.test. 3600 IN SOA ns1.example.test. hostmaster.example.test. 2024010101 7200 900 1209600 300")
+	recursive.Handle("example.test", dns.TypeSOA, testserver.Reply{Answer: []dns.RR{soa}})
+	recursive.Handle("www.example.test", dns.TypeSOA, testserver.Reply{Authority: []dns.RR{soa}})
+	// 别名指向另一个区域：应答中的SOA属于目标区域，区域查找应继续向上到 example.test
+	recursive.Handle("alias.example.test", dns.TypeSOA, testserver.Reply{
+		Answer:    []dns.RR{testserver.RR("alias.example.test. 300 IN CNAME www.other.test.")},
+		Authority: []dns.RR{testserver.RR("other.test. 3600 IN SOA ns.other.test. hostmaster.other.test. 1 7200 900 1209600 300")},
+	})
+	recursive.Answer("example.test", dns.TypeNS,
+		"example.test. 3600 IN NS ns1.example.test.",
+		"example.test. 3600 IN NS ns2.example.net.",
+		"example.test. 3600 IN NS ns3.example.org.",
+		"example.test. 3600 IN NS ns4.example.test.",
+	)
+	recursive.Answer("ns1.example.test", dns.TypeA, "ns1.example.test. 3600 IN A 192.0.2.1")
+	recursive.Answer("ns2.example.net", dns.TypeA, "ns2.example.net. 3600 IN A 192.0.2.2")
+	recursive.Answer("ns4.example.test", dns.TypeA, "ns4.example.test. 3600 IN A 192.0.2.4")
+	recursive.Answer("www.example.test", dns.TypeA, "www.example.test. 60 IN A 198.51.100.99")
+
+	for _, name := range []string{"www.example.test", "alias.example.test"} {
+		ns1.Answer(name, dns.TypeA, name+". 300 IN A 198.51.100.1")
+		ns2.Handle(name, dns.TypeA, testserver.Reply{
+			Answer:           []dns.RR{testserver.RR(name + ". 300 IN A 198.51.100.2")},
+			NotAuthoritative: true,
+		})
+	}
+
+	c = godns.New(
+		godns.WithServers(recursiveAddr),
+		godns.WithTransport(authFake{recursiveAddr: recursive, "192.0.2.1:53": ns1, "192.0.2.2:53": ns2}),
+		godns.WithRetries(0),
+	)
+	t.Cleanup(func() { c.Close() })
+	return recursive, ns1, ns2, c
+}
+
+// TestQueryAuthoritative 按NS逐个直接询问权威服务器（RD=0）：无胶水的NS通过递归服务器解析，
+// 无法解析和不可达的NS以带错误的结果返回，每个结果记录NS主机名和AA位
+func TestQueryAuthoritative(t *testing.T) {
+	for _, name := range []string{"www.example.test", "alias.example.test"} {
+		t.Run(name, func(t *testing.T) {
+			_, ns1, ns2, c := authZone(t)
+
+			res, err := c.QueryAuthoritative(context.Background(), name, dns.TypeA)
+			if err != nil {
+				t.Fatal(err)
+			}
+			type want struct {
+				server, ip, err string
+				aa              bool
+			}
+			wants := map[string]want{
+				"ns1.example.test": {server: "192.0.2.1:53", ip: "198.51.100.1", aa: true},
+				"ns2.example.net":  {server: "192.0.2.2:53", ip: "198.51.100.2"},
+				"ns3.example.org":  {err: "no address found for nameserver ns3.example.org"},
+				"ns4.example.test": {server: "192.0.2.4:53", err: "connection refused"},
+			}
+			if len(res.Results) != len(wants) {
+				t.Fatalf("got %d results, want %d: %+v", len(res.Results), len(wants), res.Results)
+			}
+			for _, r := range res.Results {
+				w, ok := wants[r.Nameserver]
+				if !ok {
+					t.Errorf("unexpected nameserver %q", r.Nameserver)
+					continue
+				}
+				if w.err != "" {
+					if r.Error == nil || !strings.Contains(r.Error.Error(), w.err) {
+						t.Errorf("%s: err = %v, want %q", r.Nameserver, r.Error, w.err)
+					}
+					continue
+				}
+				if r.Error != nil {
+					t.Errorf("%s: %v", r.Nameserver, r.Error)
+					continue
+				}
+				if r.Server != w.server || r.Authoritative != w.aa || len(r.Records) != 1 || r.Records[0].Value() != w.ip {
+					t.Errorf("%s: server %s, AA %v, records %v; want %s, AA %v, %s", r.Nameserver, r.Server, r.Authoritative, r.Records, w.server, w.aa, w.ip)
+				}
+			}
+
+			// 权威服务器收到的查询不设置RD位，且是原始名称而不是别名目标
+			for _, s := range []*testserver.Server{ns1, ns2} {
+				queries := s.Queries()
+				if len(queries) != 1 {
+					t.Fatalf("authoritative server saw %d queries, want 1", len(queries))
+				}
+				q := queries[0].Msg
+				if q.RecursionDesired || q.Question[0].Name != name+"." {
+					t.Errorf("authoritative query RD = %v, name = %s", q.RecursionDesired, q.Question[0].Name)
+				}
+			}
+		})
+	}
+}
+
+// TestQueryAuthoritativeAllFail 所有权威服务器都失败时返回错误，结果中仍包含每个NS的失败原因
+func TestQueryAuthoritativeAllFail(t *testing.T) {
+	recursive := startServer(t)
+	recursive.Answer("example.test", dns.TypeSOA, "example.test. 3600 IN SOA ns1.example.test. hostmaster.example.test. 1 7200 900 1209600 300")
+	recursive.Answer("example.test", dns.TypeNS, "example.test. 3600 IN NS ns1.example.test.", "example.test. 3600 IN NS ns2.example.test.")
+	recursive.Answer("ns1.example.test", dns.TypeA, "ns1.example.test. 3600 IN A 192.0.2.1")
+	c := godns.New(
+		godns.WithServers(recursiveAddr),
+		godns.WithTransport(authFake{recursiveAddr: recursive}),
+		godns.WithRetries(0),
+	)
+	defer c.Close()
+
+	res, err := c.QueryAuthoritative(context.Background(), "example.test", dns.TypeA)
+	if err == nil || !strings.Contains(err.Error(), "no authoritative server for example.test answered") {
+		t.Fatalf("err = %v, want no authoritative server answered", err)
+	}
+	if res == nil || len(res.Results) != 2 {
+		t.Fatalf("results = %+v, want one failure per nameserver", res)
+	}
+	for _, r := range res.Results {
+		if r.Error == nil {
+			t.Errorf("%s: no error", r.Nameserver)
+		}
+	}
+}